@@ -3,6 +3,7 @@ package beat
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/elastic/beats/libbeat/beat"
 	"github.com/elastic/beats/libbeat/cfgfile"
@@ -22,6 +23,8 @@ type Filebeat struct {
 	publisherChan chan []*FileEvent
 	Spooler       *Spooler
 	registrar     *Registrar
+	crawler       *Crawler
+	watcher       *cfgfile.Watcher
 }
 
 func New() *Filebeat {
@@ -39,9 +42,7 @@ func (fb *Filebeat) Config(b *beat.Beat) error {
 	}
 
 	// Check if optional config_dir is set to fetch additional prospector config files
-	fb.FbConfig.FetchConfigs()
-
-	return nil
+	return fb.FbConfig.FetchConfigs()
 }
 
 func (fb *Filebeat) Setup(b *beat.Beat) error {
@@ -72,7 +73,7 @@ func (fb *Filebeat) Run(b *beat.Beat) error {
 		return err
 	}
 
-	crawl := &Crawler{
+	fb.crawler = &Crawler{
 		Registrar: fb.registrar,
 	}
 
@@ -91,7 +92,12 @@ func (fb *Filebeat) Run(b *beat.Beat) error {
 	// Start up spooler
 	go fb.Spooler.Run()
 
-	crawl.Start(fb.FbConfig.Filebeat.Prospectors, fb.Spooler.Channel)
+	fb.crawler.Start(fb.FbConfig.Filebeat.Prospectors, fb.Spooler.Channel)
+
+	// Reload configuration on changes of the config files if enabled
+	if fb.FbConfig.Filebeat.ReloadFrequency != "" {
+		fb.startWatcher(b)
+	}
 
 	// Publishes event to output
 	go Publish(b, fb)
@@ -106,20 +112,87 @@ func (fb *Filebeat) Cleanup(b *beat.Beat) error {
 	return nil
 }
 
+// Reload reads the filebeat configuration again, including the files in
+// config_dir, and starts and stops prospectors according to the changes.
+// In case of errors all prospectors keep running unchanged.
+func (fb *Filebeat) Reload(b *beat.Beat) error {
+	if fb.crawler == nil {
+		return fmt.Errorf("Filebeat not running")
+	}
+
+	var config cfg.Config
+	if err := cfgfile.Read(&config, ""); err != nil {
+		return err
+	}
+	if err := config.FetchConfigs(); err != nil {
+		return err
+	}
+
+	if err := fb.crawler.Reload(config.Filebeat.Prospectors); err != nil {
+		return err
+	}
+
+	if fb.watcher != nil {
+		// includes or config_dir might have changed
+		fb.watcher.SetPaths(watchedPaths(&config))
+	}
+
+	// settings other than the prospectors are only read on startup
+	current, reloaded := fb.FbConfig.Filebeat, config.Filebeat
+	if current.SpoolSize != reloaded.SpoolSize ||
+		current.IdleTimeout != reloaded.IdleTimeout ||
+		current.RegistryFile != reloaded.RegistryFile ||
+		current.ReloadFrequency != reloaded.ReloadFrequency {
+		logp.Warn("Changes of the filebeat settings other than prospectors and config_dir require a restart")
+	}
+	config.Filebeat = current
+	config.Filebeat.Prospectors = reloaded.Prospectors
+	config.Filebeat.ConfigDir = reloaded.ConfigDir
+	fb.FbConfig = &config
+	return nil
+}
+
+// startWatcher reloads the configuration each time a change of the config
+// file, its included files or the files in config_dir is detected.
+func (fb *Filebeat) startWatcher(b *beat.Beat) {
+	period, err := time.ParseDuration(fb.FbConfig.Filebeat.ReloadFrequency)
+	if err != nil {
+		logp.Err("Configuration reload disabled, invalid reload_frequency: %v", err)
+		return
+	}
+	logp.Info("Configuration files are checked for changes every %v", period)
+
+	fb.watcher = cfgfile.NewWatcher(watchedPaths(fb.FbConfig), period)
+	go fb.watcher.Run(b.Reload)
+}
+
+// watchedPaths returns the configuration files watched for changes.
+func watchedPaths(config *cfg.Config) []string {
+	paths := cfgfile.LoadedFiles()
+	if config.Filebeat.ConfigDir != "" {
+		paths = append(paths, config.Filebeat.ConfigDir)
+	}
+	return paths
+}
+
 // Stop is called on exit for cleanup
 func (fb *Filebeat) Stop() {
 
-	// Stop harvesters
-	// Stop prospectors
-
-	// Stopping spooler will flush items
-	fb.Spooler.Stop()
+	if fb.watcher != nil {
+		fb.watcher.Stop()
+	}
 
-	// Stopping registrar will write last state
-	fb.registrar.Stop()
+	// The crawler and spooler are not set up yet if stopped before Run
+	if fb.crawler != nil {
+		// Stop prospectors and harvesters
+		fb.crawler.Stop()
+	}
 
-	// Close channels
-	//close(fb.publisherChan)
+	if fb.Spooler != nil {
+		// Stopping spooler will flush items. The registrar is stopped and
+		// writes the last state once all flushed items have been published.
+		fb.Spooler.Stop()
+	}
 }
 
 func Publish(beat *beat.Beat, fb *Filebeat) {
@@ -128,16 +201,35 @@ func Publish(beat *beat.Beat, fb *Filebeat) {
 	// Receives events from spool during flush
 	for events := range fb.publisherChan {
 
-		pubEvents := make([]common.MapStr, 0, len(events))
-		for _, event := range events {
-			pubEvents = append(pubEvents, event.ToMapStr())
+		pubEvents := toPublisherEvents(events, publisher.Publisher.DocumentID())
+		if !beat.Events.PublishEvents(pubEvents, publisher.Sync) {
+			// The publisher has been stopped on shutdown before the events
+			// were acknowledged. They are sent again on restart.
+			logp.Warn("Shutdown timeout elapsed, %d events abandoned", len(events))
+			continue
 		}
 
-		beat.Events.PublishEvents(pubEvents, publisher.Sync)
-
 		logp.Info("Events sent: %d", len(events))
 
 		// Tell the registrar that we've successfully sent these events
 		fb.registrar.Channel <- events
 	}
+
+	// Stopping registrar will write last state
+	fb.registrar.Stop()
+}
+
+// toPublisherEvents converts the events read to the events published. If
+// document IDs are enabled, the ID is derived from the source, the file
+// identity and the offset, so lines sent again after a restart keep their ID.
+func toPublisherEvents(events []*FileEvent, documentID bool) []common.MapStr {
+	pubEvents := make([]common.MapStr, 0, len(events))
+	for _, event := range events {
+		pubEvent := event.ToMapStr()
+		if documentID {
+			pubEvent[common.EventIDField] = event.ID()
+		}
+		pubEvents = append(pubEvents, pubEvent)
+	}
+	return pubEvents
 }
@@ -1 +1,96 @@
 package beat
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/elastic/beats/filebeat/crawler"
+	"github.com/elastic/beats/filebeat/input"
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToPublisherEvents(t *testing.T) {
+	source, text := "/var/log/test.log", "hello"
+	events := []*input.FileEvent{
+		{ReadTime: time.Now(), Source: &source, Text: &text, Offset: 0},
+		{ReadTime: time.Now(), Source: &source, Text: &text, Offset: 6},
+	}
+
+	pubEvents := toPublisherEvents(events, false)
+	if assert.Len(t, pubEvents, 2) {
+		assert.Equal(t, "", common.EventIDOf(pubEvents[0]))
+		assert.Equal(t, int64(6), pubEvents[1]["offset"])
+	}
+
+	pubEvents = toPublisherEvents(events, true)
+	if assert.Len(t, pubEvents, 2) {
+		assert.Equal(t, events[0].ID(), common.EventIDOf(pubEvents[0]))
+		assert.Equal(t, events[1].ID(), common.EventIDOf(pubEvents[1]))
+		assert.NotEqual(t, common.EventIDOf(pubEvents[0]), common.EventIDOf(pubEvents[1]))
+	}
+}
+
+func TestStopBeforeRun(t *testing.T) {
+	fb := &Filebeat{}
+	assert.NotPanics(t, fb.Stop)
+}
+
+func TestReloadInvalidProspector(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filebeat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "filebeat.yml")
+	writeConfig := func(content string) {
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	configFlag := flag.Lookup("c")
+	oldPath := configFlag.Value.String()
+	defer configFlag.Value.Set(oldPath)
+	configFlag.Value.Set(path)
+
+	writeConfig(`
+filebeat:
+  prospectors:
+    - paths: ["` + dir + `/a*.log"]
+`)
+	fb := New()
+	if err := fb.Config(nil); !assert.NoError(t, err) {
+		return
+	}
+
+	fb.crawler = &crawler.Crawler{
+		Registrar: &crawler.Registrar{
+			State:   map[string]*input.FileState{},
+			Persist: make(chan *input.FileState, 10),
+		},
+	}
+	fb.crawler.Start(fb.FbConfig.Filebeat.Prospectors, make(chan *input.FileEvent))
+	defer fb.crawler.Stop()
+
+	writeConfig(`
+filebeat:
+  prospectors:
+    - paths: ["` + dir + `/a*.log"]
+    - paths: ["` + dir + `/b*.log"]
+      input_type: lgo
+`)
+	assert.Error(t, fb.Reload(nil))
+
+	// the running prospectors are unchanged
+	prospectors := fb.crawler.Prospectors()
+	if assert.Len(t, prospectors, 1) {
+		assert.Equal(t, []string{dir + "/a*.log"}, prospectors[0].Paths)
+	}
+	assert.Len(t, fb.FbConfig.Filebeat.Prospectors, 1)
+}
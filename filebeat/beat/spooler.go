@@ -10,7 +10,7 @@ import (
 
 type Spooler struct {
 	Filebeat      *Filebeat
-	running       bool
+	done          chan struct{}
 	nextFlushTime time.Time
 	spool         []*input.FileEvent
 	Channel       chan *input.FileEvent
@@ -19,7 +19,7 @@ type Spooler struct {
 func NewSpooler(filebeat *Filebeat) *Spooler {
 	spooler := &Spooler{
 		Filebeat: filebeat,
-		running:  false,
+		done:     make(chan struct{}),
 	}
 
 	config := &spooler.Filebeat.FbConfig.Filebeat
@@ -66,31 +66,22 @@ func (s *Spooler) Run() {
 
 	config := &s.Filebeat.FbConfig.Filebeat
 
-	// Enable running
-	s.running = true
-
 	// Sets up ticket channel
 	ticker := time.NewTicker(config.IdleTimeoutDuration / 2)
+	defer ticker.Stop()
 
 	s.spool = make([]*input.FileEvent, 0, config.SpoolSize)
 
 	logp.Info("Starting spooler: spool_size: %v; idle_timeout: %s", config.SpoolSize, config.IdleTimeoutDuration)
 
-	// Loops until running is set to false
+	// Loops until Stop is called
+loop:
 	for {
-		if !s.running {
-			break
-		}
-
 		select {
+		case <-s.done:
+			break loop
 		case event := <-s.Channel:
-			s.spool = append(s.spool, event)
-
-			// Spooler is full -> flush
-			if len(s.spool) == cap(s.spool) {
-				logp.Debug("spooler", "Flushing spooler because spooler full. Events flushed: %v", len(s.spool))
-				s.flush()
-			}
+			s.queue(event)
 		case <-ticker.C:
 			// Flush periodically
 			if time.Now().After(s.nextFlushTime) {
@@ -102,13 +93,37 @@ func (s *Spooler) Run() {
 
 	logp.Info("Stopping spooler")
 
-	// Flush again before exiting spooler and closes channel
+	// Spool the events sent before the harvesters were stopped
+	for drained := false; !drained; {
+		select {
+		case event := <-s.Channel:
+			s.queue(event)
+		default:
+			drained = true
+		}
+	}
+
+	// Flush again before exiting spooler. Closing the publisher channel
+	// signals that no more events will be published.
 	s.flush()
-	close(s.Channel)
+	close(s.Filebeat.publisherChan)
 }
 
-// Stop stops the spooler. Flushes events before stopping
+// Stop stops the spooler. The spooled events are flushed before the spooler
+// exits. Stop does not wait for the spooler to exit.
 func (s *Spooler) Stop() {
+	close(s.done)
+}
+
+// queue adds the event to the spool and flushes the spool if it is full.
+func (s *Spooler) queue(event *input.FileEvent) {
+	s.spool = append(s.spool, event)
+
+	// Spooler is full -> flush
+	if len(s.spool) == cap(s.spool) {
+		logp.Debug("spooler", "Flushing spooler because spooler full. Events flushed: %v", len(s.spool))
+		s.flush()
+	}
 }
 
 // flush flushes all event and sends them to the publisher
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	cfg "github.com/elastic/beats/filebeat/config"
+	"github.com/elastic/beats/filebeat/input"
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/yaml.v2"
 )
@@ -53,3 +54,26 @@ func TestNewSpoolerIdleTimeout(t *testing.T) {
 
 	assert.Equal(t, idleTimoeout, fb.FbConfig.Filebeat.IdleTimeout)
 }
+
+func TestSpoolerStopFlushes(t *testing.T) {
+	config := cfg.FilebeatConfig{IdleTimeout: "1h"}
+	fb := &Filebeat{
+		FbConfig:      &cfg.Config{Filebeat: config},
+		publisherChan: make(chan []*input.FileEvent, 10),
+	}
+
+	spooler := NewSpooler(fb)
+	assert.NoError(t, spooler.Config())
+
+	spooler.Channel <- &input.FileEvent{}
+	spooler.Channel <- &input.FileEvent{}
+	go spooler.Run()
+	spooler.Stop()
+
+	// all events are flushed before the publisher channel is closed
+	count := 0
+	for events := range fb.publisherChan {
+		count += len(events)
+	}
+	assert.Equal(t, 2, count)
+}
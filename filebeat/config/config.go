@@ -1,13 +1,12 @@
 package config
 
 import (
-	"log"
-	"os"
-	"path/filepath"
+	"fmt"
 	"time"
 
 	"github.com/elastic/beats/libbeat/cfgfile"
 	"github.com/elastic/beats/libbeat/logp"
+	"github.com/joeshaw/multierror"
 )
 
 // Defaults for config variables which are not set
@@ -38,6 +37,7 @@ type FilebeatConfig struct {
 	IdleTimeoutDuration time.Duration
 	RegistryFile        string `yaml:"registry_file"`
 	ConfigDir           string `yaml:"config_dir"`
+	ReloadFrequency     string `yaml:"reload_frequency"`
 }
 
 type ProspectorConfig struct {
@@ -66,6 +66,69 @@ type HarvesterConfig struct {
 	ForceCloseFiles    bool `yaml:"force_close_files"`
 }
 
+// Validate checks the global filebeat settings.
+func (c FilebeatConfig) Validate() error {
+	var errs multierror.Errors
+	if err := validateDuration("idle_timeout", c.IdleTimeout); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateDuration("reload_frequency", c.ReloadFrequency); err != nil {
+		errs = append(errs, err)
+	} else if d, _ := time.ParseDuration(c.ReloadFrequency); c.ReloadFrequency != "" && d <= 0 {
+		errs = append(errs, fmt.Errorf("reload_frequency must be positive but was '%s'",
+			c.ReloadFrequency))
+	}
+	return errs.Err()
+}
+
+// Validate checks the prospector settings and returns an error describing all
+// problems or nil if there are none.
+func (c ProspectorConfig) Validate() error {
+	var errs multierror.Errors
+	if err := validateDuration("ignore_older", c.IgnoreOlder); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateDuration("scan_frequency", c.ScanFrequency); err != nil {
+		errs = append(errs, err)
+	}
+	return errs.Err()
+}
+
+// Validate checks the harvester settings and returns an error describing all
+// problems or nil if there are none.
+func (c HarvesterConfig) Validate() error {
+	var errs multierror.Errors
+	if _, ok := ValidInputType[c.InputType]; c.InputType != "" && !ok {
+		errs = append(errs, fmt.Errorf("Invalid input_type value '%s'", c.InputType))
+	}
+	if c.BufferSize < 0 {
+		errs = append(errs, fmt.Errorf("harvester_buffer_size must not be negative but was '%d'",
+			c.BufferSize))
+	}
+	if err := validateDuration("backoff", c.Backoff); err != nil {
+		errs = append(errs, err)
+	}
+	if c.BackoffFactor < 0 {
+		errs = append(errs, fmt.Errorf("backoff_factor must not be negative but was '%d'",
+			c.BackoffFactor))
+	}
+	if err := validateDuration("max_backoff", c.MaxBackoff); err != nil {
+		errs = append(errs, err)
+	}
+	return errs.Err()
+}
+
+// validateDuration checks the optional duration setting can be parsed.
+func validateDuration(name, value string) error {
+	if value == "" {
+		return nil
+	}
+	if _, err := time.ParseDuration(value); err != nil {
+		return fmt.Errorf("Invalid %s value '%s' (%v)", name, value, err)
+	}
+	return nil
+}
+
 const (
 	LogInputType   = "log"
 	StdinInputType = "stdin"
@@ -80,32 +143,8 @@ var ValidInputType = map[string]struct{}{
 // getConfigFiles returns list of config files.
 // In case path is a file, it will be directly returned.
 // In case it is a directory, it will fetch all .yml files inside this directory
-func getConfigFiles(path string) (configFiles []string, err error) {
-
-	// Check if path is valid file or dir
-	stat, err := os.Stat(path)
-	if err != nil {
-		return nil, err
-	}
-
-	// Create empty slice for config file list
-	configFiles = make([]string, 0)
-
-	if stat.IsDir() {
-		files, err := filepath.Glob(path + "/*.yml")
-
-		if err != nil {
-			return nil, err
-		}
-
-		configFiles = append(configFiles, files...)
-
-	} else {
-		// Only 1 config file
-		configFiles = append(configFiles, path)
-	}
-
-	return configFiles, nil
+func getConfigFiles(path string) ([]string, error) {
+	return cfgfile.ConfigFiles(path)
 }
 
 // mergeConfigFiles reads in all config files given by list configFiles and merges them into config
@@ -115,7 +154,9 @@ func mergeConfigFiles(configFiles []string, config *Config) error {
 		logp.Info("Additional configs loaded from: %s", file)
 
 		tmpConfig := &Config{}
-		cfgfile.Read(tmpConfig, file)
+		if err := cfgfile.Read(tmpConfig, file); err != nil {
+			return err
+		}
 
 		config.Filebeat.Prospectors = append(config.Filebeat.Prospectors, tmpConfig.Filebeat.Prospectors...)
 	}
@@ -124,13 +165,13 @@ func mergeConfigFiles(configFiles []string, config *Config) error {
 }
 
 // Fetches and merges all config files given by configDir. All are put into one config object
-func (config *Config) FetchConfigs() {
+func (config *Config) FetchConfigs() error {
 
 	configDir := config.Filebeat.ConfigDir
 
 	// If option not set, do nothing
 	if configDir == "" {
-		return
+		return nil
 	}
 
 	// Check if optional configDir is set to fetch additional config files
@@ -139,17 +180,18 @@ func (config *Config) FetchConfigs() {
 	configFiles, err := getConfigFiles(configDir)
 
 	if err != nil {
-		log.Fatal("Could not use config_dir of: ", configDir, err)
+		return fmt.Errorf("Could not use config_dir of: %s: %v", configDir, err)
 	}
 
 	err = mergeConfigFiles(configFiles, config)
 
 	if err != nil {
-		log.Fatal("Error merging config files: ", err)
+		return fmt.Errorf("Error merging config files: %v", err)
 	}
 
 	if len(config.Filebeat.Prospectors) == 0 {
-		log.Fatalf("No paths given. What files do you want me to watch?")
+		return fmt.Errorf("No paths given. What files do you want me to watch?")
 	}
 
+	return nil
 }
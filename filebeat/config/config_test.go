@@ -128,3 +128,47 @@ func TestMergeConfigFiles(t *testing.T) {
 
 	assert.Equal(t, 4, len(config.Filebeat.Prospectors))
 }
+
+func TestProspectorConfigValidate(t *testing.T) {
+	valid := ProspectorConfig{
+		IgnoreOlder:   "24h",
+		ScanFrequency: "10s",
+		Harvester: HarvesterConfig{
+			InputType:  "stdin",
+			Backoff:    "1s",
+			MaxBackoff: "10s",
+		},
+	}
+	assert.NoError(t, valid.Validate())
+	assert.NoError(t, valid.Harvester.Validate())
+
+	assert.Error(t, ProspectorConfig{IgnoreOlder: "24"}.Validate())
+	assert.Error(t, ProspectorConfig{ScanFrequency: "often"}.Validate())
+	assert.Error(t, HarvesterConfig{InputType: "file"}.Validate())
+	assert.Error(t, HarvesterConfig{BufferSize: -1}.Validate())
+	assert.Error(t, HarvesterConfig{MaxBackoff: "1x"}.Validate())
+}
+
+func TestFilebeatConfigValidate(t *testing.T) {
+	assert.NoError(t, FilebeatConfig{IdleTimeout: "5s", ReloadFrequency: "10s"}.Validate())
+	assert.NoError(t, FilebeatConfig{}.Validate())
+
+	assert.Error(t, FilebeatConfig{IdleTimeout: "5"}.Validate())
+	assert.Error(t, FilebeatConfig{ReloadFrequency: "often"}.Validate())
+	assert.Error(t, FilebeatConfig{ReloadFrequency: "-1s"}.Validate())
+	assert.Error(t, FilebeatConfig{ReloadFrequency: "0s"}.Validate())
+}
+
+func TestReadConfigInvalid(t *testing.T) {
+	absPath, err := filepath.Abs("../tests/files/invalid")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &Config{}
+	err = cfgfile.Read(config, absPath+"/config.yml")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "config.yml:5: unknown setting 'filebeat.prospectors.0.scan_frequncy'")
+		assert.Contains(t, err.Error(), "config.yml:3: filebeat.prospectors.0: Invalid input_type value 'file'")
+	}
+}
@@ -1,8 +1,11 @@
 package crawler
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
+	"sync"
 
 	"github.com/elastic/beats/filebeat/config"
 	"github.com/elastic/beats/filebeat/input"
@@ -25,15 +28,25 @@ type Crawler struct {
 	// Registrar object to persist the state
 	Registrar *Registrar
 	running   bool
+
+	// running prospectors by their configuration
+	prospectors map[string]*Prospector
+	eventChan   chan *input.FileEvent
+	mutex       sync.Mutex
 }
 
 func (crawler *Crawler) Start(files []config.ProspectorConfig, eventChan chan *input.FileEvent) {
 
+	crawler.mutex.Lock()
+	defer crawler.mutex.Unlock()
+
 	pendingProspectorCnt := 0
 	crawler.running = true
+	crawler.eventChan = eventChan
+	crawler.prospectors = map[string]*Prospector{}
 
 	// Prospect the globs/paths given on the command line and launch harvesters
-	for _, fileconfig := range files {
+	for key, fileconfig := range prospectorConfigs(files) {
 
 		logp.Debug("prospector", "File Configs: %v", fileconfig.Paths)
 
@@ -50,6 +63,7 @@ func (crawler *Crawler) Start(files []config.ProspectorConfig, eventChan chan *i
 		}
 
 		go prospector.Run(eventChan)
+		crawler.prospectors[key] = prospector
 		pendingProspectorCnt++
 	}
 
@@ -78,6 +92,111 @@ func (crawler *Crawler) Start(files []config.ProspectorConfig, eventChan chan *i
 	logp.Info("All prospectors initialised with %d states to persist", len(crawler.Registrar.State))
 }
 
+// Reload applies a changed list of prospector configurations. Prospectors
+// whose configuration was removed are stopped, prospectors for new
+// configurations are started and unchanged prospectors keep running. If any
+// new configuration is invalid, no prospector is started or stopped.
+func (crawler *Crawler) Reload(files []config.ProspectorConfig) error {
+
+	crawler.mutex.Lock()
+	defer crawler.mutex.Unlock()
+
+	if crawler.eventChan == nil {
+		return fmt.Errorf("Crawler not started")
+	}
+
+	configs := prospectorConfigs(files)
+
+	// Init all new prospectors first, so an invalid configuration does not
+	// affect the running prospectors
+	added := map[string]*Prospector{}
+	for key, fileconfig := range configs {
+		if _, exists := crawler.prospectors[key]; exists {
+			continue
+		}
+
+		prospector := &Prospector{
+			ProspectorConfig: fileconfig,
+			registrar:        crawler.Registrar,
+		}
+		if err := prospector.Init(); err != nil {
+			return fmt.Errorf("Error in initing prospector: %v", err)
+		}
+		added[key] = prospector
+	}
+
+	stopped := 0
+	for key, prospector := range crawler.prospectors {
+		if _, exists := configs[key]; exists {
+			continue
+		}
+
+		logp.Info("Stopping prospector: %v", prospector.ProspectorConfig.Paths)
+		prospector.Stop()
+		delete(crawler.prospectors, key)
+		stopped++
+	}
+
+	for key, prospector := range added {
+		logp.Info("Starting prospector: %v", prospector.ProspectorConfig.Paths)
+		go prospector.Run(crawler.eventChan)
+		crawler.prospectors[key] = prospector
+	}
+
+	logp.Info("Prospectors reloaded: %d started, %d stopped, %d running",
+		len(added), stopped, len(crawler.prospectors))
+	return nil
+}
+
 func (crawler *Crawler) Stop() {
-	// TODO: Properly stop prospectors and harvesters
+	crawler.mutex.Lock()
+	defer crawler.mutex.Unlock()
+
+	crawler.running = false
+	for key, prospector := range crawler.prospectors {
+		prospector.Stop()
+		delete(crawler.prospectors, key)
+	}
+}
+
+// Prospectors returns the configurations of the running prospectors.
+func (crawler *Crawler) Prospectors() []config.ProspectorConfig {
+	crawler.mutex.Lock()
+	defer crawler.mutex.Unlock()
+
+	keys := make([]string, 0, len(crawler.prospectors))
+	for key := range crawler.prospectors {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	configs := make([]config.ProspectorConfig, 0, len(keys))
+	for _, key := range keys {
+		configs = append(configs, crawler.prospectors[key].ProspectorConfig)
+	}
+	return configs
+}
+
+// prospectorConfigs indexes the prospector configurations by their settings.
+// Identical configurations are numbered, such that each of them runs its own
+// prospector.
+func prospectorConfigs(files []config.ProspectorConfig) map[string]config.ProspectorConfig {
+	configs := map[string]config.ProspectorConfig{}
+	for _, fileconfig := range files {
+		// encoding/json sorts map keys, so equal configurations give equal keys
+		settings, err := json.Marshal(fileconfig)
+		if err != nil {
+			// can not happen for the string based settings
+			logp.Err("Failed to encode prospector config: %v", err)
+		}
+
+		for i := 0; ; i++ {
+			key := fmt.Sprintf("%s#%d", settings, i)
+			if _, exists := configs[key]; !exists {
+				configs[key] = fileconfig
+				break
+			}
+		}
+	}
+	return configs
 }
@@ -0,0 +1,151 @@
+package crawler
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/elastic/beats/filebeat/config"
+	"github.com/elastic/beats/filebeat/input"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestCrawler() *Crawler {
+	return &Crawler{
+		Registrar: &Registrar{
+			State:   map[string]*input.FileState{},
+			Persist: make(chan *input.FileState, 10),
+		},
+	}
+}
+
+func testProspectorConfig(dir, pattern string) config.ProspectorConfig {
+	return config.ProspectorConfig{
+		Paths:         []string{filepath.Join(dir, pattern)},
+		ScanFrequency: "1s",
+		Harvester: config.HarvesterConfig{
+			InputType: config.LogInputType,
+		},
+	}
+}
+
+func isStopped(p *Prospector) bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestProspectorConfigsKeys(t *testing.T) {
+	a := testProspectorConfig("/tmp", "*.log")
+	b := testProspectorConfig("/tmp", "*.log")
+	b.Harvester.Fields = map[string]string{"env": "prod"}
+
+	configs := prospectorConfigs([]config.ProspectorConfig{a, b, a})
+	assert.Len(t, configs, 3)
+
+	// keys only depend on the settings
+	again := prospectorConfigs([]config.ProspectorConfig{b, a})
+	for key := range again {
+		assert.Contains(t, configs, key)
+	}
+}
+
+func TestCrawlerReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crawler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a := testProspectorConfig(dir, "a*.log")
+	b := testProspectorConfig(dir, "b*.log")
+	c := testProspectorConfig(dir, "c*.log")
+
+	crawler := newTestCrawler()
+	crawler.Start([]config.ProspectorConfig{a, b}, make(chan *input.FileEvent))
+	defer crawler.Stop()
+
+	before := map[string]*Prospector{}
+	for key, p := range crawler.prospectors {
+		before[key] = p
+	}
+	assert.Len(t, before, 2)
+
+	err = crawler.Reload([]config.ProspectorConfig{b, c})
+	assert.NoError(t, err)
+	assert.Len(t, crawler.prospectors, 2)
+
+	stopped := 0
+	for key, p := range before {
+		if current, running := crawler.prospectors[key]; running {
+			// unchanged prospector keeps running
+			assert.True(t, current == p)
+			assert.False(t, isStopped(p))
+			assert.Equal(t, b.Paths, p.ProspectorConfig.Paths)
+		} else {
+			assert.True(t, isStopped(p))
+			assert.Equal(t, a.Paths, p.ProspectorConfig.Paths)
+			stopped++
+		}
+	}
+	assert.Equal(t, 1, stopped)
+}
+
+func TestCrawlerReloadInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crawler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a := testProspectorConfig(dir, "a*.log")
+	invalid := testProspectorConfig(dir, "b*.log")
+	invalid.ScanFrequency = "often"
+
+	crawler := newTestCrawler()
+	crawler.Start([]config.ProspectorConfig{a}, make(chan *input.FileEvent))
+	defer crawler.Stop()
+
+	err = crawler.Reload([]config.ProspectorConfig{invalid})
+	assert.Error(t, err)
+
+	// the last good configuration keeps running
+	if assert.Len(t, crawler.prospectors, 1) {
+		for _, p := range crawler.prospectors {
+			assert.False(t, isStopped(p))
+			assert.Equal(t, a.Paths, p.ProspectorConfig.Paths)
+		}
+	}
+}
+
+func TestCrawlerReloadNotStarted(t *testing.T) {
+	crawler := newTestCrawler()
+	assert.Error(t, crawler.Reload(nil))
+}
+
+func TestProspectorStop(t *testing.T) {
+	crawler := newTestCrawler()
+	p := &Prospector{
+		ProspectorConfig: testProspectorConfig("/nonexistent", "*.log"),
+		registrar:        crawler.Registrar,
+	}
+	assert.NoError(t, p.Init())
+
+	done := make(chan struct{})
+	go func() {
+		p.Run(make(chan *input.FileEvent))
+		close(done)
+	}()
+
+	p.Stop()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("prospector not stopped")
+	}
+}
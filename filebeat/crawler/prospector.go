@@ -19,12 +19,14 @@ type Prospector struct {
 	lastscan         time.Time
 	registrar        *Registrar
 	missingFiles     map[string]os.FileInfo
-	running          bool
+	done             chan struct{} // closed to stop the prospector and its harvesters
 }
 
 // Init sets up default config for prospector
 func (p *Prospector) Init() error {
 
+	p.done = make(chan struct{})
+
 	err := p.setupProspectorConfig()
 	if err != nil {
 		return err
@@ -128,8 +130,6 @@ func getConfigDuration(config string, duration time.Duration, name string) (time
 // Starts scanning through all the file paths and fetch the related files. Start a harvester for each file
 func (p *Prospector) Run(spoolChan chan *input.FileEvent) {
 
-	p.running = true
-
 	logp.Info("Starting prospector of type: %v", p.ProspectorConfig.Harvester.InputType)
 	switch p.ProspectorConfig.Harvester.InputType {
 	case cfg.StdinInputType:
@@ -169,7 +169,12 @@ func (p *Prospector) logRun(spoolChan chan *input.FileEvent) {
 		p.lastscan = newlastscan
 
 		// Defer next scan for the defined scanFrequency
-		time.Sleep(p.ProspectorConfig.ScanFrequencyDuration)
+		select {
+		case <-time.After(p.ProspectorConfig.ScanFrequencyDuration):
+		case <-p.done:
+			logp.Info("Prospector stopped: %v", p.ProspectorConfig.Paths)
+			return
+		}
 		logp.Debug("prospector", "Start next scan")
 
 		// Clear out files that disappeared and we've stopped harvesting
@@ -180,10 +185,6 @@ func (p *Prospector) logRun(spoolChan chan *input.FileEvent) {
 		}
 
 		p.iteration++ // Overflow is allowed
-
-		if !p.running {
-			break
-		}
 	}
 }
 
@@ -194,6 +195,7 @@ func (p *Prospector) stdinRun(spoolChan chan *input.FileEvent) {
 		"-",
 		nil,
 		spoolChan,
+		p.done,
 	)
 
 	if err != nil {
@@ -209,14 +211,8 @@ func (p *Prospector) stdinRun(spoolChan chan *input.FileEvent) {
 
 	h.Start()
 
-	for {
-		if !p.running {
-			break
-		}
-		// Wait time during endless loop
-		oneSecond, _ := time.ParseDuration("1s")
-		time.Sleep(oneSecond)
-	}
+	<-p.done
+	logp.Info("Prospector stopped: stdin")
 }
 
 // Scans the specific path which can be a glob (/**/**/*.log)
@@ -289,7 +285,7 @@ func (p *Prospector) checkNewFile(newinfo *harvester.FileStat, file string, outp
 
 	// Init harvester with info
 	h, err := harvester.NewHarvester(
-		p.ProspectorConfig, &p.ProspectorConfig.Harvester, file, newinfo, output)
+		p.ProspectorConfig, &p.ProspectorConfig.Harvester, file, newinfo, output, p.done)
 	if err != nil {
 		logp.Err("Error initializing harvester: %v", err)
 		return
@@ -356,7 +352,7 @@ func (p *Prospector) checkExistingFile(newinfo *harvester.FileStat, newFile *inp
 
 	h, err := harvester.NewHarvester(
 		p.ProspectorConfig, &p.ProspectorConfig.Harvester,
-		file, newinfo, output)
+		file, newinfo, output, p.done)
 	if err != nil {
 		logp.Err("Error initializing harvester: %v", err)
 		return
@@ -399,8 +395,12 @@ func (p *Prospector) checkExistingFile(newinfo *harvester.FileStat, newFile *inp
 	}
 }
 
+// Stop stops scanning for files and stops all harvesters started by the
+// prospector. The offsets of the events already published are kept in the
+// registrar, such that a prospector started later on the same files continues
+// from there.
 func (p *Prospector) Stop() {
-	// TODO: Stopping is currently not implemented
+	close(p.done)
 }
 
 // Check if the given file was renamed. If file is known but with different path,
@@ -19,7 +19,6 @@ type Registrar struct {
 	State map[string]*FileState
 	// Channel used by the prospector and crawler to send FileStates to be persisted
 	Persist chan *input.FileState
-	running bool
 
 	Channel chan []*FileEvent
 	done    chan struct{}
@@ -82,8 +81,6 @@ func (r *Registrar) LoadState() {
 func (r *Registrar) Run() {
 	logp.Info("Starting Registrar")
 
-	r.running = true
-
 	// Writes registry on shutdown
 	defer r.writeRegistry()
 
@@ -91,9 +88,14 @@ func (r *Registrar) Run() {
 		select {
 		case <-r.done:
 			logp.Info("Ending Registrar")
+			r.processPending()
 			return
 		// Treats new log files to persist with higher priority then new events
 		case state := <-r.Persist:
+			if state.Source == nil {
+				// prospector started on reload finished its first scan
+				continue
+			}
 			r.State[*state.Source] = state
 			logp.Debug("prospector", "Registrar will re-save state for %s", *state.Source)
 		case events := <-r.Channel:
@@ -112,10 +114,6 @@ func (r *Registrar) processEvents(events []*FileEvent) {
 
 	// Take the last event found for each file source
 	for _, event := range events {
-		if !r.running {
-			break
-		}
-
 		// skip stdin
 		if event.InputType == cfg.StdinInputType {
 			continue
@@ -125,9 +123,20 @@ func (r *Registrar) processEvents(events []*FileEvent) {
 	}
 }
 
+// processPending processes the events sent before the registrar was stopped.
+func (r *Registrar) processPending() {
+	for {
+		select {
+		case events := <-r.Channel:
+			r.processEvents(events)
+		default:
+			return
+		}
+	}
+}
+
 func (r *Registrar) Stop() {
 	logp.Info("Stopping Registrar")
-	r.running = false
 	close(r.done)
 	// Note: don't block using waitGroup, cause this method is run by async signal handler
 }
@@ -0,0 +1,60 @@
+package crawler
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/elastic/beats/filebeat/input"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistrarStopWritesPendingEvents(t *testing.T) {
+	dir, err := ioutil.TempDir("", "registrar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "test.log")
+	assert.NoError(t, ioutil.WriteFile(source, []byte("line\n"), 0644))
+	info, err := os.Stat(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registrar, err := NewRegistrar(filepath.Join(dir, "registry"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// events sent right before stopping are still written
+	registrar.Channel <- []*input.FileEvent{
+		{Source: &source, Offset: 0, Bytes: 5, Fileinfo: &info},
+	}
+	registrar.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		registrar.Run()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("registrar not stopped")
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "registry"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	states := map[string]*input.FileState{}
+	assert.NoError(t, json.Unmarshal(data, &states))
+	if assert.Contains(t, states, source) {
+		assert.Equal(t, int64(5), states[source].Offset)
+	}
+}
@@ -14,6 +14,7 @@
 package harvester
 
 import (
+	"expvar"
 	"io"
 	"os"
 	"time"
@@ -23,6 +24,13 @@ import (
 	"github.com/elastic/beats/filebeat/input"
 )
 
+// Metrics that can be retrieved through the expvar web interface.
+var (
+	harvesterStarted = expvar.NewInt("filebeatHarvesterStarted")
+	harvesterClosed  = expvar.NewInt("filebeatHarvesterClosed")
+	harvesterRunning = expvar.NewInt("filebeatHarvesterRunning")
+)
+
 type Harvester struct {
 	Path             string /* the file path to harvest */
 	ProspectorConfig config.ProspectorConfig
@@ -33,6 +41,7 @@ type Harvester struct {
 	encoding         encoding.EncodingFactory
 	file             FileSource /* the file being watched */
 	backoff          time.Duration
+	done             <-chan struct{} /* closed when the harvester must stop */
 }
 
 // Contains statistic about file when it was last seend by the prospector
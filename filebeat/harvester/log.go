@@ -21,6 +21,7 @@ func NewHarvester(
 	path string,
 	stat *FileStat,
 	spooler chan *input.FileEvent,
+	done <-chan struct{},
 ) (*Harvester, error) {
 	encoding, ok := encoding.FindEncoding(cfg.Encoding)
 	if !ok || encoding == nil {
@@ -35,6 +36,7 @@ func NewHarvester(
 		SpoolerChan:      spooler,
 		encoding:         encoding,
 		backoff:          prospectorCfg.Harvester.BackoffDuration,
+		done:             done,
 	}
 	return h, nil
 }
@@ -42,6 +44,13 @@ func NewHarvester(
 // Log harvester reads files line by line and sends events to the defined output
 func (h *Harvester) Harvest() {
 
+	harvesterStarted.Add(1)
+	harvesterRunning.Add(1)
+	defer func() {
+		harvesterRunning.Add(-1)
+		harvesterClosed.Add(1)
+	}()
+
 	enc, err := h.open()
 	if err != nil {
 		logp.Err("Stop Harvesting. Unexpected Error: %s", err)
@@ -51,7 +60,9 @@ func (h *Harvester) Harvest() {
 		// On completion, push offset so we can continue where we left off if we relaunch on the same file
 		h.Stat.Return <- h.Offset
 		// Make sure file is closed as soon as harvester exits
-		h.file.Close()
+		if h.file != nil {
+			h.file.Close()
+		}
 	}()
 
 	if err != nil {
@@ -82,6 +93,11 @@ func (h *Harvester) Harvest() {
 	lastReadTime := time.Now()
 
 	for {
+		if h.stopped() {
+			logp.Info("Harvester stopped for file: %s", h.Path)
+			return
+		}
+
 		// Partial lines return error and are only read on completion
 		text, bytesRead, err := readLine(reader, &timedIn.lastReadTime)
 
@@ -117,10 +133,17 @@ func (h *Harvester) Harvest() {
 			Fileinfo:     &info,
 		}
 
-		h.Offset += int64(bytesRead) // Update offset if complete line has been processed
-
 		event.SetFieldsUnderRoot(h.Config.FieldsUnderRoot)
-		h.SpoolerChan <- event // ship the new event downstream
+
+		// ship the new event downstream
+		select {
+		case h.SpoolerChan <- event:
+		case <-h.done:
+			logp.Info("Harvester stopped for file: %s", h.Path)
+			return
+		}
+
+		h.Offset += int64(bytesRead) // Update offset if complete line has been processed
 	}
 }
 
@@ -128,7 +151,10 @@ func (h *Harvester) Harvest() {
 // It also recalculate and sets the next backoff duration
 func (h *Harvester) backOff() {
 	// Wait before trying to read file which reached EOF again
-	time.Sleep(h.backoff)
+	select {
+	case <-time.After(h.backoff):
+	case <-h.done:
+	}
 
 	// Increment backoff up to maxBackoff
 	if h.backoff < h.Config.MaxBackoffDuration {
@@ -182,7 +208,11 @@ func (h *Harvester) openFile() (encoding.Encoding, error) {
 		}
 
 		logp.Err("Failed opening %s: %s", h.Path, err)
-		time.Sleep(5 * time.Second)
+		select {
+		case <-time.After(5 * time.Second):
+		case <-h.done:
+			return nil, errors.New("Harvester stopped.")
+		}
 	}
 
 	// update file offset
@@ -294,7 +324,15 @@ func (h *Harvester) handleReadlineError(lastTimeRead time.Time, err error) error
 	return nil
 }
 
-func (h *Harvester) Stop() {
+// stopped returns true if the prospector owning the harvester asked the
+// harvester to stop.
+func (h *Harvester) stopped() bool {
+	select {
+	case <-h.done:
+		return true
+	default:
+		return false
+	}
 }
 
 const maxConsecutiveEmptyReads = 100
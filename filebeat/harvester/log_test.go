@@ -2,13 +2,17 @@ package harvester
 
 import (
 	"io"
+	"io/ioutil"
 	"math/rand"
 	"os"
 	"path/filepath"
 	"strconv"
 	"testing"
+	"time"
 
+	"github.com/elastic/beats/filebeat/config"
 	"github.com/elastic/beats/filebeat/harvester/encoding"
+	"github.com/elastic/beats/filebeat/input"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -111,3 +115,60 @@ func TestLineEndingChars(t *testing.T) {
 	line = []byte("NR ending \n\r")
 	assert.Equal(t, 0, lineEndingChars(line))
 }
+
+func TestHarvesterStop(t *testing.T) {
+	file, err := ioutil.TempFile("", "harvester")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+
+	firstLine := "first line\n"
+	_, err = file.WriteString(firstLine + "second line\n")
+	file.Close()
+	assert.NoError(t, err)
+
+	info, err := os.Stat(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prospectorConfig := config.ProspectorConfig{
+		Harvester: config.HarvesterConfig{
+			InputType:          config.LogInputType,
+			BufferSize:         100,
+			BackoffDuration:    time.Second,
+			BackoffFactor:      2,
+			MaxBackoffDuration: 10 * time.Second,
+		},
+	}
+
+	spooler := make(chan *input.FileEvent)
+	done := make(chan struct{})
+	stat := NewFileStat(info, 0)
+	h, err := NewHarvester(prospectorConfig, &prospectorConfig.Harvester,
+		file.Name(), stat, spooler, done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	running := harvesterRunning.Value()
+	h.Start()
+
+	event := <-spooler
+	assert.Equal(t, "first line", *event.Text)
+
+	// the second event is blocked by the spooler until the harvester is stopped
+	close(done)
+	select {
+	case offset := <-stat.Return:
+		assert.Equal(t, int64(len(firstLine)), offset)
+	case <-time.After(5 * time.Second):
+		t.Fatal("harvester not stopped")
+	}
+
+	// the running count is decreased after the offset has been returned
+	for i := 0; i < 100 && harvesterRunning.Value() != running; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, running, harvesterRunning.Value())
+}
@@ -85,6 +85,16 @@ func (f *FileEvent) ToMapStr() common.MapStr {
 	return event
 }
 
+// ID returns a stable event ID derived from the source, the file identity and
+// the offset of the line. Lines sent again after a restart get the same ID.
+func (f *FileEvent) ID() string {
+	var fileState FileStateOS
+	if f.Fileinfo != nil {
+		fileState = *GetOSFileState(f.Fileinfo)
+	}
+	return common.EventID(*f.Source, fileState, f.Offset)
+}
+
 // Check that the file isn't a symlink, mode is regular or file is nil
 func (f *File) IsRegularFile() bool {
 	if f.File == nil {
@@ -131,3 +131,24 @@ func TestFieldsUnderRoot(t *testing.T) {
 	_, found = mapStr["fields"]
 	assert.True(t, found)
 }
+
+func TestFileEventID(t *testing.T) {
+	absPath, err := filepath.Abs("../tests/files/logs/test.log")
+	assert.NoError(t, err)
+	info, err := os.Stat(absPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := FileEvent{Source: &absPath, Offset: 10, Fileinfo: &info}
+	id := event.ID()
+	assert.Equal(t, id, event.ID())
+
+	event.Offset = 20
+	assert.NotEqual(t, id, event.ID())
+
+	// stdin has no file info
+	source := "-"
+	event = FileEvent{Source: &source}
+	assert.NotEmpty(t, event.ID())
+}
@@ -5,12 +5,15 @@ import (
 	"fmt"
 	"os"
 	"runtime"
+	"sync"
 
 	"github.com/elastic/beats/libbeat/cfgfile"
 	"github.com/elastic/beats/libbeat/logp"
+	"github.com/elastic/beats/libbeat/monitoring"
 	"github.com/elastic/beats/libbeat/outputs"
 	"github.com/elastic/beats/libbeat/publisher"
 	"github.com/elastic/beats/libbeat/service"
+	"github.com/joeshaw/multierror"
 )
 
 // Beater interface that every beat must use
@@ -30,6 +33,14 @@ type FlagsHandler interface {
 	HandleFlags(*Beat)
 }
 
+// Reloader (optional) Beater extension for applying a changed
+// configuration without restart. Reload is called after the configuration
+// file has been read again into Beat.Config. On error the beat must keep
+// running with its current configuration.
+type Reloader interface {
+	Reload(*Beat) error
+}
+
 // Basic beat information
 type Beat struct {
 	Name    string
@@ -37,13 +48,16 @@ type Beat struct {
 	Config  *BeatConfig
 	BT      Beater
 	Events  publisher.Client
+
+	reloadLock sync.Mutex
 }
 
 // Basic configuration of every beat
 type BeatConfig struct {
-	Output  map[string]outputs.MothershipConfig
-	Logging logp.Logging
-	Shipper publisher.ShipperConfig
+	Output     map[string]outputs.MothershipConfig
+	Logging    logp.Logging
+	Shipper    publisher.ShipperConfig
+	Monitoring monitoring.Config
 }
 
 var printVersion *bool
@@ -75,6 +89,10 @@ func Run(name string, version string, bt Beater) *Beat {
 
 	// Configures beat
 	err := bt.Config(b)
+	if err == nil {
+		// all configuration structs have been read
+		err = cfgfile.CheckUnknownSections()
+	}
 	if err != nil {
 		logp.Critical("Config error: %v", err)
 		os.Exit(1)
@@ -117,6 +135,11 @@ func (b *Beat) LoadConfig() {
 
 	err := cfgfile.Read(&b.Config, "")
 	if err != nil {
+		if cfgfile.IsTestConfig() {
+			// report the problems of the beat specific configuration as well
+			err = joinConfigErrors(err, b.BT.Config(b), cfgfile.CheckUnknownSections())
+		}
+
 		// logging not yet initialized, so using fmt.Printf
 		fmt.Printf("Loading config file error: %v\n", err)
 		os.Exit(1)
@@ -144,6 +167,30 @@ func (b *Beat) LoadConfig() {
 	logp.Info("Init Beat: %s; Version: %s", b.Name, b.Version)
 }
 
+// joinConfigErrors combines the errors of all configuration structs into one
+// multierror. Errors of sections shared by multiple structs are reported once.
+func joinConfigErrors(errs ...error) error {
+	var all multierror.Errors
+	seen := map[string]bool{}
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+
+		nested := multierror.Errors{err}
+		if m, ok := err.(*multierror.MultiError); ok {
+			nested = m.Errors
+		}
+		for _, e := range nested {
+			if !seen[e.Error()] {
+				seen[e.Error()] = true
+				all = append(all, e)
+			}
+		}
+	}
+	return all.Err()
+}
+
 // Run calls the beater Setup and Run methods. In case of errors
 // during the setup phase, it exits the process.
 func (b *Beat) Run() {
@@ -162,10 +209,20 @@ func (b *Beat) Run() {
 	}
 	service.BeforeRun()
 
+	// Serve metrics if enabled
+	err = monitoring.Start(b.Config.Monitoring)
+	if err != nil {
+		logp.Critical("Failed to start monitoring endpoint: %v", err)
+		os.Exit(1)
+	}
+
 	// Callback is called if the processes is asked to stop.
 	// This needs to be called before the main loop is started so that
 	// it can register the signals that stop or query (on Windows) the loop.
-	service.HandleSignals(b.BT.Stop)
+	service.HandleSignals(b.Stop)
+
+	// Configuration is reloaded on SIGHUP
+	service.HandleReload(b.Reload)
 
 	logp.Info("%s sucessfully setup. Start running.", b.Name)
 
@@ -175,6 +232,10 @@ func (b *Beat) Run() {
 		logp.Critical("Run returned an error: %v", err)
 	}
 
+	// Wait for the events still queued to be published, at most until the
+	// shutdown timeout elapses
+	publisher.Publisher.Stop()
+
 	service.Cleanup()
 
 	logp.Info("Cleaning up %s before shutting down.", b.Name)
@@ -186,7 +247,45 @@ func (b *Beat) Run() {
 	}
 }
 
-// Stop calls the beater Stop action.
+// Reload reads the configuration file again and applies the logging level,
+// the publisher settings that can be changed safely and, in case the beater
+// implements Reloader, the beat specific settings. Errors are logged and the
+// current configuration keeps running.
+func (b *Beat) Reload() {
+	b.reloadLock.Lock()
+	defer b.reloadLock.Unlock()
+
+	logp.Info("Reloading configuration")
+
+	var config BeatConfig
+	if err := cfgfile.Read(&config, ""); err != nil {
+		logp.Err("Failed to reload configuration, keeping current configuration: %v", err)
+		return
+	}
+
+	if err := logp.Reload(&config.Logging); err != nil {
+		logp.Err("Failed to reload logging configuration: %v", err)
+	} else {
+		b.Config.Logging.Level = config.Logging.Level
+		b.Config.Logging.Selectors = config.Logging.Selectors
+	}
+
+	if err := publisher.Publisher.Reload(config.Output, config.Shipper); err != nil {
+		logp.Err("Failed to reload output configuration: %v", err)
+	} else {
+		b.Config.Shipper.Tags = config.Shipper.Tags
+	}
+
+	if reloader, ok := b.BT.(Reloader); ok {
+		if err := reloader.Reload(b); err != nil {
+			logp.Err("Failed to reload %s configuration: %v", b.Name, err)
+		}
+	}
+}
+
+// Stop starts the shutdown timeout of the publisher and calls the beater Stop
+// action. Events not published when the timeout elapses are abandoned.
 func (beat *Beat) Stop() {
+	publisher.Publisher.Shutdown()
 	beat.BT.Stop()
 }
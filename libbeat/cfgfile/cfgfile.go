@@ -3,22 +3,22 @@ package cfgfile
 import (
 	"flag"
 	"fmt"
-	"io/ioutil"
+	"path/filepath"
 	"runtime"
 	"strings"
-
-	"gopkg.in/yaml.v2"
 )
 
 // Command line flags
 var configfile *string
 var testConfig *bool
+var overwrites overwriteFlag
 
 func init() {
 	// The default config cannot include the beat name as it is not initialised when this
 	// function is called, but see ChangeDefaultCfgfileFlag
 	configfile = flag.String("c", "/etc/beat/beat.yml", "Configuration file")
 	testConfig = flag.Bool("configtest", false, "Test configuration and exit.")
+	flag.Var(&overwrites, "E", "Configuration overwrite (key.path=value), can be repeated")
 }
 
 // ChangeDefaultCfgfileFlag replaces the value and default value for the `-c` flag so that
@@ -39,23 +39,50 @@ func ChangeDefaultCfgfileFlag(beatName string) error {
 }
 
 // Read reads the configuration from a yaml file into the given interface structure.
-// In case path is not set this method reads from the default configuration file for the beat.
+// In case path is not set this method reads from the default configuration file for the beat
+// and applies the overwrites given by the -E command line flag.
+//
+// Environment variables referenced as ${VAR} or ${VAR:default} are expanded and
+// the files listed in the top-level include setting are merged into the
+// configuration before it is decoded. Unknown settings within the sections of
+// out and the errors returned by Validator implementations are reported in one
+// error.
 func Read(out interface{}, path string) error {
-
-	if path == "" {
+	src := &source{}
+	isDefault := path == ""
+	if isDefault {
 		path = *configfile
+		src.overwrites = overwrites
 	}
 
-	filecontent, err := ioutil.ReadFile(path)
-
+	config, err := load(path, map[string]bool{}, &src.files)
 	if err != nil {
-		return fmt.Errorf("Failed to read %s: %v. Exiting.", path, err)
+		return err
+	}
+
+	for _, o := range src.overwrites {
+		o.apply(config)
 	}
-	if err = yaml.Unmarshal(filecontent, out); err != nil {
-		return fmt.Errorf("YAML config parsing failed on %s: %v. Exiting.", path, err)
+
+	if isDefault {
+		setDefaultSource(src, config, out)
 	}
+	return decode(config, out, nil, src, false)
+}
 
-	return nil
+// ConfigDir returns the directory of the configuration file read by default.
+// Relative paths found in the configuration are resolved against it.
+func ConfigDir() string {
+	return filepath.Dir(*configfile)
+}
+
+// LoadedFiles returns the paths of all files read by the last Read of the
+// beat configuration file, including the included files.
+func LoadedFiles() []string {
+	if defaultSource.src == nil {
+		return nil
+	}
+	return append([]string{}, defaultSource.src.files...)
 }
 
 func IsTestConfig() bool {
@@ -1,6 +1,8 @@
 package cfgfile
 
 import (
+	"io/ioutil"
+	"os"
 	"path/filepath"
 	"testing"
 
@@ -16,8 +18,9 @@ type ElasticsearchConfig struct {
 }
 
 type Connection struct {
-	Port int
-	Host string
+	Enabled bool
+	Port    int
+	Host    string
 }
 
 func TestRead(t *testing.T) {
@@ -37,3 +40,201 @@ func TestRead(t *testing.T) {
 	// Chat that it is integer
 	assert.Equal(t, 9200, config.Output.Elasticsearch.Port)
 }
+
+func writeFile(t *testing.T, path, content string) {
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExpandEnv(t *testing.T) {
+	os.Setenv("CFGFILE_TEST_HOST", "es.example.com")
+	os.Setenv("CFGFILE_TEST_EMPTY", "")
+	defer os.Unsetenv("CFGFILE_TEST_HOST")
+	defer os.Unsetenv("CFGFILE_TEST_EMPTY")
+
+	tests := []struct {
+		in, out string
+	}{
+		{"host: ${CFGFILE_TEST_HOST}", "host: es.example.com"},
+		{"host: ${CFGFILE_TEST_HOST}:9200", "host: es.example.com:9200"},
+		{"host: ${CFGFILE_TEST_MISSING}", "host: "},
+		{"host: ${CFGFILE_TEST_MISSING:localhost}", "host: localhost"},
+		{"host: ${CFGFILE_TEST_EMPTY:localhost}", "host: localhost"},
+		{"host: ${CFGFILE_TEST_HOST:localhost}", "host: es.example.com"},
+		{"url: ${CFGFILE_TEST_MISSING:http://localhost:9200}", "url: http://localhost:9200"},
+		{"password: pa$$word", "password: pa$$word"},
+		{"text: $${CFGFILE_TEST_HOST}", "text: ${CFGFILE_TEST_HOST}"},
+		{"text: ${CFGFILE_TEST_HOST", "text: ${CFGFILE_TEST_HOST"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.out, expandEnv(test.in))
+	}
+}
+
+func TestReadEnv(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfgfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.yml")
+	writeFile(t, path, `
+output:
+  elasticsearch:
+    enabled: ${CFGFILE_TEST_ENABLED}
+    host: ${CFGFILE_TEST_HOST}
+    port: ${CFGFILE_TEST_PORT:9200}
+password: ${CFGFILE_TEST_PASSWORD}
+`)
+	os.Setenv("CFGFILE_TEST_ENABLED", "true")
+	os.Setenv("CFGFILE_TEST_HOST", "es.example.com")
+	defer os.Unsetenv("CFGFILE_TEST_ENABLED")
+	defer os.Unsetenv("CFGFILE_TEST_HOST")
+	defer os.Unsetenv("CFGFILE_TEST_PASSWORD")
+
+	// values of variables are not parsed as YAML
+	passwords := []string{"x #y", "[abc", "*ref", "{a: b}", "0123", "1e3", "123456", "true", ""}
+	for _, password := range passwords {
+		os.Setenv("CFGFILE_TEST_PASSWORD", password)
+
+		config := &struct {
+			TestConfig `yaml:",inline"`
+			Password   string
+		}{}
+		err := Read(config, path)
+		if !assert.NoError(t, err, password) {
+			continue
+		}
+		assert.Equal(t, password, config.Password)
+		assert.True(t, config.Output.Elasticsearch.Enabled)
+		assert.Equal(t, "es.example.com", config.Output.Elasticsearch.Host)
+		assert.Equal(t, 9200, config.Output.Elasticsearch.Port)
+	}
+}
+
+func TestReadInclude(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfgfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	os.Mkdir(filepath.Join(dir, "conf.d"), 0755)
+	writeFile(t, filepath.Join(dir, "main.yml"), `
+include: conf.d
+output:
+  elasticsearch:
+    host: localhost
+    port: 9200
+hosts: [a]
+`)
+	writeFile(t, filepath.Join(dir, "conf.d", "a.yml"), `
+output:
+  elasticsearch:
+    host: es1
+hosts: [b]
+`)
+	writeFile(t, filepath.Join(dir, "conf.d", "b.yml"), `
+hosts: [c]
+`)
+	writeFile(t, filepath.Join(dir, "conf.d", "ignored.txt"), `
+hosts: [d]
+`)
+
+	config := &struct {
+		TestConfig `yaml:",inline"`
+		Hosts      []string
+	}{}
+	err = Read(config, filepath.Join(dir, "main.yml"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "es1", config.Output.Elasticsearch.Host)
+	assert.Equal(t, 9200, config.Output.Elasticsearch.Port)
+	assert.Equal(t, []string{"a", "b", "c"}, config.Hosts)
+}
+
+func TestReadIncludeErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfgfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeFile(t, filepath.Join(dir, "cycle.yml"), "include: [cycle.yml]")
+	writeFile(t, filepath.Join(dir, "missing.yml"), "include: [missing/a.yml]")
+	writeFile(t, filepath.Join(dir, "invalid.yml"), "include: {a: b}")
+
+	for _, name := range []string{"cycle.yml", "missing.yml", "invalid.yml"} {
+		err := Read(&TestConfig{}, filepath.Join(dir, name))
+		assert.Error(t, err, name)
+	}
+}
+
+func TestOverwriteFlag(t *testing.T) {
+	var f overwriteFlag
+	assert.NoError(t, f.Set("output.elasticsearch.host=es.example.com"))
+	assert.NoError(t, f.Set("a=b=c"))
+	assert.Error(t, f.Set("novalue"))
+	assert.Error(t, f.Set("=value"))
+	assert.Error(t, f.Set("output..host=value"))
+
+	assert.Equal(t, "output.elasticsearch.host=es.example.com, a=b=c", f.String())
+}
+
+func TestReadOverwrites(t *testing.T) {
+	absPath, err := filepath.Abs("../tests/files/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldConfigfile, oldOverwrites := *configfile, overwrites
+	defer func() {
+		*configfile, overwrites = oldConfigfile, oldOverwrites
+	}()
+
+	*configfile = absPath + "/config.yml"
+	overwrites = nil
+	overwrites.Set("output.elasticsearch.host=es.example.com")
+	overwrites.Set("output.elasticsearch.port=9201")
+
+	config := &TestConfig{}
+	err = Read(config, "")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "es.example.com", config.Output.Elasticsearch.Host)
+	assert.Equal(t, 9201, config.Output.Elasticsearch.Port)
+
+	// overwrites only apply to the beats configuration file
+	config = &TestConfig{}
+	err = Read(config, absPath+"/config.yml")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "localhost", config.Output.Elasticsearch.Host)
+}
+
+func TestOverwriteApply(t *testing.T) {
+	cfg := map[interface{}]interface{}{
+		"output": map[interface{}]interface{}{
+			"elasticsearch": "not a map",
+		},
+	}
+
+	var f overwriteFlag
+	f.Set("output.elasticsearch.hosts=[\"a:9200\", \"b:9200\"]")
+	f.Set("output.elasticsearch.index=")
+	f.Set("shipper.name=test: {")
+	for _, o := range f {
+		o.apply(cfg)
+	}
+
+	es := cfg["output"].(map[interface{}]interface{})["elasticsearch"].(map[interface{}]interface{})
+	assert.Equal(t, []interface{}{"a:9200", "b:9200"}, es["hosts"])
+	assert.Equal(t, "", es["index"])
+	assert.Equal(t, "test: {", cfg["shipper"].(map[interface{}]interface{})["name"])
+}
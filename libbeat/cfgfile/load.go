@@ -0,0 +1,281 @@
+package cfgfile
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// includeKey is the top-level setting listing additional configuration files.
+const includeKey = "include"
+
+// load reads the configuration file at path, expanding environment variables
+// and merging all included files. Files currently being loaded are tracked in
+// loading to detect include cycles. The paths of all files read are appended
+// to files.
+func load(
+	path string,
+	loading map[string]bool,
+	files *[]string,
+) (map[interface{}]interface{}, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read %s: %v. Exiting.", path, err)
+	}
+	if loading[abs] {
+		return nil, fmt.Errorf("Failed to read %s: include cycle detected. Exiting.", path)
+	}
+
+	filecontent, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read %s: %v. Exiting.", path, err)
+	}
+	*files = append(*files, path)
+
+	cfg := map[interface{}]interface{}{}
+	if err = yaml.Unmarshal(filecontent, &cfg); err != nil {
+		return nil, fmt.Errorf("YAML config parsing failed on %s: %v. Exiting.", path, err)
+	}
+	expandEnvValues(cfg)
+
+	includes, err := includePaths(cfg[includeKey])
+	if err != nil {
+		return nil, fmt.Errorf("Invalid include setting in %s: %v. Exiting.", path, err)
+	}
+	delete(cfg, includeKey)
+
+	loading[abs] = true
+	defer delete(loading, abs)
+
+	for _, include := range includes {
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(path), include)
+		}
+
+		includedFiles, err := ConfigFiles(include)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to include %s from %s: %v. Exiting.",
+				include, path, err)
+		}
+
+		for _, file := range includedFiles {
+			included, err := load(file, loading, files)
+			if err != nil {
+				return nil, err
+			}
+			merge(cfg, included)
+		}
+	}
+
+	return cfg, nil
+}
+
+// includePaths returns the paths configured by the include setting, being
+// either a single path or a list of paths.
+func includePaths(setting interface{}) ([]string, error) {
+	switch v := setting.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		paths := make([]string, 0, len(v))
+		for _, elem := range v {
+			path, ok := elem.(string)
+			if !ok {
+				return nil, fmt.Errorf("path expected, found '%v'", elem)
+			}
+			paths = append(paths, path)
+		}
+		return paths, nil
+	}
+	return nil, fmt.Errorf("path or list of paths expected, found '%v'", setting)
+}
+
+// ConfigFiles returns the list of configuration files found at path.
+// In case path is a file, it will be directly returned.
+// In case it is a directory, all .yml files inside this directory are returned.
+// Otherwise path is used as glob pattern.
+func ConfigFiles(path string) ([]string, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		if !strings.ContainsAny(path, "*?[") {
+			return nil, err
+		}
+		return filepath.Glob(path)
+	}
+
+	if stat.IsDir() {
+		return filepath.Glob(filepath.Join(path, "*.yml"))
+	}
+	return []string{path}, nil
+}
+
+// merge merges src into dst. Nested maps are merged recursively and lists
+// are appended. All other values in src replace the values in dst.
+func merge(dst, src map[interface{}]interface{}) {
+	for k, v := range src {
+		switch srcValue := v.(type) {
+		case map[interface{}]interface{}:
+			if dstValue, ok := dst[k].(map[interface{}]interface{}); ok {
+				merge(dstValue, srcValue)
+				continue
+			}
+		case []interface{}:
+			if dstValue, ok := dst[k].([]interface{}); ok {
+				dst[k] = append(dstValue, srcValue...)
+				continue
+			}
+		}
+		dst[k] = v
+	}
+}
+
+// integer matches the integer values set by environment variables
+var integer = regexp.MustCompile(`^-?(0|[1-9][0-9]*)$`)
+
+// expandEnvValues expands the environment variable references in all string
+// values of the parsed configuration value. The values of the variables are
+// not parsed as YAML, so they stay strings. Only a setting consisting of a
+// single reference whose value is an integer or a boolean gets that type, so
+// settings like ports can be set by variables.
+func expandEnvValues(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		expanded := expandEnv(v)
+		if !isSingleReference(v) {
+			return expanded
+		}
+		switch {
+		case expanded == "true":
+			return true
+		case expanded == "false":
+			return false
+		case integer.MatchString(expanded):
+			if n, err := strconv.ParseInt(expanded, 10, 64); err == nil {
+				return n
+			}
+		}
+		return expanded
+	case map[interface{}]interface{}:
+		for k, elem := range v {
+			v[k] = expandEnvValues(elem)
+		}
+	case []interface{}:
+		for i, elem := range v {
+			v[i] = expandEnvValues(elem)
+		}
+	}
+	return value
+}
+
+// isSingleReference returns true if s is a single ${VAR} reference.
+func isSingleReference(s string) bool {
+	return strings.HasPrefix(s, "${") && strings.Index(s, "}") == len(s)-1
+}
+
+// expandEnv replaces ${VAR} references with the value of the environment
+// variable VAR. A default value used if VAR is unset or empty can be given by
+// ${VAR:default}. Use $${ to write a literal ${.
+func expandEnv(s string) string {
+	var buf bytes.Buffer
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			break
+		}
+
+		if i > 0 && s[i-1] == '$' {
+			// escaped reference
+			buf.WriteString(s[:i-1])
+			buf.WriteString("${")
+			s = s[i+2:]
+			continue
+		}
+
+		end := strings.IndexByte(s[i+2:], '}')
+		if end < 0 {
+			break
+		}
+
+		buf.WriteString(s[:i])
+		buf.WriteString(lookupEnv(s[i+2 : i+2+end]))
+		s = s[i+2+end+1:]
+	}
+	buf.WriteString(s)
+	return buf.String()
+}
+
+func lookupEnv(reference string) string {
+	keyAndDefault := strings.SplitN(reference, ":", 2)
+	value := os.Getenv(keyAndDefault[0])
+	if value == "" && len(keyAndDefault) == 2 {
+		value = keyAndDefault[1]
+	}
+	return value
+}
+
+// overwrite is a single configuration setting given on the command line.
+type overwrite struct {
+	path  []string
+	value string
+}
+
+// overwriteFlag collects the settings passed by the repeatable -E flag.
+type overwriteFlag []overwrite
+
+func (f *overwriteFlag) String() string {
+	settings := make([]string, len(*f))
+	for i, o := range *f {
+		settings[i] = strings.Join(o.path, ".") + "=" + o.value
+	}
+	return strings.Join(settings, ", ")
+}
+
+func (f *overwriteFlag) Set(setting string) error {
+	kv := strings.SplitN(setting, "=", 2)
+	if len(kv) != 2 || kv[0] == "" {
+		return fmt.Errorf("invalid setting '%s', expected key.path=value", setting)
+	}
+
+	path := strings.Split(kv[0], ".")
+	for _, name := range path {
+		if name == "" {
+			return fmt.Errorf("invalid setting name '%s'", kv[0])
+		}
+	}
+
+	*f = append(*f, overwrite{path: path, value: kv[1]})
+	return nil
+}
+
+// apply sets the value in cfg, creating missing parent settings. The value is
+// parsed as YAML, such that numbers, booleans and lists can be set. Values not
+// being valid YAML are set as string.
+func (o overwrite) apply(cfg map[interface{}]interface{}) {
+	m := cfg
+	for _, name := range o.path[:len(o.path)-1] {
+		child, ok := m[name].(map[interface{}]interface{})
+		if !ok {
+			child = map[interface{}]interface{}{}
+			m[name] = child
+		}
+		m = child
+	}
+
+	var value interface{} = o.value
+	if o.value != "" {
+		var parsed interface{}
+		if err := yaml.Unmarshal([]byte(o.value), &parsed); err == nil && parsed != nil {
+			value = parsed
+		}
+	}
+	m[o.path[len(o.path)-1]] = value
+}
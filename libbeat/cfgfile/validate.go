@@ -0,0 +1,555 @@
+package cfgfile
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/joeshaw/multierror"
+	"gopkg.in/yaml.v2"
+)
+
+// Validator is implemented by configuration types checking their settings.
+// Validate is called on every value of the configuration implementing the
+// interface after the configuration has been read.
+type Validator interface {
+	Validate() error
+}
+
+var (
+	validatorType   = reflect.TypeOf((*Validator)(nil)).Elem()
+	unmarshalerType = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+
+	// typeErrorLine matches the line prefix of yaml type errors. The line
+	// refers to the merged configuration and is not meaningful to users.
+	typeErrorLine = regexp.MustCompile(`^line \d+: `)
+)
+
+// defaultSource is the source of the beat configuration file, read by Read
+// without path. Sections claims the top-level sections read into any
+// configuration struct.
+var defaultSource struct {
+	src      *source
+	sections map[string]bool
+	claimed  map[string]bool
+}
+
+// source describes where the settings of a configuration come from, in order
+// to report the position of invalid settings.
+type source struct {
+	files      []string
+	overwrites []overwrite
+}
+
+// decode decodes the raw configuration into out. All unknown settings and
+// problems reported by Validator implementations are returned in one
+// multierror. If strict is false, unknown top-level settings are ignored,
+// as the configuration file is shared by multiple configuration structs.
+func decode(raw interface{}, out interface{}, path []string, src *source, strict bool) error {
+	var errs multierror.Errors
+	typeErrs := map[string]int{}
+	checkSettings(raw, reflect.TypeOf(out), path, src, strict, typeErrs, &errs)
+
+	content, err := yaml.Marshal(raw)
+	if err == nil {
+		err = yaml.Unmarshal(content, out)
+	}
+	if typeErr, ok := err.(*yaml.TypeError); ok {
+		for _, msg := range typeErr.Errors {
+			msg = typeErrorLine.ReplaceAllString(msg, "")
+			if typeErrs[msg] > 0 {
+				// already reported with the position of the setting
+				typeErrs[msg]--
+				continue
+			}
+			errs = append(errs, settingError(src, path, msg))
+		}
+	} else if err != nil {
+		errs = append(errs, err)
+	}
+
+	validate(reflect.ValueOf(out), path, src, false, &errs)
+	return errs.Err()
+}
+
+// UnpackSection decodes the raw configuration section into out, rejecting
+// unknown settings and calling Validate on all values implementing Validator.
+// The section is the path of the raw section in the beat configuration file,
+// like 'protocols.http', used to report the position of invalid settings.
+func UnpackSection(raw interface{}, out interface{}, section string) error {
+	var path []string
+	if section != "" {
+		path = strings.Split(section, ".")
+	}
+	if raw == nil {
+		raw = map[interface{}]interface{}{}
+	}
+	return decode(raw, out, path, defaultSource.src, true)
+}
+
+// CheckUnknownSections returns an error for each top-level section of the
+// beat configuration file not read into any configuration struct. It must be
+// called after all configuration structs have been read.
+func CheckUnknownSections() error {
+	var errs multierror.Errors
+	for _, name := range sortedKeys(defaultSource.sections) {
+		if !defaultSource.claimed[name] {
+			errs = append(errs, settingError(defaultSource.src, []string{name},
+				fmt.Sprintf("unknown setting '%s'", name)))
+		}
+	}
+	return errs.Err()
+}
+
+// setDefaultSource records the sections of the beat configuration file and
+// the sections claimed by out.
+func setDefaultSource(src *source, raw map[interface{}]interface{}, out interface{}) {
+	defaultSource.src = src
+	defaultSource.sections = map[string]bool{}
+	for k := range raw {
+		defaultSource.sections[fmt.Sprint(k)] = true
+	}
+
+	if defaultSource.claimed == nil {
+		defaultSource.claimed = map[string]bool{}
+	}
+	if fields, ok := structFields(indirectType(reflect.TypeOf(out))); ok {
+		for name := range fields {
+			defaultSource.claimed[name] = true
+		}
+	}
+}
+
+// checkSettings reports all settings in raw not matching a field of type t
+// and all values which can not be decoded into the type of their setting. The
+// messages of the type errors are counted in typeErrs.
+func checkSettings(
+	raw interface{},
+	t reflect.Type,
+	path []string,
+	src *source,
+	strict bool,
+	typeErrs map[string]int,
+	errs *multierror.Errors,
+) {
+	t = indirectType(t)
+	if reflect.PtrTo(t).Implements(unmarshalerType) {
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		return
+
+	case reflect.Struct:
+		m, ok := raw.(map[interface{}]interface{})
+		if !ok {
+			checkType(raw, t, path, src, typeErrs, errs)
+			return
+		}
+		fields, ok := structFields(t)
+		if !ok {
+			return
+		}
+		for _, k := range sortedKeys(m) {
+			fieldType, exists := fields[k]
+			child := appendPath(path, k)
+			if !exists {
+				if strict {
+					*errs = append(*errs, settingError(src, child,
+						fmt.Sprintf("unknown setting '%s'", strings.Join(child, "."))))
+				}
+				continue
+			}
+			checkSettings(m[k], fieldType, child, src, true, typeErrs, errs)
+		}
+
+	case reflect.Map:
+		m, ok := raw.(map[interface{}]interface{})
+		if !ok {
+			checkType(raw, t, path, src, typeErrs, errs)
+			return
+		}
+		for _, k := range sortedKeys(m) {
+			checkSettings(m[k], t.Elem(), appendPath(path, k), src, true, typeErrs, errs)
+		}
+
+	case reflect.Slice, reflect.Array:
+		list, ok := raw.([]interface{})
+		if !ok {
+			checkType(raw, t, path, src, typeErrs, errs)
+			return
+		}
+		for i, elem := range list {
+			checkSettings(elem, t.Elem(), appendPath(path, strconv.Itoa(i)), src, true,
+				typeErrs, errs)
+		}
+
+	default:
+		checkType(raw, t, path, src, typeErrs, errs)
+	}
+}
+
+// checkType reports an error if the value of the setting at path can not be
+// decoded into type t.
+func checkType(
+	raw interface{},
+	t reflect.Type,
+	path []string,
+	src *source,
+	typeErrs map[string]int,
+	errs *multierror.Errors,
+) {
+	if raw == nil {
+		return
+	}
+
+	content, err := yaml.Marshal(raw)
+	if err != nil {
+		return
+	}
+	typeErr, ok := yaml.Unmarshal(content, reflect.New(t).Interface()).(*yaml.TypeError)
+	if !ok {
+		return
+	}
+	for _, msg := range typeErr.Errors {
+		msg = typeErrorLine.ReplaceAllString(msg, "")
+		typeErrs[msg]++
+		*errs = append(*errs, settingError(src, path, msg))
+	}
+}
+
+// structFields returns the types of all settings of a struct by their yaml
+// names. Inlined structs are flattened. If the struct inlines a map, any
+// setting is accepted and false is returned.
+func structFields(t reflect.Type) (map[string]reflect.Type, bool) {
+	if t.Kind() != reflect.Struct {
+		return nil, false
+	}
+
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue // unexported
+		}
+
+		tag := strings.Split(f.Tag.Get("yaml"), ",")
+		name := tag[0]
+		if name == "-" {
+			continue
+		}
+
+		if hasOption(tag[1:], "inline") {
+			inlined, ok := structFields(indirectType(f.Type))
+			if !ok {
+				return nil, false
+			}
+			for k, v := range inlined {
+				fields[k] = v
+			}
+			continue
+		}
+
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f.Type
+	}
+	return fields, true
+}
+
+// validate calls Validate on v and all values contained in v implementing
+// Validator. Validate is not called on embedded structs, as their Validate
+// method is promoted to the embedding struct.
+func validate(
+	v reflect.Value,
+	path []string,
+	src *source,
+	embedded bool,
+	errs *multierror.Errors,
+) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	if !embedded {
+		if err := callValidate(v); err != nil {
+			for _, err := range flatten(err) {
+				if _, ok := err.(*configError); !ok {
+					// report position, unless reported by a nested section
+					err = settingError(src, path, err.Error())
+				}
+				*errs = append(*errs, err)
+			}
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		fields := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			f := fields.Field(i)
+			if f.PkgPath != "" && !f.Anonymous {
+				continue
+			}
+			tag := strings.Split(f.Tag.Get("yaml"), ",")
+			name := tag[0]
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = strings.ToLower(f.Name)
+			}
+
+			child := appendPath(path, name)
+			if f.Anonymous || hasOption(tag[1:], "inline") {
+				child = path
+			}
+			validate(v.Field(i), child, src, f.Anonymous, errs)
+		}
+
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Sort(byString(keys))
+		for _, k := range keys {
+			validate(v.MapIndex(k), appendPath(path, fmt.Sprint(k.Interface())), src, false, errs)
+		}
+
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			validate(v.Index(i), appendPath(path, strconv.Itoa(i)), src, false, errs)
+		}
+	}
+}
+
+// callValidate calls Validate on v, if v or a pointer to v implements
+// Validator.
+func callValidate(v reflect.Value) error {
+	if !v.IsValid() {
+		return nil
+	}
+
+	if v.Type().Implements(validatorType) {
+		return v.Interface().(Validator).Validate()
+	}
+	if reflect.PtrTo(v.Type()).Implements(validatorType) {
+		if !v.CanAddr() {
+			copy := reflect.New(v.Type())
+			copy.Elem().Set(v)
+			return copy.Interface().(Validator).Validate()
+		}
+		return v.Addr().Interface().(Validator).Validate()
+	}
+	return nil
+}
+
+// configError is an error of a setting, prefixed by the position of the
+// setting.
+type configError struct {
+	msg string
+}
+
+func (e *configError) Error() string {
+	return e.msg
+}
+
+// settingError creates an error for the setting at path, prefixed with the
+// position of the setting if it can be found.
+func settingError(src *source, path []string, msg string) error {
+	setting := strings.Join(path, ".")
+	pos := src.position(path)
+	if setting != "" && pos != "-E "+setting && !strings.Contains(msg, setting) {
+		msg = setting + ": " + msg
+	}
+
+	if pos != "" {
+		msg = pos + ": " + msg
+	}
+	return &configError{msg}
+}
+
+// position returns the position of the setting at path in the form
+// 'file:line'. Settings overwritten on the command line are reported as
+// '-E setting'.
+func (src *source) position(path []string) string {
+	if src == nil || len(path) == 0 {
+		return ""
+	}
+
+	for _, o := range src.overwrites {
+		if hasPrefix(path, o.path) {
+			return "-E " + strings.Join(o.path, ".")
+		}
+	}
+
+	for _, file := range src.files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		if line := findSetting(content, path); line > 0 {
+			return fmt.Sprintf("%s:%d", file, line)
+		}
+	}
+	return ""
+}
+
+// findSetting returns the line number of the setting at path in a yaml
+// document using block style, or 0 if the setting is not found. List elements
+// are addressed by their index.
+func findSetting(content []byte, path []string) int {
+	var stack []settingEntry
+	items := map[string]int{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" || trimmed[0] == '#' {
+			continue
+		}
+
+		indent := len(line) - len(trimmed)
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+
+		if trimmed == "-" || strings.HasPrefix(trimmed, "- ") {
+			parent := stackPath(stack)
+			idx := items[parent]
+			items[parent]++
+			stack = append(stack, settingEntry{indent, strconv.Itoa(idx)})
+
+			trimmed = strings.TrimLeft(trimmed[1:], " ")
+			indent = len(line) - len(trimmed)
+			if matchesPath(stack, path) {
+				return lineNo
+			}
+		}
+
+		key, ok := parseKey(trimmed)
+		if !ok {
+			continue
+		}
+		stack = append(stack, settingEntry{indent, key})
+		if matchesPath(stack, path) {
+			return lineNo
+		}
+	}
+	return 0
+}
+
+// parseKey returns the key of a 'key: value' line.
+func parseKey(line string) (string, bool) {
+	i := strings.Index(line, ":")
+	if i <= 0 || (i+1 < len(line) && line[i+1] != ' ') {
+		return "", false
+	}
+	key := strings.Trim(line[:i], `"'`)
+	if key == "" || strings.ContainsAny(key, " {[") {
+		return "", false
+	}
+	return key, true
+}
+
+// settingEntry is a key of the setting path while scanning a yaml document.
+type settingEntry struct {
+	indent int
+	key    string
+}
+
+func stackPath(stack []settingEntry) string {
+	keys := make([]string, len(stack))
+	for i, e := range stack {
+		keys[i] = e.key
+	}
+	return strings.Join(keys, ".")
+}
+
+func matchesPath(stack []settingEntry, path []string) bool {
+	if len(stack) != len(path) {
+		return false
+	}
+	for i, e := range stack {
+		if e.key != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// flatten returns the errors contained in a multierror.
+func flatten(err error) []error {
+	if m, ok := err.(*multierror.MultiError); ok {
+		var errs []error
+		for _, e := range m.Errors {
+			errs = append(errs, flatten(e)...)
+		}
+		return errs
+	}
+	return []error{err}
+}
+
+func hasOption(options []string, option string) bool {
+	for _, opt := range options {
+		if opt == option {
+			return true
+		}
+	}
+	return false
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+func appendPath(path []string, name string) []string {
+	child := make([]string, len(path), len(path)+1)
+	copy(child, path)
+	return append(child, name)
+}
+
+func hasPrefix(path, prefix []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i := range prefix {
+		if path[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedKeys(m interface{}) []string {
+	v := reflect.ValueOf(m)
+	keys := make([]string, 0, v.Len())
+	for _, k := range v.MapKeys() {
+		keys = append(keys, fmt.Sprint(k.Interface()))
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type byString []reflect.Value
+
+func (v byString) Len() int      { return len(v) }
+func (v byString) Swap(i, j int) { v[i], v[j] = v[j], v[i] }
+func (v byString) Less(i, j int) bool {
+	return fmt.Sprint(v[i].Interface()) < fmt.Sprint(v[j].Interface())
+}
@@ -0,0 +1,264 @@
+package cfgfile
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/joeshaw/multierror"
+	"github.com/stretchr/testify/assert"
+)
+
+type validatedConfig struct {
+	Section validatedSection
+	Items   []validatedItem
+	Named   map[string]validatedItem
+}
+
+type validatedSection struct {
+	Name   string
+	Common commonSettings `yaml:",inline"`
+}
+
+type commonSettings struct {
+	Port int
+}
+
+func (c commonSettings) Validate() error {
+	if c.Port < 0 {
+		return errors.New("port must not be negative")
+	}
+	return nil
+}
+
+type validatedItem struct {
+	EmbeddedItem `yaml:",inline"`
+	Value        *int
+}
+
+type EmbeddedItem struct {
+	Enabled bool
+}
+
+func (i EmbeddedItem) Validate() error {
+	if !i.Enabled {
+		return errors.New("item disabled")
+	}
+	return nil
+}
+
+func readString(t *testing.T, content string, out interface{}) error {
+	dir, err := ioutil.TempDir("", "cfgfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.yml")
+	writeFile(t, path, content)
+	return Read(out, path)
+}
+
+func errorMessages(err error) []string {
+	var msgs []string
+	if m, ok := err.(*multierror.MultiError); ok {
+		for _, e := range m.Errors {
+			msgs = append(msgs, e.Error())
+		}
+	}
+	return msgs
+}
+
+func TestReadValid(t *testing.T) {
+	config := &validatedConfig{}
+	err := readString(t, `
+section:
+  name: test
+  port: 80
+items:
+  - enabled: true
+    value: 1
+named:
+  a:
+    enabled: true
+other:
+  ignored: true
+`, config)
+	assert.NoError(t, err)
+	assert.Equal(t, 80, config.Section.Common.Port)
+}
+
+func TestReadUnknownSettings(t *testing.T) {
+	err := readString(t, `
+section:
+  nmae: test
+items:
+  - enabled: true
+    valeu: 1
+named:
+  a:
+    enabled: true
+    foo: bar
+`, &validatedConfig{})
+
+	msgs := errorMessages(err)
+	if assert.Len(t, msgs, 3) {
+		assert.Contains(t, msgs[0], "config.yml:6: unknown setting 'items.0.valeu'")
+		assert.Contains(t, msgs[1], "config.yml:10: unknown setting 'named.a.foo'")
+		assert.Contains(t, msgs[2], "config.yml:3: unknown setting 'section.nmae'")
+	}
+}
+
+func TestReadValidateErrors(t *testing.T) {
+	err := readString(t, `
+section:
+  port: -1
+items:
+  - enabled: true
+  - enabled: false
+named:
+  a:
+    enabled: false
+  b:
+    enabled: true
+    value: abc
+`, &validatedConfig{})
+
+	msgs := errorMessages(err)
+	if assert.Len(t, msgs, 4) {
+		assert.Contains(t, msgs[0], "config.yml:12: named.b.value: cannot unmarshal !!str `abc` into int")
+		assert.Contains(t, msgs[1], "config.yml:2: section: port must not be negative")
+		assert.Contains(t, msgs[2], "config.yml:6: items.1: item disabled")
+		assert.Contains(t, msgs[3], "config.yml:8: named.a: item disabled")
+	}
+}
+
+func TestReadPointer(t *testing.T) {
+	// beats read into a nil pointer to their configuration struct
+	var config *validatedConfig
+	err := readString(t, `
+section:
+  port: -1
+items:
+  - enabled: false
+    value: abc
+`, &config)
+
+	msgs := errorMessages(err)
+	if assert.Len(t, msgs, 3) {
+		assert.Contains(t, msgs[0], "config.yml:6: items.0.value: cannot unmarshal !!str `abc` into int")
+		assert.Contains(t, msgs[1], "config.yml:2: section: port must not be negative")
+		assert.Contains(t, msgs[2], "config.yml:5: items.0: item disabled")
+	}
+}
+
+func TestReadOverwriteTypeError(t *testing.T) {
+	absPath, err := filepath.Abs("../tests/files/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldConfigfile, oldOverwrites, oldSource := *configfile, overwrites, defaultSource
+	defer func() {
+		*configfile, overwrites, defaultSource = oldConfigfile, oldOverwrites, oldSource
+	}()
+
+	*configfile = absPath + "/config.yml"
+	overwrites = nil
+	overwrites.Set("output.elasticsearch.port=abc")
+
+	err = Read(&TestConfig{}, "")
+	msgs := errorMessages(err)
+	if assert.Len(t, msgs, 1) {
+		assert.Equal(t, "-E output.elasticsearch.port: cannot unmarshal !!str `abc` into int",
+			msgs[0])
+	}
+}
+
+func TestUnpackSection(t *testing.T) {
+	raw := map[interface{}]interface{}{
+		"enabled": true,
+		"valeu":   1,
+	}
+
+	var item validatedItem
+	err := UnpackSection(raw, &item, "named.x")
+	msgs := errorMessages(err)
+	if assert.Len(t, msgs, 1) {
+		assert.Contains(t, msgs[0], "unknown setting 'named.x.valeu'")
+	}
+
+	item = validatedItem{}
+	err = UnpackSection(nil, &item, "named.x")
+	msgs = errorMessages(err)
+	if assert.Len(t, msgs, 1) {
+		assert.Contains(t, msgs[0], "named.x: item disabled")
+	}
+}
+
+func TestCheckUnknownSections(t *testing.T) {
+	absPath, err := filepath.Abs("../tests/files/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldConfigfile, oldSource := *configfile, defaultSource
+	defer func() {
+		*configfile, defaultSource = oldConfigfile, oldSource
+	}()
+	*configfile = absPath + "/config.yml"
+	defaultSource.claimed = nil
+
+	err = Read(&struct{ Shipper struct{} }{}, "")
+	assert.NoError(t, err)
+	msgs := errorMessages(CheckUnknownSections())
+	if assert.Len(t, msgs, 1) {
+		assert.Contains(t, msgs[0], "config.yml:1: unknown setting 'output'")
+	}
+
+	err = Read(&TestConfig{}, "")
+	assert.NoError(t, err)
+	assert.NoError(t, CheckUnknownSections())
+}
+
+func TestFindSetting(t *testing.T) {
+	content := []byte(`
+# comment
+output:
+  elasticsearch:
+    hosts: ["localhost:9200"]
+
+  "logstash":
+    hosts:
+      - localhost:5044
+filebeat:
+  prospectors:
+    -
+      paths:
+        - /var/log/*.log
+    - paths: ["/tmp/*.log"]
+      input_type: log
+`)
+
+	tests := []struct {
+		path string
+		line int
+	}{
+		{"output", 3},
+		{"output.elasticsearch.hosts", 5},
+		{"output.logstash", 7},
+		{"output.logstash.hosts.0", 9},
+		{"filebeat.prospectors.0", 12},
+		{"filebeat.prospectors.0.paths", 13},
+		{"filebeat.prospectors.1.paths", 15},
+		{"filebeat.prospectors.1.input_type", 16},
+		{"filebeat.spool_size", 0},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.line, findSetting(content, strings.Split(test.path, ".")), test.path)
+	}
+}
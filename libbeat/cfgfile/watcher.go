@@ -0,0 +1,119 @@
+package cfgfile
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+// Watcher periodically checks configuration files for changes. Files are
+// compared by size and modification time, such that added and removed files
+// are detected as well.
+type Watcher struct {
+	period time.Duration
+	done   chan struct{}
+
+	mutex sync.Mutex
+	paths []string
+	files map[string]fileInfo
+}
+
+type fileInfo struct {
+	size    int64
+	modTime time.Time
+}
+
+// DefaultWatchPeriod is used by watchers created without positive period.
+const DefaultWatchPeriod = 10 * time.Second
+
+// NewWatcher creates a watcher checking the files found at paths every
+// period. Paths can be files, directories or glob patterns as accepted by
+// ConfigFiles.
+func NewWatcher(paths []string, period time.Duration) *Watcher {
+	if period <= 0 {
+		logp.Warn("Invalid watch period %v, checking for changes every %v",
+			period, DefaultWatchPeriod)
+		period = DefaultWatchPeriod
+	}
+
+	w := &Watcher{
+		period: period,
+		done:   make(chan struct{}),
+	}
+	w.SetPaths(paths)
+	return w
+}
+
+// SetPaths replaces the paths being watched. The current state of the files
+// is used as reference for the next check.
+func (w *Watcher) SetPaths(paths []string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.paths = paths
+	w.files = scanFiles(paths)
+}
+
+// Run calls onChange every time a change is detected. It blocks until Stop
+// is called.
+func (w *Watcher) Run(onChange func()) {
+	ticker := time.NewTicker(w.period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+		}
+
+		if w.changed() {
+			logp.Info("Configuration change detected")
+			onChange()
+		}
+	}
+}
+
+// Stop stops the watcher.
+func (w *Watcher) Stop() {
+	close(w.done)
+}
+
+// changed checks the files for changes and updates the reference state.
+func (w *Watcher) changed() bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	files := scanFiles(w.paths)
+	changed := len(files) != len(w.files)
+	for path, info := range files {
+		if old, exists := w.files[path]; !exists || old != info {
+			changed = true
+		}
+	}
+	w.files = files
+	return changed
+}
+
+// scanFiles returns the size and modification time of all files at paths.
+func scanFiles(paths []string) map[string]fileInfo {
+	files := map[string]fileInfo{}
+	for _, path := range paths {
+		matches, err := ConfigFiles(path)
+		if err != nil {
+			logp.Debug("cfgfile", "Failed to watch %s: %v", path, err)
+			continue
+		}
+
+		for _, file := range matches {
+			stat, err := os.Stat(file)
+			if err != nil {
+				continue
+			}
+			files[file] = fileInfo{size: stat.Size(), modTime: stat.ModTime()}
+		}
+	}
+	return files
+}
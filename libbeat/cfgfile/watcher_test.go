@@ -0,0 +1,91 @@
+package cfgfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatcherChanged(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "a.yml")
+	assert.NoError(t, ioutil.WriteFile(file, []byte("a: 1\n"), 0644))
+
+	w := NewWatcher([]string{dir}, time.Second)
+	assert.False(t, w.changed())
+
+	// modified file
+	assert.NoError(t, ioutil.WriteFile(file, []byte("a: 10\n"), 0644))
+	assert.True(t, w.changed())
+	assert.False(t, w.changed())
+
+	// added file
+	other := filepath.Join(dir, "b.yml")
+	assert.NoError(t, ioutil.WriteFile(other, []byte("b: 1\n"), 0644))
+	assert.True(t, w.changed())
+
+	// files not matching the pattern are ignored
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "c.txt"), nil, 0644))
+	assert.False(t, w.changed())
+
+	// removed file
+	assert.NoError(t, os.Remove(other))
+	assert.True(t, w.changed())
+}
+
+func TestWatcherRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	w := NewWatcher([]string{filepath.Join(dir, "*.yml")}, 10*time.Millisecond)
+	changes := make(chan struct{}, 1)
+	go w.Run(func() { changes <- struct{}{} })
+	defer w.Stop()
+
+	file := filepath.Join(dir, "a.yml")
+	assert.NoError(t, ioutil.WriteFile(file, []byte("a: 1\n"), 0644))
+
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("change not detected")
+	}
+}
+
+func TestNewWatcherPeriod(t *testing.T) {
+	assert.Equal(t, DefaultWatchPeriod, NewWatcher(nil, 0).period)
+	assert.Equal(t, DefaultWatchPeriod, NewWatcher(nil, -time.Second).period)
+	assert.Equal(t, time.Second, NewWatcher(nil, time.Second).period)
+}
+
+func TestLoadedFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "loaded")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	main := filepath.Join(dir, "main.yml")
+	included := filepath.Join(dir, "included.yml")
+	assert.NoError(t, ioutil.WriteFile(main, []byte("include: included.yml\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(included, []byte("output: {}\n"), 0644))
+
+	defer func(path string) { *configfile = path }(*configfile)
+	*configfile = main
+
+	config := &TestConfig{}
+	assert.NoError(t, Read(config, ""))
+	assert.Equal(t, []string{main, included}, LoadedFiles())
+}
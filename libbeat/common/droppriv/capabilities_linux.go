@@ -0,0 +1,57 @@
+package droppriv
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// checkCapabilities returns an error if any thread lacks an effective
+// capability of mask.
+func checkCapabilities(mask uint64) error {
+	paths, err := filepath.Glob("/proc/self/task/*/status")
+	if err != nil || len(paths) == 0 {
+		return fmt.Errorf("Failed to read capabilities: %v", err)
+	}
+
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			// thread exited
+			continue
+		}
+		effective, err := readEffectiveCapabilities(file)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("Failed to read capabilities from %s: %v", path, err)
+		}
+
+		if missing := mask &^ effective; missing != 0 {
+			return fmt.Errorf("Capabilities not available: %s",
+				capabilityList(missing))
+		}
+	}
+	return nil
+}
+
+// readEffectiveCapabilities returns the CapEff mask of a status file in /proc.
+func readEffectiveCapabilities(r io.Reader) (uint64, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "CapEff:") {
+			continue
+		}
+		value := strings.TrimSpace(strings.TrimPrefix(line, "CapEff:"))
+		return strconv.ParseUint(value, 16, 64)
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, errors.New("CapEff not found")
+}
@@ -0,0 +1,238 @@
+// +build linux,cgo
+
+package droppriv
+
+/*
+#define _GNU_SOURCE
+#include <dirent.h>
+#include <errno.h>
+#include <signal.h>
+#include <stdint.h>
+#include <stdlib.h>
+#include <string.h>
+#include <sys/syscall.h>
+#include <time.h>
+#include <unistd.h>
+
+// Capabilities and the keep capabilities flag are per thread attributes. The
+// Go runtime can not change them on all threads of binaries linked with cgo,
+// so the syscalls are broadcast to all threads by signaling each thread,
+// which runs the syscall in the signal handler.
+
+#define DROPPRIV_MAX_THREADS 4096
+#define DROPPRIV_MAX_ROUNDS 16
+#define DROPPRIV_TIMEOUT_MS 2000
+
+#define DROPPRIV_PENDING 0
+#define DROPPRIV_DONE 1
+
+static long bc_nr, bc_a1, bc_a2, bc_a3;
+static pid_t bc_tids[DROPPRIV_MAX_THREADS];
+static int bc_state[DROPPRIV_MAX_THREADS];
+static int bc_count;
+static int bc_errno;
+
+static void droppriv_handler(int sig) {
+	int saved = errno;
+	pid_t tid = (pid_t)syscall(SYS_gettid);
+	int count = __atomic_load_n(&bc_count, __ATOMIC_SEQ_CST);
+	int i;
+
+	if (syscall(bc_nr, bc_a1, bc_a2, bc_a3) != 0) {
+		int expected = 0;
+		__atomic_compare_exchange_n(&bc_errno, &expected, errno, 0,
+			__ATOMIC_SEQ_CST, __ATOMIC_SEQ_CST);
+	}
+
+	for (i = 0; i < count; i++) {
+		if (bc_tids[i] == tid) {
+			__atomic_store_n(&bc_state[i], DROPPRIV_DONE, __ATOMIC_SEQ_CST);
+			break;
+		}
+	}
+	errno = saved;
+}
+
+static int droppriv_known(pid_t tid) {
+	int i;
+	for (i = 0; i < bc_count; i++) {
+		if (bc_tids[i] == tid) {
+			return 1;
+		}
+	}
+	return 0;
+}
+
+// droppriv_signal_new signals all threads not signaled yet. It returns the
+// number of threads signaled or -1 with errno set.
+static int droppriv_signal_new(pid_t pid, pid_t self, int sig) {
+	DIR *dir;
+	struct dirent *entry;
+	int signaled = 0;
+
+	dir = opendir("/proc/self/task");
+	if (dir == NULL) {
+		return -1;
+	}
+
+	while ((entry = readdir(dir)) != NULL) {
+		pid_t tid = (pid_t)atoi(entry->d_name);
+		int i;
+		if (tid <= 0 || tid == self || droppriv_known(tid)) {
+			continue;
+		}
+		if (bc_count == DROPPRIV_MAX_THREADS) {
+			closedir(dir);
+			errno = EAGAIN;
+			return -1;
+		}
+
+		i = bc_count;
+		bc_tids[i] = tid;
+		__atomic_store_n(&bc_state[i], DROPPRIV_PENDING, __ATOMIC_SEQ_CST);
+		__atomic_store_n(&bc_count, i + 1, __ATOMIC_SEQ_CST);
+
+		if (syscall(SYS_tgkill, pid, tid, sig) != 0) {
+			// thread exited in the meantime
+			__atomic_store_n(&bc_state[i], DROPPRIV_DONE, __ATOMIC_SEQ_CST);
+			continue;
+		}
+		signaled++;
+	}
+	closedir(dir);
+	return signaled;
+}
+
+// droppriv_wait waits for all signaled threads to run the syscall.
+static int droppriv_wait(void) {
+	struct timespec pause = {0, 1000000};
+	int waited, i;
+
+	for (waited = 0; waited < DROPPRIV_TIMEOUT_MS; waited++) {
+		int pending = 0;
+		for (i = 0; i < bc_count; i++) {
+			if (__atomic_load_n(&bc_state[i], __ATOMIC_SEQ_CST) == DROPPRIV_PENDING) {
+				pending = 1;
+				break;
+			}
+		}
+		if (!pending) {
+			return 0;
+		}
+		nanosleep(&pause, NULL);
+	}
+	return ETIMEDOUT;
+}
+
+// droppriv_broadcast runs the syscall on the calling thread and, if it
+// succeeds, on all other threads of the process. Threads started while
+// broadcasting are signaled as well. It returns 0 or an errno value.
+static int droppriv_broadcast(long nr, long a1, long a2, long a3) {
+	struct sigaction action, saved;
+	pid_t pid = getpid();
+	pid_t self = (pid_t)syscall(SYS_gettid);
+	int sig = SIGRTMAX - 2;
+	int err = 0;
+	int round;
+
+	if (syscall(nr, a1, a2, a3) != 0) {
+		return errno;
+	}
+
+	bc_nr = nr;
+	bc_a1 = a1;
+	bc_a2 = a2;
+	bc_a3 = a3;
+	bc_count = 0;
+	bc_errno = 0;
+
+	memset(&action, 0, sizeof(action));
+	action.sa_handler = droppriv_handler;
+	action.sa_flags = SA_ONSTACK | SA_RESTART;
+	sigfillset(&action.sa_mask);
+	if (sigaction(sig, &action, &saved) != 0) {
+		return errno;
+	}
+
+	// threads created by threads not changed yet are found in the next round
+	for (round = 0; round < DROPPRIV_MAX_ROUNDS; round++) {
+		int signaled = droppriv_signal_new(pid, self, sig);
+		if (signaled < 0) {
+			err = errno;
+			break;
+		}
+		if ((err = droppriv_wait()) != 0) {
+			break;
+		}
+		if (signaled == 0) {
+			break;
+		}
+	}
+	if (err == 0 && round == DROPPRIV_MAX_ROUNDS) {
+		err = EAGAIN;
+	}
+
+	sigaction(sig, &saved, NULL);
+
+	if (err == 0) {
+		err = __atomic_load_n(&bc_errno, __ATOMIC_SEQ_CST);
+	}
+	return err;
+}
+
+// capability header version supporting 64 bit capability sets
+#define DROPPRIV_CAPABILITY_VERSION_3 0x20080522
+#define DROPPRIV_PR_SET_KEEPCAPS 8
+
+struct droppriv_cap_header {
+	uint32_t version;
+	int pid;
+};
+
+struct droppriv_cap_data {
+	uint32_t effective;
+	uint32_t permitted;
+	uint32_t inheritable;
+};
+
+static struct droppriv_cap_header cap_header;
+static struct droppriv_cap_data cap_data[2];
+
+static int droppriv_keepcaps(int keep) {
+	return droppriv_broadcast(SYS_prctl, DROPPRIV_PR_SET_KEEPCAPS, keep, 0);
+}
+
+static int droppriv_capset(uint64_t mask) {
+	cap_header.version = DROPPRIV_CAPABILITY_VERSION_3;
+	cap_header.pid = 0;
+	memset(cap_data, 0, sizeof(cap_data));
+	cap_data[0].effective = cap_data[0].permitted = (uint32_t)mask;
+	cap_data[1].effective = cap_data[1].permitted = (uint32_t)(mask >> 32);
+	return droppriv_broadcast(SYS_capset, (long)&cap_header, (long)cap_data, 0);
+}
+*/
+import "C"
+
+import "syscall"
+
+// keepCapabilities sets whether the permitted capabilities are kept when
+// switching from root to another user.
+func keepCapabilities(keep bool) error {
+	var value C.int
+	if keep {
+		value = 1
+	}
+	return capabilityError(C.droppriv_keepcaps(value))
+}
+
+// setCapabilities reduces the effective and permitted capabilities to mask.
+func setCapabilities(mask uint64) error {
+	return capabilityError(C.droppriv_capset(C.uint64_t(mask)))
+}
+
+func capabilityError(errno C.int) error {
+	if errno == 0 {
+		return nil
+	}
+	return syscall.Errno(errno)
+}
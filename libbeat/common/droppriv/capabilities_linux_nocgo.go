@@ -0,0 +1,56 @@
+// +build linux,!cgo
+
+package droppriv
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// Capabilities are per thread attributes, they are changed on all threads of
+// the Go runtime by AllThreadsSyscall.
+
+// capability header version supporting 64 bit capability sets
+const linuxCapabilityVersion3 = 0x20080522
+
+type capHeader struct {
+	version uint32
+	pid     int32
+}
+
+type capData struct {
+	effective   uint32
+	permitted   uint32
+	inheritable uint32
+}
+
+// keepCapabilities sets whether the permitted capabilities are kept when
+// switching from root to another user.
+func keepCapabilities(keep bool) error {
+	var value uintptr
+	if keep {
+		value = 1
+	}
+	_, _, errno := syscall.AllThreadsSyscall(syscall.SYS_PRCTL,
+		syscall.PR_SET_KEEPCAPS, value, 0)
+	return capabilityError(errno)
+}
+
+// setCapabilities reduces the effective and permitted capabilities to mask.
+func setCapabilities(mask uint64) error {
+	header := capHeader{version: linuxCapabilityVersion3}
+	data := [2]capData{
+		{effective: uint32(mask), permitted: uint32(mask)},
+		{effective: uint32(mask >> 32), permitted: uint32(mask >> 32)},
+	}
+	_, _, errno := syscall.AllThreadsSyscall(syscall.SYS_CAPSET,
+		uintptr(unsafe.Pointer(&header)), uintptr(unsafe.Pointer(&data[0])), 0)
+	return capabilityError(errno)
+}
+
+func capabilityError(errno syscall.Errno) error {
+	if errno == 0 {
+		return nil
+	}
+	return errno
+}
@@ -0,0 +1,93 @@
+package droppriv
+
+import (
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadEffectiveCapabilities(t *testing.T) {
+	status := "Name:\tpacketbeat\nUid:\t501\t501\t501\t501\n" +
+		"CapInh:\t0000000000000000\nCapPrm:\t0000000000003000\n" +
+		"CapEff:\t0000000000003000\n"
+
+	mask, err := readEffectiveCapabilities(strings.NewReader(status))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1<<12|1<<13), mask)
+
+	_, err = readEffectiveCapabilities(strings.NewReader("Name:\tpacketbeat\n"))
+	assert.Error(t, err)
+}
+
+// threadKeepCapabilities returns the keep capabilities flag of count threads.
+func threadKeepCapabilities(count int) []uintptr {
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	flags := make([]uintptr, count)
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			runtime.LockOSThread()
+			<-start
+			flags[i], _, _ = syscall.RawSyscall(syscall.SYS_PRCTL,
+				syscall.PR_GET_KEEPCAPS, 0, 0)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+	return flags
+}
+
+func TestKeepCapabilitiesAllThreads(t *testing.T) {
+	const threads = 20
+
+	// start the threads before changing the flag, locked goroutines
+	// terminate their thread on exit
+	ready := make(chan struct{})
+	done := make(chan struct{})
+	for i := 0; i < threads; i++ {
+		go func() {
+			runtime.LockOSThread()
+			ready <- struct{}{}
+			<-done
+		}()
+	}
+	for i := 0; i < threads; i++ {
+		<-ready
+	}
+
+	if !assert.NoError(t, keepCapabilities(true)) {
+		return
+	}
+	close(done)
+	for _, flag := range threadKeepCapabilities(threads) {
+		assert.Equal(t, uintptr(1), flag)
+	}
+
+	assert.NoError(t, keepCapabilities(false))
+	for _, flag := range threadKeepCapabilities(threads) {
+		assert.Equal(t, uintptr(0), flag)
+	}
+}
+
+func TestSetCapabilitiesAllThreads(t *testing.T) {
+	file, err := os.Open("/proc/self/status")
+	if err != nil {
+		t.Skip(err)
+	}
+	effective, err := readEffectiveCapabilities(file)
+	file.Close()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	if assert.NoError(t, setCapabilities(effective)) {
+		assert.NoError(t, checkCapabilities(effective))
+	}
+}
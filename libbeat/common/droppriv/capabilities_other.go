@@ -0,0 +1,19 @@
+// +build !linux,!windows
+
+package droppriv
+
+import "errors"
+
+var errCapabilities = errors.New("Retaining capabilities is only supported on Linux")
+
+func keepCapabilities(keep bool) error {
+	return errCapabilities
+}
+
+func setCapabilities(mask uint64) error {
+	return errCapabilities
+}
+
+func checkCapabilities(mask uint64) error {
+	return errCapabilities
+}
@@ -0,0 +1,131 @@
+// Package droppriv switches the beat to an unprivileged user after the
+// privileged initialization, optionally retaining a set of Linux capabilities.
+package droppriv
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/joeshaw/multierror"
+)
+
+// RunOptions configures the user and group the beat switches to after the
+// initialization. User and Group accept names as well as numeric IDs.
+type RunOptions struct {
+	Uid   *int
+	Gid   *int
+	User  string
+	Group string
+
+	// Linux capabilities retained after switching users, e.g. CAP_NET_RAW.
+	// If no user is configured, the capabilities must be available already.
+	Capabilities []string
+}
+
+// Validate checks the run options and returns an error describing all
+// problems or nil if there are none.
+func (c RunOptions) Validate() error {
+	var errs multierror.Errors
+
+	if c.Uid != nil && c.User != "" {
+		errs = append(errs, errors.New("uid and user must not be set both"))
+	}
+	if c.Gid != nil && c.Group != "" {
+		errs = append(errs, errors.New("gid and group must not be set both"))
+	}
+	if c.Uid != nil && *c.Uid < 0 {
+		errs = append(errs, fmt.Errorf("uid must not be negative but was '%d'", *c.Uid))
+	}
+	if c.Gid != nil && *c.Gid < 0 {
+		errs = append(errs, fmt.Errorf("gid must not be negative but was '%d'", *c.Gid))
+	}
+	if !c.switchUser() && (c.Gid != nil || c.Group != "") {
+		errs = append(errs, errors.New("gid and group require uid or user to be set"))
+	}
+
+	if _, err := parseCapabilities(c.Capabilities); err != nil {
+		errs = append(errs, err)
+	}
+	return errs.Err()
+}
+
+// switchUser returns true if a user to switch to is configured.
+func (c RunOptions) switchUser() bool {
+	return c.Uid != nil || c.User != ""
+}
+
+// capabilities by name as defined in linux/capability.h
+var capabilityNames = map[string]uint{
+	"chown":              0,
+	"dac_override":       1,
+	"dac_read_search":    2,
+	"fowner":             3,
+	"fsetid":             4,
+	"kill":               5,
+	"setgid":             6,
+	"setuid":             7,
+	"setpcap":            8,
+	"linux_immutable":    9,
+	"net_bind_service":   10,
+	"net_broadcast":      11,
+	"net_admin":          12,
+	"net_raw":            13,
+	"ipc_lock":           14,
+	"ipc_owner":          15,
+	"sys_module":         16,
+	"sys_rawio":          17,
+	"sys_chroot":         18,
+	"sys_ptrace":         19,
+	"sys_pacct":          20,
+	"sys_admin":          21,
+	"sys_boot":           22,
+	"sys_nice":           23,
+	"sys_resource":       24,
+	"sys_time":           25,
+	"sys_tty_config":     26,
+	"mknod":              27,
+	"lease":              28,
+	"audit_write":        29,
+	"audit_control":      30,
+	"setfcap":            31,
+	"mac_override":       32,
+	"mac_admin":          33,
+	"syslog":             34,
+	"wake_alarm":         35,
+	"block_suspend":      36,
+	"audit_read":         37,
+	"perfmon":            38,
+	"bpf":                39,
+	"checkpoint_restore": 40,
+}
+
+// parseCapabilities returns the bit mask of the named capabilities. Names are
+// case insensitive and the CAP_ prefix is optional.
+func parseCapabilities(names []string) (uint64, error) {
+	var mask uint64
+	var errs multierror.Errors
+	for _, name := range names {
+		key := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(name)), "cap_")
+		bit, exists := capabilityNames[key]
+		if !exists {
+			errs = append(errs, fmt.Errorf("Unknown capability '%s'", name))
+			continue
+		}
+		mask |= 1 << bit
+	}
+	return mask, errs.Err()
+}
+
+// capabilityList returns the names of the capabilities in mask.
+func capabilityList(mask uint64) string {
+	var names []string
+	for name, bit := range capabilityNames {
+		if mask&(1<<bit) != 0 {
+			names = append(names, "CAP_"+strings.ToUpper(name))
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
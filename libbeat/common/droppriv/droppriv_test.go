@@ -0,0 +1,47 @@
+package droppriv
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunOptionsValidate(t *testing.T) {
+	id := 501
+	negative := -1
+	var tests = []struct {
+		config RunOptions
+		err    string
+	}{
+		{RunOptions{}, ""},
+		{RunOptions{Uid: &id, Gid: &id}, ""},
+		{RunOptions{User: "packetbeat", Group: "pcap"}, ""},
+		{RunOptions{User: "501", Capabilities: []string{"CAP_NET_RAW", "net_admin"}}, ""},
+		{RunOptions{Capabilities: []string{"cap_net_raw"}}, ""},
+		{RunOptions{Uid: &id, User: "packetbeat"}, "uid and user"},
+		{RunOptions{User: "packetbeat", Gid: &id, Group: "pcap"}, "gid and group"},
+		{RunOptions{Uid: &negative}, "uid must not be negative"},
+		{RunOptions{Group: "pcap"}, "require uid or user"},
+		{RunOptions{User: "packetbeat", Capabilities: []string{"CAP_RAW"}}, "Unknown capability 'CAP_RAW'"},
+	}
+
+	for _, test := range tests {
+		err := test.config.Validate()
+		if test.err == "" {
+			assert.NoError(t, err, "%+v", test.config)
+		} else if assert.Error(t, err, "%+v", test.config) {
+			assert.Contains(t, err.Error(), test.err)
+		}
+	}
+}
+
+func TestParseCapabilities(t *testing.T) {
+	mask, err := parseCapabilities([]string{"CAP_NET_RAW", "net_admin", " Cap_Bpf "})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1<<13|1<<12|1<<39), mask)
+	assert.Equal(t, "CAP_BPF, CAP_NET_ADMIN, CAP_NET_RAW", capabilityList(mask))
+
+	mask, err = parseCapabilities(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), mask)
+}
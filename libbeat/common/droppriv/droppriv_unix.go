@@ -5,37 +5,140 @@ package droppriv
 import (
 	"errors"
 	"fmt"
+	"os/user"
+	"strconv"
 	"syscall"
 
 	"github.com/elastic/beats/libbeat/logp"
 )
 
-type RunOptions struct {
-	Uid *int
-	Gid *int
+// credentials are the IDs the process switches to.
+type credentials struct {
+	uid    int
+	gid    int
+	groups []int // supplementary groups
 }
 
+// DropPrivileges switches to the configured user and group, setting the
+// supplementary groups of the user. The configured capabilities are retained
+// across the switch. An error is returned if the capabilities can not be
+// kept, in which case the beat must not continue.
 func DropPrivileges(config RunOptions) error {
-	var err error
+	caps, err := parseCapabilities(config.Capabilities)
+	if err != nil {
+		return err
+	}
 
-	if config.Uid == nil {
+	if !config.switchUser() {
 		// not found, no dropping privileges but no err
+		if caps != 0 {
+			return checkCapabilities(caps)
+		}
 		return nil
 	}
 
-	if config.Gid == nil {
-		return errors.New("GID must be specified for dropping privileges")
+	creds, err := lookupCredentials(config)
+	if err != nil {
+		return err
+	}
+
+	logp.Info("Switching to user: %d.%d", creds.uid, creds.gid)
+
+	if caps != 0 {
+		if err = keepCapabilities(true); err != nil {
+			return fmt.Errorf("Failed to retain capabilities: %v", err)
+		}
 	}
 
-	logp.Info("Switching to user: %d.%d", config.Uid, config.Gid)
+	if err = syscall.Setgroups(creds.groups); err != nil {
+		return fmt.Errorf("setgroups: %s", err.Error())
+	}
 
-	if err = syscall.Setgid(*config.Gid); err != nil {
+	if err = syscall.Setgid(creds.gid); err != nil {
 		return fmt.Errorf("setgid: %s", err.Error())
 	}
 
-	if err = syscall.Setuid(*config.Uid); err != nil {
+	if err = syscall.Setuid(creds.uid); err != nil {
 		return fmt.Errorf("setuid: %s", err.Error())
 	}
 
-	return nil
+	if caps == 0 {
+		return nil
+	}
+
+	if err = keepCapabilities(false); err != nil {
+		return fmt.Errorf("Failed to retain capabilities: %v", err)
+	}
+	if err = setCapabilities(caps); err != nil {
+		return fmt.Errorf("Failed to set capabilities %s: %v",
+			capabilityList(caps), err)
+	}
+	logp.Info("Retained capabilities: %s", capabilityList(caps))
+	return checkCapabilities(caps)
+}
+
+// lookupCredentials resolves the configured user and group names. If no
+// group is configured the primary group of the user is used. The
+// supplementary groups are the groups of the user, or the group only if the
+// user is unknown.
+func lookupCredentials(config RunOptions) (credentials, error) {
+	var creds credentials
+	var account *user.User
+
+	switch {
+	case config.Uid != nil:
+		creds.uid = *config.Uid
+		account, _ = user.LookupId(strconv.Itoa(creds.uid))
+	case isNumeric(config.User):
+		creds.uid, _ = strconv.Atoi(config.User)
+		account, _ = user.LookupId(config.User)
+	default:
+		var err error
+		account, err = user.Lookup(config.User)
+		if err != nil {
+			return creds, fmt.Errorf("Unknown user '%s': %v", config.User, err)
+		}
+		creds.uid, _ = strconv.Atoi(account.Uid)
+	}
+
+	switch {
+	case config.Gid != nil:
+		creds.gid = *config.Gid
+	case isNumeric(config.Group):
+		creds.gid, _ = strconv.Atoi(config.Group)
+	case config.Group != "":
+		group, err := user.LookupGroup(config.Group)
+		if err != nil {
+			return creds, fmt.Errorf("Unknown group '%s': %v", config.Group, err)
+		}
+		creds.gid, _ = strconv.Atoi(group.Gid)
+	case account != nil:
+		creds.gid, _ = strconv.Atoi(account.Gid)
+	default:
+		return creds, errors.New("GID must be specified for dropping privileges")
+	}
+
+	creds.groups = []int{creds.gid}
+	if account == nil {
+		return creds, nil
+	}
+
+	ids, err := account.GroupIds()
+	if err != nil {
+		return creds, fmt.Errorf("Failed to read groups of user '%s': %v",
+			account.Username, err)
+	}
+	for _, id := range ids {
+		gid, err := strconv.Atoi(id)
+		if err != nil || gid == creds.gid {
+			continue
+		}
+		creds.groups = append(creds.groups, gid)
+	}
+	return creds, nil
+}
+
+func isNumeric(s string) bool {
+	_, err := strconv.Atoi(s)
+	return err == nil
 }
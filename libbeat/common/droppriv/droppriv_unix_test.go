@@ -0,0 +1,32 @@
+// +build !windows
+
+package droppriv
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookupCredentials(t *testing.T) {
+	// numeric IDs of unknown users require a group
+	uid := 54321
+	_, err := lookupCredentials(RunOptions{Uid: &uid})
+	assert.Error(t, err)
+
+	creds, err := lookupCredentials(RunOptions{User: "54321", Group: "54322"})
+	assert.NoError(t, err)
+	assert.Equal(t, credentials{uid: 54321, gid: 54322, groups: []int{54322}}, creds)
+
+	_, err = lookupCredentials(RunOptions{User: "no-such-user-54321"})
+	assert.Error(t, err)
+
+	// the primary group of known users is used by default
+	creds, err = lookupCredentials(RunOptions{User: "root"})
+	if err != nil {
+		t.Skip("user root not found")
+	}
+	assert.Equal(t, 0, creds.uid)
+	assert.Equal(t, 0, creds.gid)
+	assert.Equal(t, 0, creds.groups[0])
+}
@@ -2,14 +2,9 @@ package droppriv
 
 import "errors"
 
-type RunOptions struct {
-	Uid *int
-	Gid *int
-}
-
 func DropPrivileges(config RunOptions) error {
 
-	if config.Uid == nil {
+	if !config.switchUser() && len(config.Capabilities) == 0 {
 		// not found, no dropping privileges but no err
 		return nil
 	}
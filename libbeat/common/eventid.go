@@ -0,0 +1,29 @@
+package common
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+)
+
+// EventIDField is the event field holding the ID of an event. Outputs
+// supporting document IDs use it to index events idempotently, so events
+// published more than once are stored only once.
+const EventIDField = "@id"
+
+// EventID derives a stable event ID from the given values, e.g. the source
+// and offset of a log line. The same values always result in the same ID.
+func EventID(values ...interface{}) string {
+	h := sha1.New()
+	for _, v := range values {
+		fmt.Fprintf(h, "%v\x00", v)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// EventIDOf returns the ID of an event or an empty string if the event has
+// no ID.
+func EventIDOf(event MapStr) string {
+	id, _ := event.GetString(EventIDField)
+	return id
+}
@@ -0,0 +1,23 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventID(t *testing.T) {
+	id := EventID("/var/log/messages", 42)
+	assert.Len(t, id, 40)
+	assert.Equal(t, id, EventID("/var/log/messages", 42))
+	assert.NotEqual(t, id, EventID("/var/log/messages", 43))
+
+	// value boundaries are part of the ID
+	assert.NotEqual(t, EventID("a", "bc"), EventID("ab", "c"))
+}
+
+func TestEventIDOf(t *testing.T) {
+	assert.Equal(t, "", EventIDOf(MapStr{}))
+	assert.Equal(t, "", EventIDOf(MapStr{EventIDField: 1}))
+	assert.Equal(t, "abc", EventIDOf(MapStr{EventIDField: "abc"}))
+}
@@ -0,0 +1,239 @@
+// Package fmtstr implements format strings evaluated against events, e.g.
+// for selecting the index an event is published to.
+//
+// A format string consists of literal text and format elements:
+//
+//	%{type}            value of the event field 'type'
+//	%{[beat.name]}     value of the nested field beat.name
+//	%{[beat][name]}    same as above
+//	%{+yyyy.MM.dd}     the event's @timestamp (in UTC) in the given format
+//
+// The timestamp format uses the Joda-Time pattern letters also used by
+// Logstash. Supported are yyyy, yy, M, MM, MMM, MMMM, d, dd, H, HH, m, mm,
+// s, ss and SSS. Other letters must be quoted with single quotes.
+package fmtstr
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+var (
+	// ErrMissingField indicates an event not containing a field referenced
+	// by the format string.
+	ErrMissingField = errors.New("missing field")
+
+	// ErrMissingTimestamp indicates an event without a valid @timestamp
+	// field, but the format string contains a timestamp element.
+	ErrMissingTimestamp = errors.New("missing or invalid @timestamp")
+)
+
+// EventFormatString is a compiled format string evaluated against events.
+type EventFormatString struct {
+	raw      string
+	elements []element
+
+	// set if at least one element requires the event's timestamp
+	needsTimestamp bool
+}
+
+// element is one part of a compiled format string.
+type element interface {
+	eval(ctx *evalContext) error
+}
+
+type evalContext struct {
+	buf   bytes.Buffer
+	event common.MapStr
+	ts    time.Time
+}
+
+type literalElement string
+
+type fieldElement struct {
+	path []string
+}
+
+type timestampElement struct {
+	format []timeFormatter
+}
+
+// CompileEvent compiles a format string. An error is returned if the syntax
+// of the format string or of a timestamp format is invalid.
+func CompileEvent(in string) (*EventFormatString, error) {
+	fs := &EventFormatString{raw: in}
+
+	rest := in
+	for len(rest) > 0 {
+		start := strings.Index(rest, "%{")
+		if start < 0 {
+			fs.elements = append(fs.elements, literalElement(rest))
+			break
+		}
+		if start > 0 {
+			fs.elements = append(fs.elements, literalElement(rest[:start]))
+		}
+
+		rest = rest[start+2:]
+		end := strings.IndexByte(rest, '}')
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated format element in '%s'", in)
+		}
+
+		elem, err := compileElement(rest[:end])
+		if err != nil {
+			return nil, fmt.Errorf("invalid format string '%s': %v", in, err)
+		}
+		if _, ok := elem.(*timestampElement); ok {
+			fs.needsTimestamp = true
+		}
+		fs.elements = append(fs.elements, elem)
+		rest = rest[end+1:]
+	}
+
+	return fs, nil
+}
+
+// MustCompileEvent is a convenience equivalent of the CompileEvent function
+// that panics in case of errors.
+func MustCompileEvent(in string) *EventFormatString {
+	fs, err := CompileEvent(in)
+	if err != nil {
+		panic(err)
+	}
+	return fs
+}
+
+func compileElement(in string) (element, error) {
+	if in == "" {
+		return nil, errors.New("empty format element")
+	}
+
+	if in[0] == '+' {
+		format, err := compileTimestampFormat(in[1:])
+		if err != nil {
+			return nil, err
+		}
+		return &timestampElement{format}, nil
+	}
+
+	path, err := parseFieldPath(in)
+	if err != nil {
+		return nil, err
+	}
+	return &fieldElement{path}, nil
+}
+
+// parseFieldPath parses field references of the form 'a.b', '[a.b]' or
+// '[a][b]' into the list of nested field names.
+func parseFieldPath(in string) ([]string, error) {
+	var path []string
+
+	if in[0] != '[' {
+		path = strings.Split(in, ".")
+	} else {
+		rest := in
+		for len(rest) > 0 {
+			if rest[0] != '[' {
+				return nil, fmt.Errorf("invalid field reference '%s'", in)
+			}
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("missing ']' in field reference '%s'", in)
+			}
+			path = append(path, strings.Split(rest[1:end], ".")...)
+			rest = rest[end+1:]
+		}
+	}
+
+	for _, name := range path {
+		if name == "" {
+			return nil, fmt.Errorf("empty field name in field reference '%s'", in)
+		}
+	}
+	return path, nil
+}
+
+// Run evaluates the format string against the event.
+func (fs *EventFormatString) Run(event common.MapStr) (string, error) {
+	ctx := &evalContext{event: event}
+	if fs.needsTimestamp {
+		ts, err := eventTimestamp(event)
+		if err != nil {
+			return "", err
+		}
+		ctx.ts = ts
+	}
+
+	for _, elem := range fs.elements {
+		if err := elem.eval(ctx); err != nil {
+			return "", err
+		}
+	}
+	return ctx.buf.String(), nil
+}
+
+// IsConst returns true if the format string contains no format elements,
+// that is evaluating the format string always returns the same value.
+func (fs *EventFormatString) IsConst() bool {
+	for _, elem := range fs.elements {
+		if _, ok := elem.(literalElement); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the original format string.
+func (fs *EventFormatString) String() string {
+	return fs.raw
+}
+
+func (e literalElement) eval(ctx *evalContext) error {
+	ctx.buf.WriteString(string(e))
+	return nil
+}
+
+func (e *fieldElement) eval(ctx *evalContext) error {
+	value, err := ctx.event.GetValue(strings.Join(e.path, "."))
+	if err != nil || value == nil {
+		return fmt.Errorf("%v '%s'", ErrMissingField, strings.Join(e.path, "."))
+	}
+
+	switch v := value.(type) {
+	case string:
+		ctx.buf.WriteString(v)
+	case common.Time:
+		ctx.buf.WriteString(time.Time(v).UTC().Format(common.TsLayout))
+	case time.Time:
+		ctx.buf.WriteString(v.UTC().Format(common.TsLayout))
+	case common.MapStr, map[string]interface{}, []interface{}:
+		return fmt.Errorf("field '%s' is no primitive value",
+			strings.Join(e.path, "."))
+	default:
+		fmt.Fprint(&ctx.buf, v)
+	}
+	return nil
+}
+
+func (e *timestampElement) eval(ctx *evalContext) error {
+	for _, f := range e.format {
+		f(&ctx.buf, ctx.ts)
+	}
+	return nil
+}
+
+func eventTimestamp(event common.MapStr) (time.Time, error) {
+	switch ts := event["@timestamp"].(type) {
+	case common.Time:
+		return time.Time(ts).UTC(), nil
+	case time.Time:
+		return ts.UTC(), nil
+	}
+	return time.Time{}, ErrMissingTimestamp
+}
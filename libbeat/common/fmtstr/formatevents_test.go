@@ -0,0 +1,104 @@
+package fmtstr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func testEvent() common.MapStr {
+	ts := time.Date(2015, 12, 3, 7, 5, 9, 42*int(time.Millisecond), time.UTC)
+	return common.MapStr{
+		"@timestamp": common.Time(ts),
+		"type":       "http",
+		"count":      1,
+		"beat": common.MapStr{
+			"name": "host1",
+		},
+		"fields": map[string]interface{}{
+			"env": "prod",
+		},
+	}
+}
+
+func TestEventFormatString(t *testing.T) {
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{"packetbeat", "packetbeat"},
+		{"", ""},
+		{"%{type}", "http"},
+		{"%{count}", "1"},
+		{"%{[beat.name]}-%{type}", "host1-http"},
+		{"%{[beat][name]}", "host1"},
+		{"%{beat.name}", "host1"},
+		{"%{[fields.env]}", "prod"},
+		{"packetbeat-%{+yyyy.MM.dd}", "packetbeat-2015.12.03"},
+		{"%{[beat.name]}-%{type}-%{+yyyy.MM}", "host1-http-2015.12"},
+		{"%{+yy-M-d H:m:s.SSS}", "15-12-3 7:5:9.042"},
+		{"%{+yyyy.MM.dd'T'HH}", "2015.12.03T07"},
+		{"%{+MMM MMMM}", "Dec December"},
+		{"%{+''yyyy''}", "'2015'"},
+		{"%{[@timestamp]}", "2015-12-03T07:05:09.042Z"},
+		{"100%", "100%"},
+	}
+
+	for _, test := range tests {
+		fs, err := CompileEvent(test.format)
+		if !assert.NoError(t, err, test.format) {
+			continue
+		}
+
+		actual, err := fs.Run(testEvent())
+		assert.NoError(t, err, test.format)
+		assert.Equal(t, test.expected, actual, test.format)
+	}
+}
+
+func TestEventFormatStringCompileErrors(t *testing.T) {
+	tests := []string{
+		"%{type",
+		"%{}",
+		"%{+}",
+		"%{+yyyy.ww}",
+		"%{+yyy}",
+		"%{+'yyyy}",
+		"%{[beat}",
+		"%{[beat]name}",
+		"%{[beat..name]}",
+	}
+
+	for _, format := range tests {
+		_, err := CompileEvent(format)
+		assert.Error(t, err, format)
+	}
+}
+
+func TestEventFormatStringRunErrors(t *testing.T) {
+	tests := []struct {
+		format string
+		event  common.MapStr
+	}{
+		{"%{missing}", testEvent()},
+		{"%{[beat.missing]}", testEvent()},
+		{"%{[type.name]}", testEvent()},
+		{"%{beat}", testEvent()},
+		{"%{+yyyy}", common.MapStr{"type": "http"}},
+	}
+
+	for _, test := range tests {
+		fs := MustCompileEvent(test.format)
+		_, err := fs.Run(test.event)
+		assert.Error(t, err, test.format)
+	}
+}
+
+func TestEventFormatStringIsConst(t *testing.T) {
+	assert.True(t, MustCompileEvent("packetbeat").IsConst())
+	assert.True(t, MustCompileEvent("").IsConst())
+	assert.False(t, MustCompileEvent("packetbeat-%{+yyyy}").IsConst())
+	assert.False(t, MustCompileEvent("%{type}").IsConst())
+}
@@ -0,0 +1,115 @@
+package fmtstr
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// timeFormatter appends one part of a formatted timestamp to the buffer.
+type timeFormatter func(buf *bytes.Buffer, t time.Time)
+
+// compileTimestampFormat compiles a Joda-Time style timestamp format like
+// 'yyyy.MM.dd'.
+func compileTimestampFormat(in string) ([]timeFormatter, error) {
+	var formatters []timeFormatter
+
+	for i := 0; i < len(in); {
+		c := in[i]
+
+		// quoted literal, '' is a single quote
+		if c == '\'' {
+			end := i + 1
+			for end < len(in) && in[end] != '\'' {
+				end++
+			}
+			if end == len(in) {
+				return nil, fmt.Errorf("unterminated quote in timestamp format '%s'", in)
+			}
+			literal := in[i+1 : end]
+			if literal == "" {
+				literal = "'"
+			}
+			formatters = append(formatters, formatLiteral(literal))
+			i = end + 1
+			continue
+		}
+
+		if !isLetter(c) {
+			formatters = append(formatters, formatLiteral(string(c)))
+			i++
+			continue
+		}
+
+		// run of the same pattern letter
+		n := 1
+		for i+n < len(in) && in[i+n] == c {
+			n++
+		}
+		f, err := timePatternFormatter(c, n)
+		if err != nil {
+			return nil, fmt.Errorf("%v in timestamp format '%s'", err, in)
+		}
+		formatters = append(formatters, f)
+		i += n
+	}
+
+	if len(formatters) == 0 {
+		return nil, fmt.Errorf("empty timestamp format")
+	}
+	return formatters, nil
+}
+
+func timePatternFormatter(c byte, n int) (timeFormatter, error) {
+	switch {
+	case c == 'y' && n == 2:
+		return formatNumber(func(t time.Time) int { return t.Year() % 100 }, 2), nil
+	case c == 'y' && n == 4:
+		return formatNumber(time.Time.Year, 4), nil
+	case c == 'M' && n <= 2:
+		return formatNumber(func(t time.Time) int { return int(t.Month()) }, n), nil
+	case c == 'M' && n == 3:
+		return func(buf *bytes.Buffer, t time.Time) {
+			buf.WriteString(t.Month().String()[:3])
+		}, nil
+	case c == 'M' && n == 4:
+		return func(buf *bytes.Buffer, t time.Time) {
+			buf.WriteString(t.Month().String())
+		}, nil
+	case c == 'd' && n <= 2:
+		return formatNumber(time.Time.Day, n), nil
+	case c == 'H' && n <= 2:
+		return formatNumber(time.Time.Hour, n), nil
+	case c == 'm' && n <= 2:
+		return formatNumber(time.Time.Minute, n), nil
+	case c == 's' && n <= 2:
+		return formatNumber(time.Time.Second, n), nil
+	case c == 'S' && n == 3:
+		return formatNumber(func(t time.Time) int {
+			return t.Nanosecond() / int(time.Millisecond)
+		}, 3), nil
+	}
+	return nil, fmt.Errorf("unsupported pattern '%s'", bytes.Repeat([]byte{c}, n))
+}
+
+func formatLiteral(s string) timeFormatter {
+	return func(buf *bytes.Buffer, t time.Time) {
+		buf.WriteString(s)
+	}
+}
+
+// formatNumber formats a time component zero padded to the given width.
+func formatNumber(get func(time.Time) int, width int) timeFormatter {
+	return func(buf *bytes.Buffer, t time.Time) {
+		s := strconv.Itoa(get(t))
+		for i := len(s); i < width; i++ {
+			buf.WriteByte('0')
+		}
+		buf.WriteString(s)
+	}
+}
+
+func isLetter(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
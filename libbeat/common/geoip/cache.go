@@ -0,0 +1,73 @@
+package geoip
+
+import (
+	"container/list"
+	"sync"
+)
+
+// lruCache keeps the locations of the most recently looked up IP addresses.
+// Addresses not found are cached as nil locations. A cache of size 0 stores
+// nothing.
+type lruCache struct {
+	sync.Mutex
+	size     int
+	elements map[string]*list.Element
+	order    *list.List // most recently used element first
+}
+
+type cacheEntry struct {
+	ip       string
+	location *Location
+}
+
+func newLRUCache(size int) *lruCache {
+	return &lruCache{
+		size:     size,
+		elements: make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// get returns the cached location of ip and true if ip is in the cache.
+func (c *lruCache) get(ip string) (*Location, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	element, found := c.elements[ip]
+	if !found {
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*cacheEntry).location, true
+}
+
+// add stores the location of ip, removing the least recently used entry if
+// the cache is full.
+func (c *lruCache) add(ip string, location *Location) {
+	if c.size <= 0 {
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	if element, found := c.elements[ip]; found {
+		element.Value.(*cacheEntry).location = location
+		c.order.MoveToFront(element)
+		return
+	}
+
+	if c.order.Len() >= c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.elements, oldest.Value.(*cacheEntry).ip)
+	}
+	c.elements[ip] = c.order.PushFront(&cacheEntry{ip: ip, location: location})
+}
+
+// len returns the number of cached addresses.
+func (c *lruCache) len() int {
+	c.Lock()
+	defer c.Unlock()
+	return c.order.Len()
+}
@@ -0,0 +1,252 @@
+package geoip
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// data types of the MaxMind DB data section
+type dataType int
+
+const (
+	typeExtended dataType = iota
+	typePointer
+	typeString
+	typeDouble
+	typeBytes
+	typeUint16
+	typeUint32
+	typeMap
+	typeInt32
+	typeUint64
+	typeUint128
+	typeArray
+	typeContainer
+	typeEndMarker
+	typeBool
+	typeFloat
+)
+
+// maximum nesting of maps and arrays, protecting against corrupt databases
+const maxDepth = 32
+
+var errUnexpectedEnd = errors.New("unexpected end of data")
+
+// decoder decodes the values stored in the data section of a MaxMind DB
+// file. Offsets are relative to the start of buffer.
+type decoder struct {
+	buffer []byte
+}
+
+// decode decodes the value at offset and returns it with the offset of the
+// next value.
+func (d *decoder) decode(offset uint, depth int) (interface{}, uint, error) {
+	if depth > maxDepth {
+		return nil, 0, errors.New("maximum data nesting exceeded")
+	}
+
+	typ, size, offset, err := d.decodeControl(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if typ == typePointer {
+		pointer, next, err := d.decodePointer(size, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		// a pointer must not point to another pointer
+		typ, size, offset, err := d.decodeControl(pointer)
+		if err != nil {
+			return nil, 0, err
+		}
+		if typ == typePointer {
+			return nil, 0, errors.New("pointer to pointer found")
+		}
+		value, _, err := d.decodeValue(typ, size, offset, depth)
+		return value, next, err
+	}
+
+	return d.decodeValue(typ, size, offset, depth)
+}
+
+// decodeControl decodes the control byte(s) at offset and returns the type
+// and size of the value and the offset of the value's payload. For pointers
+// the size bits of the control byte are returned unchanged.
+func (d *decoder) decodeControl(offset uint) (dataType, uint, uint, error) {
+	if offset >= uint(len(d.buffer)) {
+		return 0, 0, 0, errUnexpectedEnd
+	}
+	ctrl := d.buffer[offset]
+	offset++
+
+	typ := dataType(ctrl >> 5)
+	if typ == typeExtended {
+		if offset >= uint(len(d.buffer)) {
+			return 0, 0, 0, errUnexpectedEnd
+		}
+		typ = dataType(7 + uint(d.buffer[offset]))
+		offset++
+	}
+
+	size := uint(ctrl & 0x1f)
+	if typ == typePointer || size < 29 {
+		return typ, size, offset, nil
+	}
+
+	n := size - 28
+	if offset+n > uint(len(d.buffer)) {
+		return 0, 0, 0, errUnexpectedEnd
+	}
+	v := uint(readUint(d.buffer[offset : offset+n]))
+	offset += n
+
+	switch size {
+	case 29:
+		size = 29 + v
+	case 30:
+		size = 285 + v
+	default:
+		size = 65821 + v
+	}
+	return typ, size, offset, nil
+}
+
+// decodePointer decodes the pointer at offset given the size bits of its
+// control byte. It returns the offset pointed to and the offset of the next
+// value.
+func (d *decoder) decodePointer(size, offset uint) (uint, uint, error) {
+	n := (size>>3)&0x3 + 1
+	if offset+n > uint(len(d.buffer)) {
+		return 0, 0, errUnexpectedEnd
+	}
+
+	v := uint(readUint(d.buffer[offset : offset+n]))
+	switch n {
+	case 1:
+		v |= (size & 0x7) << 8
+	case 2:
+		v = (v | (size&0x7)<<16) + 2048
+	case 3:
+		v = (v | (size&0x7)<<24) + 526336
+	}
+	return v, offset + n, nil
+}
+
+func (d *decoder) decodeValue(
+	typ dataType,
+	size, offset uint,
+	depth int,
+) (interface{}, uint, error) {
+	switch typ {
+	case typeMap:
+		return d.decodeMap(size, offset, depth)
+	case typeArray:
+		return d.decodeArray(size, offset, depth)
+	case typeBool:
+		if size > 1 {
+			return nil, 0, fmt.Errorf("invalid size %d of boolean", size)
+		}
+		return size == 1, offset, nil
+	}
+
+	if offset+size > uint(len(d.buffer)) {
+		return nil, 0, errUnexpectedEnd
+	}
+	payload := d.buffer[offset : offset+size]
+	next := offset + size
+
+	switch typ {
+	case typeString:
+		return string(payload), next, nil
+	case typeBytes:
+		return append([]byte(nil), payload...), next, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("invalid size %d of double", size)
+		}
+		return math.Float64frombits(readUint(payload)), next, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("invalid size %d of float", size)
+		}
+		return float64(math.Float32frombits(uint32(readUint(payload)))), next, nil
+	case typeUint16, typeUint32, typeUint64:
+		if (typ == typeUint16 && size > 2) || (typ == typeUint32 && size > 4) ||
+			size > 8 {
+			return nil, 0, fmt.Errorf("invalid size %d of unsigned integer", size)
+		}
+		return readUint(payload), next, nil
+	case typeInt32:
+		if size > 4 {
+			return nil, 0, fmt.Errorf("invalid size %d of int32", size)
+		}
+		return int64(int32(readUint(payload))), next, nil
+	case typeUint128:
+		if size > 16 {
+			return nil, 0, fmt.Errorf("invalid size %d of uint128", size)
+		}
+		return new(big.Int).SetBytes(payload), next, nil
+	default:
+		return nil, 0, fmt.Errorf("unsupported data type %d", typ)
+	}
+}
+
+func (d *decoder) decodeMap(
+	size, offset uint,
+	depth int,
+) (interface{}, uint, error) {
+	// every entry needs at least two bytes
+	if size > (uint(len(d.buffer))-offset)/2 {
+		return nil, 0, errUnexpectedEnd
+	}
+
+	m := make(map[string]interface{}, size)
+	for i := uint(0); i < size; i++ {
+		key, next, err := d.decode(offset, depth+1)
+		if err != nil {
+			return nil, 0, err
+		}
+		name, ok := key.(string)
+		if !ok {
+			return nil, 0, fmt.Errorf("invalid map key of type %T", key)
+		}
+
+		m[name], offset, err = d.decode(next, depth+1)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	return m, offset, nil
+}
+
+func (d *decoder) decodeArray(
+	size, offset uint,
+	depth int,
+) (interface{}, uint, error) {
+	if size > uint(len(d.buffer))-offset {
+		return nil, 0, errUnexpectedEnd
+	}
+
+	array := make([]interface{}, size)
+	for i := range array {
+		var err error
+		array[i], offset, err = d.decode(offset, depth+1)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	return array, offset, nil
+}
+
+// readUint reads the big endian unsigned integer in b.
+func readUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
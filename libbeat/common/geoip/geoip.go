@@ -0,0 +1,369 @@
+// Package geoip looks up the geographical location and the autonomous system
+// of IP addresses and adds them to events. GeoIP2 and GeoLite2 databases in
+// the MaxMind DB format (.mmdb) are read by a pure Go reader. Databases in
+// the legacy GeoIP format (.dat) are still supported for locations.
+package geoip
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/logp"
+
+	"github.com/nranchev/go-libGeoIP"
+)
+
+// DefaultCacheSize is the number of looked up IP addresses cached if
+// cache_size is not set.
+const DefaultCacheSize = 1000
+
+// suffix of the event field holding the GeoIP information of an IP field
+const targetSuffix = "_geoip"
+
+// names of IP fields which can be configured by their role in the transaction
+var fieldAliases = map[string]string{
+	"client": "client_ip",
+	"server": "ip",
+}
+
+// Config configures the GeoIP databases and the event fields enriched. GeoIP
+// is disabled if no database is found.
+type Config struct {
+	Paths     *[]string // Location databases (.mmdb or .dat). The first one found is used.
+	ASNPaths  []string  `yaml:"asn_paths"`  // ASN databases (.mmdb). The first one found is used.
+	Fields    []string  `yaml:"fields"`     // IP fields to enrich: client, server or any event field.
+	CacheSize *int      `yaml:"cache_size"` // Number of IP addresses cached.
+}
+
+// Validate checks the GeoIP settings and returns an error describing any
+// problems or nil.
+func (c Config) Validate() error {
+	if c.CacheSize != nil && *c.CacheSize < 0 {
+		return fmt.Errorf("cache_size must not be negative but was '%d'",
+			*c.CacheSize)
+	}
+
+	for _, field := range c.Fields {
+		if field == "" {
+			return errors.New("fields must not contain empty field names")
+		}
+	}
+
+	if len(c.Fields) > 0 && len(c.paths()) == 0 && len(c.ASNPaths) == 0 {
+		return errors.New("fields requires paths or asn_paths to be set")
+	}
+	return nil
+}
+
+func (c Config) paths() []string {
+	if c.Paths == nil {
+		return nil
+	}
+	return *c.Paths
+}
+
+// Location is the information found about an IP address. Fields not available
+// in the databases are left empty.
+type Location struct {
+	CountryISOCode string
+	RegionName     string
+	CityName       string
+	Point          *Point // nil if the coordinates are unknown
+	ASN            uint
+	Organization   string
+}
+
+// Point holds the coordinates of a location.
+type Point struct {
+	Lat float64
+	Lon float64
+}
+
+// String formats the coordinates as latitude and longitude separated by a
+// comma, as used by the client_location field.
+func (p Point) String() string {
+	return fmt.Sprintf("%f, %f", p.Lat, p.Lon)
+}
+
+// MapStr returns the location as event fields. The coordinates are stored in
+// location as geo_point object.
+func (l *Location) MapStr() common.MapStr {
+	m := common.MapStr{}
+	if l.CountryISOCode != "" {
+		m["country_iso_code"] = l.CountryISOCode
+	}
+	if l.RegionName != "" {
+		m["region_name"] = l.RegionName
+	}
+	if l.CityName != "" {
+		m["city_name"] = l.CityName
+	}
+	if l.Point != nil {
+		m["location"] = common.MapStr{"lat": l.Point.Lat, "lon": l.Point.Lon}
+	}
+	if l.ASN != 0 {
+		m["asn"] = l.ASN
+	}
+	if l.Organization != "" {
+		m["organization"] = l.Organization
+	}
+	return m
+}
+
+func (l *Location) empty() bool {
+	return *l == Location{}
+}
+
+// database fills in the information stored about an IP address.
+type database interface {
+	lookup(ip net.IP, location *Location) error
+}
+
+// GeoIP looks up IP addresses in the location and ASN databases.
+type GeoIP struct {
+	location database
+	asn      database
+	fields   []string
+	cache    *lruCache
+}
+
+// Load opens the configured databases. It returns nil if GeoIP is disabled
+// because no database could be loaded.
+func Load(config Config) *GeoIP {
+	g := &GeoIP{fields: config.Fields}
+
+	if paths := config.paths(); len(paths) > 0 {
+		g.location = loadDatabase(paths, true)
+	}
+	if len(config.ASNPaths) > 0 {
+		g.asn = loadDatabase(config.ASNPaths, false)
+	}
+
+	if g.location == nil && g.asn == nil {
+		if len(config.paths()) == 0 && len(config.ASNPaths) == 0 {
+			logp.Info("GeoIP disabled: No paths were set under shipper.geoip.paths")
+		} else {
+			logp.Warn("GeoIP disabled: Couldn't load any GeoIP database")
+		}
+		return nil
+	}
+
+	cacheSize := DefaultCacheSize
+	if config.CacheSize != nil {
+		cacheSize = *config.CacheSize
+	}
+	g.cache = newLRUCache(cacheSize)
+	return g
+}
+
+// loadDatabase opens the first existing database in paths. Databases in the
+// legacy format are only accepted if allowLegacy is set.
+func loadDatabase(paths []string, allowLegacy bool) database {
+	path := findPath(paths)
+	if path == "" {
+		logp.Warn("Couldn't load GeoIP database")
+		return nil
+	}
+
+	reader, err := Open(path)
+	if err == nil {
+		logp.Info("Loaded GeoIP data (%s) from: %s",
+			reader.Metadata.DatabaseType, path)
+		return &mmdbDatabase{reader}
+	}
+	if err != errMetadataNotFound {
+		logp.Warn("Could not load GeoIP data from %s: %v", path, err)
+		return nil
+	}
+
+	if !allowLegacy {
+		logp.Warn("Could not load GeoIP data from %s: not a MaxMind DB file", path)
+		return nil
+	}
+
+	legacy, err := libgeo.Load(path)
+	if err != nil {
+		logp.Warn("Could not load GeoIP data: %s", err.Error())
+		return nil
+	}
+	logp.Info("Loaded GeoIP data from: %s", path)
+	return &legacyDatabase{legacy}
+}
+
+// findPath returns the first existing path with symlinks resolved or an empty
+// string if no path exists.
+func findPath(paths []string) string {
+	for _, path := range paths {
+		fi, err := os.Lstat(path)
+		if err != nil {
+			logp.Err("GeoIP path could not be loaded: %s", path)
+			continue
+		}
+
+		if fi.Mode()&os.ModeSymlink == os.ModeSymlink {
+			// follow symlink
+			resolved, err := filepath.EvalSymlinks(path)
+			if err != nil {
+				logp.Warn("Could not load GeoIP data: %s", err.Error())
+				return ""
+			}
+			return resolved
+		}
+		return path
+	}
+	return ""
+}
+
+// Lookup returns the information found about ip in the databases or nil if
+// ip is invalid or not found. Results are cached.
+func (g *GeoIP) Lookup(ip string) *Location {
+	if location, found := g.cache.get(ip); found {
+		return location
+	}
+
+	location := g.lookup(ip)
+	g.cache.add(ip, location)
+	return location
+}
+
+func (g *GeoIP) lookup(ip string) *Location {
+	addr := net.ParseIP(strings.TrimSpace(ip))
+	if addr == nil {
+		return nil
+	}
+
+	location := &Location{}
+	for _, db := range []database{g.location, g.asn} {
+		if db == nil {
+			continue
+		}
+		if err := db.lookup(addr, location); err != nil {
+			logp.Warn("GeoIP lookup of %s failed: %v", ip, err)
+		}
+	}
+
+	if location.empty() {
+		return nil
+	}
+	return location
+}
+
+// Enrich adds the information found about the configured IP fields to event.
+// The information about field is stored in <field>_geoip, for the client and
+// server aliases in client_geoip and server_geoip.
+func (g *GeoIP) Enrich(event common.MapStr) {
+	for _, name := range g.fields {
+		field := name
+		if alias, exists := fieldAliases[name]; exists {
+			field = alias
+		}
+
+		ip, err := event.GetString(field)
+		if err != nil || ip == "" {
+			continue
+		}
+
+		if location := g.Lookup(ip); location != nil {
+			if _, err := event.Put(name+targetSuffix, location.MapStr()); err != nil {
+				logp.Debug("geoip", "Failed to add GeoIP information of %s: %v", field, err)
+			}
+		}
+	}
+}
+
+// mmdbDatabase reads GeoIP2 City, Country and ASN databases.
+type mmdbDatabase struct {
+	reader *Reader
+}
+
+func (db *mmdbDatabase) lookup(ip net.IP, location *Location) error {
+	if ip.To4() == nil && db.reader.Metadata.IPVersion == 4 {
+		return nil
+	}
+
+	value, err := db.reader.Lookup(ip)
+	if err != nil || value == nil {
+		return err
+	}
+	record, _ := value.(map[string]interface{})
+
+	// City and ASN databases contain different fields, only set the fields
+	// found
+	if country, ok := record["country"].(map[string]interface{}); ok {
+		setString(&location.CountryISOCode, country["iso_code"])
+	}
+	if subdivisions, ok := record["subdivisions"].([]interface{}); ok &&
+		len(subdivisions) > 0 {
+		setString(&location.RegionName, englishName(subdivisions[0]))
+	}
+	setString(&location.CityName, englishName(record["city"]))
+
+	if coordinates, ok := record["location"].(map[string]interface{}); ok {
+		lat, okLat := coordinates["latitude"].(float64)
+		lon, okLon := coordinates["longitude"].(float64)
+		if okLat && okLon {
+			location.Point = &Point{Lat: lat, Lon: lon}
+		}
+	}
+
+	if asn, ok := toUint(record["autonomous_system_number"]); ok {
+		location.ASN = asn
+	}
+	setString(&location.Organization, record["autonomous_system_organization"])
+	return nil
+}
+
+// setString sets s to value if value is a non-empty string.
+func setString(s *string, value interface{}) {
+	if v, ok := value.(string); ok && v != "" {
+		*s = v
+	}
+}
+
+// englishName returns the English name of a GeoIP2 record like city.
+func englishName(record interface{}) string {
+	m, ok := record.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	names, ok := m["names"].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	name, _ := names["en"].(string)
+	return name
+}
+
+// legacyDatabase reads GeoIP City and Country databases in the legacy format.
+type legacyDatabase struct {
+	geoip *libgeo.GeoIP
+}
+
+func (db *legacyDatabase) lookup(ip net.IP, location *Location) error {
+	ip4 := ip.To4()
+	if ip4 == nil {
+		// legacy databases only contain IPv4 addresses
+		return nil
+	}
+
+	loc := db.geoip.GetLocationByIP(ip4.String())
+	if loc == nil {
+		return nil
+	}
+
+	location.CountryISOCode = loc.CountryCode
+	location.RegionName = loc.Region
+	location.CityName = loc.City
+	if loc.Latitude != 0 || loc.Longitude != 0 {
+		location.Point = &Point{
+			Lat: float64(loc.Latitude),
+			Lon: float64(loc.Longitude),
+		}
+	}
+	return nil
+}
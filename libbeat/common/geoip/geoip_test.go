@@ -0,0 +1,190 @@
+package geoip
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func cityNetworks() []testNetwork {
+	return []testNetwork{
+		{"81.2.69.0/24", map[string]interface{}{
+			"city": map[string]interface{}{
+				"names": map[string]interface{}{"en": "London", "de": "London"},
+			},
+			"country": map[string]interface{}{"iso_code": "GB"},
+			"location": map[string]interface{}{
+				"latitude":  51.5142,
+				"longitude": -0.0931,
+			},
+			"subdivisions": []interface{}{
+				map[string]interface{}{
+					"iso_code": "ENG",
+					"names":    map[string]interface{}{"en": "England"},
+				},
+			},
+		}},
+		{"2001:db8::/32", map[string]interface{}{
+			"country": map[string]interface{}{"iso_code": "US"},
+		}},
+	}
+}
+
+func asnNetworks() []testNetwork {
+	return []testNetwork{
+		{"81.2.0.0/16", map[string]interface{}{
+			"autonomous_system_number":       uint32(20712),
+			"autonomous_system_organization": "Andrews & Arnold Ltd",
+		}},
+	}
+}
+
+// writeDatabases writes test City and ASN databases to a temporary directory
+// and returns the configuration using them.
+func writeDatabases(t *testing.T) (Config, string) {
+	dir, err := ioutil.TempDir("", "geoip")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	city := filepath.Join(dir, "GeoLite2-City.mmdb")
+	asn := filepath.Join(dir, "GeoLite2-ASN.mmdb")
+	err = ioutil.WriteFile(city, buildDatabase(t, 6, 28, cityNetworks()), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(asn, buildDatabase(t, 6, 24, asnNetworks()), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paths := []string{filepath.Join(dir, "missing.mmdb"), city}
+	return Config{Paths: &paths, ASNPaths: []string{asn}}, dir
+}
+
+func TestLoadDisabled(t *testing.T) {
+	assert.Nil(t, Load(Config{}))
+
+	paths := []string{"/does/not/exist.mmdb"}
+	assert.Nil(t, Load(Config{Paths: &paths}))
+}
+
+func TestLookup(t *testing.T) {
+	config, dir := writeDatabases(t)
+	defer os.RemoveAll(dir)
+
+	g := Load(config)
+	if g == nil {
+		t.Fatal("GeoIP not loaded")
+	}
+
+	assert.Equal(t, &Location{
+		CountryISOCode: "GB",
+		RegionName:     "England",
+		CityName:       "London",
+		Point:          &Point{Lat: 51.5142, Lon: -0.0931},
+		ASN:            20712,
+		Organization:   "Andrews & Arnold Ltd",
+	}, g.Lookup("81.2.69.160"))
+
+	// found in the ASN database only
+	assert.Equal(t, &Location{
+		ASN:          20712,
+		Organization: "Andrews & Arnold Ltd",
+	}, g.Lookup("81.2.100.1"))
+
+	assert.Equal(t, &Location{CountryISOCode: "US"}, g.Lookup("2001:db8::1"))
+	assert.Nil(t, g.Lookup("10.0.0.1"))
+	assert.Nil(t, g.Lookup("not an ip"))
+
+	assert.Equal(t, "51.514200, -0.093100",
+		g.Lookup("81.2.69.160").Point.String())
+}
+
+func TestLookupCache(t *testing.T) {
+	config, dir := writeDatabases(t)
+	defer os.RemoveAll(dir)
+
+	size := 2
+	config.CacheSize = &size
+	g := Load(config)
+
+	first := g.Lookup("81.2.69.160")
+	assert.True(t, first == g.Lookup("81.2.69.160"), "cached location expected")
+
+	g.Lookup("10.0.0.1")
+	g.Lookup("10.0.0.2")
+	assert.Equal(t, 2, g.cache.len())
+
+	_, cached := g.cache.get("81.2.69.160")
+	assert.False(t, cached, "least recently used address not evicted")
+	_, cached = g.cache.get("10.0.0.1")
+	assert.True(t, cached, "addresses not found must be cached")
+
+	size = 0
+	g = Load(config)
+	g.Lookup("81.2.69.160")
+	assert.Equal(t, 0, g.cache.len())
+}
+
+func TestEnrich(t *testing.T) {
+	config, dir := writeDatabases(t)
+	defer os.RemoveAll(dir)
+
+	config.Fields = []string{"client", "server", "real_ip", "http.forwarded_ip"}
+	g := Load(config)
+
+	event := common.MapStr{
+		"client_ip": "81.2.69.160",
+		"ip":        "10.0.0.1",
+		"real_ip":   "2001:db8::1",
+		"http": common.MapStr{
+			"forwarded_ip": "81.2.100.1",
+		},
+	}
+	g.Enrich(event)
+
+	assert.Equal(t, common.MapStr{
+		"country_iso_code": "GB",
+		"region_name":      "England",
+		"city_name":        "London",
+		"location":         common.MapStr{"lat": 51.5142, "lon": -0.0931},
+		"asn":              uint(20712),
+		"organization":     "Andrews & Arnold Ltd",
+	}, event["client_geoip"])
+	assert.NotContains(t, event, "server_geoip")
+	assert.Equal(t, common.MapStr{"country_iso_code": "US"}, event["real_ip_geoip"])
+	assert.Equal(t, common.MapStr{
+		"asn":          uint(20712),
+		"organization": "Andrews & Arnold Ltd",
+	}, event["http"].(common.MapStr)["forwarded_ip_geoip"])
+}
+
+func TestConfigValidate(t *testing.T) {
+	paths := []string{"GeoLite2-City.mmdb"}
+	negative := -1
+	var tests = []struct {
+		config Config
+		err    string
+	}{
+		{Config{}, ""},
+		{Config{Paths: &paths, Fields: []string{"client"}}, ""},
+		{Config{ASNPaths: paths, Fields: []string{"server"}}, ""},
+		{Config{Fields: []string{"client"}}, "fields requires paths or asn_paths"},
+		{Config{Paths: &paths, Fields: []string{""}}, "empty field names"},
+		{Config{CacheSize: &negative}, "cache_size must not be negative"},
+	}
+
+	for _, test := range tests {
+		err := test.config.Validate()
+		if test.err == "" {
+			assert.NoError(t, err, "%+v", test.config)
+		} else if assert.Error(t, err, "%+v", test.config) {
+			assert.Contains(t, err.Error(), test.err)
+		}
+	}
+}
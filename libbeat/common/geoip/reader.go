@@ -0,0 +1,213 @@
+package geoip
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+)
+
+// metadataStartMarker precedes the metadata section at the end of a MaxMind
+// DB file.
+var metadataStartMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+// size of the zero bytes separating the search tree from the data section
+const dataSectionSeparatorSize = 16
+
+var errMetadataNotFound = errors.New("no MaxMind DB metadata found")
+
+// Metadata describes the contents of a MaxMind DB file.
+type Metadata struct {
+	DatabaseType             string
+	Description              map[string]interface{}
+	Languages                []string
+	IPVersion                uint
+	NodeCount                uint
+	RecordSize               uint
+	BinaryFormatMajorVersion uint
+	BinaryFormatMinorVersion uint
+	BuildEpoch               uint
+}
+
+// Reader looks up IP addresses in a MaxMind DB file (.mmdb), the format used
+// by the GeoIP2 and GeoLite2 databases. The complete file is held in memory.
+// A Reader is safe for concurrent use.
+type Reader struct {
+	Metadata Metadata
+
+	buffer    []byte  // search tree followed by the data section
+	data      decoder // decoder of the data section
+	ipv4Start uint    // node to start IPv4 lookups at
+}
+
+// Open reads the MaxMind DB file at path.
+func Open(path string) (*Reader, error) {
+	buffer, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return FromBytes(buffer)
+}
+
+// FromBytes creates a Reader from the contents of a MaxMind DB file.
+func FromBytes(buffer []byte) (*Reader, error) {
+	markerStart := bytes.LastIndex(buffer, metadataStartMarker)
+	if markerStart == -1 {
+		return nil, errMetadataNotFound
+	}
+
+	metaStart := uint(markerStart + len(metadataStartMarker))
+	meta := decoder{buffer: buffer[metaStart:]}
+	value, _, err := meta.decode(0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid metadata: %v", err)
+	}
+
+	r := &Reader{}
+	if err := r.Metadata.read(value); err != nil {
+		return nil, err
+	}
+
+	treeSize := r.Metadata.NodeCount * r.Metadata.RecordSize / 4
+	dataStart := treeSize + dataSectionSeparatorSize
+	if dataStart > uint(markerStart) {
+		return nil, errors.New("search tree exceeds the database size")
+	}
+
+	r.buffer = buffer[:treeSize]
+	r.data = decoder{buffer: buffer[dataStart:markerStart]}
+
+	if r.Metadata.IPVersion == 6 {
+		// IPv4 addresses are stored in the ::/96 subnet
+		node := uint(0)
+		for i := 0; i < 96 && node < r.Metadata.NodeCount; i++ {
+			node = r.readNode(node, 0)
+		}
+		r.ipv4Start = node
+	}
+
+	return r, nil
+}
+
+// read sets the metadata from the decoded metadata map.
+func (m *Metadata) read(value interface{}) error {
+	fields, ok := value.(map[string]interface{})
+	if !ok {
+		return errors.New("metadata is not a map")
+	}
+
+	m.DatabaseType, _ = fields["database_type"].(string)
+	m.Description, _ = fields["description"].(map[string]interface{})
+	if languages, ok := fields["languages"].([]interface{}); ok {
+		for _, language := range languages {
+			if s, ok := language.(string); ok {
+				m.Languages = append(m.Languages, s)
+			}
+		}
+	}
+
+	var ok1, ok2, ok3, ok4 bool
+	m.IPVersion, ok1 = toUint(fields["ip_version"])
+	m.NodeCount, ok2 = toUint(fields["node_count"])
+	m.RecordSize, ok3 = toUint(fields["record_size"])
+	m.BinaryFormatMajorVersion, ok4 = toUint(fields["binary_format_major_version"])
+	if !ok1 || !ok2 || !ok3 || !ok4 {
+		return errors.New("metadata is missing required fields")
+	}
+	m.BinaryFormatMinorVersion, _ = toUint(fields["binary_format_minor_version"])
+	m.BuildEpoch, _ = toUint(fields["build_epoch"])
+
+	if m.BinaryFormatMajorVersion != 2 {
+		return fmt.Errorf("unsupported binary format version %d",
+			m.BinaryFormatMajorVersion)
+	}
+	if m.IPVersion != 4 && m.IPVersion != 6 {
+		return fmt.Errorf("unsupported IP version %d", m.IPVersion)
+	}
+	if m.RecordSize != 24 && m.RecordSize != 28 && m.RecordSize != 32 {
+		return fmt.Errorf("unsupported record size %d", m.RecordSize)
+	}
+	return nil
+}
+
+// Lookup returns the data stored for the network containing ip. Maps are
+// returned as map[string]interface{}, arrays as []interface{} and unsigned
+// integers as uint64. nil is returned if the address is not found.
+func (r *Reader) Lookup(ip net.IP) (interface{}, error) {
+	pointer, err := r.lookupPointer(ip)
+	if pointer == 0 || err != nil {
+		return nil, err
+	}
+
+	offset := pointer - r.Metadata.NodeCount - dataSectionSeparatorSize
+	value, _, err := r.data.decode(offset, 0)
+	return value, err
+}
+
+// lookupPointer traverses the search tree and returns the record pointing to
+// the data of ip or 0 if ip is not found.
+func (r *Reader) lookupPointer(ip net.IP) (uint, error) {
+	if ip == nil {
+		return 0, errors.New("invalid IP address")
+	}
+
+	node := uint(0)
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+		node = r.ipv4Start
+	} else if r.Metadata.IPVersion == 4 {
+		return 0, fmt.Errorf("IPv6 address %v can not be looked up in an IPv4 "+
+			"only database", ip)
+	}
+
+	nodeCount := r.Metadata.NodeCount
+	bitCount := uint(len(ip) * 8)
+	for i := uint(0); i < bitCount && node < nodeCount; i++ {
+		bit := uint(ip[i>>3]>>(7-i%8)) & 1
+		node = r.readNode(node, bit)
+	}
+
+	switch {
+	case node == nodeCount:
+		return 0, nil
+	case node > nodeCount:
+		return node, nil
+	default:
+		return 0, errors.New("invalid node in search tree")
+	}
+}
+
+// readNode returns the left (bit 0) or right (bit 1) record of node.
+func (r *Reader) readNode(node, bit uint) uint {
+	b := r.buffer
+	switch r.Metadata.RecordSize {
+	case 24:
+		off := node*6 + bit*3
+		return uint(b[off])<<16 | uint(b[off+1])<<8 | uint(b[off+2])
+	case 28:
+		off := node * 7
+		if bit == 0 {
+			return uint(b[off+3]&0xF0)<<20 | uint(b[off])<<16 |
+				uint(b[off+1])<<8 | uint(b[off+2])
+		}
+		return uint(b[off+3]&0x0F)<<24 | uint(b[off+4])<<16 |
+			uint(b[off+5])<<8 | uint(b[off+6])
+	default:
+		off := node*8 + bit*4
+		return uint(b[off])<<24 | uint(b[off+1])<<16 |
+			uint(b[off+2])<<8 | uint(b[off+3])
+	}
+}
+
+func toUint(v interface{}) (uint, bool) {
+	switch n := v.(type) {
+	case uint64:
+		return uint(n), true
+	case int64:
+		if n >= 0 {
+			return uint(n), true
+		}
+	}
+	return 0, false
+}
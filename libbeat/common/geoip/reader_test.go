@@ -0,0 +1,320 @@
+package geoip
+
+import (
+	"encoding/binary"
+	"math"
+	"net"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testNetwork struct {
+	cidr string
+	data interface{}
+}
+
+// record of a search tree node while building a test database
+type testRecord struct {
+	node int // index of the next node if > 0
+	data int // offset in the data section + 1 if > 0
+}
+
+// buildDatabase creates a MaxMind DB file containing networks.
+func buildDatabase(
+	t *testing.T,
+	ipVersion, recordSize int,
+	networks []testNetwork,
+) []byte {
+	nodes := [][2]testRecord{{}}
+	var data []byte
+
+	for _, network := range networks {
+		_, ipNet, err := net.ParseCIDR(network.cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ip := []byte(ipNet.IP)
+		prefix, _ := ipNet.Mask.Size()
+		if ipVersion == 6 && len(ip) == 4 {
+			ip = append(make([]byte, 12), ip...)
+			prefix += 96
+		}
+
+		offset := len(data)
+		data = append(data, encode(network.data)...)
+
+		node := 0
+		for i := 0; i < prefix; i++ {
+			bit := (ip[i/8] >> uint(7-i%8)) & 1
+			if i == prefix-1 {
+				nodes[node][bit] = testRecord{data: offset + 1}
+				break
+			}
+			if nodes[node][bit].node == 0 {
+				nodes = append(nodes, [2]testRecord{})
+				nodes[node][bit] = testRecord{node: len(nodes) - 1}
+			}
+			node = nodes[node][bit].node
+		}
+	}
+
+	nodeCount := len(nodes)
+	var buffer []byte
+	for _, node := range nodes {
+		var records [2]uint32
+		for bit, record := range node {
+			switch {
+			case record.node > 0:
+				records[bit] = uint32(record.node)
+			case record.data > 0:
+				records[bit] = uint32(nodeCount + dataSectionSeparatorSize +
+					record.data - 1)
+			default:
+				records[bit] = uint32(nodeCount)
+			}
+		}
+		buffer = append(buffer, encodeNode(recordSize, records)...)
+	}
+
+	buffer = append(buffer, make([]byte, dataSectionSeparatorSize)...)
+	buffer = append(buffer, data...)
+	buffer = append(buffer, metadataStartMarker...)
+	buffer = append(buffer, encode(map[string]interface{}{
+		"binary_format_major_version": uint32(2),
+		"binary_format_minor_version": uint32(0),
+		"database_type":               "Test-City",
+		"ip_version":                  uint32(ipVersion),
+		"languages":                   []interface{}{"en"},
+		"node_count":                  uint32(nodeCount),
+		"record_size":                 uint32(recordSize),
+	})...)
+	return buffer
+}
+
+func encodeNode(recordSize int, records [2]uint32) []byte {
+	left, right := records[0], records[1]
+	switch recordSize {
+	case 24:
+		return []byte{byte(left >> 16), byte(left >> 8), byte(left),
+			byte(right >> 16), byte(right >> 8), byte(right)}
+	case 28:
+		return []byte{byte(left >> 16), byte(left >> 8), byte(left),
+			byte(left>>20)&0xF0 | byte(right>>24)&0x0F,
+			byte(right >> 16), byte(right >> 8), byte(right)}
+	default:
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint32(b, left)
+		binary.BigEndian.PutUint32(b[4:], right)
+		return b
+	}
+}
+
+// encode encodes v in the MaxMind DB data format.
+func encode(v interface{}) []byte {
+	switch v := v.(type) {
+	case string:
+		return append(encodeControl(typeString, len(v)), v...)
+	case float64:
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint64(b, math.Float64bits(v))
+		return append(encodeControl(typeDouble, 8), b...)
+	case uint32:
+		b := make([]byte, 4)
+		binary.BigEndian.PutUint32(b, v)
+		return append(encodeControl(typeUint32, 4), b...)
+	case bool:
+		size := 0
+		if v {
+			size = 1
+		}
+		return encodeControl(typeBool, size)
+	case []interface{}:
+		b := encodeControl(typeArray, len(v))
+		for _, value := range v {
+			b = append(b, encode(value)...)
+		}
+		return b
+	case map[string]interface{}:
+		var keys []string
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		b := encodeControl(typeMap, len(v))
+		for _, key := range keys {
+			b = append(b, encode(key)...)
+			b = append(b, encode(v[key])...)
+		}
+		return b
+	}
+	panic("unsupported type")
+}
+
+func encodeControl(typ dataType, size int) []byte {
+	var extra []byte
+	switch {
+	case size >= 65821:
+		s := size - 65821
+		extra = []byte{byte(s >> 16), byte(s >> 8), byte(s)}
+		size = 31
+	case size >= 285:
+		s := size - 285
+		extra = []byte{byte(s >> 8), byte(s)}
+		size = 30
+	case size >= 29:
+		extra = []byte{byte(size - 29)}
+		size = 29
+	}
+
+	if typ > typeMap {
+		b := []byte{byte(size), byte(typ - 7)}
+		return append(b, extra...)
+	}
+	return append([]byte{byte(typ)<<5 | byte(size)}, extra...)
+}
+
+func testNetworks() []testNetwork {
+	return []testNetwork{
+		{"81.2.69.0/24", map[string]interface{}{"city": "London"}},
+		{"81.2.70.0/23", map[string]interface{}{"city": "Cambridge"}},
+		{"2001:db8::/32", map[string]interface{}{"city": "Documentation"}},
+	}
+}
+
+func TestReaderLookup(t *testing.T) {
+	var tests = []struct {
+		ip   string
+		city string
+	}{
+		{"81.2.69.142", "London"},
+		{"81.2.69.0", "London"},
+		{"81.2.70.1", "Cambridge"},
+		{"81.2.71.255", "Cambridge"},
+		{"81.2.72.1", ""},
+		{"10.0.0.1", ""},
+		{"2001:db8::1", "Documentation"},
+		{"2001:db9::1", ""},
+	}
+
+	for _, recordSize := range []int{24, 28, 32} {
+		reader, err := FromBytes(buildDatabase(t, 6, recordSize, testNetworks()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "Test-City", reader.Metadata.DatabaseType)
+		assert.Equal(t, []string{"en"}, reader.Metadata.Languages)
+		assert.Equal(t, uint(recordSize), reader.Metadata.RecordSize)
+
+		for _, test := range tests {
+			value, err := reader.Lookup(net.ParseIP(test.ip))
+			assert.NoError(t, err)
+			if test.city == "" {
+				assert.Nil(t, value, "%s (record size %d)", test.ip, recordSize)
+				continue
+			}
+			assert.Equal(t, map[string]interface{}{"city": test.city}, value,
+				"%s (record size %d)", test.ip, recordSize)
+		}
+	}
+}
+
+func TestReaderLookupIPv4Database(t *testing.T) {
+	networks := testNetworks()[:2]
+	reader, err := FromBytes(buildDatabase(t, 4, 24, networks))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := reader.Lookup(net.ParseIP("81.2.69.142"))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"city": "London"}, value)
+
+	_, err = reader.Lookup(net.ParseIP("2001:db8::1"))
+	assert.Error(t, err)
+}
+
+func TestReaderInvalidDatabase(t *testing.T) {
+	_, err := FromBytes([]byte("not a database"))
+	assert.Equal(t, errMetadataNotFound, err)
+
+	// metadata promising a search tree larger than the file
+	b := append([]byte(nil), metadataStartMarker...)
+	b = append(b, encode(map[string]interface{}{
+		"binary_format_major_version": uint32(2),
+		"ip_version":                  uint32(6),
+		"node_count":                  uint32(1000),
+		"record_size":                 uint32(24),
+	})...)
+	_, err = FromBytes(b)
+	assert.Error(t, err)
+
+	b = append([]byte(nil), metadataStartMarker...)
+	b = append(b, encode(map[string]interface{}{
+		"binary_format_major_version": uint32(2),
+		"ip_version":                  uint32(6),
+		"node_count":                  uint32(0),
+		"record_size":                 uint32(20),
+	})...)
+	_, err = FromBytes(b)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "unsupported record size")
+	}
+}
+
+func TestDecoderTypes(t *testing.T) {
+	long := string(make([]byte, 300))
+	value := map[string]interface{}{
+		"array":  []interface{}{"a", uint32(1), true},
+		"bool":   false,
+		"double": 1.5,
+		"long":   long,
+	}
+
+	d := decoder{buffer: encode(value)}
+	decoded, next, err := d.decode(0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(len(d.buffer)), next)
+	assert.Equal(t, map[string]interface{}{
+		"array":  []interface{}{"a", uint64(1), true},
+		"bool":   false,
+		"double": 1.5,
+		"long":   long,
+	}, decoded)
+}
+
+func TestDecoderPointer(t *testing.T) {
+	// the string "city" at offset 0, followed by a map using a pointer to it
+	// as key and as value
+	buffer := encode("city")
+	mapStart := uint(len(buffer))
+	buffer = append(buffer, encodeControl(typeMap, 1)...)
+	buffer = append(buffer, byte(typePointer)<<5, 0, byte(typePointer)<<5, 0)
+	buffer = append(buffer, encode("end")...)
+
+	d := decoder{buffer: buffer}
+	decoded, next, err := d.decode(mapStart, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"city": "city"}, decoded)
+
+	// decoding continues after the pointer, not after the value pointed to
+	decoded, _, err = d.decode(next, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "end", decoded)
+
+	// pointer to a pointer
+	d = decoder{buffer: []byte{byte(typePointer) << 5, 0}}
+	_, _, err = d.decode(0, 0)
+	assert.Error(t, err)
+}
+
+func TestDecoderTruncated(t *testing.T) {
+	b := encode(map[string]interface{}{"city": "London"})
+	for i := 0; i < len(b); i++ {
+		d := decoder{buffer: b[:i]}
+		_, _, err := d.decode(0, 0)
+		assert.Error(t, err, "truncated at %d", i)
+	}
+}
@@ -2,10 +2,18 @@ package common
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// ErrKeyNotFound is returned by the MapStr path functions if a key does not
+// exist.
+var ErrKeyNotFound = errors.New("key not found")
+
 // Commonly used map of things, used in JSON creation and the like.
 type MapStr map[string]interface{}
 
@@ -39,6 +47,302 @@ func (m MapStr) Update(d MapStr) {
 	}
 }
 
+// DeepUpdate recursively copies the key-value pairs from d to m. Nested maps
+// existing in both m and d are merged, all other values of m are overwritten.
+func (m MapStr) DeepUpdate(d MapStr) {
+	for k, v := range d {
+		if src, ok := toMapStr(v); ok {
+			if dst, ok := toMapStr(m[k]); ok {
+				dst.DeepUpdate(src)
+				continue
+			}
+		}
+		m[k] = v
+	}
+}
+
+// Clone returns a deep copy of m. Nested maps and arrays are copied, all other
+// values are shared.
+func (m MapStr) Clone() MapStr {
+	result := make(MapStr, len(m))
+	for k, v := range m {
+		result[k] = cloneValue(v)
+	}
+	return result
+}
+
+func cloneValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case MapStr:
+		return v.Clone()
+	case map[string]interface{}:
+		return map[string]interface{}(MapStr(v).Clone())
+	case []MapStr:
+		list := make([]MapStr, len(v))
+		for i, m := range v {
+			list[i] = m.Clone()
+		}
+		return list
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, elem := range v {
+			list[i] = cloneValue(elem)
+		}
+		return list
+	case []string:
+		return append([]string(nil), v...)
+	}
+	return v
+}
+
+// Flatten returns a copy of m with all nested maps flattened into keys in
+// dotted notation, e.g. {"a": {"b": 1}} becomes {"a.b": 1}. Arrays are kept
+// as values.
+func (m MapStr) Flatten() MapStr {
+	return flatten("", m, MapStr{})
+}
+
+func flatten(prefix string, in, out MapStr) MapStr {
+	for k, v := range in {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+
+		if m, ok := toMapStr(v); ok {
+			flatten(key, m, out)
+		} else {
+			out[key] = v
+		}
+	}
+	return out
+}
+
+// GetValue returns the value of the key given in dotted notation, e.g.
+// "beat.name". Array elements are selected by their index, e.g. "tags.0".
+// ErrKeyNotFound is returned if the key does not exist.
+func (m MapStr) GetValue(key string) (interface{}, error) {
+	keys := strings.Split(key, ".")
+	var value interface{} = m
+	for i, k := range keys {
+		var err error
+		value, err = child(value, k)
+		if err != nil {
+			return nil, pathError(keys[:i], err)
+		}
+	}
+	return value, nil
+}
+
+// HasKey returns true if the key given in dotted notation exists. An error is
+// returned if a parent of the key is neither a map nor an array.
+func (m MapStr) HasKey(key string) (bool, error) {
+	_, err := m.GetValue(key)
+	if err == ErrKeyNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Put sets the value of the key given in dotted notation and returns the
+// previous value, nil if the key did not exist. Missing parent maps are
+// created. Array elements can be replaced by their index, but arrays are not
+// extended.
+func (m MapStr) Put(key string, value interface{}) (interface{}, error) {
+	keys := strings.Split(key, ".")
+	var container interface{} = m
+	for i, k := range keys[:len(keys)-1] {
+		next, err := child(container, k)
+		if err == ErrKeyNotFound {
+			if parent, ok := toMapStr(container); ok {
+				next = MapStr{}
+				parent[k] = next
+				err = nil
+			}
+		}
+		if err != nil {
+			return nil, pathError(keys[:i], err)
+		}
+		container = next
+	}
+
+	last := keys[len(keys)-1]
+	if parent, ok := toMapStr(container); ok {
+		old := parent[last]
+		parent[last] = value
+		return old, nil
+	}
+
+	elem, err := arrayElement(container, last)
+	if err != nil {
+		return nil, pathError(keys[:len(keys)-1], err)
+	}
+	if !elem.CanSet() {
+		// arrays stored by value can not be modified
+		return nil, fmt.Errorf("can not modify %T at '%s'", container, key)
+	}
+	v := reflect.ValueOf(value)
+	if !v.IsValid() && elem.Kind() == reflect.Interface {
+		v = reflect.Zero(elem.Type()) // nil value
+	}
+	if !v.IsValid() || !v.Type().AssignableTo(elem.Type()) {
+		return nil, fmt.Errorf("can not store %T in array of %v at '%s'",
+			value, elem.Type(), key)
+	}
+	old := elem.Interface()
+	elem.Set(v)
+	return old, nil
+}
+
+// Delete removes the key given in dotted notation. ErrKeyNotFound is returned
+// if the key does not exist. Array elements can not be deleted.
+func (m MapStr) Delete(key string) error {
+	keys := strings.Split(key, ".")
+	parentKey := strings.Join(keys[:len(keys)-1], ".")
+	last := keys[len(keys)-1]
+
+	var container interface{} = m
+	if parentKey != "" {
+		var err error
+		if container, err = m.GetValue(parentKey); err != nil {
+			return err
+		}
+	}
+
+	parent, ok := toMapStr(container)
+	if !ok {
+		return fmt.Errorf("can not delete '%s' from %T at '%s'",
+			last, container, parentKey)
+	}
+	if _, exists := parent[last]; !exists {
+		return ErrKeyNotFound
+	}
+	delete(parent, last)
+	return nil
+}
+
+// GetString returns the string value of the key given in dotted notation.
+func (m MapStr) GetString(key string) (string, error) {
+	value, err := m.GetValue(key)
+	if err != nil {
+		return "", err
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", typeError(key, "string", value)
+	}
+	return s, nil
+}
+
+// GetBool returns the boolean value of the key given in dotted notation.
+func (m MapStr) GetBool(key string) (bool, error) {
+	value, err := m.GetValue(key)
+	if err != nil {
+		return false, err
+	}
+	b, ok := value.(bool)
+	if !ok {
+		return false, typeError(key, "bool", value)
+	}
+	return b, nil
+}
+
+// GetInt returns the value of the key given in dotted notation as int64. All
+// integer types are accepted, unsigned values must not overflow an int64.
+func (m MapStr) GetInt(key string) (int64, error) {
+	value, err := m.GetValue(key)
+	if err != nil {
+		return 0, err
+	}
+
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+		if v.Uint() <= 1<<63-1 {
+			return int64(v.Uint()), nil
+		}
+	}
+	return 0, typeError(key, "integer", value)
+}
+
+// GetMapStr returns the map stored under the key given in dotted notation.
+// Modifications of the returned map change m.
+func (m MapStr) GetMapStr(key string) (MapStr, error) {
+	value, err := m.GetValue(key)
+	if err != nil {
+		return nil, err
+	}
+	result, ok := toMapStr(value)
+	if !ok {
+		return nil, typeError(key, "map", value)
+	}
+	return result, nil
+}
+
+// toMapStr returns v as MapStr if v is a map. The returned map shares the
+// contents of v.
+func toMapStr(v interface{}) (MapStr, bool) {
+	switch m := v.(type) {
+	case MapStr:
+		return m, true
+	case map[string]interface{}:
+		return MapStr(m), true
+	}
+	return nil, false
+}
+
+// child returns the value of key in the map or array container.
+func child(container interface{}, key string) (interface{}, error) {
+	if m, ok := toMapStr(container); ok {
+		value, exists := m[key]
+		if !exists {
+			return nil, ErrKeyNotFound
+		}
+		return value, nil
+	}
+
+	elem, err := arrayElement(container, key)
+	if err != nil {
+		return nil, err
+	}
+	return elem.Interface(), nil
+}
+
+// arrayElement returns the element of the array or slice container with the
+// index given in key.
+func arrayElement(container interface{}, key string) (reflect.Value, error) {
+	v := reflect.ValueOf(container)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return reflect.Value{}, fmt.Errorf("expected map or array but found %T",
+			container)
+	}
+
+	index, err := strconv.Atoi(key)
+	if err != nil {
+		return reflect.Value{}, fmt.Errorf("invalid array index '%s'", key)
+	}
+	if index < 0 || index >= v.Len() {
+		return reflect.Value{}, ErrKeyNotFound
+	}
+	return v.Index(index), nil
+}
+
+// pathError adds the path of the container to err, unless err is
+// ErrKeyNotFound.
+func pathError(path []string, err error) error {
+	if err == ErrKeyNotFound || len(path) == 0 {
+		return err
+	}
+	return fmt.Errorf("%v at '%s'", err, strings.Join(path, "."))
+}
+
+func typeError(key, expected string, value interface{}) error {
+	return fmt.Errorf("value of key '%s' is %T, not %s", key, value, expected)
+}
+
 // Checks if a timestamp field exists and if it doesn't it adds
 // one by using the injected now() function as a time source.
 func (m MapStr) EnsureTimestampField(now func() time.Time) error {
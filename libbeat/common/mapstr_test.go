@@ -185,3 +185,204 @@ func TestString(t *testing.T) {
 		assert.Equal(t, test.Output, test.Input.String())
 	}
 }
+
+func TestMapStrDeepUpdate(t *testing.T) {
+	m := MapStr{
+		"a": MapStr{"b": 1, "c": 2},
+		"d": map[string]interface{}{"e": 3},
+		"f": 4,
+	}
+	m.DeepUpdate(MapStr{
+		"a": MapStr{"c": 5, "g": 6},
+		"d": MapStr{"h": 7},
+		"f": MapStr{"i": 8},
+	})
+
+	assert.Equal(t, MapStr{
+		"a": MapStr{"b": 1, "c": 5, "g": 6},
+		"d": map[string]interface{}{"e": 3, "h": 7},
+		"f": MapStr{"i": 8},
+	}, m)
+}
+
+func TestMapStrClone(t *testing.T) {
+	m := MapStr{
+		"a": MapStr{"b": 1},
+		"c": []interface{}{MapStr{"d": 2}},
+		"e": []string{"f"},
+		"g": map[string]interface{}{"h": 3},
+	}
+	c := m.Clone()
+	assert.Equal(t, m, c)
+
+	c["a"].(MapStr)["b"] = 10
+	c["c"].([]interface{})[0].(MapStr)["d"] = 20
+	c["e"].([]string)[0] = "x"
+	c["g"].(map[string]interface{})["h"] = 30
+	assert.Equal(t, MapStr{
+		"a": MapStr{"b": 1},
+		"c": []interface{}{MapStr{"d": 2}},
+		"e": []string{"f"},
+		"g": map[string]interface{}{"h": 3},
+	}, m)
+}
+
+func TestMapStrFlatten(t *testing.T) {
+	m := MapStr{
+		"a": MapStr{"b": MapStr{"c": 1}, "d": 2},
+		"e": map[string]interface{}{"f": 3},
+		"g": []interface{}{MapStr{"h": 4}},
+		"i": 5,
+	}
+	assert.Equal(t, MapStr{
+		"a.b.c": 1,
+		"a.d":   2,
+		"e.f":   3,
+		"g":     []interface{}{MapStr{"h": 4}},
+		"i":     5,
+	}, m.Flatten())
+}
+
+func TestMapStrGetValue(t *testing.T) {
+	m := MapStr{
+		"a": MapStr{"b": map[string]interface{}{"c": 1}},
+		"d": []interface{}{"x", MapStr{"e": 2}},
+		"f": []string{"y", "z"},
+		"g": 3,
+	}
+
+	var tests = []struct {
+		key   string
+		value interface{}
+		err   string
+	}{
+		{"a.b.c", 1, ""},
+		{"a.b", map[string]interface{}{"c": 1}, ""},
+		{"d.0", "x", ""},
+		{"d.1.e", 2, ""},
+		{"f.1", "z", ""},
+		{"g", 3, ""},
+		{"a.x", nil, ErrKeyNotFound.Error()},
+		{"d.2", nil, ErrKeyNotFound.Error()},
+		{"d.-1", nil, ErrKeyNotFound.Error()},
+		{"d.x", nil, "invalid array index 'x' at 'd'"},
+		{"g.h", nil, "expected map or array but found int at 'g'"},
+	}
+
+	for _, test := range tests {
+		value, err := m.GetValue(test.key)
+		if test.err != "" {
+			if assert.Error(t, err, test.key) {
+				assert.Equal(t, test.err, err.Error(), test.key)
+			}
+			continue
+		}
+		assert.NoError(t, err, test.key)
+		assert.Equal(t, test.value, value, test.key)
+	}
+
+	found, err := m.HasKey("d.1.e")
+	assert.True(t, found)
+	assert.NoError(t, err)
+	found, err = m.HasKey("a.x")
+	assert.False(t, found)
+	assert.NoError(t, err)
+	found, err = m.HasKey("g.h")
+	assert.False(t, found)
+	assert.Error(t, err)
+}
+
+func TestMapStrPut(t *testing.T) {
+	m := MapStr{
+		"a": MapStr{"b": 1},
+		"c": []interface{}{"x", MapStr{}},
+		"d": []string{"y"},
+		"e": 2,
+	}
+
+	old, err := m.Put("a.b", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, old)
+
+	old, err = m.Put("f.g.h", 3)
+	assert.NoError(t, err)
+	assert.Nil(t, old)
+
+	_, err = m.Put("c.0", "z")
+	assert.NoError(t, err)
+	_, err = m.Put("c.1.i", 4)
+	assert.NoError(t, err)
+	_, err = m.Put("d.0", "w")
+	assert.NoError(t, err)
+
+	assert.Equal(t, MapStr{
+		"a": MapStr{"b": 10},
+		"c": []interface{}{"z", MapStr{"i": 4}},
+		"d": []string{"w"},
+		"e": 2,
+		"f": MapStr{"g": MapStr{"h": 3}},
+	}, m)
+
+	_, err = m.Put("d.0", 1)
+	assert.Error(t, err, "int stored in []string")
+	_, err = m.Put("c.5", 1)
+	assert.Equal(t, ErrKeyNotFound, err)
+	_, err = m.Put("e.f", 1)
+	assert.Error(t, err, "value put into int")
+}
+
+func TestMapStrDelete(t *testing.T) {
+	m := MapStr{
+		"a": MapStr{"b": 1, "c": 2},
+		"d": []interface{}{MapStr{"e": 3}},
+		"f": 4,
+	}
+
+	assert.NoError(t, m.Delete("a.b"))
+	assert.NoError(t, m.Delete("d.0.e"))
+	assert.NoError(t, m.Delete("f"))
+	assert.Equal(t, MapStr{
+		"a": MapStr{"c": 2},
+		"d": []interface{}{MapStr{}},
+	}, m)
+
+	assert.Equal(t, ErrKeyNotFound, m.Delete("a.x"))
+	assert.Equal(t, ErrKeyNotFound, m.Delete("x.y"))
+	assert.Error(t, m.Delete("d.0"), "array elements can not be deleted")
+}
+
+func TestMapStrTypedGetters(t *testing.T) {
+	m := MapStr{
+		"s": "text",
+		"b": true,
+		"n": MapStr{"i": uint16(80), "big": uint64(1 << 63)},
+		"m": map[string]interface{}{"x": 1},
+	}
+
+	s, err := m.GetString("s")
+	assert.NoError(t, err)
+	assert.Equal(t, "text", s)
+	_, err = m.GetString("b")
+	assert.Error(t, err)
+	_, err = m.GetString("missing")
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	b, err := m.GetBool("b")
+	assert.NoError(t, err)
+	assert.True(t, b)
+
+	i, err := m.GetInt("n.i")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(80), i)
+	_, err = m.GetInt("n.big")
+	assert.Error(t, err, "overflow")
+	_, err = m.GetInt("s")
+	assert.Error(t, err)
+
+	sub, err := m.GetMapStr("m")
+	assert.NoError(t, err)
+	sub["y"] = 2
+	assert.Equal(t, map[string]interface{}{"x": 1, "y": 2}, m["m"])
+	_, err = m.GetMapStr("s")
+	assert.Error(t, err)
+}
@@ -102,17 +102,18 @@ func (b *Buffer) ReadFrom(r io.Reader) (int64, error) {
 	var total int64
 	for {
 		n, err := r.Read(buf[:])
+		if n > 0 {
+			if _, werr := b.Write(buf[:n]); werr != nil {
+				return total, werr
+			}
+			total += int64(n)
+		}
 		if err != nil {
 			if err == io.EOF {
 				break
 			}
 			return total, err
 		}
-		_, err = b.Write(buf[:n])
-		if err != nil {
-			return total, err
-		}
-		total += int64(n)
 	}
 
 	return total, nil
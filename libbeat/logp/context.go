@@ -0,0 +1,48 @@
+package logp
+
+// Context adds key/value pairs to the messages logged through it. In JSON
+// format each pair is written as a separate field, in text format the pairs
+// are appended to the message as key=value.
+//
+//	log := logp.With("output", "elasticsearch", "host", host)
+//	log.Info("Connection established")
+type Context struct {
+	fields []interface{}
+}
+
+// With returns a Context adding the given key/value pairs to each message.
+// Keys should be strings. A key without value is added with a nil value.
+func With(keysAndValues ...interface{}) *Context {
+	return &Context{fields: keysAndValues}
+}
+
+// With returns a new Context adding keysAndValues to the pairs of c.
+func (c *Context) With(keysAndValues ...interface{}) *Context {
+	fields := make([]interface{}, 0, len(c.fields)+len(keysAndValues))
+	fields = append(fields, c.fields...)
+	if len(fields)%2 != 0 {
+		// keep the pairs aligned if the last key has no value
+		fields = append(fields, nil)
+	}
+	return &Context{fields: append(fields, keysAndValues...)}
+}
+
+func (c *Context) Debug(selector string, format string, v ...interface{}) {
+	debugMessage(3, selector, c.fields, format, v...)
+}
+
+func (c *Context) Info(format string, v ...interface{}) {
+	msg(LOG_INFO, "INFO ", c.fields, format, v...)
+}
+
+func (c *Context) Warn(format string, v ...interface{}) {
+	msg(LOG_WARNING, "WARN ", c.fields, format, v...)
+}
+
+func (c *Context) Err(format string, v ...interface{}) {
+	msg(LOG_ERR, "ERR ", c.fields, format, v...)
+}
+
+func (c *Context) Critical(format string, v ...interface{}) {
+	msg(LOG_CRIT, "CRIT ", c.fields, format, v...)
+}
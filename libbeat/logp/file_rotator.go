@@ -1,16 +1,26 @@
 package logp
 
 import (
+	"compress/gzip"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 )
 
 const RotatorMaxFiles = 1024
 const DefaultKeepFiles = 7
 const DefaultRotateEveryBytes = 10 * 1024 * 1024
+const DefaultPermissions = 0644
+
+// Intervals supported for time based rotation.
+const (
+	RotateDaily  = "daily"
+	RotateHourly = "hourly"
+)
 
 type FileRotator struct {
 	Path             string
@@ -18,8 +28,20 @@ type FileRotator struct {
 	RotateEveryBytes *uint64
 	KeepFiles        *int
 
+	// Interval rotates the files at the start of each day or hour in
+	// addition to the size limit. Valid values are daily and hourly.
+	Interval string
+
+	// Compress stores the rotated files gzip compressed with a .gz suffix.
+	Compress bool
+
+	// Permissions of the files written as octal string, e.g. "0600".
+	Permissions string
+
 	current      *os.File
 	current_size uint64
+	rotateAt     time.Time        // time of the next time based rotation
+	now          func() time.Time // current time, replaced by tests
 }
 
 func (rotator *FileRotator) CreateDirectory() error {
@@ -56,7 +78,54 @@ func (rotator *FileRotator) CheckIfConfigSane() error {
 	if *rotator.KeepFiles < 2 || *rotator.KeepFiles >= RotatorMaxFiles {
 		return fmt.Errorf("The number of files to keep should be between 2 and %d", RotatorMaxFiles-1)
 	}
-	return nil
+	return rotator.checkRotation()
+}
+
+// checkRotation checks the rotation interval and the file permissions.
+func (rotator *FileRotator) checkRotation() error {
+	switch rotator.Interval {
+	case "", RotateDaily, RotateHourly:
+	default:
+		return fmt.Errorf("The rotation interval must be %s or %s but was '%s'",
+			RotateDaily, RotateHourly, rotator.Interval)
+	}
+	_, err := rotator.permissions()
+	return err
+}
+
+// permissions returns the configured file permissions or the default.
+func (rotator *FileRotator) permissions() (os.FileMode, error) {
+	if rotator.Permissions == "" {
+		return DefaultPermissions, nil
+	}
+
+	perm, err := strconv.ParseUint(rotator.Permissions, 8, 32)
+	if err != nil || perm > 0777 {
+		return 0, fmt.Errorf("The file permissions must be an octal number "+
+			"like 0600 but were '%s'", rotator.Permissions)
+	}
+	return os.FileMode(perm), nil
+}
+
+func (rotator *FileRotator) currentTime() time.Time {
+	if rotator.now != nil {
+		return rotator.now()
+	}
+	return time.Now()
+}
+
+// nextRotation returns the start of the day or hour following t, or the zero
+// time if no rotation interval is configured.
+func (rotator *FileRotator) nextRotation(t time.Time) time.Time {
+	year, month, day := t.Date()
+	switch rotator.Interval {
+	case RotateDaily:
+		return time.Date(year, month, day+1, 0, 0, 0, 0, t.Location())
+	case RotateHourly:
+		return time.Date(year, month, day, t.Hour()+1, 0, 0, 0, t.Location())
+	default:
+		return time.Time{}
+	}
 }
 
 func (rotator *FileRotator) WriteLine(line []byte) error {
@@ -88,14 +157,24 @@ func (rotator *FileRotator) shouldRotate() bool {
 		return true
 	}
 
+	if !rotator.rotateAt.IsZero() && !rotator.currentTime().Before(rotator.rotateAt) {
+		return true
+	}
+
 	return false
 }
 
+// FilePath returns the path of the file with the given number. The current
+// file has number 0. If Compress is set, the rotated files have the .gz
+// suffix.
 func (rotator *FileRotator) FilePath(file_no int) string {
 	if file_no == 0 {
 		return filepath.Join(rotator.Path, rotator.Name)
 	}
 	filename := strings.Join([]string{rotator.Name, strconv.Itoa(file_no)}, ".")
+	if rotator.Compress {
+		filename += ".gz"
+	}
 	return filepath.Join(rotator.Path, filename)
 }
 
@@ -139,24 +218,92 @@ func (rotator *FileRotator) Rotate() error {
 			return fmt.Errorf("File %s exists, when rotating would overwrite it", rotator.FilePath(file_no+1))
 		}
 
-		err := os.Rename(file_path, rotator.FilePath(file_no+1))
+		var err error
+		if file_no == 0 && rotator.Compress {
+			err = rotator.compressFile(file_path, rotator.FilePath(file_no+1))
+		} else {
+			err = os.Rename(file_path, rotator.FilePath(file_no+1))
+		}
 		if err != nil {
 			return err
 		}
 	}
 
 	// create the new file
-	file_path := rotator.FilePath(0)
-	current, err := os.Create(file_path)
+	current, err := rotator.createFile(rotator.FilePath(0))
 	if err != nil {
 		return err
 	}
 	rotator.current = current
 	rotator.current_size = 0
+	rotator.rotateAt = rotator.nextRotation(rotator.currentTime())
 
 	// delete the extra file, ignore errors here
-	file_path = rotator.FilePath(*rotator.KeepFiles)
+	file_path := rotator.FilePath(*rotator.KeepFiles)
 	os.Remove(file_path)
 
 	return nil
 }
+
+// createFile creates or truncates the file at path with the configured
+// permissions.
+func (rotator *FileRotator) createFile(path string) (*os.File, error) {
+	perm, err := rotator.permissions()
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	// the permissions of existing files and the umask are overridden
+	if err := file.Chmod(perm); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// compressFile writes the gzip compressed content of the file at src to dst
+// and removes src.
+func (rotator *FileRotator) compressFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := rotator.createFile(dst)
+	if err != nil {
+		return err
+	}
+
+	writer := gzip.NewWriter(out)
+	if _, err = io.Copy(writer, in); err == nil {
+		err = writer.Close()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(dst)
+		return err
+	}
+
+	in.Close()
+	return os.Remove(src)
+}
+
+// Close closes the current file. The next line written rotates the files
+// and opens a new one.
+func (rotator *FileRotator) Close() error {
+	if rotator.current == nil {
+		return nil
+	}
+
+	err := rotator.current.Close()
+	rotator.current = nil
+	return err
+}
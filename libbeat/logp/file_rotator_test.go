@@ -2,10 +2,13 @@ package logp
 
 import (
 	"bytes"
+	"compress/gzip"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -159,3 +162,118 @@ func TestConfigSane(t *testing.T) {
 	assert.NotNil(t, rotator.CheckIfConfigSane())
 
 }
+
+func newTestRotator(t *testing.T, dir string) *FileRotator {
+	rotator := &FileRotator{Path: dir, Name: "test"}
+	if err := rotator.CheckIfConfigSane(); err != nil {
+		t.Fatal(err)
+	}
+	return rotator
+}
+
+func TestRotatorInterval(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test_rotator_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Date(2016, 1, 10, 23, 30, 0, 0, time.Local)
+	rotator := newTestRotator(t, dir)
+	rotator.Interval = RotateDaily
+	rotator.now = func() time.Time { return now }
+
+	assert.NoError(t, rotator.WriteLine([]byte("1")))
+	assert.NoError(t, rotator.WriteLine([]byte("2")))
+	assert.False(t, rotator.FileExists(1))
+
+	// next day
+	now = now.Add(time.Hour)
+	assert.NoError(t, rotator.WriteLine([]byte("3")))
+	assert.True(t, rotator.FileExists(1))
+	assert.False(t, rotator.FileExists(2))
+	rotator.Close()
+
+	contents, _ := ioutil.ReadFile(rotator.FilePath(1))
+	assert.Equal(t, "1\n2\n", string(contents))
+	contents, _ = ioutil.ReadFile(rotator.FilePath(0))
+	assert.Equal(t, "3\n", string(contents))
+}
+
+func TestRotatorNextRotation(t *testing.T) {
+	rotator := &FileRotator{}
+	t0 := time.Date(2016, 1, 31, 23, 30, 0, 0, time.UTC)
+	assert.True(t, rotator.nextRotation(t0).IsZero())
+
+	rotator.Interval = RotateHourly
+	assert.Equal(t, time.Date(2016, 2, 1, 0, 0, 0, 0, time.UTC), rotator.nextRotation(t0))
+	assert.Equal(t, time.Date(2016, 1, 31, 11, 0, 0, 0, time.UTC),
+		rotator.nextRotation(time.Date(2016, 1, 31, 10, 0, 0, 0, time.UTC)))
+
+	rotator.Interval = RotateDaily
+	assert.Equal(t, time.Date(2016, 2, 1, 0, 0, 0, 0, time.UTC),
+		rotator.nextRotation(time.Date(2016, 1, 31, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestRotatorCompress(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test_rotator_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rotator := newTestRotator(t, dir)
+	rotator.Compress = true
+
+	assert.NoError(t, rotator.WriteLine([]byte("1")))
+	assert.NoError(t, rotator.Rotate())
+	assert.NoError(t, rotator.WriteLine([]byte("2")))
+	assert.NoError(t, rotator.Rotate())
+	rotator.Close()
+
+	assert.Equal(t, filepath.Join(dir, "test.2.gz"), rotator.FilePath(2))
+	assert.False(t, fileExists(filepath.Join(dir, "test.1")))
+
+	for file, expected := range map[int]string{1: "2\n", 2: "1\n"} {
+		f, err := os.Open(rotator.FilePath(file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader, err := gzip.NewReader(f)
+		if assert.NoError(t, err) {
+			contents, _ := ioutil.ReadAll(reader)
+			assert.Equal(t, expected, string(contents))
+		}
+		f.Close()
+	}
+}
+
+func TestRotatorPermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file permissions are not supported on windows")
+	}
+
+	dir, err := ioutil.TempDir("", "test_rotator_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rotator := newTestRotator(t, dir)
+	rotator.Permissions = "0600"
+	assert.NoError(t, rotator.WriteLine([]byte("1")))
+	rotator.Close()
+
+	info, err := os.Stat(rotator.FilePath(0))
+	if assert.NoError(t, err) {
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	}
+
+	rotator.Permissions = "0999"
+	assert.Error(t, rotator.CheckIfConfigSane())
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -1,10 +1,16 @@
 package logp
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"runtime"
 	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -23,88 +29,210 @@ const (
 	LOG_DEBUG
 )
 
+// Names of the log levels as used in the configuration and in JSON messages.
+var levelNames = map[Priority]string{
+	LOG_CRIT:    "critical",
+	LOG_ERR:     "error",
+	LOG_WARNING: "warning",
+	LOG_INFO:    "info",
+	LOG_DEBUG:   "debug",
+}
+
+// Time format of the timestamp in JSON messages.
+const jsonTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
 type Logger struct {
-	toSyslog            bool
-	toStderr            bool
-	toFile              bool
-	level               Priority
-	selectors           map[string]bool
-	debug_all_selectors bool
+	toSyslog bool
+	toStderr bool
+	toFile   bool
+	filter   atomic.Value // *filter, replaced on reload
+	json     bool         // write messages as JSON objects
 
 	logger  *log.Logger
 	syslog  [LOG_DEBUG + 1]*log.Logger
 	rotator *FileRotator
 }
 
+// filter holds the log level and the debug selectors. It is never modified
+// but replaced as a whole, so it can be read while the configuration is
+// reloaded.
+type filter struct {
+	level        Priority
+	selectors    map[string]bool
+	allSelectors bool // all debug selectors are enabled
+}
+
 var _log Logger
 
-func debugMessage(calldepth int, selector, format string, v ...interface{}) {
-	if _log.level >= LOG_DEBUG {
-		if !_log.debug_all_selectors {
-			selected := _log.selectors[selector]
+// currentFilter returns the log level and debug selectors in use.
+func currentFilter() *filter {
+	if f, ok := _log.filter.Load().(*filter); ok {
+		return f
+	}
+	return &filter{}
+}
+
+// setFilter replaces the log level and debug selectors.
+func setFilter(level Priority, debugSelectors []string) {
+	selectors, all := selectorsMap(debugSelectors)
+	_log.filter.Store(&filter{
+		level:        level,
+		selectors:    selectors,
+		allSelectors: all,
+	})
+}
+
+func debugMessage(calldepth int, selector string, ctx []interface{}, format string, v ...interface{}) {
+	f := currentFilter()
+	if f.level >= LOG_DEBUG {
+		if !f.allSelectors {
+			selected := f.selectors[selector]
 			if !selected {
 				return
 			}
 		}
 
-		send(calldepth+1, LOG_DEBUG, "DBG  ", format, v...)
+		send(calldepth+1, LOG_DEBUG, "DBG  ", selector, ctx, format, v...)
 	}
 }
 
-func send(calldepth int, level Priority, prefix string, format string, v ...interface{}) {
+func send(
+	calldepth int,
+	level Priority,
+	prefix, selector string,
+	ctx []interface{},
+	format string, v ...interface{},
+) {
+	message := fmt.Sprintf(format, v...)
+	if _log.json {
+		message = jsonMessage(calldepth, level, selector, ctx, message)
+		prefix = ""
+	} else if len(ctx) > 0 {
+		message += " " + formatContext(ctx)
+	}
+
 	if _log.toSyslog {
-		_log.syslog[level].Output(calldepth, fmt.Sprintf(format, v...))
+		_log.syslog[level].Output(calldepth, message)
 	}
 	if _log.toStderr {
-		_log.logger.Output(calldepth, fmt.Sprintf(prefix+format, v...))
+		_log.logger.Output(calldepth, prefix+message)
 	}
 	if _log.toFile {
-		// Creates a timestamp for the file log message and formats it
-		prefix = time.Now().Format(time.RFC3339) + " " + prefix
-		_log.rotator.WriteLine([]byte(fmt.Sprintf(prefix+format, v...)))
+		if !_log.json {
+			// Creates a timestamp for the file log message and formats it
+			prefix = time.Now().Format(time.RFC3339) + " " + prefix
+		}
+		_log.rotator.WriteLine([]byte(prefix + message))
+	}
+}
+
+// jsonMessage encodes the message with its level, selector, caller and
+// context as JSON object. The context keys must not overwrite the other
+// fields.
+func jsonMessage(
+	calldepth int,
+	level Priority,
+	selector string,
+	ctx []interface{},
+	message string,
+) string {
+	fields := map[string]interface{}{}
+	addContext(fields, ctx, false)
+
+	fields["timestamp"] = time.Now().UTC().Format(jsonTimeFormat)
+	fields["level"] = levelNames[level]
+	fields["message"] = message
+	if selector != "" {
+		fields["selector"] = selector
+	}
+	if _, file, line, ok := runtime.Caller(calldepth); ok {
+		fields["caller"] = fmt.Sprintf("%s:%d", filepath.Base(file), line)
+	}
+
+	encoded, err := json.Marshal(fields)
+	if err != nil {
+		// context values which can not be encoded are written as strings
+		addContext(fields, ctx, true)
+		encoded, _ = json.Marshal(fields)
+	}
+	return string(encoded)
+}
+
+// addContext adds the key/value pairs of ctx to fields. A key without value
+// is added with a nil value.
+func addContext(fields map[string]interface{}, ctx []interface{}, asString bool) {
+	for i := 0; i < len(ctx); i += 2 {
+		var value interface{}
+		if i+1 < len(ctx) {
+			value = ctx[i+1]
+		}
+		if asString {
+			value = fmt.Sprint(value)
+		}
+		fields[fmt.Sprint(ctx[i])] = value
+	}
+}
+
+// formatContext formats the key/value pairs of ctx as key=value list.
+// Strings containing spaces are quoted.
+func formatContext(ctx []interface{}) string {
+	pairs := make([]string, 0, (len(ctx)+1)/2)
+	for i := 0; i < len(ctx); i += 2 {
+		var value interface{}
+		if i+1 < len(ctx) {
+			value = ctx[i+1]
+		}
+
+		str := fmt.Sprint(value)
+		if strings.ContainsAny(str, " \t\"=") {
+			str = strconv.Quote(str)
+		}
+		pairs = append(pairs, fmt.Sprintf("%v=%s", ctx[i], str))
 	}
+	return strings.Join(pairs, " ")
 }
 
 func Debug(selector string, format string, v ...interface{}) {
-	debugMessage(3, selector, format, v...)
+	debugMessage(3, selector, nil, format, v...)
 }
 
 func MakeDebug(selector string) func(string, ...interface{}) {
 	return func(msg string, v ...interface{}) {
-		debugMessage(3, selector, msg, v...)
+		debugMessage(3, selector, nil, msg, v...)
 	}
 }
 
 func IsDebug(selector string) bool {
-	return _log.debug_all_selectors || _log.selectors[selector]
+	f := currentFilter()
+	return f.allSelectors || f.selectors[selector]
 }
 
-func msg(level Priority, prefix string, format string, v ...interface{}) {
-	if _log.level >= level {
-		send(4, level, prefix, format, v...)
+func msg(level Priority, prefix string, ctx []interface{}, format string, v ...interface{}) {
+	if currentFilter().level >= level {
+		send(4, level, prefix, "", ctx, format, v...)
 	}
 }
 
 func Info(format string, v ...interface{}) {
-	msg(LOG_INFO, "INFO ", format, v...)
+	msg(LOG_INFO, "INFO ", nil, format, v...)
 }
 
 func Warn(format string, v ...interface{}) {
-	msg(LOG_WARNING, "WARN ", format, v...)
+	msg(LOG_WARNING, "WARN ", nil, format, v...)
 }
 
 func Err(format string, v ...interface{}) {
-	msg(LOG_ERR, "ERR ", format, v...)
+	msg(LOG_ERR, "ERR ", nil, format, v...)
 }
 
 func Critical(format string, v ...interface{}) {
-	msg(LOG_CRIT, "CRIT ", format, v...)
+	msg(LOG_CRIT, "CRIT ", nil, format, v...)
 }
 
 // WTF prints the message at CRIT level and panics immediately with the same
 // message
 func WTF(format string, v ...interface{}) {
-	msg(LOG_CRIT, "CRIT ", format, v)
+	msg(LOG_CRIT, "CRIT ", nil, format, v)
 	panic(fmt.Sprintf(format, v...))
 }
 
@@ -119,15 +247,7 @@ func Recover(msg string) {
 func LogInit(level Priority, prefix string, toSyslog bool, toStderr bool, debugSelectors []string) {
 	_log.toSyslog = toSyslog
 	_log.toStderr = toStderr
-	_log.level = level
-
-	_log.selectors = make(map[string]bool)
-	for _, selector := range debugSelectors {
-		_log.selectors[selector] = true
-		if selector == "*" {
-			_log.debug_all_selectors = true
-		}
-	}
+	setFilter(level, debugSelectors)
 
 	if _log.toSyslog {
 		SetToSyslog(true, prefix)
@@ -138,11 +258,29 @@ func LogInit(level Priority, prefix string, toSyslog bool, toStderr bool, debugS
 	}
 }
 
+// selectorsMap returns the set of debug selectors and whether all selectors
+// are enabled.
+func selectorsMap(debugSelectors []string) (map[string]bool, bool) {
+	selectors := make(map[string]bool)
+	all := false
+	for _, selector := range debugSelectors {
+		selectors[selector] = true
+		if selector == "*" {
+			all = true
+		}
+	}
+	return selectors, all
+}
+
 func SetToStderr(toStderr bool, prefix string) {
 	_log.toStderr = toStderr
 	if _log.toStderr {
 		// Add timestamp
 		flag := log.Ldate | log.Ltime | log.Lmicroseconds | log.LUTC | log.Lshortfile
+		if _log.json {
+			// JSON messages contain the timestamp and caller
+			flag, prefix = 0, ""
+		}
 		_log.logger = log.New(os.Stderr, prefix, flag)
 	}
 }
@@ -157,10 +295,27 @@ func SetToSyslog(toSyslog bool, prefix string) {
 				_log.toSyslog = false
 				break
 			}
+			if _log.json {
+				_log.syslog[prio].SetFlags(0)
+				_log.syslog[prio].SetPrefix("")
+			}
 		}
 	}
 }
 
+// SetJSON enables or disables writing messages as JSON objects. Each message
+// is written as one line containing the fields timestamp, level, caller,
+// message, selector for debug messages and the context of the message.
+func SetJSON(enabled bool, prefix string) {
+	_log.json = enabled
+	if _log.toStderr {
+		SetToStderr(true, prefix)
+	}
+	if _log.toSyslog {
+		SetToSyslog(true, prefix)
+	}
+}
+
 func SetToFile(toFile bool, rotator *FileRotator) error {
 	_log.toFile = toFile
 	if _log.toFile {
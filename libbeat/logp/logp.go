@@ -7,6 +7,8 @@ import (
 	"log"
 	"runtime"
 	"strings"
+
+	"github.com/joeshaw/multierror"
 )
 
 // cmd line flags
@@ -20,6 +22,25 @@ type Logging struct {
 	To_syslog *bool
 	To_files  *bool
 	Level     string
+	Format    string
+}
+
+// Validate checks the logging settings and returns an error describing all
+// problems or nil if there are none.
+func (c Logging) Validate() error {
+	var errs multierror.Errors
+	if _, err := getLogLevel(&c); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := isJSONFormat(&c); err != nil {
+		errs = append(errs, err)
+	}
+	if c.Files != nil {
+		if err := c.Files.checkRotation(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs.Err()
 }
 
 func init() {
@@ -35,26 +56,14 @@ func init() {
 // line flag with a later SetStderr call.
 func Init(name string, config *Logging) error {
 
-	logLevel, err := getLogLevel(config)
+	logLevel, debugSelectors, err := getLevelAndSelectors(config)
 	if err != nil {
 		return err
 	}
 
-	if *verbose {
-		if LOG_INFO > logLevel {
-			logLevel = LOG_INFO
-		}
-	}
-
-	debugSelectors := config.Selectors
-	if logLevel == LOG_DEBUG {
-		if len(debugSelectors) == 0 {
-			debugSelectors = []string{"*"}
-		}
-	}
-	if len(*debugSelectorsStr) > 0 {
-		debugSelectors = strings.Split(*debugSelectorsStr, ",")
-		logLevel = LOG_DEBUG
+	toJSON, err := isJSONFormat(config)
+	if err != nil {
+		return err
 	}
 
 	var defaultToFiles, defaultToSyslog bool
@@ -89,6 +98,7 @@ func Init(name string, config *Logging) error {
 	}
 
 	LogInit(Priority(logLevel), "", toSyslog, true, debugSelectors)
+	SetJSON(toJSON, "")
 	if len(debugSelectors) > 0 {
 		config.Selectors = debugSelectors
 	}
@@ -124,6 +134,48 @@ func Init(name string, config *Logging) error {
 	return nil
 }
 
+// Reload applies the log level and debug selectors of config to the running
+// logging system. The command line flags still take precedence. All other
+// logging settings require a restart.
+func Reload(config *Logging) error {
+	logLevel, debugSelectors, err := getLevelAndSelectors(config)
+	if err != nil {
+		return err
+	}
+
+	setFilter(logLevel, debugSelectors)
+	Info("Logging level and selectors reloaded")
+	return nil
+}
+
+// getLevelAndSelectors combines the log level and debug selectors from config
+// with the -v and -d command line flags.
+func getLevelAndSelectors(config *Logging) (Priority, []string, error) {
+	logLevel, err := getLogLevel(config)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if *verbose {
+		if LOG_INFO > logLevel {
+			logLevel = LOG_INFO
+		}
+	}
+
+	debugSelectors := config.Selectors
+	if logLevel == LOG_DEBUG {
+		if len(debugSelectors) == 0 {
+			debugSelectors = []string{"*"}
+		}
+	}
+	if len(*debugSelectorsStr) > 0 {
+		debugSelectors = strings.Split(*debugSelectorsStr, ",")
+		logLevel = LOG_DEBUG
+	}
+
+	return logLevel, debugSelectors, nil
+}
+
 func SetStderr() {
 	if !*toStderr {
 		SetToStderr(false, "")
@@ -136,17 +188,28 @@ func getLogLevel(config *Logging) (Priority, error) {
 		return LOG_ERR, nil
 	}
 
-	levels := map[string]Priority{
-		"critical": LOG_CRIT,
-		"error":    LOG_ERR,
-		"warning":  LOG_WARNING,
-		"info":     LOG_INFO,
-		"debug":    LOG_DEBUG,
+	name := strings.ToLower(config.Level)
+	for level, levelName := range levelNames {
+		if name == levelName {
+			return level, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level: %v", config.Level)
+}
+
+// isJSONFormat returns true if the messages are to be written as JSON
+// objects. The format is either text (the default) or json.
+func isJSONFormat(config *Logging) (bool, error) {
+	if config == nil {
+		return false, nil
 	}
 
-	level, ok := levels[strings.ToLower(config.Level)]
-	if !ok {
-		return 0, fmt.Errorf("unknown log level: %v", config.Level)
+	switch strings.ToLower(config.Format) {
+	case "", "text":
+		return false, nil
+	case "json":
+		return true, nil
+	default:
+		return false, fmt.Errorf("unknown log format: %v", config.Format)
 	}
-	return level, nil
 }
@@ -0,0 +1,164 @@
+package logp
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReload(t *testing.T) {
+	saved := _log
+	defer func() { _log = saved }()
+
+	LogInit(LOG_ERR, "", false, false, nil)
+
+	err := Reload(&Logging{Level: "debug", Selectors: []string{"publish"}})
+	assert.NoError(t, err)
+	assert.Equal(t, LOG_DEBUG, currentFilter().level)
+	assert.True(t, IsDebug("publish"))
+	assert.False(t, IsDebug("service"))
+
+	err = Reload(&Logging{Level: "debug"})
+	assert.NoError(t, err)
+	assert.True(t, IsDebug("service"))
+
+	err = Reload(&Logging{Level: "warning"})
+	assert.NoError(t, err)
+	assert.Equal(t, LOG_WARNING, currentFilter().level)
+	assert.False(t, IsDebug("publish"))
+}
+
+// Reloading must not race with messages logged concurrently.
+func TestReloadConcurrent(t *testing.T) {
+	saved := _log
+	defer func() { _log = saved }()
+
+	LogInit(LOG_ERR, "", false, false, nil)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			Debug("publish", "message %d", i)
+			Info("message %d", i)
+			IsDebug("publish")
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		Reload(&Logging{Level: "error", Selectors: []string{"publish"}})
+	}
+	<-done
+}
+
+func TestReloadInvalidLevel(t *testing.T) {
+	saved := _log
+	defer func() { _log = saved }()
+
+	LogInit(LOG_INFO, "", false, false, []string{"publish"})
+
+	err := Reload(&Logging{Level: "verbose"})
+	assert.Error(t, err)
+	assert.Equal(t, LOG_INFO, currentFilter().level)
+	assert.True(t, IsDebug("publish"))
+}
+
+// logToFile logs to a file in a temporary directory and returns a function
+// reading the lines logged.
+func logToFile(t *testing.T, json bool) func() []string {
+	dir, err := ioutil.TempDir("", "logp")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	LogInit(LOG_DEBUG, "", false, false, []string{"test"})
+	SetJSON(json, "")
+	err = SetToFile(true, &FileRotator{Path: dir, Name: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return func() []string {
+		defer os.RemoveAll(dir)
+		_log.rotator.Close()
+
+		data, err := ioutil.ReadFile(filepath.Join(dir, "test"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.Split(strings.TrimSpace(string(data)), "\n")
+	}
+}
+
+func TestJSONFormat(t *testing.T) {
+	saved := _log
+	defer func() { _log = saved }()
+
+	read := logToFile(t, true)
+	Info("started %d workers", 2)
+	With("output", "redis", "count", 3).Debug("test", "sent")
+	lines := read()
+
+	if !assert.Len(t, lines, 2) {
+		return
+	}
+
+	var info map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &info))
+	assert.Equal(t, "info", info["level"])
+	assert.Equal(t, "started 2 workers", info["message"])
+	assert.Contains(t, info["caller"], "logp_test.go:")
+	assert.NotContains(t, info, "selector")
+	_, err := time.Parse(time.RFC3339, info["timestamp"].(string))
+	assert.NoError(t, err)
+
+	var debug map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &debug))
+	assert.Equal(t, "debug", debug["level"])
+	assert.Equal(t, "test", debug["selector"])
+	assert.Equal(t, "redis", debug["output"])
+	assert.Equal(t, float64(3), debug["count"])
+}
+
+func TestTextFormatContext(t *testing.T) {
+	saved := _log
+	defer func() { _log = saved }()
+
+	read := logToFile(t, false)
+	With("host", "localhost:9200", "error", "connection refused").Warn("retrying")
+	lines := read()
+
+	if assert.Len(t, lines, 1) {
+		assert.True(t, strings.HasSuffix(lines[0],
+			`WARN retrying host=localhost:9200 error="connection refused"`), lines[0])
+	}
+}
+
+func TestContextWith(t *testing.T) {
+	ctx := With("a", 1, "b").With("c", 2)
+	assert.Equal(t, []interface{}{"a", 1, "b", nil, "c", 2}, ctx.fields)
+	assert.Equal(t, "a=1 b=<nil> c=2", formatContext(ctx.fields))
+}
+
+func TestJSONMessageUnsupportedValue(t *testing.T) {
+	line := jsonMessage(1, LOG_INFO, "", []interface{}{"ch", make(chan int)}, "msg")
+
+	var fields map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(line), &fields))
+	assert.IsType(t, "", fields["ch"])
+}
+
+func TestLoggingValidate(t *testing.T) {
+	assert.NoError(t, Logging{}.Validate())
+	assert.NoError(t, Logging{Level: "info", Format: "json"}.Validate())
+	assert.Error(t, Logging{Level: "verbose"}.Validate())
+	assert.Error(t, Logging{Format: "xml"}.Validate())
+	assert.Error(t, Logging{Files: &FileRotator{Interval: "weekly"}}.Validate())
+	assert.Error(t, Logging{Files: &FileRotator{Permissions: "rw"}}.Validate())
+}
@@ -0,0 +1,135 @@
+// Package monitoring provides the HTTP endpoint exposing the metrics of a
+// beat. Metrics are registered with the expvar package and served as JSON at
+// /debug/vars. Optionally the pprof profiling handlers are served at
+// /debug/pprof/.
+package monitoring
+
+import (
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/elastic/beats/libbeat/logp"
+)
+
+// Config configures the monitoring endpoint. The endpoint is disabled if no
+// bind address is set.
+type Config struct {
+	BindAddress string `yaml:"bind_address"` // Bind address of the HTTP endpoint. Format is host:port.
+	Pprof       bool   `yaml:"pprof"`        // Serve the pprof profiling handlers.
+}
+
+// Time the application was started.
+var startTime = time.Now().UTC()
+
+func init() {
+	expvar.Publish("uptime", expvar.Func(uptime))
+	expvar.Publish("runtime", expvar.Func(runtimeStats))
+}
+
+// Validate checks the bind address and returns an error describing any
+// problems or nil.
+func (c Config) Validate() error {
+	if c.BindAddress == "" {
+		if c.Pprof {
+			return fmt.Errorf("pprof requires bind_address to be set")
+		}
+		return nil
+	}
+
+	host, portStr, err := net.SplitHostPort(c.BindAddress)
+	if err != nil {
+		return fmt.Errorf("bind_address must be formatted as host:port but "+
+			"was '%s' (%v)", c.BindAddress, err)
+	}
+
+	if len(host) == 0 {
+		return fmt.Errorf("bind_address value ('%s') is missing a host",
+			c.BindAddress)
+	}
+
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return fmt.Errorf("bind_address port value ('%s') must be a number "+
+			"(%v)", portStr, err)
+	}
+
+	if port < 1 || port > 65535 {
+		return fmt.Errorf("bind_address port must be within [1-65535] but "+
+			"was '%d'", port)
+	}
+
+	return nil
+}
+
+// Enabled returns true if a bind address is configured.
+func (c Config) Enabled() bool {
+	return c.BindAddress != ""
+}
+
+// Start listens on the configured bind address and serves the metrics in the
+// background. Nothing is started if the endpoint is not enabled.
+func Start(config Config) error {
+	if !config.Enabled() {
+		return nil
+	}
+
+	sock, err := net.Listen("tcp", config.BindAddress)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		logp.Info("Metrics hosted at http://%s/debug/vars", sock.Addr())
+		err := http.Serve(sock, NewHandler(config))
+		if err != nil {
+			logp.Warn("Unable to launch HTTP service for metrics. %v", err)
+		}
+	}()
+	return nil
+}
+
+// NewHandler returns the handler serving the metrics and, if enabled, the
+// pprof handlers.
+func NewHandler(config Config) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	if config.Pprof {
+		logp.Info("Profiling data hosted at /debug/pprof/")
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+	return mux
+}
+
+// uptime returns a map of uptime related metrics.
+func uptime() interface{} {
+	now := time.Now().UTC()
+	uptimeDur := now.Sub(startTime)
+
+	return map[string]interface{}{
+		"start_time":  startTime,
+		"uptime":      uptimeDur.String(),
+		"uptime_ms":   fmt.Sprintf("%d", uptimeDur.Nanoseconds()/int64(time.Millisecond)),
+		"server_time": now,
+	}
+}
+
+// runtimeStats returns Go runtime metrics not contained in memstats.
+func runtimeStats() interface{} {
+	return map[string]interface{}{
+		"goroutines": runtime.NumGoroutine(),
+		"cpus":       runtime.NumCPU(),
+		"gomaxprocs": runtime.GOMAXPROCS(0),
+		"version":    runtime.Version(),
+	}
+}
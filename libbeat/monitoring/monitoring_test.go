@@ -0,0 +1,74 @@
+package monitoring
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigValidate(t *testing.T) {
+	var tests = []struct {
+		config Config
+		err    string
+	}{
+		{Config{}, ""},
+		{Config{BindAddress: "localhost:6060"}, ""},
+		{Config{BindAddress: "localhost:6060", Pprof: true}, ""},
+		{Config{Pprof: true}, "pprof requires bind_address to be set"},
+		{Config{BindAddress: "localhost"}, "bind_address must be formatted as host:port"},
+		{Config{BindAddress: ":6060"}, "is missing a host"},
+		{Config{BindAddress: "localhost:http"}, "must be a number"},
+		{Config{BindAddress: "localhost:0"}, "must be within [1-65535]"},
+	}
+
+	for _, test := range tests {
+		err := test.config.Validate()
+		if test.err == "" {
+			assert.NoError(t, err, "%+v", test.config)
+		} else if assert.Error(t, err, "%+v", test.config) {
+			assert.Contains(t, err.Error(), test.err)
+		}
+	}
+}
+
+func TestHandlerVars(t *testing.T) {
+	server := httptest.NewServer(NewHandler(Config{}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/debug/vars")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	vars := map[string]interface{}{}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&vars))
+	assert.Contains(t, vars, "memstats")
+	assert.Contains(t, vars, "uptime")
+	assert.Contains(t, vars, "runtime")
+}
+
+func TestHandlerPprof(t *testing.T) {
+	disabled := httptest.NewServer(NewHandler(Config{}))
+	defer disabled.Close()
+
+	resp, err := http.Get(disabled.URL + "/debug/pprof/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	enabled := httptest.NewServer(NewHandler(Config{Pprof: true}))
+	defer enabled.Close()
+
+	resp, err = http.Get(enabled.URL + "/debug/pprof/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
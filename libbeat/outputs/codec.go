@@ -0,0 +1,195 @@
+package outputs
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/fmtstr"
+)
+
+// Codec encodes an event into the message written by an output. The encoded
+// message must not end with a newline, outputs writing lines add the newline.
+type Codec interface {
+	Encode(event common.MapStr) ([]byte, error)
+}
+
+// CodecConfig selects the codec of the file, console and redis outputs. At
+// most one codec can be configured. If no codec is configured, events are
+// encoded as JSON.
+type CodecConfig struct {
+	JSON   *JSONCodecConfig
+	Format *FormatCodecConfig
+	CSV    *CSVCodecConfig
+}
+
+// JSONCodecConfig configures the JSON codec.
+type JSONCodecConfig struct {
+	Pretty bool
+}
+
+// FormatCodecConfig configures the format string codec. The format string
+// uses the same syntax as the index, e.g. '%{[@timestamp]} %{[message]}'.
+type FormatCodecConfig struct {
+	String string
+}
+
+// CSVCodecConfig configures the line codec writing the values of the
+// selected fields separated by Separator (',' by default). Fields are
+// selected by name, using dots for nested fields (e.g. 'beat.name').
+type CSVCodecConfig struct {
+	Fields    []string
+	Separator string
+}
+
+var (
+	// ErrMultipleCodecs indicates a codec configuration with more than one
+	// codec configured.
+	ErrMultipleCodecs = errors.New("only one codec can be configured")
+)
+
+// LoadCodec creates the codec configured in the outputs codec section. If no
+// codec is configured, the JSON codec is used without pretty printing.
+func LoadCodec(config *MothershipConfig) (Codec, error) {
+	codecs := 0
+	for _, set := range []bool{
+		config.Codec.JSON != nil,
+		config.Codec.Format != nil,
+		config.Codec.CSV != nil,
+	} {
+		if set {
+			codecs++
+		}
+	}
+	if codecs > 1 {
+		return nil, ErrMultipleCodecs
+	}
+
+	switch {
+	case config.Codec.JSON != nil:
+		return NewJSONCodec(config.Codec.JSON.Pretty), nil
+	case config.Codec.Format != nil:
+		return NewFormatCodec(config.Codec.Format.String)
+	case config.Codec.CSV != nil:
+		return NewCSVCodec(config.Codec.CSV.Fields, config.Codec.CSV.Separator)
+	}
+
+	return NewJSONCodec(false), nil
+}
+
+type jsonCodec struct {
+	pretty bool
+}
+
+// NewJSONCodec creates a codec encoding events as JSON documents. If pretty
+// is set, the documents are indented.
+func NewJSONCodec(pretty bool) Codec {
+	return jsonCodec{pretty}
+}
+
+func (c jsonCodec) Encode(event common.MapStr) ([]byte, error) {
+	if c.pretty {
+		return json.MarshalIndent(event, "", "  ")
+	}
+	return json.Marshal(event)
+}
+
+type formatCodec struct {
+	format *fmtstr.EventFormatString
+}
+
+// NewFormatCodec creates a codec evaluating a format string for every
+// event. Events missing a field referenced by the format string can not be
+// encoded.
+func NewFormatCodec(format string) (Codec, error) {
+	if format == "" {
+		return nil, errors.New("format codec requires a format string")
+	}
+
+	fs, err := fmtstr.CompileEvent(format)
+	if err != nil {
+		return nil, fmt.Errorf("invalid codec format string: %v", err)
+	}
+	return formatCodec{fs}, nil
+}
+
+func (c formatCodec) Encode(event common.MapStr) ([]byte, error) {
+	s, err := c.format.Run(event)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(s), nil
+}
+
+type csvCodec struct {
+	fields    []string
+	separator rune
+}
+
+// NewCSVCodec creates a codec writing the values of the given fields in
+// CSV format. Missing fields are written as empty values, objects and arrays
+// are written as JSON.
+func NewCSVCodec(fields []string, separator string) (Codec, error) {
+	if len(fields) == 0 {
+		return nil, errors.New("csv codec requires at least one field")
+	}
+
+	sep := ','
+	if separator != "" {
+		r, size := utf8.DecodeRuneInString(separator)
+		if size != len(separator) || r == '"' || r == '\n' || r == '\r' {
+			return nil, fmt.Errorf("invalid csv separator '%s'", separator)
+		}
+		sep = r
+	}
+
+	return csvCodec{fields: fields, separator: sep}, nil
+}
+
+func (c csvCodec) Encode(event common.MapStr) ([]byte, error) {
+	record := make([]string, len(c.fields))
+	for i, field := range c.fields {
+		// missing fields are written as empty values
+		value, _ := event.GetValue(field)
+		text, err := formatValue(value)
+		if err != nil {
+			return nil, err
+		}
+		record[i] = text
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Comma = c.separator
+	if err := w.Write(record); err != nil {
+		return nil, err
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// formatValue formats a field value as text.
+func formatValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case common.Time:
+		return time.Time(v).UTC().Format(common.TsLayout), nil
+	case time.Time:
+		return v.UTC().Format(common.TsLayout), nil
+	case common.MapStr, map[string]interface{}, []interface{}:
+		b, err := json.Marshal(v)
+		return string(b), err
+	}
+	return fmt.Sprint(value), nil
+}
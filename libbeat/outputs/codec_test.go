@@ -0,0 +1,113 @@
+package outputs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func codecTestEvent() common.MapStr {
+	ts := time.Date(2015, 11, 18, 12, 30, 5, 123000000, time.UTC)
+	return common.MapStr{
+		"@timestamp": common.Time(ts),
+		"type":       "log",
+		"message":    `hello, "world"`,
+		"offset":     42,
+		"beat":       common.MapStr{"name": "host1"},
+		"tags":       []interface{}{"a", "b"},
+	}
+}
+
+func TestLoadCodecDefault(t *testing.T) {
+	codec, err := LoadCodec(&MothershipConfig{})
+	assert.NoError(t, err)
+	assert.Equal(t, NewJSONCodec(false), codec)
+
+	// the pretty option of the console output is not used by other outputs
+	pretty := true
+	codec, err = LoadCodec(&MothershipConfig{Pretty: &pretty})
+	assert.NoError(t, err)
+	assert.Equal(t, NewJSONCodec(false), codec)
+}
+
+func TestLoadCodecMultiple(t *testing.T) {
+	_, err := LoadCodec(&MothershipConfig{
+		Codec: CodecConfig{
+			JSON:   &JSONCodecConfig{},
+			Format: &FormatCodecConfig{String: "%{message}"},
+		},
+	})
+	assert.Equal(t, ErrMultipleCodecs, err)
+}
+
+func TestLoadCodecInvalid(t *testing.T) {
+	configs := []CodecConfig{
+		{Format: &FormatCodecConfig{}},
+		{Format: &FormatCodecConfig{String: "%{message"}},
+		{CSV: &CSVCodecConfig{}},
+		{CSV: &CSVCodecConfig{Fields: []string{"a"}, Separator: ";;"}},
+		{CSV: &CSVCodecConfig{Fields: []string{"a"}, Separator: "\""}},
+	}
+	for _, codec := range configs {
+		_, err := LoadCodec(&MothershipConfig{Codec: codec})
+		assert.Error(t, err)
+	}
+}
+
+func TestJSONCodec(t *testing.T) {
+	event := common.MapStr{"message": "test", "offset": 1}
+
+	msg, err := NewJSONCodec(false).Encode(event)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"message":"test","offset":1}`, string(msg))
+
+	msg, err = NewJSONCodec(true).Encode(event)
+	assert.NoError(t, err)
+	assert.Equal(t, "{\n  \"message\": \"test\",\n  \"offset\": 1\n}", string(msg))
+}
+
+func TestFormatCodec(t *testing.T) {
+	codec, err := LoadCodec(&MothershipConfig{
+		Codec: CodecConfig{
+			Format: &FormatCodecConfig{String: "%{[@timestamp]} %{[beat.name]}: %{message}"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := codec.Encode(codecTestEvent())
+	assert.NoError(t, err)
+	assert.Equal(t, `2015-11-18T12:30:05.123Z host1: hello, "world"`, string(msg))
+
+	_, err = codec.Encode(common.MapStr{"message": "no beat"})
+	assert.Error(t, err)
+}
+
+func TestCSVCodec(t *testing.T) {
+	codec, err := NewCSVCodec([]string{
+		"@timestamp", "beat.name", "offset", "message", "tags", "missing",
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := codec.Encode(codecTestEvent())
+	assert.NoError(t, err)
+	assert.Equal(t,
+		`2015-11-18T12:30:05.123Z,host1,42,"hello, ""world""","[""a"",""b""]",`,
+		string(msg))
+}
+
+func TestCSVCodecSeparator(t *testing.T) {
+	codec, err := NewCSVCodec([]string{"type", "beat.name", "offset"}, "\t")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := codec.Encode(codecTestEvent())
+	assert.NoError(t, err)
+	assert.Equal(t, "log\thost1\t42", string(msg))
+}
@@ -1,7 +1,6 @@
 package console
 
 import (
-	"encoding/json"
 	"os"
 	"time"
 
@@ -21,16 +20,28 @@ func (p plugin) NewOutput(
 	config *outputs.MothershipConfig,
 	topologyExpire int,
 ) (outputs.Outputer, error) {
-	pretty := config.Pretty != nil && *config.Pretty
-	return newConsole(pretty), nil
+	codec, err := loadCodec(config)
+	if err != nil {
+		return nil, err
+	}
+	return newConsole(codec), nil
+}
+
+// loadCodec creates the configured codec. If no codec is configured, the
+// pretty option of the console output selects pretty printed JSON.
+func loadCodec(config *outputs.MothershipConfig) (outputs.Codec, error) {
+	if config.Codec == (outputs.CodecConfig{}) && config.Pretty != nil && *config.Pretty {
+		return outputs.NewJSONCodec(true), nil
+	}
+	return outputs.LoadCodec(config)
 }
 
 type console struct {
-	pretty bool
+	codec outputs.Codec
 }
 
-func newConsole(pretty bool) *console {
-	return &console{pretty}
+func newConsole(codec outputs.Codec) *console {
+	return &console{codec}
 }
 
 func writeBuffer(buf []byte) error {
@@ -51,21 +62,14 @@ func (c *console) PublishEvent(
 	ts time.Time,
 	event common.MapStr,
 ) error {
-	var jsonEvent []byte
-	var err error
-
-	if c.pretty {
-		jsonEvent, err = json.MarshalIndent(event, "", "  ")
-	} else {
-		jsonEvent, err = json.Marshal(event)
-	}
+	msg, err := c.codec.Encode(event)
 	if err != nil {
-		logp.Err("Fail to convert the event to JSON: %s", err)
+		logp.Err("Fail to encode event: %s", err)
 		outputs.SignalCompleted(s)
 		return err
 	}
 
-	if err = writeBuffer(jsonEvent); err != nil {
+	if err = writeBuffer(msg); err != nil {
 		goto fail
 	}
 	if err = writeBuffer([]byte{'\n'}); err != nil {
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/outputs"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -53,21 +54,21 @@ func run(c *console, events ...common.MapStr) (string, error) {
 }
 
 func TestConsoleOneEvent(t *testing.T) {
-	lines, err := run(newConsole(false), event("event", "myevent"))
+	lines, err := run(newConsole(outputs.NewJSONCodec(false)), event("event", "myevent"))
 	assert.Nil(t, err)
 	expected := "{\"event\":\"myevent\"}\n"
 	assert.Equal(t, expected, lines)
 }
 
 func TestConsoleOneEventIndented(t *testing.T) {
-	lines, err := run(newConsole(true), event("event", "myevent"))
+	lines, err := run(newConsole(outputs.NewJSONCodec(true)), event("event", "myevent"))
 	assert.Nil(t, err)
 	expected := "{\n  \"event\": \"myevent\"\n}\n"
 	assert.Equal(t, expected, lines)
 }
 
 func TestConsoleMultipleEvents(t *testing.T) {
-	lines, err := run(newConsole(false),
+	lines, err := run(newConsole(outputs.NewJSONCodec(false)),
 		event("event", "event1"),
 		event("event", "event2"),
 		event("event", "event3"),
@@ -79,7 +80,7 @@ func TestConsoleMultipleEvents(t *testing.T) {
 }
 
 func TestConsoleMultipleEventsIndented(t *testing.T) {
-	lines, err := run(newConsole(true),
+	lines, err := run(newConsole(outputs.NewJSONCodec(true)),
 		event("event", "event1"),
 		event("event", "event2"),
 		event("event", "event3"),
@@ -91,3 +92,33 @@ func TestConsoleMultipleEventsIndented(t *testing.T) {
 		"{\n  \"event\": \"event3\"\n}\n"
 	assert.Equal(t, expected, lines)
 }
+
+func TestConsoleFormatCodec(t *testing.T) {
+	codec, err := outputs.NewFormatCodec("%{type}: %{message}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines, err := run(newConsole(codec),
+		common.MapStr{"type": "log", "message": "line1"},
+		common.MapStr{"message": "dropped"},
+		common.MapStr{"type": "log", "message": "line2"},
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, "log: line1\nlog: line2\n", lines)
+}
+
+func TestLoadCodecPretty(t *testing.T) {
+	pretty := true
+	codec, err := loadCodec(&outputs.MothershipConfig{Pretty: &pretty})
+	assert.NoError(t, err)
+	assert.Equal(t, outputs.NewJSONCodec(true), codec)
+
+	// a configured codec takes precedence
+	codec, err = loadCodec(&outputs.MothershipConfig{
+		Pretty: &pretty,
+		Codec:  outputs.CodecConfig{JSON: &outputs.JSONCodecConfig{}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, outputs.NewJSONCodec(false), codec)
+}
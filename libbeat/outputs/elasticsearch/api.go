@@ -13,6 +13,7 @@ type QueryResult struct {
 	Exists  bool            `json:"exists"`
 	Created bool            `json:"created"`
 	Matches []string        `json:"matches"`
+	Error   json.RawMessage `json:"error"`
 }
 
 type SearchResults struct {
@@ -95,7 +96,9 @@ func (es *Connection) Index(
 
 	status, resp, err := es.apiCall(method, index, docType, id, params, body)
 	if err != nil {
-		return status, nil, err
+		// return the error reported by Elasticsearch if the response has one
+		result, _ := readQueryResult(resp)
+		return status, result, err
 	}
 	result, err := readQueryResult(resp)
 	return status, result, err
@@ -159,6 +162,53 @@ func (es *Connection) CountSearchURI(
 	return status, result, err
 }
 
+// LoadTemplate installs an index template under the given name, replacing
+// an existing template of the same name.
+// Implements: https://www.elastic.co/guide/en/elasticsearch/reference/current/indices-templates.html
+func (es *Connection) LoadTemplate(
+	name string,
+	template map[string]interface{},
+) (int, *QueryResult, error) {
+	status, resp, err := es.apiCall("PUT", "_template", "", name, nil, template)
+	if err != nil {
+		return status, nil, err
+	}
+	result, err := readQueryResult(resp)
+	return status, result, err
+}
+
+// TemplateExists checks if an index template with the given name is
+// installed.
+func (es *Connection) TemplateExists(name string) (bool, error) {
+	status, _, err := es.apiCall("HEAD", "_template", "", name, nil, nil)
+	if status == 404 {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// GetVersion returns the version number of the Elasticsearch node, e.g.
+// "2.1.0".
+func (es *Connection) GetVersion() (string, error) {
+	_, resp, err := es.request("GET", "/", nil, nil)
+	if err != nil {
+		return "", err
+	}
+
+	var info struct {
+		Version struct {
+			Number string `json:"number"`
+		} `json:"version"`
+	}
+	if err := json.Unmarshal(resp, &info); err != nil {
+		return "", err
+	}
+	return info.Version.Number, nil
+}
+
 func (es *Connection) apiCall(
 	method, index, docType, id string,
 	params map[string]string,
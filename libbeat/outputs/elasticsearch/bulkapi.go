@@ -2,7 +2,9 @@ package elasticsearch
 
 import (
 	"bytes"
+	"compress/gzip"
 	"encoding/json"
+	"net/http"
 
 	"github.com/elastic/beats/libbeat/logp"
 )
@@ -25,6 +27,7 @@ type bulkMeta struct {
 type bulkMetaIndex struct {
 	Index   string `json:"_index"`
 	DocType string `json:"_type"`
+	ID      string `json:"_id,omitempty"`
 }
 
 type BulkResult struct {
@@ -129,7 +132,29 @@ func (conn *Connection) sendBulkRequest(
 	url := makeURL(conn.URL, path, params)
 	logp.Debug("elasticsearch", "Sending bulk request to %s", url)
 
-	return conn.execRequest(method, url, buf)
+	if conn.compressionLevel == 0 {
+		return conn.execRequest(method, url, buf)
+	}
+
+	var compressed bytes.Buffer
+	w, err := gzip.NewWriterLevel(&compressed, conn.compressionLevel)
+	if err != nil {
+		return 0, nil, err
+	}
+	if _, err := buf.WriteTo(w); err != nil {
+		return 0, nil, err
+	}
+	if err := w.Close(); err != nil {
+		return 0, nil, err
+	}
+
+	req, err := http.NewRequest(method, url, &compressed)
+	if err != nil {
+		logp.Warn("Failed to create request: %v", err)
+		return 0, nil, err
+	}
+	req.Header.Add("Content-Encoding", "gzip")
+	return conn.execHTTPRequest(req)
 }
 
 func bulkEncode(metaBuilder MetaBuilder, body []interface{}) bytes.Buffer {
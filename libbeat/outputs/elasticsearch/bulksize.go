@@ -0,0 +1,78 @@
+package elasticsearch
+
+import (
+	"expvar"
+	"net"
+)
+
+// Metrics of the adaptive bulk sizing.
+var (
+	bulkSizeCurrent   = expvar.NewInt("libbeatEsBulkSize")
+	bulkSizeDecreases = expvar.NewInt("libbeatEsBulkSizeDecreases")
+	bulkSizeIncreases = expvar.NewInt("libbeatEsBulkSizeIncreases")
+	bulkThrottled     = expvar.NewInt("libbeatEsBulkThrottledEvents")
+	bulkTimeouts      = expvar.NewInt("libbeatEsBulkTimeouts")
+)
+
+const minBulkSize = 1
+
+// bulkSizer adapts the number of events sent per bulk request. The bulk size
+// is halved if Elasticsearch is overloaded (status 429 or timeout) and grows
+// by factor 1.5 on success, up to the configured bulk_max_size.
+type bulkSizer struct {
+	size int // current bulk size, <= 0 if unlimited
+	max  int // configured bulk_max_size, <= 0 if unlimited
+}
+
+func newBulkSizer(max int) bulkSizer {
+	return bulkSizer{size: max, max: max}
+}
+
+// limit returns the number of events of n events to send in the next bulk
+// request.
+func (b *bulkSizer) limit(n int) int {
+	if b.size > 0 && n > b.size {
+		return b.size
+	}
+	return n
+}
+
+// shrink halves the bulk size after sending sent events failed due to
+// Elasticsearch being overloaded.
+func (b *bulkSizer) shrink(sent int) {
+	size := sent / 2
+	if size < minBulkSize {
+		size = minBulkSize
+	}
+	if b.size > 0 && size >= b.size {
+		return
+	}
+
+	debug("Decrease bulk size to %v", size)
+	b.size = size
+	bulkSizeDecreases.Add(1)
+	bulkSizeCurrent.Set(int64(size))
+}
+
+// grow increases the bulk size after a successful bulk request.
+func (b *bulkSizer) grow() {
+	if b.size <= 0 || b.size == b.max {
+		return
+	}
+
+	size := b.size + b.size/2 + 1
+	if b.max > 0 && size > b.max {
+		size = b.max
+	}
+
+	debug("Increase bulk size to %v", size)
+	b.size = size
+	bulkSizeIncreases.Add(1)
+	bulkSizeCurrent.Set(int64(size))
+}
+
+// isTimeout checks if a request failed due to a timeout.
+func isTimeout(err error) bool {
+	nerr, ok := err.(net.Error)
+	return ok && nerr.Timeout()
+}
@@ -0,0 +1,179 @@
+package elasticsearch
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/fmtstr"
+	"github.com/elastic/beats/libbeat/outputs/mode"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBulkSizer(t *testing.T) {
+	b := newBulkSizer(50)
+	assert.Equal(t, 50, b.limit(100))
+	assert.Equal(t, 20, b.limit(20))
+
+	b.shrink(50)
+	assert.Equal(t, 25, b.size)
+	b.shrink(25)
+	b.shrink(12)
+	b.shrink(6)
+	b.shrink(3)
+	b.shrink(1)
+	assert.Equal(t, minBulkSize, b.size)
+
+	// a smaller batch failing must not increase the bulk size
+	b.size = 10
+	b.shrink(30)
+	assert.Equal(t, 10, b.size)
+
+	b.grow()
+	assert.Equal(t, 16, b.size)
+	b.grow()
+	b.grow()
+	b.grow()
+	assert.Equal(t, 50, b.size)
+}
+
+func TestBulkSizerUnlimited(t *testing.T) {
+	b := newBulkSizer(-1)
+	assert.Equal(t, 1000, b.limit(1000))
+
+	b.grow()
+	assert.Equal(t, 1000, b.limit(1000))
+
+	b.shrink(1000)
+	assert.Equal(t, 500, b.limit(1000))
+	b.grow()
+	assert.Equal(t, 751, b.limit(1000))
+}
+
+// bulkMock is an Elasticsearch mock answering bulk requests. Items are
+// rejected with status 429 while throttle is set.
+type bulkMock struct {
+	throttle   bool
+	requests   []int // number of events per bulk request
+	compressed bool
+}
+
+func (m *bulkMock) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasSuffix(r.URL.Path, "_bulk") {
+		return // connection check
+	}
+
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		m.compressed = true
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			w.WriteHeader(400)
+			return
+		}
+		body = gz
+	}
+
+	lines := 0
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		lines++
+	}
+	count := lines / 2
+	m.requests = append(m.requests, count)
+
+	status := 201
+	if m.throttle {
+		status = 429
+	}
+	items := make([]string, count)
+	for i := range items {
+		items[i] = fmt.Sprintf(`{"create": {"status": %d}}`, status)
+	}
+	fmt.Fprintf(w, `{"items": [%s]}`, strings.Join(items, ","))
+}
+
+func newBulkMockClient(m *bulkMock, maxBulkSize, compressionLevel int) (*Client, func()) {
+	server := httptest.NewServer(m)
+	client := NewClient(server.URL, fmtstr.MustCompileEvent("test"), nil, nil, "", "")
+	client.bulkSize = newBulkSizer(maxBulkSize)
+	client.compressionLevel = compressionLevel
+	client.Connect(time.Second)
+	return client, server.Close
+}
+
+func makeEvents(n int) []common.MapStr {
+	events := make([]common.MapStr, n)
+	for i := range events {
+		events[i] = common.MapStr{
+			"@timestamp": common.Time(time.Now()),
+			"type":       "test",
+			"message":    fmt.Sprintf("event %d", i),
+		}
+	}
+	return events
+}
+
+func TestPublishEventsAdaptiveBulkSize(t *testing.T) {
+	m := &bulkMock{throttle: true}
+	client, stop := newBulkMockClient(m, 40, 0)
+	defer stop()
+
+	// throttled: all events are returned and the bulk size is halved
+	events := makeEvents(100)
+	rest, err := client.PublishEvents(events)
+	assert.Equal(t, mode.ErrTempBulkFailure, err)
+	assert.Len(t, rest, 100)
+	assert.Equal(t, []int{40}, m.requests)
+	assert.Equal(t, 20, client.bulkSize.size)
+
+	// recovered: events are sent in batches of the current bulk size, the
+	// bulk size grows with every successful request
+	m.throttle = false
+	m.requests = nil
+	for len(rest) > 0 {
+		rest, err = client.PublishEvents(rest)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, []int{20, 31, 40, 9}, m.requests)
+	assert.Equal(t, 40, client.bulkSize.size)
+}
+
+func TestPublishEventsCompressed(t *testing.T) {
+	m := &bulkMock{}
+	client, stop := newBulkMockClient(m, 50, gzip.BestSpeed)
+	defer stop()
+
+	rest, err := client.PublishEvents(makeEvents(10))
+	assert.NoError(t, err)
+	assert.Empty(t, rest)
+	assert.True(t, m.compressed)
+	assert.Equal(t, []int{10}, m.requests)
+}
+
+func TestPublishEventsTimeoutShrinksBulkSize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "_bulk") {
+			time.Sleep(200 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, fmtstr.MustCompileEvent("test"), nil, nil, "", "")
+	client.bulkSize = newBulkSizer(40)
+	client.Connect(50 * time.Millisecond)
+
+	before := bulkTimeouts.Value()
+	rest, err := client.PublishEvents(makeEvents(100))
+	assert.Error(t, err)
+	assert.Len(t, rest, 100)
+	assert.Equal(t, 20, client.bulkSize.size)
+	assert.Equal(t, before+1, bulkTimeouts.Value())
+}
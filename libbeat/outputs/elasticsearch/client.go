@@ -12,13 +12,17 @@ import (
 	"time"
 
 	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/fmtstr"
 	"github.com/elastic/beats/libbeat/logp"
 	"github.com/elastic/beats/libbeat/outputs/mode"
 )
 
 type Client struct {
 	Connection
-	index string
+	index      *fmtstr.EventFormatString
+	template   *template
+	deadLetter deadLetter
+	bulkSize   bulkSizer
 }
 
 type Connection struct {
@@ -26,12 +30,18 @@ type Connection struct {
 	Username string
 	Password string
 
+	// gzip compression level of bulk requests, 0 disables compression
+	compressionLevel int
+
 	http      *http.Client
 	connected bool
 }
 
+// NewClient creates a new Elasticsearch client. The index format string
+// selects the index of each published event.
 func NewClient(
-	esURL, index string, proxyURL *url.URL, tls *tls.Config,
+	esURL string, index *fmtstr.EventFormatString,
+	proxyURL *url.URL, tls *tls.Config,
 	username, password string,
 ) *Client {
 	proxy := http.ProxyFromEnvironment
@@ -52,6 +62,9 @@ func NewClient(
 			},
 		},
 		index,
+		nil,
+		nil,
+		newBulkSizer(0),
 	}
 	return client
 }
@@ -59,22 +72,29 @@ func NewClient(
 func (client *Client) Clone() *Client {
 	newClient := &Client{
 		Connection{
-			URL:      client.URL,
-			Username: client.Username,
-			Password: client.Password,
+			URL:              client.URL,
+			Username:         client.Username,
+			Password:         client.Password,
+			compressionLevel: client.compressionLevel,
 			http: &http.Client{
 				Transport: client.http.Transport,
 			},
 			connected: false,
 		},
 		client.index,
+		client.template,
+		client.deadLetter,
+		newBulkSizer(client.bulkSize.max),
 	}
 	return newClient
 }
 
-// PublishEvents sends all events to elasticsearch. On error a slice with all
-// events not published or confirmed to be processed by elasticsearch will be
-// returned. The input slice backing memory will be reused by return the value.
+// PublishEvents sends the events to elasticsearch. At most the current bulk
+// size of events is sent in one bulk request. The events not sent yet are
+// returned without error, so the connection mode continues sending. On error
+// a slice with all events not published or confirmed to be processed by
+// elasticsearch will be returned. The input slice backing memory will be
+// reused by return the value.
 func (client *Client) PublishEvents(
 	events []common.MapStr,
 ) ([]common.MapStr, error) {
@@ -82,6 +102,21 @@ func (client *Client) PublishEvents(
 		return events, ErrNotConnected
 	}
 
+	n := client.bulkSize.limit(len(events))
+	failed, err := client.publishBulk(events[:n])
+	if len(failed) == 0 && err == nil {
+		return events[n:], nil
+	}
+	return append(failed, events[n:]...), err
+}
+
+// publishBulk sends the events in one bulk request, adapting the bulk size to
+// the outcome.
+func (client *Client) publishBulk(
+	events []common.MapStr,
+) ([]common.MapStr, error) {
+	sent := len(events)
+
 	// new request to store all events into
 	request, err := client.startBulkRequest("", "", nil)
 	if err != nil {
@@ -100,11 +135,22 @@ func (client *Client) PublishEvents(
 	_, res, err := request.Flush()
 	if err != nil {
 		logp.Err("Failed to perform any bulk index operations: %s", err)
+		if isTimeout(err) {
+			bulkTimeouts.Add(1)
+			client.bulkSize.shrink(sent)
+		}
 		return events, err
 	}
 
 	// check response for transient errors
-	events = bulkCollectPublishFails(res, events)
+	events, rejected, throttled := bulkCollectPublishFails(res, events)
+	client.writeDeadLetter(rejected)
+	if throttled > 0 {
+		bulkThrottled.Add(int64(throttled))
+		client.bulkSize.shrink(sent)
+	} else {
+		client.bulkSize.grow()
+	}
 	if len(events) > 0 {
 		return events, mode.ErrTempBulkFailure
 	}
@@ -116,13 +162,18 @@ func (client *Client) PublishEvents(
 // successfully added to bulk request.
 func bulkEncodePublishRequest(
 	requ *bulkRequest,
-	index string,
+	index *fmtstr.EventFormatString,
 	events []common.MapStr,
 ) []common.MapStr {
 	okEvents := events[:0]
 	for _, event := range events {
-		meta := eventBulkMeta(index, event)
-		err := requ.Send(meta, event)
+		meta, err := eventBulkMeta(index, event)
+		if err != nil {
+			logp.Err("Failed to select index or type: %s", err)
+			continue
+		}
+
+		err = requ.Send(meta, event)
 		if err != nil {
 			logp.Err("Failed to encode event: %s", err)
 			continue
@@ -133,27 +184,41 @@ func bulkEncodePublishRequest(
 	return okEvents
 }
 
-func eventBulkMeta(index string, event common.MapStr) bulkMeta {
-	ts := time.Time(event["@timestamp"].(common.Time)).UTC()
-	index = fmt.Sprintf("%s-%d.%02d.%02d", index,
-		ts.Year(), ts.Month(), ts.Day())
+func eventBulkMeta(
+	index *fmtstr.EventFormatString,
+	event common.MapStr,
+) (bulkMeta, error) {
+	name, err := index.Run(event)
+	if err != nil {
+		return bulkMeta{}, err
+	}
+
+	docType, err := event.GetString("type")
+	if err != nil {
+		return bulkMeta{}, err
+	}
+
 	meta := bulkMeta{
 		Index: bulkMetaIndex{
-			Index:   index,
-			DocType: event["type"].(string),
+			Index:   name,
+			DocType: docType,
+			ID:      common.EventIDOf(event),
 		},
 	}
-	return meta
+	return meta, nil
 }
 
 // bulkCollectPublishFails checks per item errors returning all events
 // to be tried again due to error code returned for that items. If indexing an
 // event failed due to some error in the event itself (e.g. does not respect mapping),
-// the event will be dropped.
+// the event will be dropped and returned in the rejected list. The number of
+// events rejected with status 429 (too many requests) is returned as well.
 func bulkCollectPublishFails(
 	res *BulkResult,
 	events []common.MapStr,
-) []common.MapStr {
+) ([]common.MapStr, []rejectedEvent, int) {
+	var rejected []rejectedEvent
+	throttled := 0
 	failed := events[:0]
 	for i, rawItem := range res.Items {
 		status, msg, err := itemStatus(rawItem)
@@ -171,14 +236,19 @@ func bulkCollectPublishFails(
 		if status < 500 && status != 429 {
 			// hard failure, don't collect
 			logp.Warn("Can not index event (status=%v): %v", status, msg)
+			rejected = append(rejected, rejectedEvent{events[i], status, msg})
 			continue
 		}
 
+		if status == 429 {
+			throttled++
+		}
+
 		debug("Failed to insert data(%v): %v", i, events[i])
 		logp.Info("Bulk item insert failed (i=%v, status=%v): %v", i, status, msg)
 		failed = append(failed, events[i])
 	}
-	return failed
+	return failed, rejected, throttled
 }
 
 func itemStatus(m json.RawMessage) (int, string, error) {
@@ -210,13 +280,23 @@ func (client *Client) PublishEvent(event common.MapStr) error {
 		return ErrNotConnected
 	}
 
-	ts := time.Time(event["@timestamp"].(common.Time))
-	index := fmt.Sprintf("%s-%d.%02d.%02d",
-		client.index, ts.Year(), ts.Month(), ts.Day())
+	index, err := client.index.Run(event)
+	if err != nil {
+		// the event can not be indexed => don't retry
+		logp.Err("Failed to select index: %s", err)
+		return nil
+	}
+	docType, err := event.GetString("type")
+	if err != nil {
+		// the event can not be indexed => don't retry
+		logp.Err("Failed to select type: %s", err)
+		return nil
+	}
 	logp.Debug("output_elasticsearch", "Publish event: %s", event)
 
 	// insert the events one by one
-	status, _, err := client.Index(index, event["type"].(string), "", nil, event)
+	id := common.EventIDOf(event)
+	status, result, err := client.Index(index, docType, id, nil, event)
 	if err != nil {
 		logp.Warn("Fail to insert a single event: %s", err)
 		if err == ErrJSONEncodeFailed {
@@ -231,12 +311,35 @@ func (client *Client) PublishEvent(event common.MapStr) error {
 		return err
 	case status >= 300 && status < 500:
 		// won't be able to index event in Elasticsearch => don't retry
+		reason := err.Error()
+		if result != nil && len(result.Error) > 0 {
+			reason = string(result.Error)
+		}
+		client.writeDeadLetter([]rejectedEvent{{event, status, reason}})
 		return nil
 	}
 
 	return nil
 }
 
+// Connect connects to Elasticsearch and installs the index template if
+// configured.
+func (client *Client) Connect(timeout time.Duration) error {
+	err := client.Connection.Connect(timeout)
+	if err != nil || client.template == nil {
+		return err
+	}
+
+	if err := client.loadTemplate(); err != nil {
+		client.connected = false
+		return err
+	}
+
+	// checking for a missing template resets the connected flag
+	client.connected = true
+	return nil
+}
+
 func (conn *Connection) Connect(timeout time.Duration) error {
 	var err error
 	conn.connected, err = conn.Ping(timeout)
@@ -301,7 +404,10 @@ func (conn *Connection) execRequest(
 		logp.Warn("Failed to create request", err)
 		return 0, nil, err
 	}
+	return conn.execHTTPRequest(req)
+}
 
+func (conn *Connection) execHTTPRequest(req *http.Request) (int, []byte, error) {
 	req.Header.Add("Accept", "application/json")
 	if conn.Username != "" || conn.Password != "" {
 		req.SetBasicAuth(conn.Username, conn.Password)
@@ -317,7 +423,9 @@ func (conn *Connection) execRequest(
 	status := resp.StatusCode
 	if status >= 300 {
 		conn.connected = false
-		return status, nil, fmt.Errorf("%v", resp.Status)
+		// the body describes the error, e.g. the reason a document was rejected
+		obj, _ := ioutil.ReadAll(resp.Body)
+		return status, obj, fmt.Errorf("%v", resp.Status)
 	}
 
 	obj, err := ioutil.ReadAll(resp.Body)
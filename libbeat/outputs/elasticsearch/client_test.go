@@ -3,7 +3,10 @@ package elasticsearch
 import (
 	"encoding/json"
 	"testing"
+	"time"
 
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/outputs"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -33,3 +36,53 @@ func TestES2StyleErrorStatus(t *testing.T) {
 	assert.Equal(t, 400, code)
 	assert.Equal(t, `{"reason": "test_error"}`, msg)
 }
+
+func TestEventBulkMeta(t *testing.T) {
+	ts := time.Date(2015, 12, 3, 23, 0, 0, 0, time.UTC)
+	event := common.MapStr{
+		"@timestamp": common.Time(ts),
+		"type":       "http",
+		"beat":       common.MapStr{"name": "host1"},
+	}
+
+	tests := map[string]string{
+		"packetbeat":                         "packetbeat-2015.12.03",
+		"%{[beat.name]}-%{type}-%{+yyyy.MM}": "host1-http-2015.12",
+		"packetbeat-%{+yyyy.MM.dd.HH}":       "packetbeat-2015.12.03.23",
+	}
+	for format, expected := range tests {
+		index, err := outputs.CompileIndex(format)
+		if !assert.NoError(t, err, format) {
+			continue
+		}
+
+		meta, err := eventBulkMeta(index, event)
+		assert.NoError(t, err, format)
+		assert.Equal(t, expected, meta.Index.Index, format)
+		assert.Equal(t, "http", meta.Index.DocType, format)
+	}
+
+	index, _ := outputs.CompileIndex("%{[fields.missing]}")
+	_, err := eventBulkMeta(index, event)
+	assert.Error(t, err)
+}
+
+func TestEventBulkMetaID(t *testing.T) {
+	index, _ := outputs.CompileIndex("packetbeat")
+	event := common.MapStr{
+		"@timestamp": common.Time(time.Now()),
+		"type":       "http",
+	}
+
+	meta, err := eventBulkMeta(index, event)
+	assert.NoError(t, err)
+	assert.Equal(t, "", meta.Index.ID)
+
+	encoded, _ := json.Marshal(meta)
+	assert.NotContains(t, string(encoded), "_id")
+
+	event[common.EventIDField] = "abc"
+	meta, err = eventBulkMeta(index, event)
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", meta.Index.ID)
+}
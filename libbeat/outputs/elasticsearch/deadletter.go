@@ -0,0 +1,186 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/logp"
+	"github.com/elastic/beats/libbeat/outputs"
+)
+
+// Counters of the events stored in the dead letter destination.
+var (
+	deadLetterEvents      = expvar.NewInt("libbeatEsDeadLetterEvents")
+	deadLetterWriteErrors = expvar.NewInt("libbeatEsDeadLetterWriteErrors")
+)
+
+const deadLetterDocType = "dead_letter"
+
+// rejectedEvent is an event Elasticsearch refused to index with a status
+// not worth retrying, e.g. due to a mapping conflict.
+type rejectedEvent struct {
+	event  common.MapStr
+	status int
+	reason string
+}
+
+// deadLetter stores rejected events, so they can be fixed and replayed.
+type deadLetter interface {
+	// Write stores the rejected events. The connection of the client which
+	// published the events is passed for destinations in Elasticsearch.
+	Write(conn *Connection, events []rejectedEvent) error
+}
+
+// newDeadLetter creates the dead letter destination configured. It returns
+// nil if no destination is configured.
+func newDeadLetter(beat string, config *outputs.DeadLetterConfig) (deadLetter, error) {
+	if config == nil {
+		return nil, nil
+	}
+
+	switch {
+	case config.Path != "" && config.Index != "":
+		return nil, errors.New("dead_letter: path and index are mutually exclusive")
+	case config.Path != "":
+		return newFileDeadLetter(beat, config)
+	case config.Index != "":
+		return &indexDeadLetter{index: config.Index}, nil
+	}
+	return nil, errors.New("dead_letter requires path or index to be set")
+}
+
+// deadLetterRecord returns the record stored for a rejected event.
+func deadLetterRecord(ts time.Time, rejected rejectedEvent) common.MapStr {
+	return common.MapStr{
+		"@timestamp": common.Time(ts),
+		"status":     rejected.status,
+		"error":      rejected.reason,
+		"event":      rejected.event,
+	}
+}
+
+// fileDeadLetter writes one JSON record per line to rotating files.
+type fileDeadLetter struct {
+	mutex   sync.Mutex
+	rotator logp.FileRotator
+}
+
+func newFileDeadLetter(beat string, config *outputs.DeadLetterConfig) (*fileDeadLetter, error) {
+	d := &fileDeadLetter{}
+	d.rotator.Path = config.Path
+	d.rotator.Name = config.Filename
+	if d.rotator.Name == "" {
+		d.rotator.Name = beat + "-dead-letter"
+	}
+
+	if config.RotateEveryKb > 0 {
+		rotateEveryBytes := uint64(config.RotateEveryKb) * 1024
+		d.rotator.RotateEveryBytes = &rotateEveryBytes
+	}
+	if config.NumberOfFiles > 0 {
+		keepFiles := config.NumberOfFiles
+		d.rotator.KeepFiles = &keepFiles
+	}
+
+	if err := d.rotator.CreateDirectory(); err != nil {
+		return nil, err
+	}
+	if err := d.rotator.CheckIfConfigSane(); err != nil {
+		return nil, err
+	}
+
+	logp.Info("Dead letter events are written to %s", d.rotator.FilePath(0))
+	return d, nil
+}
+
+func (d *fileDeadLetter) Write(conn *Connection, events []rejectedEvent) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	now := time.Now()
+	for _, rejected := range events {
+		line, err := json.Marshal(deadLetterRecord(now, rejected))
+		if err != nil {
+			return err
+		}
+		if err := d.rotator.WriteLine(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexDeadLetter indexes the records into daily indices of their own. The
+// original event is stored as JSON string so the mapping conflicts which
+// caused the rejection cannot occur again.
+type indexDeadLetter struct {
+	index string
+}
+
+func (d *indexDeadLetter) Write(conn *Connection, events []rejectedEvent) error {
+	request, err := conn.startBulkRequest("", "", nil)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	index := fmt.Sprintf("%s-%d.%02d.%02d", d.index,
+		now.Year(), now.Month(), now.Day())
+	meta := bulkMeta{
+		Index: bulkMetaIndex{Index: index, DocType: deadLetterDocType},
+	}
+
+	for _, rejected := range events {
+		record := deadLetterRecord(now, rejected)
+		event, err := json.Marshal(rejected.event)
+		if err != nil {
+			return err
+		}
+		record["event"] = string(event)
+
+		if err := request.Send(meta, record); err != nil {
+			return err
+		}
+	}
+
+	_, res, err := request.Flush()
+	if err != nil {
+		return err
+	}
+	if res == nil {
+		return nil
+	}
+
+	for _, item := range res.Items {
+		status, msg, err := itemStatus(item)
+		if err != nil {
+			return err
+		}
+		if status >= 300 {
+			return fmt.Errorf("status=%v: %v", status, msg)
+		}
+	}
+	return nil
+}
+
+// writeDeadLetter stores rejected events in the dead letter destination if
+// one is configured.
+func (client *Client) writeDeadLetter(events []rejectedEvent) {
+	if client.deadLetter == nil || len(events) == 0 {
+		return
+	}
+
+	err := client.deadLetter.Write(&client.Connection, events)
+	if err != nil {
+		logp.Err("Failed to write %d events to dead letter destination: %v",
+			len(events), err)
+		deadLetterWriteErrors.Add(int64(len(events)))
+		return
+	}
+	deadLetterEvents.Add(int64(len(events)))
+}
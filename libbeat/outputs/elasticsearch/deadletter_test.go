@@ -0,0 +1,207 @@
+package elasticsearch
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/fmtstr"
+	"github.com/elastic/beats/libbeat/outputs"
+	"github.com/stretchr/testify/assert"
+)
+
+func testEvent(msg string) common.MapStr {
+	return common.MapStr{
+		"@timestamp": common.Time(time.Now()),
+		"type":       "test",
+		"message":    msg,
+	}
+}
+
+// readDeadLetterFile reads all records written to the dead letter file.
+func readDeadLetterFile(t *testing.T, path string) []map[string]interface{} {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var records []map[string]interface{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestBulkCollectPublishFails(t *testing.T) {
+	events := []common.MapStr{testEvent("a"), testEvent("b"), testEvent("c"), testEvent("d")}
+	res := &BulkResult{Items: []json.RawMessage{
+		json.RawMessage(`{"create": {"status": 201}}`),
+		json.RawMessage(`{"create": {"status": 400, "error": {"reason": "mapper_parsing_exception"}}}`),
+		json.RawMessage(`{"create": {"status": 429, "error": "rejected"}}`),
+		json.RawMessage(`{"create": {"status": 409, "error": "conflict"}}`),
+	}}
+	b, c, d := events[1], events[2], events[3]
+
+	failed, rejected, throttled := bulkCollectPublishFails(res, events)
+	assert.Equal(t, []common.MapStr{c}, failed)
+	assert.Equal(t, 1, throttled)
+	if assert.Len(t, rejected, 2) {
+		assert.Equal(t, b, rejected[0].event)
+		assert.Equal(t, 400, rejected[0].status)
+		assert.Equal(t, `{"reason": "mapper_parsing_exception"}`, rejected[0].reason)
+		assert.Equal(t, d, rejected[1].event)
+		assert.Equal(t, 409, rejected[1].status)
+	}
+}
+
+func TestFileDeadLetter(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "deadletter")
+	defer os.RemoveAll(dir)
+
+	d, err := newDeadLetter("testbeat", &outputs.DeadLetterConfig{Path: dir})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	event := testEvent("a")
+	err = d.Write(nil, []rejectedEvent{{event, 400, "bad mapping"}})
+	assert.NoError(t, err)
+
+	records := readDeadLetterFile(t, filepath.Join(dir, "testbeat-dead-letter"))
+	if assert.Len(t, records, 1) {
+		record := records[0]
+		assert.Equal(t, float64(400), record["status"])
+		assert.Equal(t, "bad mapping", record["error"])
+		assert.NotEmpty(t, record["@timestamp"])
+		assert.Equal(t, "a", record["event"].(map[string]interface{})["message"])
+	}
+}
+
+func TestIndexDeadLetter(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, _ := ioutil.ReadAll(r.Body)
+		body = string(content)
+		w.Write([]byte(`{"items": [{"create": {"status": 201}}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, nil, nil, nil, "", "")
+	d, err := newDeadLetter("testbeat", &outputs.DeadLetterConfig{Index: "failed"})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = d.Write(&client.Connection, []rejectedEvent{{testEvent("a"), 400, "bad mapping"}})
+	assert.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(body), "\n")
+	if assert.Len(t, lines, 2) {
+		assert.Contains(t, lines[0], `"_index":"failed-`)
+		assert.Contains(t, lines[0], `"_type":"dead_letter"`)
+
+		var record map[string]interface{}
+		json.Unmarshal([]byte(lines[1]), &record)
+		assert.Equal(t, "bad mapping", record["error"])
+		assert.IsType(t, "", record["event"], "event must be stored as string")
+		assert.Contains(t, record["event"], `"message":"a"`)
+	}
+}
+
+func TestNewDeadLetter_invalid(t *testing.T) {
+	d, err := newDeadLetter("testbeat", nil)
+	assert.NoError(t, err)
+	assert.Nil(t, d)
+
+	_, err = newDeadLetter("testbeat", &outputs.DeadLetterConfig{})
+	assert.Error(t, err)
+
+	_, err = newDeadLetter("testbeat", &outputs.DeadLetterConfig{Path: "/tmp", Index: "failed"})
+	assert.Error(t, err)
+}
+
+// Verify that events rejected by a bulk request end up in the dead letter
+// file and are counted.
+func TestPublishEvents_deadLetter(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "deadletter")
+	defer os.RemoveAll(dir)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"items": [
+			{"create": {"status": 201}},
+			{"create": {"status": 400, "error": "bad mapping"}}
+		]}`))
+	}))
+	defer server.Close()
+
+	d, err := newDeadLetter("testbeat", &outputs.DeadLetterConfig{Path: dir})
+	if !assert.NoError(t, err) {
+		return
+	}
+	client := NewClient(server.URL, fmtstr.MustCompileEvent("testbeat"), nil, nil, "", "")
+	client.deadLetter = d
+	if err := client.Connect(time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	before := deadLetterEvents.Value()
+	failed, err := client.PublishEvents([]common.MapStr{testEvent("ok"), testEvent("bad")})
+	assert.NoError(t, err)
+	assert.Empty(t, failed)
+	assert.Equal(t, before+1, deadLetterEvents.Value())
+
+	records := readDeadLetterFile(t, filepath.Join(dir, "testbeat-dead-letter"))
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, "bad", records[0]["event"].(map[string]interface{})["message"])
+		assert.Equal(t, `"bad mapping"`, records[0]["error"])
+	}
+}
+
+// Verify that an event rejected when published on its own ends up in the dead
+// letter file with the error reported by Elasticsearch.
+func TestPublishEvent_deadLetter(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "deadletter")
+	defer os.RemoveAll(dir)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "HEAD" {
+			return
+		}
+		w.WriteHeader(400)
+		w.Write([]byte(`{"error": {"type": "mapper_parsing_exception", "reason": "failed to parse"}, "status": 400}`))
+	}))
+	defer server.Close()
+
+	d, err := newDeadLetter("testbeat", &outputs.DeadLetterConfig{Path: dir})
+	if !assert.NoError(t, err) {
+		return
+	}
+	client := NewClient(server.URL, fmtstr.MustCompileEvent("testbeat"), nil, nil, "", "")
+	client.deadLetter = d
+	if err := client.Connect(time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NoError(t, client.PublishEvent(testEvent("bad")))
+
+	records := readDeadLetterFile(t, filepath.Join(dir, "testbeat-dead-letter"))
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, float64(400), records[0]["status"])
+		assert.Equal(t, `{"type": "mapper_parsing_exception", "reason": "failed to parse"}`,
+			records[0]["error"])
+	}
+}
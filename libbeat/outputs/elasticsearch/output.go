@@ -1,13 +1,16 @@
 package elasticsearch
 
 import (
+	"compress/gzip"
 	"crypto/tls"
 	"errors"
+	"fmt"
 	"net/url"
 	"strings"
 	"time"
 
 	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/fmtstr"
 	"github.com/elastic/beats/libbeat/logp"
 	"github.com/elastic/beats/libbeat/outputs"
 	"github.com/elastic/beats/libbeat/outputs/mode"
@@ -78,7 +81,33 @@ func (out *elasticsearchOutput) init(
 		return err
 	}
 
-	clients, err := mode.MakeClients(config, makeClientFactory(beat, tlsConfig, config))
+	if config.CompressionLevel < 0 || config.CompressionLevel > gzip.BestCompression {
+		return fmt.Errorf("compression_level must be between 0 and %v",
+			gzip.BestCompression)
+	}
+
+	tmpl, err := newTemplate(beat, config.Template)
+	if err != nil {
+		return err
+	}
+
+	deadLetter, err := newDeadLetter(beat, config.DeadLetter)
+	if err != nil {
+		return err
+	}
+
+	out.index = beat
+	if config.Index != "" {
+		out.index = config.Index
+	}
+	index, err := outputs.CompileIndex(out.index)
+	if err != nil {
+		return err
+	}
+	logp.Info("Using index pattern %s", index)
+
+	clients, err := mode.MakeClients(config,
+		makeClientFactory(index, tlsConfig, tmpl, deadLetter, config))
 	if err != nil {
 		return err
 	}
@@ -143,17 +172,14 @@ func (out *elasticsearchOutput) init(
 	}
 
 	out.mode = m
-	if config.Index != "" {
-		out.index = config.Index
-	} else {
-		out.index = beat
-	}
 	return nil
 }
 
 func makeClientFactory(
-	beat string,
+	index *fmtstr.EventFormatString,
 	tls *tls.Config,
+	tmpl *template,
+	deadLetter deadLetter,
 	config outputs.MothershipConfig,
 ) func(string) (mode.ProtocolClient, error) {
 	return func(host string) (mode.ProtocolClient, error) {
@@ -179,16 +205,21 @@ func makeClientFactory(
 			logp.Info("Using proxy URL: %s", proxyURL)
 		}
 
-		index := beat
-		if config.Index != "" {
-			index = config.Index
-		}
-
 		client := NewClient(esURL, index, proxyURL, tls, config.Username, config.Password)
+		client.template = tmpl
+		client.deadLetter = deadLetter
+		client.compressionLevel = config.CompressionLevel
+		client.bulkSize = newBulkSizer(*config.BulkMaxSize)
 		return client, nil
 	}
 }
 
+// Close closes the connections to all hosts. In flight events are signaled
+// as failed.
+func (out *elasticsearchOutput) Close() error {
+	return out.mode.Close()
+}
+
 func (out *elasticsearchOutput) PublishEvent(
 	signaler outputs.Signaler,
 	ts time.Time,
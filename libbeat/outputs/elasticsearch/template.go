@@ -0,0 +1,128 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/elastic/beats/libbeat/cfgfile"
+	"github.com/elastic/beats/libbeat/logp"
+	"github.com/elastic/beats/libbeat/outputs"
+)
+
+// template is an index template installed by the clients when connecting
+// to Elasticsearch.
+type template struct {
+	name      string
+	overwrite bool
+
+	// template used if no variant for the cluster version is configured
+	body map[string]interface{}
+
+	// variants by major Elasticsearch version ("1x", "2x")
+	versions map[string]map[string]interface{}
+}
+
+// newTemplate reads the template files configured. It returns nil if
+// template loading is not configured.
+func newTemplate(beat string, config outputs.TemplateConfig) (*template, error) {
+	if config.Path == "" {
+		return nil, nil
+	}
+
+	t := &template{
+		name:      config.Name,
+		overwrite: config.Overwrite,
+		versions:  map[string]map[string]interface{}{},
+	}
+	if t.name == "" {
+		t.name = beat
+	}
+
+	var err error
+	t.body, err = readTemplate(config.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	for version, versionConfig := range config.Versions {
+		if versionConfig.Path == "" {
+			continue
+		}
+		t.versions[version], err = readTemplate(versionConfig.Path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// readTemplate reads a JSON template file. Relative paths are resolved
+// against the directory of the configuration file.
+func readTemplate(path string) (map[string]interface{}, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(cfgfile.ConfigDir(), path)
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read template file %s: %v", path, err)
+	}
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(content, &body); err != nil {
+		return nil, fmt.Errorf("Failed to parse template file %s: %v", path, err)
+	}
+	return body, nil
+}
+
+// forVersion returns the template variant for the given Elasticsearch
+// version.
+func (t *template) forVersion(version string) map[string]interface{} {
+	if body, ok := t.versions[majorVersion(version)]; ok {
+		return body
+	}
+	return t.body
+}
+
+// majorVersion returns the major version of a version number in the form
+// used by the template configuration, e.g. "2x" for "2.1.0".
+func majorVersion(version string) string {
+	major := strings.SplitN(version, ".", 2)[0]
+	if major == "" {
+		return ""
+	}
+	return major + "x"
+}
+
+// loadTemplate installs the template if it does not exist yet or if
+// overwrite is set.
+func (client *Client) loadTemplate() error {
+	t := client.template
+
+	if !t.overwrite {
+		exists, err := client.TemplateExists(t.name)
+		if err != nil {
+			return fmt.Errorf("Failed to check for template %s: %v", t.name, err)
+		}
+		if exists {
+			debug("Template %s already exists", t.name)
+			return nil
+		}
+	}
+
+	version, err := client.GetVersion()
+	if err != nil {
+		return fmt.Errorf("Failed to read Elasticsearch version: %v", err)
+	}
+
+	_, _, err = client.LoadTemplate(t.name, t.forVersion(version))
+	if err != nil {
+		return fmt.Errorf("Failed to load template %s: %v", t.name, err)
+	}
+
+	logp.Info("Loaded template %s for Elasticsearch %s", t.name, version)
+	return nil
+}
@@ -0,0 +1,163 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/elastic/beats/libbeat/outputs"
+	"github.com/stretchr/testify/assert"
+)
+
+// templateMock is an Elasticsearch mock serving the cluster version and
+// the index template API.
+type templateMock struct {
+	version   string
+	templates map[string]map[string]interface{}
+	loads     int
+}
+
+func (m *templateMock) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	const prefix = "/_template/"
+
+	switch {
+	case r.URL.Path == "/" && r.Method == "HEAD":
+		w.WriteHeader(200)
+	case r.URL.Path == "/" && r.Method == "GET":
+		w.Write([]byte(`{"version": {"number": "` + m.version + `"}}`))
+	case len(r.URL.Path) > len(prefix) && r.URL.Path[:len(prefix)] == prefix:
+		name := r.URL.Path[len(prefix):]
+		switch r.Method {
+		case "HEAD":
+			if _, exists := m.templates[name]; !exists {
+				w.WriteHeader(404)
+			}
+		case "PUT":
+			var body map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&body)
+			m.templates[name] = body
+			m.loads++
+			w.Write([]byte(`{"acknowledged": true}`))
+		}
+	default:
+		w.WriteHeader(400)
+	}
+}
+
+func writeTemplateFile(t *testing.T, dir, name, pattern string) string {
+	path := filepath.Join(dir, name)
+	content := []byte(`{"template": "` + pattern + `"}`)
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newTemplateClient(
+	t *testing.T,
+	mock *templateMock,
+	config outputs.TemplateConfig,
+) (*Client, func()) {
+	tmpl, err := newTemplate("testbeat", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(mock)
+	client := NewClient(server.URL, nil, nil, nil, "", "")
+	client.template = tmpl
+	return client, server.Close
+}
+
+func TestTemplateLoad(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "template")
+	defer os.RemoveAll(dir)
+
+	mock := &templateMock{version: "2.1.0", templates: map[string]map[string]interface{}{}}
+	client, stop := newTemplateClient(t, mock, outputs.TemplateConfig{
+		Path: writeTemplateFile(t, dir, "default.json", "testbeat-*"),
+	})
+	defer stop()
+
+	err := client.Connect(time.Second)
+	assert.NoError(t, err)
+	assert.True(t, client.IsConnected())
+	assert.Equal(t, 1, mock.loads)
+	assert.Equal(t, "testbeat-*", mock.templates["testbeat"]["template"])
+
+	// existing template is not replaced on reconnect
+	client.Close()
+	err = client.Connect(time.Second)
+	assert.NoError(t, err)
+	assert.True(t, client.IsConnected())
+	assert.Equal(t, 1, mock.loads)
+}
+
+func TestTemplateOverwrite(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "template")
+	defer os.RemoveAll(dir)
+
+	mock := &templateMock{
+		version: "2.1.0",
+		templates: map[string]map[string]interface{}{
+			"custom": {"template": "old-*"},
+		},
+	}
+	client, stop := newTemplateClient(t, mock, outputs.TemplateConfig{
+		Name:      "custom",
+		Path:      writeTemplateFile(t, dir, "default.json", "testbeat-*"),
+		Overwrite: true,
+	})
+	defer stop()
+
+	err := client.Connect(time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, mock.loads)
+	assert.Equal(t, "testbeat-*", mock.templates["custom"]["template"])
+}
+
+func TestTemplateVersion(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "template")
+	defer os.RemoveAll(dir)
+
+	config := outputs.TemplateConfig{
+		Path: writeTemplateFile(t, dir, "default.json", "default-*"),
+		Versions: map[string]outputs.TemplateVersionConfig{
+			"1x": {Path: writeTemplateFile(t, dir, "es1x.json", "es1x-*")},
+		},
+	}
+
+	tests := map[string]string{
+		"1.7.3": "es1x-*",
+		"2.1.0": "default-*",
+	}
+	for version, pattern := range tests {
+		mock := &templateMock{version: version, templates: map[string]map[string]interface{}{}}
+		client, stop := newTemplateClient(t, mock, config)
+
+		err := client.Connect(time.Second)
+		assert.NoError(t, err)
+		assert.Equal(t, pattern, mock.templates["testbeat"]["template"], version)
+		stop()
+	}
+}
+
+func TestNewTemplate(t *testing.T) {
+	tmpl, err := newTemplate("testbeat", outputs.TemplateConfig{})
+	assert.NoError(t, err)
+	assert.Nil(t, tmpl)
+
+	_, err = newTemplate("testbeat", outputs.TemplateConfig{Path: "/does/not/exist.json"})
+	assert.Error(t, err)
+}
+
+func TestMajorVersion(t *testing.T) {
+	assert.Equal(t, "2x", majorVersion("2.1.0"))
+	assert.Equal(t, "1x", majorVersion("1.7.3"))
+	assert.Equal(t, "", majorVersion(""))
+}
@@ -1,7 +1,6 @@
 package fileout
 
 import (
-	"encoding/json"
 	"time"
 
 	"github.com/elastic/beats/libbeat/common"
@@ -30,9 +29,16 @@ func (f FileOutputPlugin) NewOutput(
 
 type fileOutput struct {
 	rotator logp.FileRotator
+	codec   outputs.Codec
 }
 
 func (out *fileOutput) init(beat string, config *outputs.MothershipConfig, topology_expire int) error {
+	codec, err := outputs.LoadCodec(config)
+	if err != nil {
+		return err
+	}
+	out.codec = codec
+
 	out.rotator.Path = config.Path
 	out.rotator.Name = config.Filename
 	if out.rotator.Name == "" {
@@ -60,7 +66,7 @@ func (out *fileOutput) init(beat string, config *outputs.MothershipConfig, topol
 
 	out.rotator.KeepFiles = &keepfiles
 
-	err := out.rotator.CreateDirectory()
+	err = out.rotator.CreateDirectory()
 	if err != nil {
 		return err
 	}
@@ -73,21 +79,26 @@ func (out *fileOutput) init(beat string, config *outputs.MothershipConfig, topol
 	return nil
 }
 
+// Close closes the file currently written to.
+func (out *fileOutput) Close() error {
+	return out.rotator.Close()
+}
+
 func (out *fileOutput) PublishEvent(
 	trans outputs.Signaler,
 	ts time.Time,
 	event common.MapStr,
 ) error {
-	jsonEvent, err := json.Marshal(event)
+	msg, err := out.codec.Encode(event)
 	if err != nil {
 		// mark as success so event is not sent again.
 		outputs.SignalCompleted(trans)
 
-		logp.Err("Fail to convert the event to JSON: %s", err)
+		logp.Err("Fail to encode event: %s", err)
 		return err
 	}
 
-	err = out.rotator.WriteLine(jsonEvent)
+	err = out.rotator.WriteLine(msg)
 	if err != nil {
 		logp.Err("Error when writing line to file: %s", err)
 	}
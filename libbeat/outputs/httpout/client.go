@@ -0,0 +1,246 @@
+package httpout
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/logp"
+	"github.com/elastic/beats/libbeat/outputs/mode"
+)
+
+// batchFormat selects how a batch of events is encoded into one request body.
+type batchFormat uint8
+
+const (
+	// JSONArray encodes a batch as one JSON array of events.
+	JSONArray batchFormat = iota
+
+	// NDJSON encodes a batch as newline delimited JSON documents.
+	NDJSON
+)
+
+var (
+	// ErrNotConnected indicates failure due to client having no valid connection
+	ErrNotConnected = errors.New("not connected")
+)
+
+// client implements the mode.ProtocolClient interface sending each batch of
+// events in one HTTP request.
+type client struct {
+	url     string
+	method  string
+	headers map[string]string
+
+	username    string
+	password    string
+	bearerToken string
+
+	format           batchFormat
+	compressionLevel int
+
+	http      *http.Client
+	connected bool
+}
+
+// clientSettings holds the settings shared by all clients of the output.
+type clientSettings struct {
+	method  string
+	headers map[string]string
+
+	username    string
+	password    string
+	bearerToken string
+
+	format           batchFormat
+	compressionLevel int
+
+	proxyURL *url.URL
+	tls      *tls.Config
+}
+
+func newClient(url string, settings clientSettings) *client {
+	proxy := http.ProxyFromEnvironment
+	if settings.proxyURL != nil {
+		proxy = http.ProxyURL(settings.proxyURL)
+	}
+
+	return &client{
+		url:              url,
+		method:           settings.method,
+		headers:          settings.headers,
+		username:         settings.username,
+		password:         settings.password,
+		bearerToken:      settings.bearerToken,
+		format:           settings.format,
+		compressionLevel: settings.compressionLevel,
+		http: &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: settings.tls,
+				Proxy:           proxy,
+			},
+		},
+	}
+}
+
+// Connect sets the request timeout. No request is sent, as generic HTTP
+// endpoints might not support any request besides publishing events.
+func (c *client) Connect(timeout time.Duration) error {
+	c.http.Timeout = timeout
+	c.connected = true
+	return nil
+}
+
+func (c *client) Close() error {
+	c.connected = false
+	return nil
+}
+
+func (c *client) IsConnected() bool {
+	return c.connected
+}
+
+func (c *client) PublishEvent(event common.MapStr) error {
+	_, err := c.PublishEvents([]common.MapStr{event})
+	return err
+}
+
+// PublishEvents sends all events in one request. Events are returned for
+// retry if the request failed or the endpoint responded with a status code
+// indicating a temporary failure (408, 429 or 5xx). Other status codes
+// drop the events.
+func (c *client) PublishEvents(
+	events []common.MapStr,
+) ([]common.MapStr, error) {
+	if !c.connected {
+		return events, ErrNotConnected
+	}
+
+	body, count := c.encodeBatch(events)
+	if count == 0 {
+		return nil, nil
+	}
+
+	status, err := c.send(body)
+	if err != nil {
+		logp.Err("Failed to publish events to %v: %v", c.url, err)
+		c.connected = false
+		return events, err
+	}
+
+	switch {
+	case status < 300:
+		debug("%v events published to %v", count, c.url)
+		return nil, nil
+	case status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable:
+		// endpoint overloaded => retry after back off
+		logp.Warn("Endpoint %v overloaded (status=%v), retrying", c.url, status)
+		return events, mode.ErrTempBulkFailure
+	case status == http.StatusRequestTimeout || status >= 500:
+		// server error => try again, potentially with another endpoint
+		c.connected = false
+		return events, fmt.Errorf("publish failed with status %v", status)
+	default:
+		logp.Err("Dropping %v events rejected by %v with status %v", count, c.url, status)
+		return nil, nil
+	}
+}
+
+// encodeBatch encodes events into the request body, dropping events failing
+// to encode. The number of events encoded is returned.
+func (c *client) encodeBatch(events []common.MapStr) ([]byte, int) {
+	var buf bytes.Buffer
+	if c.format == JSONArray {
+		buf.WriteByte('[')
+	}
+
+	count := 0
+	for _, event := range events {
+		doc, err := json.Marshal(event)
+		if err != nil {
+			logp.Err("Fail to convert the event to JSON: %s", err)
+			continue
+		}
+
+		if count > 0 && c.format == JSONArray {
+			buf.WriteByte(',')
+		}
+		buf.Write(doc)
+		if c.format == NDJSON {
+			buf.WriteByte('\n')
+		}
+		count++
+	}
+
+	if c.format == JSONArray {
+		buf.WriteByte(']')
+	}
+	return buf.Bytes(), count
+}
+
+// send executes the request returning the response status code.
+func (c *client) send(body []byte) (int, error) {
+	var reader io.Reader = bytes.NewReader(body)
+	if c.compressionLevel > 0 {
+		var buf bytes.Buffer
+		w, err := gzip.NewWriterLevel(&buf, c.compressionLevel)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := w.Write(body); err != nil {
+			return 0, err
+		}
+		if err := w.Close(); err != nil {
+			return 0, err
+		}
+		reader = &buf
+	}
+
+	req, err := http.NewRequest(c.method, c.url, reader)
+	if err != nil {
+		return 0, err
+	}
+
+	if c.format == NDJSON {
+		req.Header.Set("Content-Type", "application/x-ndjson")
+	} else {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.compressionLevel > 0 {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if c.bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.bearerToken)
+	} else if c.username != "" || c.password != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	for name, value := range c.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer closing(resp.Body)
+
+	// read response, so the connection can be reused
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	return resp.StatusCode, nil
+}
+
+func closing(c io.Closer) {
+	err := c.Close()
+	if err != nil {
+		logp.Warn("Close failed with: %v", err)
+	}
+}
@@ -0,0 +1,255 @@
+package httpout
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/outputs"
+	"github.com/elastic/beats/libbeat/outputs/mode"
+	"github.com/stretchr/testify/assert"
+)
+
+// testServer records all requests received and answers with status.
+type testServer struct {
+	*httptest.Server
+
+	mutex    sync.Mutex
+	status   int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newTestServer(status int) *testServer {
+	s := &testServer{status: status}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			gz, err := gzip.NewReader(r.Body)
+			if err != nil {
+				w.WriteHeader(400)
+				return
+			}
+			body = gz
+		}
+		content, _ := ioutil.ReadAll(body)
+
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		s.requests = append(s.requests, r)
+		s.bodies = append(s.bodies, content)
+		w.WriteHeader(s.status)
+	}))
+	return s
+}
+
+func (s *testServer) received() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.requests)
+}
+
+func testEvents(n int) []common.MapStr {
+	events := make([]common.MapStr, n)
+	for i := range events {
+		events[i] = common.MapStr{"type": "test", "count": i}
+	}
+	return events
+}
+
+func newTestClient(t *testing.T, url string, config outputs.MothershipConfig) *client {
+	settings, err := loadSettings(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newClient(url, settings)
+	assert.NoError(t, c.Connect(time.Second))
+	return c
+}
+
+func TestPublishJSONArray(t *testing.T) {
+	s := newTestServer(200)
+	defer s.Close()
+
+	c := newTestClient(t, s.URL+"/ingest", outputs.MothershipConfig{})
+	rest, err := c.PublishEvents(testEvents(3))
+	assert.NoError(t, err)
+	assert.Empty(t, rest)
+
+	if assert.Equal(t, 1, s.received()) {
+		req := s.requests[0]
+		assert.Equal(t, "POST", req.Method)
+		assert.Equal(t, "/ingest", req.URL.Path)
+		assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+
+		var docs []map[string]interface{}
+		assert.NoError(t, json.Unmarshal(s.bodies[0], &docs))
+		assert.Len(t, docs, 3)
+		assert.Equal(t, 2.0, docs[2]["count"])
+	}
+}
+
+func TestPublishNDJSONGzip(t *testing.T) {
+	s := newTestServer(200)
+	defer s.Close()
+
+	c := newTestClient(t, s.URL, outputs.MothershipConfig{
+		Method:           "put",
+		BatchFormat:      "ndjson",
+		CompressionLevel: gzip.BestSpeed,
+	})
+	rest, err := c.PublishEvents(testEvents(3))
+	assert.NoError(t, err)
+	assert.Empty(t, rest)
+
+	if assert.Equal(t, 1, s.received()) {
+		req := s.requests[0]
+		assert.Equal(t, "PUT", req.Method)
+		assert.Equal(t, "gzip", req.Header.Get("Content-Encoding"))
+		assert.Equal(t, "application/x-ndjson", req.Header.Get("Content-Type"))
+
+		lines := 0
+		scanner := bufio.NewScanner(bytes.NewReader(s.bodies[0]))
+		for scanner.Scan() {
+			var doc map[string]interface{}
+			assert.NoError(t, json.Unmarshal(scanner.Bytes(), &doc))
+			lines++
+		}
+		assert.Equal(t, 3, lines)
+	}
+}
+
+func TestPublishHeadersAndAuth(t *testing.T) {
+	s := newTestServer(200)
+	defer s.Close()
+
+	c := newTestClient(t, s.URL, outputs.MothershipConfig{
+		Username: "user",
+		Password: "secret",
+		Headers:  map[string]string{"X-Tenant": "beats"},
+	})
+	assert.NoError(t, c.PublishEvent(common.MapStr{"type": "test"}))
+
+	c = newTestClient(t, s.URL, outputs.MothershipConfig{
+		BearerToken: "token",
+	})
+	assert.NoError(t, c.PublishEvent(common.MapStr{"type": "test"}))
+
+	if assert.Equal(t, 2, s.received()) {
+		user, pass, ok := s.requests[0].BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "user", user)
+		assert.Equal(t, "secret", pass)
+		assert.Equal(t, "beats", s.requests[0].Header.Get("X-Tenant"))
+
+		assert.Equal(t, "Bearer token", s.requests[1].Header.Get("Authorization"))
+	}
+}
+
+func TestPublishStatusClassification(t *testing.T) {
+	tests := []struct {
+		status    int
+		err       error
+		retry     bool
+		connected bool
+	}{
+		{201, nil, false, true},
+		{204, nil, false, true},
+		{400, nil, false, true},
+		{404, nil, false, true},
+		{429, mode.ErrTempBulkFailure, true, true},
+		{503, mode.ErrTempBulkFailure, true, true},
+		{408, nil, true, false},
+		{500, nil, true, false},
+		{502, nil, true, false},
+	}
+
+	for _, test := range tests {
+		s := newTestServer(test.status)
+		c := newTestClient(t, s.URL, outputs.MothershipConfig{})
+
+		rest, err := c.PublishEvents(testEvents(2))
+		if test.retry {
+			assert.Error(t, err, "status %v", test.status)
+			assert.Len(t, rest, 2, "status %v", test.status)
+			if test.err != nil {
+				assert.Equal(t, test.err, err, "status %v", test.status)
+			}
+		} else {
+			assert.NoError(t, err, "status %v", test.status)
+			assert.Empty(t, rest, "status %v", test.status)
+		}
+		assert.Equal(t, test.connected, c.IsConnected(), "status %v", test.status)
+		s.Close()
+	}
+}
+
+func TestPublishConnectionError(t *testing.T) {
+	s := newTestServer(200)
+	url := s.URL
+	s.Close()
+
+	c := newTestClient(t, url, outputs.MothershipConfig{})
+	rest, err := c.PublishEvents(testEvents(2))
+	assert.Error(t, err)
+	assert.Len(t, rest, 2)
+	assert.False(t, c.IsConnected())
+
+	rest, err = c.PublishEvents(testEvents(2))
+	assert.Equal(t, ErrNotConnected, err)
+	assert.Len(t, rest, 2)
+}
+
+func TestLoadSettingsErrors(t *testing.T) {
+	configs := []outputs.MothershipConfig{
+		{Method: "GET"},
+		{BatchFormat: "xml"},
+		{CompressionLevel: 10},
+		{ProxyURL: "http://[::1"},
+		{ProxyURL: "ftp://proxy"},
+	}
+	for i, config := range configs {
+		_, err := loadSettings(config)
+		assert.Error(t, err, "config %v", i)
+	}
+}
+
+func TestParseHostURL(t *testing.T) {
+	u, err := parseHostURL("localhost:8080/ingest")
+	assert.NoError(t, err)
+	assert.Equal(t, "http://localhost:8080/ingest", u)
+
+	u, err = parseHostURL("https://collector/hec")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://collector/hec", u)
+
+	_, err = parseHostURL("ftp://collector")
+	assert.Error(t, err)
+}
+
+func TestOutputBulkPublish(t *testing.T) {
+	s := newTestServer(200)
+	defer s.Close()
+
+	output, err := httpOutputPlugin{}.NewOutput("test", &outputs.MothershipConfig{
+		Hosts: []string{s.URL},
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signal := outputs.NewSyncSignal()
+	bulk := output.(outputs.BulkOutputer)
+	assert.NoError(t, bulk.BulkPublish(signal, time.Now(), testEvents(5)))
+	assert.True(t, signal.Wait())
+	assert.Equal(t, 1, s.received())
+}
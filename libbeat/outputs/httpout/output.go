@@ -0,0 +1,226 @@
+// Package httpout implements the http output publishing batches of events to
+// generic HTTP endpoints.
+package httpout
+
+import (
+	"compress/gzip"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/logp"
+	"github.com/elastic/beats/libbeat/outputs"
+	"github.com/elastic/beats/libbeat/outputs/mode"
+)
+
+var debug = logp.MakeDebug("http")
+
+const (
+	defaultMaxRetries = 3
+	defaultBulkSize   = 50
+	defaultTimeout    = 30 * time.Second
+)
+
+var (
+	waitRetry    = time.Duration(1) * time.Second
+	maxWaitRetry = time.Duration(60) * time.Second
+)
+
+func init() {
+	outputs.RegisterOutputPlugin("http", httpOutputPlugin{})
+}
+
+type httpOutputPlugin struct{}
+
+type httpOutput struct {
+	mode mode.ConnectionMode
+}
+
+// NewOutput instantiates a new output plugin instance publishing to HTTP
+// endpoints.
+func (p httpOutputPlugin) NewOutput(
+	beat string,
+	config *outputs.MothershipConfig,
+	topologyExpire int,
+) (outputs.Outputer, error) {
+
+	// configure bulk size in config in case it is not set
+	if config.BulkMaxSize == nil {
+		bulkSize := defaultBulkSize
+		config.BulkMaxSize = &bulkSize
+	}
+
+	output := &httpOutput{}
+	err := output.init(*config)
+	if err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+func (out *httpOutput) init(config outputs.MothershipConfig) error {
+	settings, err := loadSettings(config)
+	if err != nil {
+		return err
+	}
+
+	clients, err := mode.MakeClients(config, func(host string) (mode.ProtocolClient, error) {
+		hostURL, err := parseHostURL(host)
+		if err != nil {
+			logp.Err("Invalid host param set: %s, Error: %v", host, err)
+			return nil, err
+		}
+		logp.Info("Publishing events to %v %v", settings.method, hostURL)
+		return newClient(hostURL, settings), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	timeout := defaultTimeout
+	if config.Timeout != 0 {
+		timeout = time.Duration(config.Timeout) * time.Second
+	}
+
+	maxRetries := defaultMaxRetries
+	if config.MaxRetries != nil {
+		maxRetries = *config.MaxRetries
+	}
+	maxAttempts := maxRetries + 1 // maximum number of send attempts (-1 = infinite)
+	if maxRetries < 0 {
+		maxAttempts = 0
+	}
+
+	var m mode.ConnectionMode
+	if len(clients) == 1 {
+		m, err = mode.NewSingleConnectionMode(clients[0], maxAttempts,
+			waitRetry, timeout, maxWaitRetry)
+	} else {
+		loadBalance := config.LoadBalance == nil || *config.LoadBalance
+		if loadBalance {
+			m, err = mode.NewLoadBalancerMode(clients, maxAttempts,
+				waitRetry, timeout, maxWaitRetry)
+		} else {
+			m, err = mode.NewFailOverConnectionMode(clients, maxAttempts, waitRetry, timeout)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	out.mode = m
+	return nil
+}
+
+// loadSettings validates the configuration shared by all clients.
+func loadSettings(config outputs.MothershipConfig) (clientSettings, error) {
+	settings := clientSettings{
+		method:           strings.ToUpper(config.Method),
+		headers:          config.Headers,
+		username:         config.Username,
+		password:         config.Password,
+		bearerToken:      config.BearerToken,
+		compressionLevel: config.CompressionLevel,
+	}
+
+	switch settings.method {
+	case "":
+		settings.method = "POST"
+	case "POST", "PUT":
+	default:
+		return settings, fmt.Errorf("unsupported http method '%v'", config.Method)
+	}
+
+	switch config.BatchFormat {
+	case "", "json_array":
+		settings.format = JSONArray
+	case "ndjson":
+		settings.format = NDJSON
+	default:
+		return settings, fmt.Errorf("unsupported batch_format '%v'", config.BatchFormat)
+	}
+
+	if config.CompressionLevel < 0 || config.CompressionLevel > gzip.BestCompression {
+		return settings, fmt.Errorf("compression_level must be between 0 and %v",
+			gzip.BestCompression)
+	}
+
+	var err error
+	settings.tls, err = outputs.LoadTLSConfig(config.TLS)
+	if err != nil {
+		return settings, err
+	}
+
+	if config.ProxyURL != "" {
+		settings.proxyURL, err = parseProxyURL(config.ProxyURL)
+		if err != nil {
+			return settings, err
+		}
+		logp.Info("Using proxy URL: %s", settings.proxyURL)
+	}
+
+	return settings, nil
+}
+
+// parseHostURL checks the host being an absolute http or https URL. If the
+// scheme is missing, http is used.
+func parseHostURL(host string) (string, error) {
+	if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+
+	u, err := url.Parse(host)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("unsupported scheme '%v'", u.Scheme)
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("missing host in '%v'", host)
+	}
+	return u.String(), nil
+}
+
+// parseProxyURL parses the proxy URL. If the scheme is missing, http is
+// used.
+func parseProxyURL(raw string) (*url.URL, error) {
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+
+	proxyURL, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy_url: %v", err)
+	}
+	if !strings.HasPrefix(proxyURL.Scheme, "http") || proxyURL.Host == "" {
+		return nil, fmt.Errorf("invalid proxy_url '%v'", raw)
+	}
+	return proxyURL, nil
+}
+
+// Close closes the connections to all hosts. In flight events are signaled
+// as failed.
+func (out *httpOutput) Close() error {
+	return out.mode.Close()
+}
+
+func (out *httpOutput) PublishEvent(
+	signaler outputs.Signaler,
+	ts time.Time,
+	event common.MapStr,
+) error {
+	return out.mode.PublishEvent(signaler, event)
+}
+
+// BulkPublish implements the BulkOutputer interface sending all events in
+// one request.
+func (out *httpOutput) BulkPublish(
+	trans outputs.Signaler,
+	ts time.Time,
+	events []common.MapStr,
+) error {
+	return out.mode.PublishEvents(trans, events)
+}
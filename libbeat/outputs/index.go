@@ -0,0 +1,25 @@
+package outputs
+
+import (
+	"fmt"
+
+	"github.com/elastic/beats/libbeat/common/fmtstr"
+)
+
+// DailyIndexSuffix is appended to index names not containing any format
+// element, creating one index per day.
+const DailyIndexSuffix = "-%{+yyyy.MM.dd}"
+
+// CompileIndex compiles the index format string of an output. If the index
+// contains no format element, the daily suffix is appended, so a plain index
+// name 'beat' selects the index 'beat-YYYY.MM.DD'.
+func CompileIndex(index string) (*fmtstr.EventFormatString, error) {
+	fs, err := fmtstr.CompileEvent(index)
+	if err != nil {
+		return nil, fmt.Errorf("invalid index: %v", err)
+	}
+	if fs.IsConst() {
+		return fmtstr.CompileEvent(index + DailyIndexSuffix)
+	}
+	return fs, nil
+}
@@ -18,6 +18,10 @@ import (
 // with different mode. The client implements slow start with low window sizes +
 // window size backoff in case of long running transactions.
 //
+// Sending is pipelined: up to maxPending windows are sent without waiting for
+// ACKs. ACKs are matched to the oldest pending window as they arrive, so the
+// throughput is not bound by the round-trip time to logstash.
+//
 // it is suggested to use lumberjack in conjunction with roundRobinConnectionMode
 // if logstash becomes unresponsive
 type lumberjackClient struct {
@@ -25,14 +29,24 @@ type lumberjackClient struct {
 	windowSize      int
 	maxOkWindowSize int // max window size sending was successful for
 	maxWindowSize   int
+	maxPending      int // max number of windows waiting for ACK
 	timeout         time.Duration
-	countTimeoutErr int
+}
+
+// window is a batch of events sent to logstash waiting for ACK.
+type window struct {
+	events []common.MapStr // events encoded into the window
+	size   int             // compressed payload size in bytes
+	acked  uint32          // number of events ACKed so far
 }
 
 const (
 	minWindowSize             int = 1
 	defaultStartMaxWindowSize int = 10
-	maxAllowedTimeoutErr      int = 3
+
+	// maxPendingBytes limits the size of all compressed payloads waiting for
+	// ACK. At least one window is always sent.
+	maxPendingBytes int = 10 * 1024 * 1024
 )
 
 // errors
@@ -53,13 +67,18 @@ var (
 func newLumberjackClient(
 	conn TransportClient,
 	maxWindowSize int,
+	maxPending int,
 	timeout time.Duration,
 ) *lumberjackClient {
+	if maxPending < 1 {
+		maxPending = 1
+	}
 	return &lumberjackClient{
 		TransportClient: conn,
 		windowSize:      defaultStartMaxWindowSize,
 		timeout:         timeout,
 		maxWindowSize:   maxWindowSize,
+		maxPending:      maxPending,
 	}
 }
 
@@ -73,69 +92,100 @@ func (l *lumberjackClient) PublishEvent(event common.MapStr) error {
 func (l *lumberjackClient) PublishEvents(
 	events []common.MapStr,
 ) ([]common.MapStr, error) {
-	for len(events) > 0 {
-		n, err := l.publishWindowed(events)
+	var pending []*window
+	pendingBytes := 0
+
+	for len(events) > 0 || len(pending) > 0 {
+		// send windows until the pipeline is full
+		canSend := len(events) > 0 && len(pending) < l.maxPending &&
+			(len(pending) == 0 || pendingBytes < maxPendingBytes)
+		if canSend {
+			n, w, err := l.sendWindow(events)
+			if err != nil {
+				return l.onFail(pending, events, err)
+			}
+
+			events = events[n:]
+			if w != nil {
+				pending = append(pending, w)
+				pendingBytes += w.size
+			}
+			continue
+		}
 
-		logp.Debug("logstash", "%v events out of %v events sent to logstash. Continue sending ...", n, len(events))
-		events = events[n:]
+		// wait for ACK of the oldest window
+		w := pending[0]
+		seq, err := l.readACK()
 		if err != nil {
-			return events, err
+			return l.onFail(pending, events, err)
+		}
+
+		count := uint32(len(w.events))
+		if seq > count {
+			return l.onFail(pending, events, ErrProtocolError)
+		}
+		if seq > w.acked {
+			// accept partial ACK, the timeout has been reset by readACK
+			w.acked = seq
+		}
+		if w.acked < count {
+			continue
+		}
+
+		debug("%v events ACKed by logstash, %v windows pending", count, len(pending)-1)
+		pending = pending[1:]
+		pendingBytes -= w.size
+		if len(w.events) >= l.windowSize {
+			// only windows sent with the current window size prove the window
+			// size to be ok
+			l.growWindow()
 		}
 	}
 	return nil, nil
 }
 
-// publishWindowed published events with current maximum window size to logstash
-// returning the total number of events sent (due to window size, or acks until
-// failure).
-func (l *lumberjackClient) publishWindowed(events []common.MapStr) (int, error) {
-	if len(events) == 0 {
-		return 0, nil
-	}
-
+// sendWindow sends the next window of events returning the number of events
+// processed and the window waiting for ACK. The window is nil if encoding of
+// all events failed.
+func (l *lumberjackClient) sendWindow(
+	events []common.MapStr,
+) (int, *window, error) {
 	logp.Debug("logstash", "Try to publish %v events to logstash with window size %v", len(events), l.windowSize)
 
 	// prepare message payload
 	if len(events) > l.windowSize {
 		events = events[:l.windowSize]
 	}
-	count, payload, err := l.compressEvents(events)
+	okEvents, payload, err := l.compressEvents(events)
 	if err != nil {
-		return 0, err
+		return 0, nil, err
 	}
 
-	if count == 0 {
+	if len(okEvents) == 0 {
 		// encoding of all events failed. Let's stop here and report all events
 		// as exported so no one tries to send/encode the same events once again
 		// The compress/encode function already prints critical per failed encoding
 		// failure.
-		return len(events), nil
+		return len(events), nil, nil
 	}
 
 	// send window size:
-	if err = l.sendWindowSize(count); err != nil {
-		return l.onFail(0, err)
+	if err = l.sendWindowSize(uint32(len(okEvents))); err != nil {
+		return 0, nil, err
 	}
 
 	// send payload
 	if err = l.sendCompressed(payload); err != nil {
-		return l.onFail(0, err)
+		return 0, nil, err
 	}
 
-	// wait for ACK (accept partial ACK to reset timeout)
-	// reset timeout timer for every ACK received.
-	var ackSeq uint32
-	for ackSeq < count {
-		// read until all acks
-		ackSeq, err = l.readACK()
-		if err != nil {
-			return l.onFail(int(ackSeq), err)
-		}
-	}
+	return len(events), &window{events: okEvents, size: len(payload)}, nil
+}
 
-	// success: increase window size by factor 1.5 until max window size
-	// (window size grows exponentially)
-	// TODO: use duration until ACK to estimate an ok max window size value
+// growWindow increases the window size by factor 1.5 until max window size
+// after a window has been ACKed (window size grows exponentially).
+// TODO: use duration until ACK to estimate an ok max window size value
+func (l *lumberjackClient) growWindow() {
 	if l.maxOkWindowSize < l.windowSize {
 		l.maxOkWindowSize = l.windowSize
 
@@ -151,59 +201,62 @@ func (l *lumberjackClient) publishWindowed(events []common.MapStr) (int, error)
 			l.windowSize = l.maxOkWindowSize
 		}
 	}
-
-	return len(events), nil
 }
 
-func (l *lumberjackClient) onFail(n int, err error) (int, error) {
-	// if timeout error, back off and ignore error
-	nerr, ok := err.(net.Error)
-	if !ok || !nerr.Timeout() {
-		// no timeout error, close connection and return error
-		_ = l.Close()
-		return n, err
+// onFail closes the connection and returns all events not ACKed yet. ACKs
+// of pending windows can not be matched anymore after a failure, so all
+// pending windows not ACKed must be send again.
+func (l *lumberjackClient) onFail(
+	pending []*window,
+	events []common.MapStr,
+	err error,
+) ([]common.MapStr, error) {
+	var rest []common.MapStr
+	for _, w := range pending {
+		rest = append(rest, w.events[w.acked:]...)
 	}
-
-	// if we've seen 3 consecutive timeout errors, close connection
-	l.countTimeoutErr++
-	if l.countTimeoutErr == maxAllowedTimeoutErr {
-		_ = l.Close()
-		return n, err
+	rest = append(rest, events...)
+
+	// timeout error: reduce window size, so the next attempt might succeed
+	// with the reconnected client or another client is asked to send events
+	if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
+		l.windowSize = l.windowSize / 2
+		if l.windowSize < minWindowSize {
+			l.windowSize = minWindowSize
+		}
+		debug("Timeout waiting for ACK, decrease window size to %v", l.windowSize)
 	}
 
-	// timeout error. reduce window size and return 0 published events. Send
-	// mode might try to publish again with reduce window size or ask another
-	// client to send events
-	l.windowSize = l.windowSize / 2
-	if l.windowSize < minWindowSize {
-		l.windowSize = minWindowSize
-	}
-	return n, nil
+	_ = l.Close()
+	return rest, err
 }
 
+// compressEvents encodes and compresses events returning the events encoded
+// successfully.
 func (l *lumberjackClient) compressEvents(
 	events []common.MapStr,
-) (uint32, []byte, error) {
+) ([]common.MapStr, []byte, error) {
 	buf := bytes.NewBuffer(nil)
 
 	// compress events
 	compressor, _ := zlib.NewWriterLevel(buf, 3) // todo make compression level configurable?
-	var sequence uint32
+	okEvents := make([]common.MapStr, 0, len(events))
 	for _, event := range events {
-		sequence++
+		sequence := uint32(len(okEvents) + 1)
 		err := l.writeDataFrame(event, sequence, compressor)
 		if err != nil {
 			logp.Critical("failed to encode event: %v", err)
-			sequence-- //forget this last broken event and continue
+			continue //forget this last broken event and continue
 		}
+		okEvents = append(okEvents, event)
 	}
 	if err := compressor.Close(); err != nil {
 		debug("Finalizing zlib compression failed with: %s", err)
-		return 0, nil, err
+		return nil, nil, err
 	}
 	payload := buf.Bytes()
 
-	return sequence, payload, nil
+	return okEvents, payload, nil
 }
 
 func (l *lumberjackClient) readACK() (uint32, error) {
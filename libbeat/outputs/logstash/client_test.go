@@ -301,7 +301,7 @@ const testMaxWindowSize = 64
 func TestSendZero(t *testing.T) {
 	transp := newMockTransport()
 	client := newClientTestDriver(
-		newLumberjackClient(transp, testMaxWindowSize, 5*time.Second))
+		newLumberjackClient(transp, testMaxWindowSize, 1, 5*time.Second))
 
 	client.Publish(make([]common.MapStr, 0))
 
@@ -316,7 +316,7 @@ func TestSendZero(t *testing.T) {
 func TestSimpleEvent(t *testing.T) {
 	transp := newMockTransport()
 	client := newClientTestDriver(
-		newLumberjackClient(transp, testMaxWindowSize, 5*time.Second))
+		newLumberjackClient(transp, testMaxWindowSize, 1, 5*time.Second))
 
 	event := common.MapStr{"name": "me", "line": 10}
 	client.Publish([]common.MapStr{event})
@@ -349,7 +349,7 @@ func TestSimpleEvent(t *testing.T) {
 func TestStructuredEvent(t *testing.T) {
 	transp := newMockTransport()
 	client := newClientTestDriver(
-		newLumberjackClient(transp, testMaxWindowSize, 5*time.Second))
+		newLumberjackClient(transp, testMaxWindowSize, 1, 5*time.Second))
 	event := common.MapStr{
 		"name": "test",
 		"struct": common.MapStr{
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/fmtstr"
 	"github.com/elastic/beats/libbeat/logp"
 	"github.com/elastic/beats/libbeat/outputs"
 	"github.com/elastic/beats/libbeat/outputs/mode"
@@ -35,8 +36,11 @@ func (p logstashOutputPlugin) NewOutput(
 }
 
 type logstash struct {
-	mode  mode.ConnectionMode
-	index string
+	mode mode.ConnectionMode
+
+	// beat is reported in @metadata.beat, index in @metadata.index
+	beat  string
+	index *fmtstr.EventFormatString
 }
 
 const (
@@ -46,6 +50,7 @@ const (
 	logstasDefaultMaxTimeout = 90 * time.Second
 	defaultSendRetries       = 3
 	defaultMaxWindowSize     = 1024
+	defaultPipelining        = 4
 )
 
 var waitRetry = time.Duration(1) * time.Second
@@ -58,6 +63,15 @@ func (lj *logstash) init(
 	config outputs.MothershipConfig,
 	topologyExpire int,
 ) error {
+	index := beat
+	if config.Index != "" {
+		index = config.Index
+	}
+	indexFormat, err := outputs.CompileIndex(index)
+	if err != nil {
+		return err
+	}
+
 	useTLS := (config.TLS != nil)
 	timeout := logstashDefaultTimeout
 	if config.Timeout != 0 {
@@ -74,8 +88,12 @@ func (lj *logstash) init(
 		maxWindowSize = *config.BulkMaxSize
 	}
 
+	pipelining := defaultPipelining
+	if config.Pipelining != nil {
+		pipelining = *config.Pipelining
+	}
+
 	var clients []mode.ProtocolClient
-	var err error
 	if useTLS {
 		var tlsConfig *tls.Config
 		tlsConfig, err = outputs.LoadTLSConfig(config.TLS)
@@ -84,13 +102,13 @@ func (lj *logstash) init(
 		}
 
 		clients, err = mode.MakeClients(config,
-			makeClientFactory(maxWindowSize, timeout,
+			makeClientFactory(maxWindowSize, pipelining, timeout,
 				func(host string) (TransportClient, error) {
 					return newTLSClient(host, defaultPort, tlsConfig)
 				}))
 	} else {
 		clients, err = mode.MakeClients(config,
-			makeClientFactory(maxWindowSize, timeout,
+			makeClientFactory(maxWindowSize, pipelining, timeout,
 				func(host string) (TransportClient, error) {
 					return newTCPClient(host, defaultPort)
 				}))
@@ -128,16 +146,17 @@ func (lj *logstash) init(
 	}
 
 	lj.mode = m
-	if config.Index != "" {
-		lj.index = config.Index
-	} else {
-		lj.index = beat
+	lj.index = indexFormat
+	lj.beat = beat
+	if fs, _ := fmtstr.CompileEvent(index); fs.IsConst() {
+		lj.beat = index
 	}
 	return nil
 }
 
 func makeClientFactory(
 	maxWindowSize int,
+	pipelining int,
 	timeout time.Duration,
 	makeTransp func(string) (TransportClient, error),
 ) func(string) (mode.ProtocolClient, error) {
@@ -146,10 +165,16 @@ func makeClientFactory(
 		if err != nil {
 			return nil, err
 		}
-		return newLumberjackClient(transp, maxWindowSize, timeout), nil
+		return newLumberjackClient(transp, maxWindowSize, pipelining, timeout), nil
 	}
 }
 
+// Close closes the connections to all hosts. In flight events are signaled
+// as failed.
+func (lj *logstash) Close() error {
+	return lj.mode.Close()
+}
+
 // TODO: update Outputer interface to support multiple events for batch-like
 //       processing (e.g. for filebeat). Batch like processing might reduce
 //       send/receive overhead per event for other implementors too.
@@ -158,7 +183,11 @@ func (lj *logstash) PublishEvent(
 	ts time.Time,
 	event common.MapStr,
 ) error {
-	lj.addMeta(event)
+	if err := lj.addMeta(event); err != nil {
+		logp.Err("Dropping event: %v", err)
+		outputs.SignalCompleted(signaler)
+		return nil
+	}
 	return lj.mode.PublishEvent(signaler, event)
 }
 
@@ -169,19 +198,43 @@ func (lj *logstash) BulkPublish(
 	ts time.Time,
 	events []common.MapStr,
 ) error {
+	okEvents := events[:0]
 	for _, event := range events {
-		lj.addMeta(event)
+		if err := lj.addMeta(event); err != nil {
+			logp.Err("Dropping event: %v", err)
+			continue
+		}
+		okEvents = append(okEvents, event)
+	}
+	if len(okEvents) == 0 {
+		outputs.SignalCompleted(trans)
+		return nil
 	}
-	return lj.mode.PublishEvents(trans, events)
+	return lj.mode.PublishEvents(trans, okEvents)
 }
 
 // addMeta adapts events to be compatible with logstash forwarer messages by renaming
 // the "message" field to "line". The lumberjack server in logstash will
 // decode/rename the "line" field into "message".
-func (lj *logstash) addMeta(event common.MapStr) {
+//
+// The index the event is meant for is set in @metadata.index, so Logstash can
+// use the same index as the elasticsearch output ('%{[@metadata][index]}').
+func (lj *logstash) addMeta(event common.MapStr) error {
+	index, err := lj.index.Run(event)
+	if err != nil {
+		return err
+	}
+
+	docType, err := event.GetString("type")
+	if err != nil {
+		return err
+	}
+
 	// add metadata for indexing
 	event["@metadata"] = common.MapStr{
-		"beat": lj.index,
-		"type": event["type"].(string),
+		"beat":  lj.beat,
+		"type":  docType,
+		"index": index,
 	}
+	return nil
 }
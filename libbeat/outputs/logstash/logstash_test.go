@@ -484,3 +484,42 @@ func TestLogstashInvalidTLS(t *testing.T) {
 	assert.True(t, result.handshakeFail)
 	assert.False(t, result.signal)
 }
+
+func TestLogstashAddMeta(t *testing.T) {
+	ts := time.Date(2015, 12, 3, 0, 0, 0, 0, time.UTC)
+	newEvent := func() common.MapStr {
+		return common.MapStr{
+			"@timestamp": common.Time(ts),
+			"type":       "log",
+			"beat":       common.MapStr{"name": "host1"},
+		}
+	}
+
+	tests := []struct {
+		index string
+		beat  string
+		meta  string
+	}{
+		{"", "testbeat", "testbeat-2015.12.03"},
+		{"custom", "custom", "custom-2015.12.03"},
+		{"%{[beat.name]}-%{+yyyy.MM}", "testbeat", "host1-2015.12"},
+	}
+
+	for _, test := range tests {
+		lj := &logstash{}
+		config := outputs.MothershipConfig{
+			Index: test.index,
+			Hosts: []string{"localhost:5044"},
+		}
+		if err := lj.init("testbeat", config, 0); err != nil {
+			t.Fatal(err)
+		}
+
+		event := newEvent()
+		assert.NoError(t, lj.addMeta(event))
+		meta := event["@metadata"].(common.MapStr)
+		assert.Equal(t, test.beat, meta["beat"], test.index)
+		assert.Equal(t, test.meta, meta["index"], test.index)
+		assert.Equal(t, "log", meta["type"], test.index)
+	}
+}
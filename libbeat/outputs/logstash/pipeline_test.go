@@ -0,0 +1,213 @@
+package logstash
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/streambuf"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// pipeTransport is a TransportClient backed by one end of a net.Pipe.
+type pipeTransport struct {
+	net.Conn
+	connected bool
+}
+
+func (p *pipeTransport) Connect(timeout time.Duration) error {
+	p.connected = true
+	return nil
+}
+
+func (p *pipeTransport) IsConnected() bool { return p.connected }
+
+func (p *pipeTransport) Close() error {
+	p.connected = false
+	return p.Conn.Close()
+}
+
+// pipeServer reads lumberjack windows from the server end of the pipe.
+type pipeServer struct {
+	conn net.Conn
+	buf  *streambuf.Buffer
+}
+
+func newPipeClient(
+	maxPending int,
+	timeout time.Duration,
+) (*lumberjackClient, *pipeServer) {
+	client, server := net.Pipe()
+	transp := &pipeTransport{Conn: client, connected: true}
+	lj := newLumberjackClient(transp, testMaxWindowSize, maxPending, timeout)
+	return lj, &pipeServer{conn: server, buf: streambuf.New(nil)}
+}
+
+// recvWindow reads the next window size and compressed payload, returning
+// the events received.
+func (s *pipeServer) recvWindow() ([]*message, error) {
+	var events []*message
+	for events == nil {
+		msg, err := readMessage(s.buf)
+		if err != nil {
+			return nil, err
+		}
+		if msg == nil {
+			tmp := make([]byte, 4096)
+			n, err := s.conn.Read(tmp)
+			if err != nil {
+				return nil, err
+			}
+			s.buf.Write(tmp[:n])
+			continue
+		}
+		if msg.code == 'C' {
+			events = msg.events
+		}
+	}
+	return events, nil
+}
+
+func (s *pipeServer) sendACK(seq uint32) error {
+	buf := streambuf.New(nil)
+	buf.WriteByte('2')
+	buf.WriteByte('A')
+	buf.WriteNetUint32(seq)
+	_, err := s.conn.Write(buf.Bytes())
+	return err
+}
+
+type publishResult struct {
+	rest []common.MapStr
+	err  error
+}
+
+func publishAsync(client *lumberjackClient, events []common.MapStr) chan publishResult {
+	ch := make(chan publishResult, 1)
+	go func() {
+		rest, err := client.PublishEvents(events)
+		ch <- publishResult{rest, err}
+	}()
+	return ch
+}
+
+func makeTestEvents(n int) []common.MapStr {
+	events := make([]common.MapStr, n)
+	for i := range events {
+		events[i] = common.MapStr{"type": "test", "message": fmt.Sprintf("event %d", i)}
+	}
+	return events
+}
+
+func TestPublishPipelinedWindows(t *testing.T) {
+	client, server := newPipeClient(4, 5*time.Second)
+	defer server.conn.Close()
+
+	res := publishAsync(client, makeTestEvents(40))
+
+	// 4 windows of start window size are sent before any ACK
+	var received []int
+	for i := 0; i < 4; i++ {
+		events, err := server.recvWindow()
+		if err != nil {
+			t.Fatal(err)
+		}
+		received = append(received, len(events))
+	}
+	assert.Equal(t, []int{10, 10, 10, 10}, received)
+
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, server.sendACK(10))
+	}
+
+	r := <-res
+	assert.NoError(t, r.err)
+	assert.Empty(t, r.rest)
+	assert.Equal(t, 15, client.windowSize)
+}
+
+func TestPublishPipelinedSendsOnACK(t *testing.T) {
+	client, server := newPipeClient(2, 5*time.Second)
+	defer server.conn.Close()
+
+	res := publishAsync(client, makeTestEvents(30))
+
+	// pipeline full after 2 windows, next window is sent after first ACK
+	// with increased window size
+	for i := 0; i < 2; i++ {
+		_, err := server.recvWindow()
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, server.sendACK(10))
+
+	events, err := server.recvWindow()
+	assert.NoError(t, err)
+	assert.Len(t, events, 10)
+	assert.Equal(t, uint32(1), events[0].seq)
+	assert.Equal(t, "event 20", events[0].doc["message"])
+
+	assert.NoError(t, server.sendACK(10))
+	assert.NoError(t, server.sendACK(10))
+
+	r := <-res
+	assert.NoError(t, r.err)
+	assert.Empty(t, r.rest)
+}
+
+func TestPublishPartialACK(t *testing.T) {
+	client, server := newPipeClient(2, 5*time.Second)
+
+	events := makeTestEvents(20)
+	res := publishAsync(client, events)
+
+	for i := 0; i < 2; i++ {
+		_, err := server.recvWindow()
+		assert.NoError(t, err)
+	}
+
+	// partial ACKs of first window, then connection is lost
+	assert.NoError(t, server.sendACK(3))
+	assert.NoError(t, server.sendACK(7))
+	server.conn.Close()
+
+	r := <-res
+	assert.Error(t, r.err)
+	assert.Equal(t, events[7:], r.rest)
+	assert.False(t, client.IsConnected())
+}
+
+func TestPublishTimeoutShrinksWindow(t *testing.T) {
+	client, server := newPipeClient(2, 50*time.Millisecond)
+	defer server.conn.Close()
+
+	events := makeTestEvents(10)
+	res := publishAsync(client, events)
+
+	_, err := server.recvWindow()
+	assert.NoError(t, err)
+
+	// no ACK => timeout
+	r := <-res
+	assert.Error(t, r.err)
+	assert.Len(t, r.rest, 10)
+	assert.Equal(t, 5, client.windowSize)
+	assert.False(t, client.IsConnected())
+}
+
+func TestPublishInvalidACK(t *testing.T) {
+	client, server := newPipeClient(1, 5*time.Second)
+	defer server.conn.Close()
+
+	res := publishAsync(client, makeTestEvents(5))
+
+	_, err := server.recvWindow()
+	assert.NoError(t, err)
+	assert.NoError(t, server.sendACK(6))
+
+	r := <-res
+	assert.Equal(t, ErrProtocolError, r.err)
+	assert.Len(t, r.rest, 5)
+}
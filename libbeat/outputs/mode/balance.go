@@ -205,6 +205,7 @@ func (m *LoadBalancerMode) onMessage(client ProtocolClient, msg eventsMessage) {
 				}
 
 				// wait before retry
+				sendRetries.Add(1)
 				backoff := time.Duration(int64(m.waitRetry) * (1 << backoffCount))
 				if backoff > m.maxWaitRetry {
 					backoff = m.maxWaitRetry
@@ -230,6 +231,7 @@ func (m *LoadBalancerMode) onFail(msg eventsMessage, err error) {
 
 	logp.Info("Error publishing events (retrying): %s", err)
 
+	sendRetries.Add(1)
 	if ok := m.forwardEvent(m.retries, msg); !ok {
 		outputs.SignalFailed(msg.signaler, err)
 	}
@@ -186,6 +186,7 @@ func (f *FailOverConnectionMode) publish(
 			break
 		}
 
+		sendRetries.Add(1)
 		time.Sleep(f.waitRetry)
 	}
 
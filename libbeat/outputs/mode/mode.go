@@ -4,12 +4,19 @@ package mode
 
 import (
 	"errors"
+	"expvar"
 	"time"
 
 	"github.com/elastic/beats/libbeat/common"
 	"github.com/elastic/beats/libbeat/outputs"
 )
 
+// Metrics that can be retrieved through the expvar web interface.
+var (
+	// number of failed send attempts being retried
+	sendRetries = expvar.NewInt("libbeatOutputSendRetries")
+)
+
 // ErrNoHostsConfigured indicates missing host or hosts configuration
 var ErrNoHostsConfigured = errors.New("no host configuration found")
 
@@ -141,6 +141,7 @@ func (s *SingleConnectionMode) publish(
 		}
 
 		logp.Info("send fail")
+		sendRetries.Add(1)
 		backoff := time.Duration(int64(s.waitRetry) * (1 << backoffCount))
 		if backoff > s.maxWaitRetry {
 			backoff = s.maxWaitRetry
@@ -1,10 +1,15 @@
 package outputs
 
 import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"regexp"
 	"time"
 
 	"github.com/elastic/beats/libbeat/common"
 	"github.com/elastic/beats/libbeat/logp"
+	"github.com/joeshaw/multierror"
 )
 
 type MothershipConfig struct {
@@ -17,6 +22,10 @@ type MothershipConfig struct {
 	Username          string
 	Password          string
 	ProxyURL          string `yaml:"proxy_url"`
+	BearerToken       string `yaml:"bearer_token"`
+	Method            string
+	Headers           map[string]string
+	BatchFormat       string `yaml:"batch_format"`
 	Index             string
 	Path              string
 	Db                int
@@ -29,10 +38,123 @@ type MothershipConfig struct {
 	DataType          string
 	FlushInterval     *int  `yaml:"flush_interval"`
 	BulkMaxSize       *int  `yaml:"bulk_max_size"`
+	Pipelining        *int  `yaml:"pipelining"`
+	CompressionLevel  int   `yaml:"compression_level"`
 	MaxRetries        *int  `yaml:"max_retries"`
 	Pretty            *bool `yaml:"pretty"`
+	Codec             CodecConfig
 	TLS               *TLSConfig
 	Worker            int
+	Template          TemplateConfig
+	DeadLetter        *DeadLetterConfig `yaml:"dead_letter"`
+	Route             *RouteConfig
+}
+
+// Validate checks the settings shared by all outputs and returns an error
+// describing all problems or nil if there are none. Settings specific to an
+// output type are checked when the output is created.
+func (c MothershipConfig) Validate() error {
+	var errs multierror.Errors
+	if c.Port < 0 || c.Port > 65535 {
+		errs = append(errs, fmt.Errorf("port must be within [0-65535] but was '%d'", c.Port))
+	}
+	if c.CompressionLevel < 0 || c.CompressionLevel > gzip.BestCompression {
+		errs = append(errs, fmt.Errorf("compression_level must be within [0-%d] but was '%d'",
+			gzip.BestCompression, c.CompressionLevel))
+	}
+	if c.Worker < 0 {
+		errs = append(errs, fmt.Errorf("worker must not be negative but was '%d'", c.Worker))
+	}
+	if c.Timeout < 0 {
+		errs = append(errs, fmt.Errorf("timeout must not be negative but was '%d'", c.Timeout))
+	}
+	if c.ReconnectInterval < 0 {
+		errs = append(errs, fmt.Errorf("reconnect_interval must not be negative but was '%d'",
+			c.ReconnectInterval))
+	}
+	if _, err := LoadCodec(&c); err != nil {
+		errs = append(errs, fmt.Errorf("invalid codec: %v", err))
+	}
+	return errs.Err()
+}
+
+// RouteConfig selects the events published to an output. An event is
+// published to the output if it matches any of the When conditions. If Default
+// is set, the output also receives all events not published to any other
+// output. Outputs without route configuration receive all events.
+type RouteConfig struct {
+	Default bool
+	When    []ConditionConfig
+}
+
+// ConditionConfig is a condition on event fields. Fields are named using
+// dots for nested fields (e.g. 'fields.env') and array indices for array
+// elements (e.g. 'tags.0'). An event matches the condition
+// if all configured checks succeed.
+type ConditionConfig struct {
+	// field values must be equal to the given values
+	Equals map[string]interface{}
+
+	// string fields must contain the given substring, arrays (e.g. tags) must
+	// contain the given element
+	Contains map[string]string
+
+	// field values must match the given regular expressions
+	Regexp map[string]string
+
+	// fields must be present
+	Exists []string
+}
+
+// Validate checks the route has conditions or is the default route.
+func (c RouteConfig) Validate() error {
+	if !c.Default && len(c.When) == 0 {
+		return errors.New("route requires 'when' conditions or 'default'")
+	}
+	return nil
+}
+
+// Validate checks the condition is not empty and all regular expressions
+// compile.
+func (c ConditionConfig) Validate() error {
+	if len(c.Equals)+len(c.Contains)+len(c.Regexp)+len(c.Exists) == 0 {
+		return errors.New("empty condition")
+	}
+
+	var errs multierror.Errors
+	for field, expr := range c.Regexp {
+		if _, err := regexp.Compile(expr); err != nil {
+			errs = append(errs, fmt.Errorf("invalid regexp for field %s: %v", field, err))
+		}
+	}
+	return errs.Err()
+}
+
+// DeadLetterConfig configures where the elasticsearch output stores events
+// rejected by Elasticsearch. Events are written to rotating files in Path or
+// indexed into Index.
+type DeadLetterConfig struct {
+	Path          string
+	Filename      string
+	RotateEveryKb int `yaml:"rotate_every_kb"`
+	NumberOfFiles int `yaml:"number_of_files"`
+	Index         string
+}
+
+// TemplateConfig configures the index template installed by the
+// elasticsearch output. Template loading is disabled if Path is not set.
+type TemplateConfig struct {
+	Name      string
+	Path      string
+	Overwrite bool
+
+	// Versions selects a different template file by the major version of
+	// the Elasticsearch cluster. The keys have the form "1x", "2x".
+	Versions map[string]TemplateVersionConfig
+}
+
+type TemplateVersionConfig struct {
+	Path string
 }
 
 type Outputer interface {
@@ -40,6 +162,12 @@ type Outputer interface {
 	PublishEvent(trans Signaler, ts time.Time, event common.MapStr) error
 }
 
+// Closer is implemented by outputs holding connections or files, which are
+// closed when the beat shuts down.
+type Closer interface {
+	Close() error
+}
+
 type TopologyOutputer interface {
 	// Register the agent name and its IPs to the topology map
 	PublishIPs(name string, localAddrs []string) error
@@ -94,6 +222,12 @@ func InitOutputs(
 	configs map[string]MothershipConfig,
 	topologyExpire int,
 ) ([]OutputPlugin, error) {
+	for name := range configs {
+		if _, exists := enabledOutputPlugins[name]; !exists {
+			return nil, fmt.Errorf("unknown output type '%s'", name)
+		}
+	}
+
 	var plugins []OutputPlugin = nil
 	for name, plugin := range enabledOutputPlugins {
 		config, exists := configs[name]
@@ -124,6 +258,18 @@ func CastBulkOutputer(out Outputer) BulkOutputer {
 	return &bulkOutputAdapter{out}
 }
 
+// Close closes out if it implements the Closer interface.
+func Close(out Outputer) error {
+	if c, ok := out.(Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func (b *bulkOutputAdapter) Close() error {
+	return Close(b.Outputer)
+}
+
 func (b *bulkOutputAdapter) BulkPublish(
 	signal Signaler,
 	ts time.Time,
@@ -0,0 +1,32 @@
+package outputs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMothershipConfigValidate(t *testing.T) {
+	assert.NoError(t, MothershipConfig{Port: 9200, CompressionLevel: 9}.Validate())
+
+	invalid := []MothershipConfig{
+		{Port: 70000},
+		{CompressionLevel: 10},
+		{Worker: -1},
+		{Timeout: -1},
+		{ReconnectInterval: -1},
+		{Codec: CodecConfig{JSON: &JSONCodecConfig{}, CSV: &CSVCodecConfig{}}},
+	}
+	for _, config := range invalid {
+		assert.Error(t, config.Validate(), "%+v", config)
+	}
+}
+
+func TestRouteConfigValidate(t *testing.T) {
+	assert.NoError(t, RouteConfig{Default: true}.Validate())
+	assert.Error(t, RouteConfig{}.Validate())
+
+	assert.NoError(t, ConditionConfig{Exists: []string{"type"}}.Validate())
+	assert.Error(t, ConditionConfig{}.Validate())
+	assert.Error(t, ConditionConfig{Regexp: map[string]string{"type": "("}}.Validate())
+}
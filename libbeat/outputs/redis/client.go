@@ -0,0 +1,203 @@
+package redis
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/fmtstr"
+	"github.com/elastic/beats/libbeat/logp"
+	"github.com/elastic/beats/libbeat/outputs"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// ErrNotConnected indicates failure due to client having no valid connection
+var ErrNotConnected = errors.New("not connected")
+
+// transport holds the settings to connect to one Redis server.
+type transport struct {
+	hostport string
+	password string
+	timeout  time.Duration
+	tls      *tls.Config
+}
+
+// client implements the mode.ProtocolClient interface publishing events to
+// one Redis server. All commands of a batch of events are pipelined.
+type client struct {
+	transport
+	conn redis.Conn
+
+	db       int
+	index    *fmtstr.EventFormatString
+	codec    outputs.Codec
+	dataType redisDataType
+}
+
+func newClient(
+	tr transport,
+	db int,
+	index *fmtstr.EventFormatString,
+	codec outputs.Codec,
+	dataType redisDataType,
+) *client {
+	return &client{
+		transport: tr,
+		db:        db,
+		index:     index,
+		codec:     codec,
+		dataType:  dataType,
+	}
+}
+
+// dial connects to the Redis server, authenticates and selects the database.
+func (t *transport) dial(timeout time.Duration, db int) (redis.Conn, error) {
+	dial := func(network, addr string) (net.Conn, error) {
+		conn, err := net.DialTimeout(network, addr, timeout)
+		if err != nil || t.tls == nil {
+			return conn, err
+		}
+
+		config := t.tls.Clone()
+		if config.ServerName == "" {
+			config.ServerName, _, _ = net.SplitHostPort(addr)
+		}
+		tlsConn := tls.Client(conn, config)
+		if timeout > 0 {
+			_ = tlsConn.SetDeadline(time.Now().Add(timeout))
+		}
+		if err := tlsConn.Handshake(); err != nil {
+			_ = tlsConn.Close()
+			return nil, err
+		}
+		_ = tlsConn.SetDeadline(time.Time{})
+		return tlsConn, nil
+	}
+
+	return redis.Dial("tcp", t.hostport,
+		redis.DialNetDial(dial),
+		redis.DialReadTimeout(t.timeout),
+		redis.DialWriteTimeout(t.timeout),
+		redis.DialPassword(t.password),
+		redis.DialDatabase(db))
+}
+
+func (c *client) Connect(timeout time.Duration) error {
+	if c.IsConnected() {
+		_ = c.Close()
+	}
+
+	debug("connect to redis %v", c.hostport)
+	conn, err := c.dial(timeout, c.db)
+	if err != nil {
+		logp.Err("Failed to connect to redis %v: %v", c.hostport, err)
+		return err
+	}
+	c.conn = conn
+	return nil
+}
+
+func (c *client) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+func (c *client) IsConnected() bool {
+	return c.conn != nil
+}
+
+func (c *client) PublishEvent(event common.MapStr) error {
+	_, err := c.PublishEvents([]common.MapStr{event})
+	return err
+}
+
+// PublishEvents pipelines one RPUSH or PUBLISH command per event and waits
+// for all replies. On connection failure all events not confirmed by Redis
+// are returned.
+func (c *client) PublishEvents(
+	events []common.MapStr,
+) ([]common.MapStr, error) {
+	if c.conn == nil {
+		return events, ErrNotConnected
+	}
+
+	command := "RPUSH"
+	if c.dataType == RedisChannelType {
+		command = "PUBLISH"
+	}
+
+	// send commands, dropping events failing to encode
+	okEvents := events[:0]
+	for i, event := range events {
+		key, err := c.index.Run(event)
+		if err != nil {
+			logp.Err("Fail to select the key: %s", err)
+			continue
+		}
+
+		msg, err := c.codec.Encode(event)
+		if err != nil {
+			logp.Err("Fail to encode event: %s", err)
+			continue
+		}
+
+		if err := c.conn.Send(command, key, msg); err != nil {
+			return c.onFail(append(okEvents, events[i:]...), err)
+		}
+		okEvents = append(okEvents, event)
+	}
+	if len(okEvents) == 0 {
+		return nil, nil
+	}
+
+	if err := c.conn.Flush(); err != nil {
+		return c.onFail(okEvents, err)
+	}
+
+	// read replies
+	for i := range okEvents {
+		_, err := c.conn.Receive()
+		if err == nil {
+			continue
+		}
+
+		if _, ok := err.(redis.Error); ok {
+			// error reply (e.g. key holding wrong type) => don't retry
+			logp.Err("Fail to publish event to redis: %s", err)
+			continue
+		}
+		return c.onFail(okEvents[i:], err)
+	}
+
+	return nil, nil
+}
+
+// onFail closes the connection after a network error, so the connection mode
+// reconnects.
+func (c *client) onFail(events []common.MapStr, err error) ([]common.MapStr, error) {
+	logp.Err("Fail to publish events to redis %v: %s", c.hostport, err)
+	_ = c.Close()
+	return events, err
+}
+
+// fullAddress adds the default port to host if no port is given.
+func fullAddress(host string, defaultPort int) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+
+	if strings.Contains(host, ":") {
+		// IPv6 address detected
+		return fmt.Sprintf("[%v]:%v", host, defaultPort)
+	}
+	return fmt.Sprintf("%v:%v", host, defaultPort)
+}
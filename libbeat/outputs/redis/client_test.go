@@ -0,0 +1,239 @@
+package redis
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/fmtstr"
+	"github.com/elastic/beats/libbeat/outputs"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockRedis is a minimal Redis server recording all commands received.
+// RPUSH commands to the key "wrongtype" are answered with an error reply, the
+// connection is closed on RPUSH to the key "close".
+type mockRedis struct {
+	listener net.Listener
+	commands chan []string
+}
+
+func newMockRedis(t *testing.T) *mockRedis {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &mockRedis{listener: l, commands: make(chan []string, 100)}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go m.serve(conn)
+		}
+	}()
+	return m
+}
+
+func (m *mockRedis) Close() {
+	_ = m.listener.Close()
+}
+
+func (m *mockRedis) serve(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	for {
+		cmd, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		m.commands <- cmd
+
+		switch {
+		case cmd[0] == "RPUSH" && cmd[1] == "close":
+			return
+		case cmd[0] == "RPUSH" && cmd[1] == "wrongtype":
+			fmt.Fprint(conn, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n")
+		case cmd[0] == "RPUSH" || cmd[0] == "PUBLISH":
+			fmt.Fprint(conn, ":1\r\n")
+		default:
+			fmt.Fprint(conn, "+OK\r\n")
+		}
+	}
+}
+
+// readCommand reads one command encoded as RESP array of bulk strings.
+func readCommand(r *bufio.Reader) ([]string, error) {
+	readLine := func(prefix byte) (int, error) {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return 0, err
+		}
+		if len(line) < 3 || line[0] != prefix {
+			return 0, fmt.Errorf("unexpected line: %q", line)
+		}
+		return strconv.Atoi(line[1 : len(line)-2])
+	}
+
+	n, err := readLine('*')
+	if err != nil {
+		return nil, err
+	}
+	cmd := make([]string, n)
+	for i := range cmd {
+		size, err := readLine('$')
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		cmd[i] = string(buf[:size])
+	}
+	return cmd, nil
+}
+
+func (m *mockRedis) receive(n int) [][]string {
+	var cmds [][]string
+	for i := 0; i < n; i++ {
+		select {
+		case cmd := <-m.commands:
+			cmds = append(cmds, cmd)
+		case <-time.After(time.Second):
+			return cmds
+		}
+	}
+	return cmds
+}
+
+func newMockClient(
+	m *mockRedis,
+	index string,
+	dataType redisDataType,
+) *client {
+	tr := transport{
+		hostport: m.listener.Addr().String(),
+		password: "secret",
+		timeout:  time.Second,
+	}
+	return newClient(tr, 2, fmtstr.MustCompileEvent(index), outputs.NewJSONCodec(false), dataType)
+}
+
+func TestClientConnect(t *testing.T) {
+	m := newMockRedis(t)
+	defer m.Close()
+
+	c := newMockClient(m, "test", RedisListType)
+	assert.False(t, c.IsConnected())
+	assert.NoError(t, c.Connect(time.Second))
+	assert.True(t, c.IsConnected())
+	defer c.Close()
+
+	assert.Equal(t, [][]string{
+		{"AUTH", "secret"},
+		{"SELECT", "2"},
+	}, m.receive(2))
+}
+
+func TestClientPublishEventsPipelined(t *testing.T) {
+	m := newMockRedis(t)
+	defer m.Close()
+
+	c := newMockClient(m, "beat-%{type}", RedisListType)
+	assert.NoError(t, c.Connect(time.Second))
+	defer c.Close()
+	m.receive(2)
+
+	events := []common.MapStr{
+		{"type": "a", "message": "1"},
+		{"type": "b", "message": "2"},
+		{"message": "no type"}, // dropped, no key
+		{"type": "a", "message": "3"},
+	}
+	rest, err := c.PublishEvents(events)
+	assert.NoError(t, err)
+	assert.Empty(t, rest)
+
+	cmds := m.receive(3)
+	if assert.Len(t, cmds, 3) {
+		assert.Equal(t, []string{"RPUSH", "beat-a", `{"message":"1","type":"a"}`}, cmds[0])
+		assert.Equal(t, []string{"RPUSH", "beat-b", `{"message":"2","type":"b"}`}, cmds[1])
+		assert.Equal(t, []string{"RPUSH", "beat-a", `{"message":"3","type":"a"}`}, cmds[2])
+	}
+}
+
+func TestClientPublishEventsChannel(t *testing.T) {
+	m := newMockRedis(t)
+	defer m.Close()
+
+	c := newMockClient(m, "test", RedisChannelType)
+	assert.NoError(t, c.Connect(time.Second))
+	defer c.Close()
+	m.receive(2)
+
+	assert.NoError(t, c.PublishEvent(common.MapStr{"type": "a"}))
+	assert.Equal(t, [][]string{{"PUBLISH", "test", `{"type":"a"}`}}, m.receive(1))
+}
+
+func TestClientPublishEventsErrorReply(t *testing.T) {
+	m := newMockRedis(t)
+	defer m.Close()
+
+	c := newMockClient(m, "%{type}", RedisListType)
+	assert.NoError(t, c.Connect(time.Second))
+	defer c.Close()
+	m.receive(2)
+
+	// error replies drop the event, but keep the connection
+	rest, err := c.PublishEvents([]common.MapStr{
+		{"type": "wrongtype"},
+		{"type": "ok"},
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, rest)
+	assert.True(t, c.IsConnected())
+	assert.Len(t, m.receive(2), 2)
+}
+
+func TestClientPublishEventsConnectionLost(t *testing.T) {
+	m := newMockRedis(t)
+	defer m.Close()
+
+	c := newMockClient(m, "%{type}", RedisListType)
+	assert.NoError(t, c.Connect(time.Second))
+	m.receive(2)
+
+	// events not confirmed before the connection is closed are returned
+	events := []common.MapStr{
+		{"type": "ok"},
+		{"type": "close"},
+		{"type": "ok"},
+	}
+	rest, err := c.PublishEvents(events)
+	assert.Error(t, err)
+	assert.Len(t, rest, 2)
+	assert.False(t, c.IsConnected())
+}
+
+func TestClientNotConnected(t *testing.T) {
+	c := newClient(transport{}, 0, fmtstr.MustCompileEvent("test"), outputs.NewJSONCodec(false), RedisListType)
+	events := []common.MapStr{{"type": "a"}}
+	rest, err := c.PublishEvents(events)
+	assert.Equal(t, ErrNotConnected, err)
+	assert.Equal(t, events, rest)
+}
+
+func TestFullAddress(t *testing.T) {
+	assert.Equal(t, "localhost:6379", fullAddress("localhost", 6379))
+	assert.Equal(t, "localhost:1234", fullAddress("localhost:1234", 6379))
+	assert.Equal(t, "[::1]:6379", fullAddress("::1", 6379))
+}
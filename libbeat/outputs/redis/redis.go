@@ -4,7 +4,7 @@
 package redis
 
 import (
-	"encoding/json"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"strings"
@@ -12,8 +12,10 @@ import (
 	"time"
 
 	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/fmtstr"
 	"github.com/elastic/beats/libbeat/logp"
 	"github.com/elastic/beats/libbeat/outputs"
+	"github.com/elastic/beats/libbeat/outputs/mode"
 
 	"github.com/garyburd/redigo/redis"
 )
@@ -46,40 +48,35 @@ const (
 )
 
 type redisOutput struct {
-	Index string
-	Conn  redis.Conn
-
-	TopologyExpire    time.Duration
-	ReconnectInterval time.Duration
-	Hostname          string
-	Password          string
-	Db                int
-	DbTopology        int
-	Timeout           time.Duration
-	DataType          redisDataType
-
-	TopologyMap atomic.Value // Value holds a map[string][string]
-	connected   bool
-}
+	mode mode.ConnectionMode
 
-type message struct {
-	trans outputs.Signaler
-	index string
-	msg   string
+	// transports of all configured hosts, used to store and read the topology
+	transports     []transport
+	DbTopology     int
+	TopologyExpire time.Duration
+	TopologyMap    atomic.Value // Value holds a map[string][string]
 }
 
-func (out *redisOutput) Init(beat string, config outputs.MothershipConfig, topology_expire int) error {
+const (
+	redisDefaultPort    = 6379
+	redisDefaultTimeout = 5 * time.Second
+	defaultSendRetries  = 3
+)
 
-	logp.Warn("Redis Output is deprecated. Please use the Redis Output Plugin from Logstash instead.")
+var debug = logp.MakeDebug("redis")
 
-	out.Hostname = fmt.Sprintf("%s:%d", config.Host, config.Port)
+var (
+	waitRetry    = time.Duration(1) * time.Second
+	maxWaitRetry = time.Duration(60) * time.Second
+)
 
-	if config.Password != "" {
-		out.Password = config.Password
-	}
+func (out *redisOutput) Init(beat string, config outputs.MothershipConfig, topology_expire int) error {
 
-	if config.Db != 0 {
-		out.Db = config.Db
+	logp.Warn("Redis Output is deprecated. Please use the Redis Output Plugin from Logstash instead.")
+
+	defaultPort := redisDefaultPort
+	if config.Port != 0 {
+		defaultPort = config.Port
 	}
 
 	out.DbTopology = 1
@@ -87,22 +84,24 @@ func (out *redisOutput) Init(beat string, config outputs.MothershipConfig, topol
 		out.DbTopology = config.Db_topology
 	}
 
-	out.Timeout = 5 * time.Second
+	timeout := redisDefaultTimeout
 	if config.Timeout != 0 {
-		out.Timeout = time.Duration(config.Timeout) * time.Second
+		timeout = time.Duration(config.Timeout) * time.Second
 	}
 
+	index := beat
 	if config.Index != "" {
-		out.Index = config.Index
-	} else {
-		out.Index = beat
+		index = config.Index
+	}
+	indexFormat, err := fmtstr.CompileEvent(index)
+	if err != nil {
+		return fmt.Errorf("invalid index: %v", err)
 	}
 
-	out.ReconnectInterval = time.Duration(1) * time.Second
+	reconnectInterval := waitRetry
 	if config.ReconnectInterval != 0 {
-		out.ReconnectInterval = time.Duration(config.ReconnectInterval) * time.Second
+		reconnectInterval = time.Duration(config.ReconnectInterval) * time.Second
 	}
-	logp.Info("Reconnect Interval set to: %v", out.ReconnectInterval)
 
 	expSec := 15
 	if topology_expire != 0 {
@@ -110,87 +109,105 @@ func (out *redisOutput) Init(beat string, config outputs.MothershipConfig, topol
 	}
 	out.TopologyExpire = time.Duration(expSec) * time.Second
 
+	var dataType redisDataType
 	switch config.DataType {
 	case "", "list":
-		out.DataType = RedisListType
+		dataType = RedisListType
 	case "channel":
-		out.DataType = RedisChannelType
+		dataType = RedisChannelType
 	default:
 		return errors.New("Bad Redis data type")
 	}
 
-	logp.Info("[RedisOutput] Using Redis server %s", out.Hostname)
-	if out.Password != "" {
-		logp.Info("[RedisOutput] Using password to connect to Redis")
-	}
-	logp.Info("[RedisOutput] Redis connection timeout %s", out.Timeout)
-	logp.Info("[RedisOutput] Redis reconnect interval %s", out.ReconnectInterval)
-	logp.Info("[RedisOutput] Using index pattern %s", out.Index)
-	logp.Info("[RedisOutput] Topology expires after %s", out.TopologyExpire)
-	logp.Info("[RedisOutput] Using db %d for storing events", out.Db)
-	logp.Info("[RedisOutput] Using db %d for storing topology", out.DbTopology)
-	logp.Info("[RedisOutput] Using %d data type", out.DataType)
-
-	out.Reconnect()
-
-	return nil
-}
-
-func (out *redisOutput) RedisConnect(db int) (redis.Conn, error) {
-	conn, err := redis.DialTimeout(
-		"tcp",
-		out.Hostname,
-		out.Timeout, out.Timeout, out.Timeout)
+	codec, err := outputs.LoadCodec(&config)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	if len(out.Password) > 0 {
-		_, err = conn.Do("AUTH", out.Password)
+	var tlsConfig *tls.Config
+	if config.TLS != nil {
+		tlsConfig, err = outputs.LoadTLSConfig(config.TLS)
 		if err != nil {
-			return nil, err
+			return err
 		}
 	}
 
-	_, err = conn.Do("PING")
+	clients, err := mode.MakeClients(config, func(host string) (mode.ProtocolClient, error) {
+		tr := transport{
+			hostport: fullAddress(host, defaultPort),
+			password: config.Password,
+			timeout:  timeout,
+			tls:      tlsConfig,
+		}
+		out.transports = append(out.transports, tr)
+		return newClient(tr, config.Db, indexFormat, codec, dataType), nil
+	})
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	_, err = conn.Do("SELECT", db)
-	if err != nil {
-		return nil, err
+	sendRetries := defaultSendRetries
+	if config.MaxRetries != nil {
+		sendRetries = *config.MaxRetries
+	}
+	maxAttempts := sendRetries + 1
+	if sendRetries < 0 {
+		maxAttempts = 0
 	}
 
-	return conn, nil
-}
-
-func (out *redisOutput) Connect() error {
-	var err error
-	out.Conn, err = out.RedisConnect(out.Db)
+	var m mode.ConnectionMode
+	if len(clients) == 1 {
+		m, err = mode.NewSingleConnectionMode(clients[0],
+			maxAttempts, reconnectInterval, timeout, maxWaitRetry)
+	} else {
+		loadBalance := config.LoadBalance != nil && *config.LoadBalance
+		if loadBalance {
+			m, err = mode.NewLoadBalancerMode(clients, maxAttempts,
+				reconnectInterval, timeout, maxWaitRetry)
+		} else {
+			m, err = mode.NewFailOverConnectionMode(clients, maxAttempts,
+				reconnectInterval, timeout)
+		}
+	}
 	if err != nil {
 		return err
 	}
-	out.connected = true
+	out.mode = m
 
-	return nil
-}
+	for _, tr := range out.transports {
+		logp.Info("[RedisOutput] Using Redis server %s", tr.hostport)
+	}
+	if config.Password != "" {
+		logp.Info("[RedisOutput] Using password to connect to Redis")
+	}
+	if tlsConfig != nil {
+		logp.Info("[RedisOutput] Using TLS to connect to Redis")
+	}
+	logp.Info("[RedisOutput] Redis connection timeout %s", timeout)
+	logp.Info("[RedisOutput] Redis reconnect interval %s", reconnectInterval)
+	logp.Info("[RedisOutput] Max Retries set to: %v", sendRetries)
+	logp.Info("[RedisOutput] Using index pattern %s", indexFormat)
+	logp.Info("[RedisOutput] Topology expires after %s", out.TopologyExpire)
+	logp.Info("[RedisOutput] Using db %d for storing events", config.Db)
+	logp.Info("[RedisOutput] Using db %d for storing topology", out.DbTopology)
+	logp.Info("[RedisOutput] Using %d data type", dataType)
 
-func (out *redisOutput) Close() {
-	_ = out.Conn.Close()
+	return nil
 }
 
-func (out *redisOutput) Reconnect() {
-
-	for {
-		err := out.Connect()
-		if err != nil {
-			logp.Warn("Error connecting to Redis (%s). Retrying in %s", err, out.ReconnectInterval)
-			time.Sleep(out.ReconnectInterval)
-		} else {
-			break
+// connectTopology connects to the topology database of the first available
+// Redis server.
+func (out *redisOutput) connectTopology() (redis.Conn, error) {
+	err := ErrNotConnected
+	for _, tr := range out.transports {
+		var conn redis.Conn
+		conn, err = tr.dial(tr.timeout, out.DbTopology)
+		if err == nil {
+			return conn, nil
 		}
+		logp.Warn("Error connecting to Redis %s: %s", tr.hostport, err)
 	}
+	return nil, err
 }
 
 func (out *redisOutput) GetNameByIP(ip string) string {
@@ -208,7 +225,7 @@ func (out *redisOutput) PublishIPs(name string, localAddrs []string) error {
 	logp.Debug("output_redis", "[%s] Publish the IPs %s", name, localAddrs)
 
 	// connect to db
-	conn, err := out.RedisConnect(out.DbTopology)
+	conn, err := out.connectTopology()
 	if err != nil {
 		return err
 	}
@@ -255,72 +272,26 @@ func (out *redisOutput) UpdateLocalTopologyMap(conn redis.Conn) {
 	logp.Debug("output_redis", "Topology %s", topologyMapTmp)
 }
 
+// Close closes the connections to all hosts. In flight events are signaled
+// as failed.
+func (out *redisOutput) Close() error {
+	return out.mode.Close()
+}
+
 func (out *redisOutput) PublishEvent(
 	signal outputs.Signaler,
 	ts time.Time,
 	event common.MapStr,
 ) error {
-	return out.BulkPublish(signal, ts, []common.MapStr{event})
+	return out.mode.PublishEvent(signal, event)
 }
 
+// BulkPublish implements the BulkOutputer interface pipelining all events
+// to one Redis server.
 func (out *redisOutput) BulkPublish(
 	signal outputs.Signaler,
 	ts time.Time,
 	events []common.MapStr,
 ) error {
-	if !out.connected {
-		logp.Debug("output_redis", "Droping pkt ...")
-		return errors.New("Not connected")
-	}
-
-	command := "RPUSH"
-	if out.DataType == RedisChannelType {
-		command = "PUBLISH"
-	}
-
-	if len(events) == 1 { // single event
-		event := events[0]
-		jsonEvent, err := json.Marshal(event)
-		if err != nil {
-			logp.Err("Fail to convert the event to JSON: %s", err)
-			outputs.SignalCompleted(signal)
-			return err
-		}
-
-		_, err = out.Conn.Do(command, out.Index, string(jsonEvent))
-		outputs.Signal(signal, err)
-		out.onFail(err)
-		return err
-	}
-
-	for _, event := range events {
-		jsonEvent, err := json.Marshal(event)
-		if err != nil {
-			logp.Err("Fail to convert the event to JSON: %s", err)
-			continue
-		}
-		err = out.Conn.Send(command, out.Index, string(jsonEvent))
-		if err != nil {
-			outputs.SignalFailed(signal, err)
-			out.onFail(err)
-			return err
-		}
-	}
-	if err := out.Conn.Flush(); err != nil {
-		outputs.Signal(signal, err)
-		out.onFail(err)
-		return err
-	}
-	_, err := out.Conn.Receive()
-	outputs.Signal(signal, err)
-	out.onFail(err)
-	return err
-}
-
-func (out *redisOutput) onFail(err error) {
-	if err != nil {
-		logp.Err("Fail to publish event to REDIS: %s", err)
-		out.connected = false
-		go out.Reconnect()
-	}
+	return out.mode.PublishEvents(signal, events)
 }
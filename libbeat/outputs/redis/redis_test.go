@@ -30,32 +30,19 @@ func TestTopologyInRedis(t *testing.T) {
 		t.Skip("Skipping topology tests in short mode, because they require REDIS")
 	}
 
-	var redisOutput1 = redisOutput{
-		Index:          "packetbeat",
-		Hostname:       GetRedisAddr(),
-		Password:       "",
-		DbTopology:     1,
-		Timeout:        time.Duration(5) * time.Second,
-		TopologyExpire: time.Duration(15) * time.Second,
-	}
-
-	var redisOutput2 = redisOutput{
-		Index:          "packetbeat",
-		Hostname:       GetRedisAddr(),
-		Password:       "",
-		DbTopology:     1,
-		Timeout:        time.Duration(5) * time.Second,
-		TopologyExpire: time.Duration(15) * time.Second,
-	}
-
-	var redisOutput3 = redisOutput{
-		Index:          "packetbeat",
-		Hostname:       GetRedisAddr(),
-		Password:       "",
-		DbTopology:     1,
-		Timeout:        time.Duration(5) * time.Second,
-		TopologyExpire: time.Duration(15) * time.Second,
+	newRedisOutput := func() *redisOutput {
+		return &redisOutput{
+			transports: []transport{{
+				hostport: GetRedisAddr(),
+				timeout:  time.Duration(5) * time.Second,
+			}},
+			DbTopology:     1,
+			TopologyExpire: time.Duration(15) * time.Second,
+		}
 	}
+	redisOutput1 := newRedisOutput()
+	redisOutput2 := newRedisOutput()
+	redisOutput3 := newRedisOutput()
 
 	redisOutput1.PublishIPs("proxy1", []string{"10.1.0.4"})
 	redisOutput2.PublishIPs("proxy2", []string{"10.1.0.9", "fe80::4e8d:79ff:fef2:de6a"})
@@ -5,7 +5,6 @@ import (
 
 	"github.com/elastic/beats/libbeat/common"
 	"github.com/elastic/beats/libbeat/logp"
-	"github.com/elastic/beats/libbeat/outputs"
 )
 
 type asyncPublisher struct {
@@ -47,12 +46,7 @@ func (p *asyncPublisher) onMessage(m message) {
 	// be implemented in the furute.
 	// If m.signal is nil, NewSplitSignaler will return nil -> signaler will
 	// only set if client did send one
-	if m.context.signal != nil && len(p.outputs) > 1 {
-		m.context.signal = outputs.NewSplitSignaler(m.context.signal, len(p.outputs))
-	}
-	for _, o := range p.outputs {
-		o.send(m)
-	}
+	p.pub.currentRouter().forward(m, p.outputs)
 }
 
 func (p *asyncPublisher) client() eventPublisher {
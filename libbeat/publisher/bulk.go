@@ -1,6 +1,8 @@
 package publisher
 
 import (
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/elastic/beats/libbeat/common"
@@ -8,6 +10,7 @@ import (
 )
 
 type bulkWorker struct {
+	queued int64 // number of events sent to the worker and not forwarded yet
 	output worker
 	ws     *workerSignal
 
@@ -17,6 +20,10 @@ type bulkWorker struct {
 	maxBatchSize int
 	events       []common.MapStr    // batched events
 	pending      []outputs.Signaler // pending signalers for batched events
+
+	// guards the queue from being sent to after the worker has been stopped
+	sendLock sync.RWMutex
+	closed   bool
 }
 
 func newBulkWorker(
@@ -39,8 +46,29 @@ func newBulkWorker(
 	return b
 }
 
+// send forwards m to the worker. If the worker has been stopped, m is
+// signaled as failed instead.
 func (b *bulkWorker) send(m message) {
-	b.queue <- m
+	b.sendLock.RLock()
+	defer b.sendLock.RUnlock()
+
+	if b.closed {
+		outputs.SignalFailed(m.context.signal, nil)
+		return
+	}
+
+	atomic.AddInt64(&b.queued, int64(m.count()))
+	select {
+	case <-b.ws.done:
+		atomic.AddInt64(&b.queued, -int64(m.count()))
+		outputs.SignalFailed(m.context.signal, nil)
+	case b.queue <- m:
+	}
+}
+
+// pendingEvents returns the number of events queued or batched by the worker.
+func (b *bulkWorker) pendingEvents() int64 {
+	return atomic.LoadInt64(&b.queued)
 }
 
 func (b *bulkWorker) run() {
@@ -115,6 +143,7 @@ func (b *bulkWorker) publish() {
 		},
 		events: b.events,
 	})
+	atomic.AddInt64(&b.queued, -int64(len(b.events)))
 
 	b.pending = nil
 	b.events = make([]common.MapStr, 0, b.maxBatchSize)
@@ -122,6 +151,15 @@ func (b *bulkWorker) publish() {
 
 func (b *bulkWorker) shutdown() {
 	b.flushTicker.Stop()
+
+	b.sendLock.Lock()
+	b.closed = true
+	b.sendLock.Unlock()
+
+	// events batched but not forwarded are dropped
+	for _, signal := range b.pending {
+		outputs.SignalFailed(signal, nil)
+	}
 	stopQueue(b.queue)
 	b.ws.wg.Done()
 }
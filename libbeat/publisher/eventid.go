@@ -0,0 +1,54 @@
+package publisher
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+// EventIDFunc computes the ID of an event. Beats can install their own
+// function to derive IDs from the event content, so events published again
+// after a restart get the same ID.
+type EventIDFunc func(event common.MapStr) (string, error)
+
+// SetEventIDFunc installs the function used to compute the ID of events not
+// having an ID yet. If not set, a random ID is generated.
+func (publisher *PublisherType) SetEventIDFunc(f EventIDFunc) {
+	publisher.eventID = f
+}
+
+// DocumentID returns true if event IDs are enabled in the shipper
+// configuration. Beats setting the event ID on their own when creating the
+// event must only do so if enabled.
+func (publisher *PublisherType) DocumentID() bool {
+	return publisher.documentID
+}
+
+// addEventID sets the ID of an event if the event has no ID yet.
+func (publisher *PublisherType) addEventID(event common.MapStr) error {
+	if common.EventIDOf(event) != "" {
+		return nil
+	}
+
+	f := publisher.eventID
+	if f == nil {
+		f = randomEventID
+	}
+	id, err := f(event)
+	if err != nil {
+		return err
+	}
+	event[common.EventIDField] = id
+	return nil
+}
+
+// randomEventID generates a random ID. The ID is set once when the event is
+// published, so output retries reuse the ID.
+func randomEventID(event common.MapStr) (string, error) {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id[:]), nil
+}
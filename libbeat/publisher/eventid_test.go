@@ -0,0 +1,55 @@
+package publisher
+
+import (
+	"testing"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddEventIDRandom(t *testing.T) {
+	pub := &PublisherType{}
+
+	e1, e2 := testEvent(), testEvent()
+	assert.NoError(t, pub.addEventID(e1))
+	assert.NoError(t, pub.addEventID(e2))
+
+	id := common.EventIDOf(e1)
+	assert.Len(t, id, 32)
+	assert.NotEqual(t, id, common.EventIDOf(e2))
+
+	// IDs are not replaced
+	assert.NoError(t, pub.addEventID(e1))
+	assert.Equal(t, id, common.EventIDOf(e1))
+}
+
+func TestAddEventIDFunc(t *testing.T) {
+	pub := &PublisherType{}
+	pub.SetEventIDFunc(func(event common.MapStr) (string, error) {
+		return common.EventID(event["type"]), nil
+	})
+
+	event := testEvent()
+	assert.NoError(t, pub.addEventID(event))
+	assert.Equal(t, common.EventID("test"), common.EventIDOf(event))
+
+	// IDs set by the beat are kept
+	event = testEvent()
+	event[common.EventIDField] = "beat-id"
+	assert.NoError(t, pub.addEventID(event))
+	assert.Equal(t, "beat-id", common.EventIDOf(event))
+}
+
+func TestPreprocessEventID(t *testing.T) {
+	testPub := newTestPublisherNoBulk(CompletedResponse)
+	testPub.pub.documentID = true
+
+	event := testEvent()
+	assert.True(t, testPub.syncPublishEvent(event))
+
+	msgs, err := testPub.outputMsgHandler.waitForMessages(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotEmpty(t, common.EventIDOf(msgs[0].event))
+}
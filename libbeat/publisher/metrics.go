@@ -0,0 +1,103 @@
+package publisher
+
+import (
+	"expvar"
+
+	"github.com/elastic/beats/libbeat/outputs"
+)
+
+// Metrics that can be retrieved through the expvar web interface.
+var (
+	// number of events published, acked and failed by output
+	outputEvents = expvar.NewMap("libbeatOutputEvents")
+)
+
+func init() {
+	expvar.Publish("libbeatPublisherQueues", expvar.Func(queueLengths))
+}
+
+// queueLengths returns the number of messages waiting in the publisher and
+// output worker queues.
+func queueLengths() interface{} {
+	queues := map[string]interface{}{}
+	if p := Publisher.syncPublisher; p != nil {
+		queues["sync"] = len(p.queue)
+	}
+	if p := Publisher.asyncPublisher; p != nil {
+		queues["async"] = len(p.queue)
+	}
+
+	outputQueues := map[string]int{}
+	for _, o := range Publisher.Output {
+		if o.stats != nil {
+			outputQueues[o.stats.name] = len(o.queue)
+		}
+	}
+	queues["outputs"] = outputQueues
+	return queues
+}
+
+// outputStats counts the events passed to one output.
+type outputStats struct {
+	name      string
+	published *expvar.Int // events passed to the output
+	acked     *expvar.Int // events the output reported as sent
+	failed    *expvar.Int // events failed to send, being dropped or retried
+}
+
+// statsSignaler counts the events of one publish request on completion
+// before forwarding the signal.
+type statsSignaler struct {
+	stats    *outputStats
+	count    int64
+	signaler outputs.Signaler
+}
+
+func newOutputStats(name string) *outputStats {
+	s := &outputStats{
+		name:      name,
+		published: new(expvar.Int),
+		acked:     new(expvar.Int),
+		failed:    new(expvar.Int),
+	}
+
+	m := new(expvar.Map).Init()
+	m.Set("published", s.published)
+	m.Set("acked", s.acked)
+	m.Set("failed", s.failed)
+	outputEvents.Set(name, m)
+	return s
+}
+
+// signaler counts count events as published and returns a signaler counting
+// them as acked or failed once the output signals the result.
+func (s *outputStats) signaler(signaler outputs.Signaler, count int) outputs.Signaler {
+	if s == nil {
+		return signaler
+	}
+
+	s.published.Add(int64(count))
+	return &statsSignaler{stats: s, count: int64(count), signaler: signaler}
+}
+
+func (s *statsSignaler) Completed() {
+	s.stats.acked.Add(s.count)
+	outputs.SignalCompleted(s.signaler)
+}
+
+func (s *statsSignaler) Failed() {
+	s.stats.failed.Add(s.count)
+	outputs.SignalFailed(s.signaler, nil)
+}
+
+// inFlight returns the number of events passed to the output and not yet
+// reported as acked or failed.
+func (s *outputStats) inFlight() int64 {
+	if s == nil {
+		return 0
+	}
+
+	// published is read last, as it is always increased first
+	done := s.acked.Value() + s.failed.Value()
+	return s.published.Value() - done
+}
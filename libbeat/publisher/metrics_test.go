@@ -0,0 +1,57 @@
+package publisher
+
+import (
+	"expvar"
+	"testing"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/outputs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutputStatsCounts(t *testing.T) {
+	outputer := &testOutputer{events: make(chan common.MapStr, 10)}
+	ow := newOutputWorker("statsTest", outputs.MothershipConfig{}, outputer, newWorkerSignal(), 1)
+
+	s := newTestSignaler()
+	ow.onMessage(testBulkMessage(s, []common.MapStr{testEvent(), testEvent()}))
+	assert.True(t, s.wait())
+
+	s = newTestSignaler()
+	ow.onMessage(testMessage(s, testEvent()))
+	assert.True(t, s.wait())
+
+	assert.Equal(t, int64(3), ow.stats.published.Value())
+	assert.Equal(t, int64(3), ow.stats.acked.Value())
+	assert.Equal(t, int64(0), ow.stats.failed.Value())
+
+	m, ok := outputEvents.Get("statsTest").(*expvar.Map)
+	if assert.True(t, ok) {
+		assert.Equal(t, "3", m.Get("acked").String())
+	}
+}
+
+func TestOutputStatsFailed(t *testing.T) {
+	stats := newOutputStats("statsFailedTest")
+
+	s := newTestSignaler()
+	stats.signaler(s, 5).Failed()
+	assert.False(t, s.wait())
+
+	assert.Equal(t, int64(5), stats.published.Value())
+	assert.Equal(t, int64(5), stats.failed.Value())
+	assert.Equal(t, int64(0), stats.acked.Value())
+}
+
+func TestOutputStatsNil(t *testing.T) {
+	var stats *outputStats
+	s := newTestSignaler()
+	assert.True(t, stats.signaler(s, 1) == s)
+}
+
+func TestQueueLengths(t *testing.T) {
+	queues, ok := queueLengths().(map[string]interface{})
+	if assert.True(t, ok) {
+		assert.Contains(t, queues, "outputs")
+	}
+}
@@ -13,9 +13,11 @@ type outputWorker struct {
 	out         outputs.BulkOutputer
 	config      outputs.MothershipConfig
 	maxBulkSize int
+	stats       *outputStats
 }
 
 func newOutputWorker(
+	name string,
 	config outputs.MothershipConfig,
 	out outputs.Outputer,
 	ws *workerSignal,
@@ -30,6 +32,7 @@ func newOutputWorker(
 		out:         outputs.CastBulkOutputer(out),
 		config:      config,
 		maxBulkSize: maxBulkSize,
+		stats:       newOutputStats(name),
 	}
 	o.messageWorker.init(ws, hwm, o)
 	return o
@@ -51,17 +54,21 @@ func (o *outputWorker) onEvent(ctx *context, event common.MapStr) {
 	ts := time.Time(event["@timestamp"].(common.Time)).UTC()
 
 	if !ctx.sync {
-		_ = o.out.PublishEvent(ctx.signal, ts, event)
+		_ = o.out.PublishEvent(o.stats.signaler(ctx.signal, 1), ts, event)
 		return
 	}
 
 	signal := outputs.NewSyncSignal()
 	for {
-		o.out.PublishEvent(signal, ts, event)
+		o.out.PublishEvent(o.stats.signaler(signal, 1), ts, event)
 		if signal.Wait() {
 			outputs.SignalCompleted(ctx.signal)
 			break
 		}
+		if o.stopped() {
+			outputs.SignalFailed(ctx.signal, nil)
+			break
+		}
 	}
 }
 
@@ -104,7 +111,7 @@ func (o *outputWorker) sendBulk(
 	ts := time.Time(events[0]["@timestamp"].(common.Time)).UTC()
 
 	if sync == nil {
-		err := o.out.BulkPublish(ctx.signal, ts, events)
+		err := o.out.BulkPublish(o.stats.signaler(ctx.signal, len(events)), ts, events)
 		if err != nil {
 			logp.Info("Error bulk publishing events: %s", err)
 		}
@@ -112,7 +119,22 @@ func (o *outputWorker) sendBulk(
 	}
 
 	for done := false; !done; done = sync.Wait() {
-		o.out.BulkPublish(sync, ts, events)
+		if o.stopped() {
+			outputs.SignalFailed(ctx.signal, nil)
+			return
+		}
+		o.out.BulkPublish(o.stats.signaler(sync, len(events)), ts, events)
 	}
 	outputs.SignalCompleted(ctx.signal)
 }
+
+// stopped returns true if the output worker has been asked to stop. Sync
+// publishing gives up retrying once stopped.
+func (o *outputWorker) stopped() bool {
+	select {
+	case <-o.ws.done:
+		return true
+	default:
+		return false
+	}
+}
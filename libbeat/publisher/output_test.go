@@ -29,6 +29,7 @@ func (t *testOutputer) PublishEvent(trans outputs.Signaler, ts time.Time,
 func TestOutputWorker(t *testing.T) {
 	outputer := &testOutputer{events: make(chan common.MapStr, 10)}
 	ow := newOutputWorker(
+		"test",
 		outputs.MothershipConfig{},
 		outputer,
 		newWorkerSignal(),
@@ -2,7 +2,6 @@ package publisher
 
 import (
 	"errors"
-	"fmt"
 
 	"github.com/elastic/beats/libbeat/common"
 	"github.com/elastic/beats/libbeat/logp"
@@ -52,13 +51,21 @@ func (p *preprocessor) onMessage(m message) {
 			continue
 		}
 
+		if publisher.documentID {
+			if err := publisher.addEventID(event); err != nil {
+				logp.Err("Failed to compute event ID: %v", err)
+				ignore = append(ignore, i)
+				continue
+			}
+		}
+
 		// add additional Beat meta data
 		event["beat"] = common.MapStr{
 			"name":     publisher.name,
 			"hostname": publisher.hostname,
 		}
-		if len(publisher.tags) > 0 {
-			event["tags"] = publisher.tags
+		if tags := publisher.currentTags(); len(tags) > 0 {
+			event["tags"] = tags
 		}
 
 		if logp.IsDebug("publish") {
@@ -160,31 +167,35 @@ func updateEventAddresses(publisher *PublisherType, event common.MapStr) bool {
 		}
 	}
 
-	if publisher.IgnoreOutgoing && dstServer != "" &&
-		dstServer != publisher.name {
-		// duplicated transaction -> ignore it
-		debug("Ignore duplicated transaction on %s: %s -> %s",
-			publisher.name, srcServer, dstServer)
-		return false
+	// servers named by reverse DNS do not run a shipper
+	if publisher.IgnoreOutgoing && dst != nil {
+		dstShipper := publisher.GetShipperName(dst.Ip)
+		if dstShipper != "" && dstShipper != publisher.name {
+			// duplicated transaction -> ignore it
+			debug("Ignore duplicated transaction on %s: %s -> %s",
+				publisher.name, srcServer, dstShipper)
+			return false
+		}
 	}
 
-	if publisher.GeoLite != nil {
-		realIP, exists := event["real_ip"]
-		if exists && len(realIP.(string)) > 0 {
-			loc := publisher.GeoLite.GetLocationByIP(realIP.(string))
-			if loc != nil && loc.Latitude != 0 && loc.Longitude != 0 {
-				loc := fmt.Sprintf("%f, %f", loc.Latitude, loc.Longitude)
-				event["client_location"] = loc
+	if publisher.GeoIP != nil {
+		realIP, err := event.GetString("real_ip")
+		if err == nil && len(realIP) > 0 {
+			loc := publisher.GeoIP.Lookup(realIP)
+			if loc != nil && loc.Point != nil {
+				event["client_location"] = loc.Point.String()
 			}
 		} else {
-			if len(srcServer) == 0 && src != nil { // only for external IP addresses
-				loc := publisher.GeoLite.GetLocationByIP(src.Ip)
-				if loc != nil && loc.Latitude != 0 && loc.Longitude != 0 {
-					loc := fmt.Sprintf("%f, %f", loc.Latitude, loc.Longitude)
-					event["client_location"] = loc
+			// only for external IP addresses
+			if src != nil && len(publisher.GetShipperName(src.Ip)) == 0 {
+				loc := publisher.GeoIP.Lookup(src.Ip)
+				if loc != nil && loc.Point != nil {
+					event["client_location"] = loc.Point.String()
 				}
 			}
 		}
+
+		publisher.GeoIP.Enrich(event)
 	}
 
 	return true
@@ -48,3 +48,31 @@ func TestFilterEvent(t *testing.T) {
 		assert.Regexp(t, test.err, filterEvent(test.f()))
 	}
 }
+
+// Test transactions to servers named by reverse DNS are not taken for
+// transactions to other shippers.
+func TestUpdateEventAddressesIgnoreOutgoing(t *testing.T) {
+	pt := &PublisherType{
+		name:           shipperName,
+		IgnoreOutgoing: true,
+		topology: staticTopology{
+			"10.0.0.2": "web2.example.com", // resolved by reverse DNS
+			"10.0.0.3": "otherShipper",
+		},
+		shippers: staticTopology{"10.0.0.3": "otherShipper"},
+	}
+
+	event := common.MapStr{
+		"src": &common.Endpoint{Ip: "10.0.0.1", Port: 40000},
+		"dst": &common.Endpoint{Ip: "10.0.0.2", Port: 80},
+	}
+	assert.True(t, updateEventAddresses(pt, event))
+	assert.Equal(t, "web2.example.com", event["server"])
+	assert.Equal(t, "out", event["direction"])
+
+	event = common.MapStr{
+		"src": &common.Endpoint{Ip: "10.0.0.1", Port: 40000},
+		"dst": &common.Endpoint{Ip: "10.0.0.3", Port: 80},
+	}
+	assert.False(t, updateEventAddresses(pt, event))
+}
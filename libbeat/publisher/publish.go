@@ -4,18 +4,21 @@ import (
 	"encoding/json"
 	"errors"
 	"flag"
+	"fmt"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/common/geoip"
 	"github.com/elastic/beats/libbeat/logp"
 	"github.com/elastic/beats/libbeat/outputs"
-	"github.com/nranchev/go-libGeoIP"
 
 	// load supported output plugins
 	_ "github.com/elastic/beats/libbeat/outputs/console"
 	_ "github.com/elastic/beats/libbeat/outputs/elasticsearch"
 	_ "github.com/elastic/beats/libbeat/outputs/fileout"
+	_ "github.com/elastic/beats/libbeat/outputs/httpout"
 	_ "github.com/elastic/beats/libbeat/outputs/logstash"
 	_ "github.com/elastic/beats/libbeat/outputs/redis"
 )
@@ -55,19 +58,50 @@ type PublisherType struct {
 	Index          string
 	Output         []*outputWorker
 	TopologyOutput outputs.TopologyOutputer
+
+	// resolves IP addresses to server names, consulting the configured
+	// providers and TopologyOutput. nil if no provider is configured
+	topology TopologyProvider
+
+	// resolves IP addresses to shipper names, consulting the static topology
+	// and TopologyOutput only. nil if neither is configured
+	shippers TopologyProvider
+
+	// selects the outputs each event is published to, nil if all events are
+	// published to all outputs
+	router *router
+
+	// configuration the publisher was initialized with, used to find the
+	// settings changed on reload
+	outputNames   []string
+	outputConfigs map[string]outputs.MothershipConfig
+	shipper       ShipperConfig
+
+	// guards the settings being replaced on reload (router and tags)
+	reloadLock sync.RWMutex
+
 	IgnoreOutgoing bool
-	GeoLite        *libgeo.GeoIP
+	GeoIP          *geoip.GeoIP
+
+	// set the event ID of each published event
+	documentID bool
+	eventID    EventIDFunc
 
 	RefreshTopologyTimer <-chan time.Time
 
-	// wsOutput and wsPublisher should be used for proper shutdown of publisher
-	// (not implemented yet). On shutdown the publisher should be finished first
-	// and the outputers next, so no publisher will attempt to send messages on
-	// closed channels.
+	// wsOutput and wsPublisher are used for shutdown of the publisher. On
+	// shutdown the publisher workers are stopped first and the output workers
+	// next, see Stop.
 	// Note: beat data producers must be shutdown before the publisher plugin
 	wsOutput    workerSignal
 	wsPublisher workerSignal
 
+	// time waited on shutdown for queued events to be published
+	shutdownTimeout time.Duration
+	deadline        time.Time
+	shutdownOnce    sync.Once
+	stopOnce        sync.Once
+
 	syncPublisher  *syncPublisher
 	asyncPublisher *asyncPublisher
 }
@@ -78,7 +112,29 @@ type ShipperConfig struct {
 	Ignore_outgoing       bool
 	Topology_expire       int
 	Tags                  []string
-	Geoip                 common.Geoip
+	Geoip                 geoip.Config
+	Topology              TopologyConfig
+	Document_id           bool
+	Shutdown_timeout      string
+}
+
+// Validate checks the shipper settings and returns an error describing all
+// problems or nil if there are none.
+func (c ShipperConfig) Validate() error {
+	if c.Shutdown_timeout == "" {
+		return nil
+	}
+
+	timeout, err := time.ParseDuration(c.Shutdown_timeout)
+	if err != nil {
+		return fmt.Errorf("Invalid shutdown_timeout value '%s' (%v)",
+			c.Shutdown_timeout, err)
+	}
+	if timeout < 0 {
+		return fmt.Errorf("shutdown_timeout must not be negative but was '%s'",
+			c.Shutdown_timeout)
+	}
+	return nil
 }
 
 var Publisher PublisherType
@@ -108,9 +164,36 @@ func (publisher *PublisherType) IsPublisherIP(ip string) bool {
 		}
 	}
 
+	// the topology might map additional addresses to the shipper, like the
+	// public IP of a NAT gateway
+	if publisher.shippers != nil && publisher.name != "" {
+		return publisher.shippers.GetNameByIP(ip) == publisher.name
+	}
+
 	return false
 }
 
+// GetShipperName returns the name of the shipper running on the server with
+// the IP address ip or an empty string if no shipper is known for ip. Unlike
+// GetServerName, names resolved by reverse DNS are not considered.
+func (publisher *PublisherType) GetShipperName(ip string) string {
+	islocal, err := common.IsLoopback(ip)
+	if err != nil {
+		logp.Err("Parsing IP %s fails with: %s", ip, err)
+		return ""
+	}
+
+	if islocal {
+		return publisher.name
+	}
+
+	if publisher.shippers != nil {
+		return publisher.shippers.GetNameByIP(ip)
+	}
+
+	return ""
+}
+
 func (publisher *PublisherType) GetServerName(ip string) string {
 	// in case the IP is localhost, return current shipper name
 	islocal, err := common.IsLoopback(ip)
@@ -124,8 +207,8 @@ func (publisher *PublisherType) GetServerName(ip string) string {
 	}
 
 	// find the shipper with the desired IP
-	if publisher.TopologyOutput != nil {
-		return publisher.TopologyOutput.GetNameByIP(ip)
+	if publisher.topology != nil {
+		return publisher.topology.GetNameByIP(ip)
 	}
 
 	return ""
@@ -172,13 +255,23 @@ func (publisher *PublisherType) Init(
 ) error {
 	var err error
 	publisher.IgnoreOutgoing = shipper.Ignore_outgoing
+	publisher.documentID = shipper.Document_id
+
+	publisher.shutdownTimeout = DefaultShutdownTimeout
+	if shipper.Shutdown_timeout != "" {
+		publisher.shutdownTimeout, err = time.ParseDuration(shipper.Shutdown_timeout)
+		if err != nil {
+			return fmt.Errorf("Invalid shutdown_timeout value '%s' (%v)",
+				shipper.Shutdown_timeout, err)
+		}
+	}
 
 	publisher.disabled = *publishDisabled
 	if publisher.disabled {
 		logp.Info("Dry run mode. All output types except the file based one are disabled.")
 	}
 
-	publisher.GeoLite = common.LoadGeoIPData(shipper.Geoip)
+	publisher.GeoIP = geoip.Load(shipper.Geoip)
 
 	publisher.wsOutput.Init()
 	publisher.wsPublisher.Init()
@@ -191,6 +284,8 @@ func (publisher *PublisherType) Init(
 
 		var outputers []*outputWorker
 		var topoOutput outputs.TopologyOutputer
+		var names []string
+		var routes []*outputs.RouteConfig
 		for _, plugin := range plugins {
 			output := plugin.Output
 			config := plugin.Config
@@ -198,7 +293,9 @@ func (publisher *PublisherType) Init(
 			debug("Create output worker")
 
 			outputers = append(outputers,
-				newOutputWorker(config, output, &publisher.wsOutput, 1000))
+				newOutputWorker(plugin.Name, config, output, &publisher.wsOutput, 1000))
+			names = append(names, plugin.Name)
+			routes = append(routes, config.Route)
 
 			if !config.Save_topology {
 				continue
@@ -221,19 +318,35 @@ func (publisher *PublisherType) Init(
 			logp.Info("Using %s to store the topology", plugin.Name)
 		}
 
+		publisher.router, err = newRouter(names, routes)
+		if err != nil {
+			return err
+		}
+		if publisher.router != nil {
+			publisher.router.logRoutes()
+		}
+
 		Publisher.Output = outputers
 		Publisher.TopologyOutput = topoOutput
+		publisher.outputNames = names
 	}
+	publisher.outputConfigs = configs
+	publisher.shipper = shipper
 
 	if !publisher.disabled {
 		if len(publisher.Output) == 0 {
 			logp.Info("No outputs are defined. Please define one under the output section.")
 			return errors.New("No outputs are defined. Please define one under the output section.")
 		}
+	}
 
-		if publisher.TopologyOutput == nil {
-			logp.Debug("publish", "No output is defined to store the topology. The server fields might not be filled.")
-		}
+	publisher.topology, publisher.shippers, err = initTopology(shipper.Topology,
+		publisher.TopologyOutput)
+	if err != nil {
+		return err
+	}
+	if publisher.topology == nil {
+		logp.Debug("publish", "No topology provider is configured. The server fields might not be filled.")
 	}
 
 	publisher.shipperName = shipper.Name
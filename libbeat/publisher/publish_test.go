@@ -51,10 +51,35 @@ func TestPublisherTypeGetServerName(t *testing.T) {
 	assert.Equal(t, "", pt.GetServerName("172.0.0.1"))
 
 	// Hostname is returned when topology knows the IP.
-	pt.TopologyOutput = testTopology{hostname: hostOnNetwork}
+	pt.topology = testTopology{hostname: hostOnNetwork}
 	assert.Equal(t, hostOnNetwork, pt.GetServerName("172.0.0.1"))
 }
 
+// Test IsPublisherIP.
+func TestPublisherTypeIsPublisherIP(t *testing.T) {
+	pt := &PublisherType{name: shipperName, ipaddrs: []string{"10.0.0.1"}}
+	assert.True(t, pt.IsPublisherIP("10.0.0.1"))
+	assert.False(t, pt.IsPublisherIP("10.0.0.2"))
+
+	// Addresses mapped to the shipper by the topology belong to the shipper.
+	pt.shippers = staticTopology{"192.0.2.1": shipperName, "10.0.0.2": "other"}
+	assert.True(t, pt.IsPublisherIP("192.0.2.1"))
+	assert.False(t, pt.IsPublisherIP("10.0.0.2"))
+}
+
+// Test GetShipperName.
+func TestPublisherTypeGetShipperName(t *testing.T) {
+	pt := &PublisherType{name: shipperName}
+	assert.Equal(t, shipperName, pt.GetShipperName("127.0.0.1"))
+
+	// Servers only known to the topology do not run a shipper.
+	pt.topology = testTopology{hostname: hostOnNetwork}
+	assert.Equal(t, "", pt.GetShipperName("172.0.0.1"))
+
+	pt.shippers = testTopology{hostname: hostOnNetwork}
+	assert.Equal(t, hostOnNetwork, pt.GetShipperName("172.0.0.1"))
+}
+
 // Test the PublisherType Client() method.
 func TestPublisherTypeClient(t *testing.T) {
 	pt := &PublisherType{}
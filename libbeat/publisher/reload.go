@@ -0,0 +1,104 @@
+package publisher
+
+import (
+	"reflect"
+
+	"github.com/elastic/beats/libbeat/logp"
+	"github.com/elastic/beats/libbeat/outputs"
+)
+
+// Reload applies the settings of a reloaded configuration which can be changed
+// while events are being published: the output routes and the shipper tags.
+// Changes of all other settings are logged as requiring a restart. In case of
+// errors the current settings are kept.
+func (publisher *PublisherType) Reload(
+	configs map[string]outputs.MothershipConfig,
+	shipper ShipperConfig,
+) error {
+	var r *router
+	routes := map[string]*outputs.RouteConfig{}
+	if !publisher.disabled {
+		var err error
+		r, routes, err = publisher.reloadRouter(configs)
+		if err != nil {
+			return err
+		}
+	}
+
+	current := publisher.shipper
+	current.Tags = shipper.Tags
+	if !reflect.DeepEqual(current, shipper) {
+		logp.Warn("Changes of the shipper settings other than tags require a restart")
+	}
+
+	publisher.reloadLock.Lock()
+	publisher.router = r
+	publisher.tags = shipper.Tags
+	publisher.reloadLock.Unlock()
+
+	for name, route := range routes {
+		config := publisher.outputConfigs[name]
+		config.Route = route
+		publisher.outputConfigs[name] = config
+	}
+	publisher.shipper.Tags = shipper.Tags
+
+	if r != nil {
+		r.logRoutes()
+	}
+	logp.Info("Publisher settings reloaded")
+	return nil
+}
+
+// reloadRouter creates the router for the new output configurations. Outputs
+// can not be added or removed without restart, such that the routes of
+// removed outputs are kept.
+func (publisher *PublisherType) reloadRouter(
+	configs map[string]outputs.MothershipConfig,
+) (*router, map[string]*outputs.RouteConfig, error) {
+	for name := range configs {
+		if _, exists := publisher.outputConfigs[name]; !exists {
+			logp.Warn("Adding the %s output requires a restart", name)
+		}
+	}
+
+	routes := map[string]*outputs.RouteConfig{}
+	routeList := make([]*outputs.RouteConfig, len(publisher.outputNames))
+	for i, name := range publisher.outputNames {
+		current := publisher.outputConfigs[name]
+		config, exists := configs[name]
+		if !exists {
+			logp.Warn("Removing the %s output requires a restart", name)
+			routeList[i] = current.Route
+			continue
+		}
+
+		routes[name] = config.Route
+		routeList[i] = config.Route
+
+		current.Route = config.Route
+		if !reflect.DeepEqual(current, config) {
+			logp.Warn("Changes of the %s output settings other than route require a restart", name)
+		}
+	}
+
+	r, err := newRouter(publisher.outputNames, routeList)
+	if err != nil {
+		return nil, nil, err
+	}
+	return r, routes, nil
+}
+
+// currentRouter returns the router selecting the outputs of each event.
+func (publisher *PublisherType) currentRouter() *router {
+	publisher.reloadLock.RLock()
+	defer publisher.reloadLock.RUnlock()
+	return publisher.router
+}
+
+// currentTags returns the tags added to each event.
+func (publisher *PublisherType) currentTags() []string {
+	publisher.reloadLock.RLock()
+	defer publisher.reloadLock.RUnlock()
+	return publisher.tags
+}
@@ -0,0 +1,85 @@
+package publisher
+
+import (
+	"testing"
+
+	"github.com/elastic/beats/libbeat/outputs"
+	"github.com/stretchr/testify/assert"
+)
+
+func newReloadPublisher() *PublisherType {
+	return &PublisherType{
+		outputNames: []string{"security", "default"},
+		outputConfigs: map[string]outputs.MothershipConfig{
+			"security": {Host: "localhost"},
+			"default":  {Host: "localhost"},
+		},
+		shipper: ShipperConfig{Name: "test", Tags: []string{"a"}},
+		tags:    []string{"a"},
+	}
+}
+
+func TestReloadRoutesAndTags(t *testing.T) {
+	p := newReloadPublisher()
+
+	err := p.Reload(map[string]outputs.MothershipConfig{
+		"security": {Host: "localhost", Route: &outputs.RouteConfig{
+			When: []outputs.ConditionConfig{
+				{Equals: map[string]interface{}{"type": "security"}},
+			},
+		}},
+		"default": {Host: "localhost", Route: &outputs.RouteConfig{Default: true}},
+	}, ShipperConfig{Name: "test", Tags: []string{"b", "c"}})
+	assert.NoError(t, err)
+
+	r := p.currentRouter()
+	if assert.NotNil(t, r) {
+		assert.Len(t, r.routes, 2)
+		assert.True(t, r.routes[1].isDefault)
+	}
+	assert.Equal(t, []string{"b", "c"}, p.currentTags())
+	assert.NotNil(t, p.outputConfigs["security"].Route)
+
+	// removing all routes publishes all events to all outputs again
+	err = p.Reload(map[string]outputs.MothershipConfig{
+		"security": {Host: "localhost"},
+		"default":  {Host: "localhost"},
+	}, ShipperConfig{Name: "test"})
+	assert.NoError(t, err)
+	assert.Nil(t, p.currentRouter())
+	assert.Nil(t, p.currentTags())
+}
+
+func TestReloadInvalidRouteKeepsSettings(t *testing.T) {
+	p := newReloadPublisher()
+
+	err := p.Reload(map[string]outputs.MothershipConfig{
+		"security": {Route: &outputs.RouteConfig{
+			When: []outputs.ConditionConfig{
+				{Regexp: map[string]string{"type": "("}},
+			},
+		}},
+		"default": {},
+	}, ShipperConfig{Tags: []string{"b"}})
+	assert.Error(t, err)
+	assert.Nil(t, p.currentRouter())
+	assert.Equal(t, []string{"a"}, p.currentTags())
+}
+
+func TestReloadRemovedOutputKeepsRoute(t *testing.T) {
+	p := newReloadPublisher()
+	p.outputConfigs["security"] = outputs.MothershipConfig{
+		Route: &outputs.RouteConfig{Default: true},
+	}
+
+	err := p.Reload(map[string]outputs.MothershipConfig{
+		"default": {Host: "localhost"},
+	}, ShipperConfig{Name: "test", Tags: []string{"a"}})
+	assert.NoError(t, err)
+
+	r := p.currentRouter()
+	if assert.NotNil(t, r) {
+		assert.True(t, r.routes[0].isDefault)
+		assert.Nil(t, r.routes[1])
+	}
+}
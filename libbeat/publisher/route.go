@@ -0,0 +1,329 @@
+package publisher
+
+import (
+	"expvar"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/logp"
+	"github.com/elastic/beats/libbeat/outputs"
+)
+
+// Metrics of the event routing.
+var (
+	routedEvents   = expvar.NewMap("libbeatPublisherRoutedEvents")
+	unroutedEvents = expvar.NewInt("libbeatPublisherUnroutedEvents")
+)
+
+// router selects the outputs events are published to, based on the route
+// configuration of each output.
+type router struct {
+	names  []string
+	routes []*route // one entry per output, nil if output receives all events
+}
+
+// route is the compiled route configuration of one output.
+type route struct {
+	conditions []*condition
+	isDefault  bool
+}
+
+// condition is a compiled ConditionConfig. Fields are given in dotted
+// notation, see common.MapStr.GetValue.
+type condition struct {
+	equals   []fieldValue
+	contains []fieldValue
+	regexp   []fieldRegexp
+	exists   []string
+}
+
+type fieldValue struct {
+	field string
+	value string
+}
+
+type fieldRegexp struct {
+	field string
+	re    *regexp.Regexp
+}
+
+// newRouter compiles the route configurations of all outputs. If no output
+// has a route configured, nil is returned and all events are published to all
+// outputs.
+func newRouter(names []string, configs []*outputs.RouteConfig) (*router, error) {
+	r := &router{names: names, routes: make([]*route, len(configs))}
+	enabled := false
+	for i, config := range configs {
+		if config == nil {
+			continue
+		}
+
+		rt, err := newRoute(config)
+		if err != nil {
+			return nil, fmt.Errorf("invalid route for output %s: %v", names[i], err)
+		}
+		r.routes[i] = rt
+		enabled = true
+	}
+	if !enabled {
+		return nil, nil
+	}
+	return r, nil
+}
+
+func newRoute(config *outputs.RouteConfig) (*route, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	rt := &route{isDefault: config.Default}
+	for _, c := range config.When {
+		if err := c.Validate(); err != nil {
+			return nil, err
+		}
+		cond, err := newCondition(c)
+		if err != nil {
+			return nil, err
+		}
+		rt.conditions = append(rt.conditions, cond)
+	}
+	return rt, nil
+}
+
+// newCondition compiles a condition. The config must have been validated.
+func newCondition(config outputs.ConditionConfig) (*condition, error) {
+	c := &condition{}
+	for field, value := range config.Equals {
+		c.equals = append(c.equals, fieldValue{field, fmt.Sprint(value)})
+	}
+	for field, value := range config.Contains {
+		c.contains = append(c.contains, fieldValue{field, value})
+	}
+	for field, expr := range config.Regexp {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regexp for field %s: %v", field, err)
+		}
+		c.regexp = append(c.regexp, fieldRegexp{field, re})
+	}
+	c.exists = append(c.exists, config.Exists...)
+	return c, nil
+}
+
+// selectOutputs returns the outputs the event is published to.
+func (r *router) selectOutputs(event common.MapStr) []bool {
+	selected := make([]bool, len(r.routes))
+	matched := false
+	for i, rt := range r.routes {
+		if rt == nil {
+			selected[i] = true
+			continue
+		}
+		if rt.matches(event) {
+			selected[i] = true
+			matched = true
+		}
+	}
+
+	if !matched {
+		// publish events not matched by any route to the default outputs
+		for i, rt := range r.routes {
+			if rt != nil && rt.isDefault {
+				selected[i] = true
+			}
+		}
+	}
+	return selected
+}
+
+// forward publishes m to the selected outputs. The message signal is split
+// between all outputs receiving events. Events not selected for any output are
+// dropped and reported as published.
+func (r *router) forward(m message, workers []worker) {
+	if r == nil {
+		forwardAll(m, workers)
+		return
+	}
+
+	msgs := make([]message, len(workers))
+	count := 0
+	if m.event != nil {
+		selected := r.selectOutputs(m.event)
+		for i, ok := range selected {
+			if ok {
+				msgs[i] = message{context: m.context, event: m.event}
+				r.onRouted(i, 1)
+				count++
+			}
+		}
+		if count == 0 {
+			r.onUnrouted(1)
+		}
+	} else {
+		perOutput := make([][]common.MapStr, len(workers))
+		unrouted := 0
+		for _, event := range m.events {
+			selected := r.selectOutputs(event)
+			routed := false
+			for i, ok := range selected {
+				if ok {
+					perOutput[i] = append(perOutput[i], event)
+					routed = true
+				}
+			}
+			if !routed {
+				unrouted++
+			}
+		}
+		if unrouted > 0 {
+			r.onUnrouted(unrouted)
+		}
+
+		for i, events := range perOutput {
+			if len(events) > 0 {
+				msgs[i] = message{context: m.context, events: events}
+				r.onRouted(i, len(events))
+				count++
+			}
+		}
+	}
+
+	if count == 0 {
+		outputs.SignalCompleted(m.context.signal)
+		return
+	}
+
+	signal := m.context.signal
+	if count > 1 {
+		signal = outputs.NewSplitSignaler(signal, count)
+	}
+	for i, msg := range msgs {
+		if msg.event == nil && msg.events == nil {
+			continue
+		}
+		msg.context.signal = signal
+		workers[i].send(msg)
+	}
+}
+
+func (r *router) onRouted(output, count int) {
+	routedEvents.Add(r.names[output], int64(count))
+}
+
+func (r *router) onUnrouted(count int) {
+	debug("drop %v events not matching any output route", count)
+	unroutedEvents.Add(int64(count))
+}
+
+// forwardAll publishes m to all outputs.
+func forwardAll(m message, workers []worker) {
+	if len(workers) > 1 {
+		m.context.signal = outputs.NewSplitSignaler(m.context.signal, len(workers))
+	}
+	for _, w := range workers {
+		w.send(m)
+	}
+}
+
+func (rt *route) matches(event common.MapStr) bool {
+	for _, c := range rt.conditions {
+		if c.matches(event) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *condition) matches(event common.MapStr) bool {
+	for _, f := range c.equals {
+		value := lookupField(event, f.field)
+		if value == nil || !isPrimitive(value) || fmt.Sprint(value) != f.value {
+			return false
+		}
+	}
+
+	for _, f := range c.contains {
+		switch value := lookupField(event, f.field).(type) {
+		case string:
+			if !strings.Contains(value, f.value) {
+				return false
+			}
+		case []string:
+			if !containsString(value, f.value) {
+				return false
+			}
+		case []interface{}:
+			found := false
+			for _, elem := range value {
+				if isPrimitive(elem) && fmt.Sprint(elem) == f.value {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+
+	for _, f := range c.regexp {
+		value, ok := lookupField(event, f.field).(string)
+		if !ok || !f.re.MatchString(value) {
+			return false
+		}
+	}
+
+	for _, field := range c.exists {
+		if lookupField(event, field) == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// lookupField returns the value of a nested field or nil if the field is
+// missing.
+func lookupField(event common.MapStr, field string) interface{} {
+	value, err := event.GetValue(field)
+	if err != nil {
+		return nil
+	}
+	return value
+}
+
+func isPrimitive(value interface{}) bool {
+	switch value.(type) {
+	case common.MapStr, map[string]interface{}, []interface{}, []string:
+		return false
+	}
+	return true
+}
+
+func containsString(list []string, s string) bool {
+	for _, elem := range list {
+		if elem == s {
+			return true
+		}
+	}
+	return false
+}
+
+// logRoutes prints the route configuration of all outputs.
+func (r *router) logRoutes() {
+	for i, rt := range r.routes {
+		switch {
+		case rt == nil:
+			logp.Info("Output %s receives all events", r.names[i])
+		case rt.isDefault:
+			logp.Info("Output %s receives events matching %v conditions and all unrouted events",
+				r.names[i], len(rt.conditions))
+		default:
+			logp.Info("Output %s receives events matching %v conditions",
+				r.names[i], len(rt.conditions))
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package publisher
+
+import (
+	"testing"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/outputs"
+	"github.com/stretchr/testify/assert"
+)
+
+func routeEvent(typ string, tags ...interface{}) common.MapStr {
+	event := testEvent()
+	event["type"] = typ
+	event["fields"] = common.MapStr{"env": "prod", "level": "debug"}
+	if len(tags) > 0 {
+		event["tags"] = tags
+	}
+	return event
+}
+
+func TestConditionMatches(t *testing.T) {
+	tests := []struct {
+		config  outputs.ConditionConfig
+		event   common.MapStr
+		matches bool
+	}{
+		{
+			outputs.ConditionConfig{Equals: map[string]interface{}{"type": "security"}},
+			routeEvent("security"),
+			true,
+		},
+		{
+			outputs.ConditionConfig{Equals: map[string]interface{}{"type": "security"}},
+			routeEvent("log"),
+			false,
+		},
+		{
+			outputs.ConditionConfig{Equals: map[string]interface{}{
+				"type":         "log",
+				"fields.level": "debug",
+			}},
+			routeEvent("log"),
+			true,
+		},
+		{
+			outputs.ConditionConfig{Equals: map[string]interface{}{
+				"type":       "log",
+				"fields.env": "dev",
+			}},
+			routeEvent("log"),
+			false,
+		},
+		{
+			outputs.ConditionConfig{Equals: map[string]interface{}{"count": 1}},
+			common.MapStr{"count": 1},
+			true,
+		},
+		{
+			outputs.ConditionConfig{Contains: map[string]string{"tags": "security"}},
+			routeEvent("log", "web", "security"),
+			true,
+		},
+		{
+			outputs.ConditionConfig{Contains: map[string]string{"tags": "security"}},
+			routeEvent("log", "web"),
+			false,
+		},
+		{
+			outputs.ConditionConfig{Contains: map[string]string{"tags": "security"}},
+			common.MapStr{"tags": []string{"security"}},
+			true,
+		},
+		{
+			outputs.ConditionConfig{Contains: map[string]string{"message": "denied"}},
+			common.MapStr{"message": "access denied for user"},
+			true,
+		},
+		{
+			outputs.ConditionConfig{Regexp: map[string]string{"message": "^ERR"}},
+			common.MapStr{"message": "ERROR: failed"},
+			true,
+		},
+		{
+			outputs.ConditionConfig{Regexp: map[string]string{"message": "^ERR"}},
+			common.MapStr{"message": "INFO: ERROR"},
+			false,
+		},
+		{
+			outputs.ConditionConfig{Exists: []string{"fields.env"}},
+			routeEvent("log"),
+			true,
+		},
+		{
+			outputs.ConditionConfig{Exists: []string{"fields.missing"}},
+			routeEvent("log"),
+			false,
+		},
+		{
+			outputs.ConditionConfig{Equals: map[string]interface{}{"tags.0": "security"}},
+			routeEvent("log", "web", "security"),
+			false,
+		},
+		{
+			outputs.ConditionConfig{Equals: map[string]interface{}{"tags.1": "security"}},
+			routeEvent("log", "web", "security"),
+			true,
+		},
+		{
+			outputs.ConditionConfig{Exists: []string{"tags.2"}},
+			routeEvent("log", "web", "security"),
+			false,
+		},
+	}
+
+	for i, test := range tests {
+		c, err := newCondition(test.config)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		assert.Equal(t, test.matches, c.matches(test.event), "test %v", i)
+	}
+}
+
+func TestNewRouterErrors(t *testing.T) {
+	configs := []*outputs.RouteConfig{
+		{},
+		{When: []outputs.ConditionConfig{{}}},
+		{When: []outputs.ConditionConfig{{Regexp: map[string]string{"type": "("}}}},
+	}
+	for _, config := range configs {
+		_, err := newRouter([]string{"test"}, []*outputs.RouteConfig{config})
+		assert.Error(t, err)
+	}
+}
+
+func TestNewRouterDisabled(t *testing.T) {
+	r, err := newRouter([]string{"a", "b"}, []*outputs.RouteConfig{nil, nil})
+	assert.NoError(t, err)
+	assert.Nil(t, r)
+}
+
+func newTestRouter(t *testing.T) (*router, []*testMessageHandler, []worker) {
+	r, err := newRouter(
+		[]string{"security", "debug", "default"},
+		[]*outputs.RouteConfig{
+			{When: []outputs.ConditionConfig{
+				{Equals: map[string]interface{}{"type": "security"}},
+				{Contains: map[string]string{"tags": "security"}},
+			}},
+			{When: []outputs.ConditionConfig{
+				{Equals: map[string]interface{}{"fields.level": "debug"}},
+			}},
+			{Default: true},
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var handlers []*testMessageHandler
+	var workers []worker
+	for i := 0; i < 3; i++ {
+		mh := &testMessageHandler{
+			msgs:     make(chan message, 10),
+			response: CompletedResponse,
+		}
+		handlers = append(handlers, mh)
+		workers = append(workers, mh)
+	}
+	return r, handlers, workers
+}
+
+func TestRouterForwardEvent(t *testing.T) {
+	r, handlers, workers := newTestRouter(t)
+
+	s := newTestSignaler()
+	r.forward(testMessage(s, routeEvent("security")), workers)
+	assert.True(t, s.wait())
+
+	assert.Len(t, handlers[0].msgs, 1)
+	assert.Len(t, handlers[1].msgs, 1) // fields.level == debug
+	assert.Len(t, handlers[2].msgs, 0)
+}
+
+func TestRouterForwardDefault(t *testing.T) {
+	r, handlers, workers := newTestRouter(t)
+
+	event := testEvent()
+	s := newTestSignaler()
+	r.forward(testMessage(s, event), workers)
+	assert.True(t, s.wait())
+
+	assert.Len(t, handlers[0].msgs, 0)
+	assert.Len(t, handlers[1].msgs, 0)
+	assert.Len(t, handlers[2].msgs, 1)
+}
+
+func TestRouterForwardBulk(t *testing.T) {
+	r, handlers, workers := newTestRouter(t)
+
+	security := testEvent()
+	security["tags"] = []interface{}{"security"}
+	other := testEvent()
+
+	s := newTestSignaler()
+	r.forward(testBulkMessage(s, []common.MapStr{security, other, other}), workers)
+	assert.True(t, s.wait())
+
+	msgs, err := handlers[0].waitForMessages(1)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []common.MapStr{security}, msgs[0].events)
+	}
+	assert.Len(t, handlers[1].msgs, 0)
+	msgs, err = handlers[2].waitForMessages(1)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []common.MapStr{other, other}, msgs[0].events)
+	}
+}
+
+func TestRouterUnrouted(t *testing.T) {
+	r, err := newRouter([]string{"security"}, []*outputs.RouteConfig{
+		{When: []outputs.ConditionConfig{
+			{Equals: map[string]interface{}{"type": "security"}},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mh := &testMessageHandler{msgs: make(chan message, 10), response: FailedResponse}
+
+	before := unroutedEvents.Value()
+	s := newTestSignaler()
+	r.forward(testBulkMessage(s, []common.MapStr{testEvent(), testEvent()}), []worker{mh})
+
+	// unrouted events are dropped and reported as published
+	assert.True(t, s.wait())
+	assert.Len(t, mh.msgs, 0)
+	assert.Equal(t, before+2, unroutedEvents.Value())
+}
+
+func TestRouterNil(t *testing.T) {
+	var r *router
+	mh1 := &testMessageHandler{msgs: make(chan message, 10), response: CompletedResponse}
+	mh2 := &testMessageHandler{msgs: make(chan message, 10), response: CompletedResponse}
+
+	s := newTestSignaler()
+	r.forward(testMessage(s, testEvent()), []worker{mh1, mh2})
+	assert.True(t, s.wait())
+	assert.Len(t, mh1.msgs, 1)
+	assert.Len(t, mh2.msgs, 1)
+}
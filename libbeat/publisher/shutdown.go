@@ -0,0 +1,110 @@
+package publisher
+
+import (
+	"time"
+
+	"github.com/elastic/beats/libbeat/logp"
+	"github.com/elastic/beats/libbeat/outputs"
+)
+
+// DefaultShutdownTimeout is the time waited on shutdown for queued events to
+// be published if shipper.shutdown_timeout is not set.
+const DefaultShutdownTimeout = 5 * time.Second
+
+// interval checking if all events have been published on shutdown
+const drainCheckInterval = 50 * time.Millisecond
+
+// Shutdown starts the shutdown timeout. It is called before the beat stops
+// its producers. Once the timeout elapses the publisher is stopped, even if
+// events are still waiting to be published, which unblocks clients waiting
+// for events to be acknowledged.
+func (publisher *PublisherType) Shutdown() {
+	publisher.shutdownOnce.Do(func() {
+		publisher.deadline = time.Now().Add(publisher.shutdownTimeout)
+		time.AfterFunc(publisher.shutdownTimeout, publisher.Stop)
+	})
+}
+
+// Stop waits until all queued events have been published and acknowledged
+// by the outputs, at most until the shutdown timeout elapses. Afterwards the
+// workers are stopped and the outputs are closed. Events not published by
+// then are signaled as failed. The beat producers must be stopped before.
+func (publisher *PublisherType) Stop() {
+	publisher.Shutdown()
+	publisher.stopOnce.Do(publisher.stop)
+}
+
+func (publisher *PublisherType) stop() {
+	if publisher.asyncPublisher == nil {
+		// not initialized
+		return
+	}
+
+	logp.Info("Stopping publisher, waiting up to %v for events to be published",
+		publisher.deadline.Sub(time.Now()))
+
+	abandoned := publisher.drain(publisher.deadline)
+	if abandoned > 0 {
+		logp.Warn("Shutdown timeout of %v elapsed, %d events are abandoned",
+			publisher.shutdownTimeout, abandoned)
+	} else {
+		logp.Info("All events published")
+	}
+
+	// Signal all workers to stop first, so no worker blocks forwarding events
+	// to the next one. Closing the outputs fails all events still in flight,
+	// unblocking the output workers.
+	publisher.wsPublisher.stopAsync()
+	publisher.wsOutput.stopAsync()
+	for _, o := range publisher.Output {
+		if err := outputs.Close(o.out); err != nil {
+			logp.Err("Failed to close output %s: %v", o.stats.name, err)
+		}
+	}
+
+	publisher.wsPublisher.wg.Wait()
+	publisher.wsOutput.wg.Wait()
+	logp.Info("Publisher stopped")
+}
+
+// drain waits until no events are pending or the deadline is reached. It
+// returns the number of events still pending.
+func (publisher *PublisherType) drain(deadline time.Time) int64 {
+	for {
+		pending := publisher.pending()
+		if pending == 0 {
+			return 0
+		}
+
+		wait := deadline.Sub(time.Now())
+		if wait <= 0 {
+			return pending
+		}
+		if wait > drainCheckInterval {
+			wait = drainCheckInterval
+		}
+		time.Sleep(wait)
+	}
+}
+
+// pending returns the number of events queued by the publisher or passed to
+// an output and not acknowledged yet. Events being handed to an output might
+// be counted twice.
+func (publisher *PublisherType) pending() int64 {
+	var n int64
+	if p := publisher.syncPublisher; p != nil {
+		n += p.pendingEvents()
+	}
+	if p := publisher.asyncPublisher; p != nil {
+		n += p.pendingEvents()
+		for _, w := range p.outputs {
+			if b, ok := w.(*bulkWorker); ok {
+				n += b.pendingEvents()
+			}
+		}
+	}
+	for _, o := range publisher.Output {
+		n += o.pendingEvents() + o.stats.inFlight()
+	}
+	return n
+}
@@ -0,0 +1,190 @@
+package publisher
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/outputs"
+	"github.com/stretchr/testify/assert"
+)
+
+// holdingOutputer keeps the signals of all published events until they are
+// released or the output is closed.
+type holdingOutputer struct {
+	mutex   sync.Mutex
+	signals []outputs.Signaler
+	closed  bool
+}
+
+var _ outputs.Closer = &holdingOutputer{}
+
+func (o *holdingOutputer) PublishEvent(
+	signal outputs.Signaler,
+	ts time.Time,
+	event common.MapStr,
+) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if o.closed {
+		outputs.SignalFailed(signal, nil)
+		return nil
+	}
+	o.signals = append(o.signals, signal)
+	return nil
+}
+
+func (o *holdingOutputer) Close() error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	o.closed = true
+	for _, signal := range o.signals {
+		outputs.SignalFailed(signal, nil)
+	}
+	o.signals = nil
+	return nil
+}
+
+// release signals all events received so far as completed and returns their
+// number.
+func (o *holdingOutputer) release() int {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	n := len(o.signals)
+	for _, signal := range o.signals {
+		outputs.SignalCompleted(signal)
+	}
+	o.signals = nil
+	return n
+}
+
+func (o *holdingOutputer) isClosed() bool {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.closed
+}
+
+func newShutdownTestPublisher(out outputs.Outputer, timeout time.Duration) *PublisherType {
+	bulkSize := -1 // disable batching
+	config := outputs.MothershipConfig{BulkMaxSize: &bulkSize}
+
+	pub := &PublisherType{shutdownTimeout: timeout}
+	pub.wsOutput.Init()
+	pub.wsPublisher.Init()
+	pub.Output = []*outputWorker{
+		newOutputWorker("shutdownTest", config, out, &pub.wsOutput, 10),
+	}
+	pub.syncPublisher = newSyncPublisher(pub)
+	pub.asyncPublisher = newAsyncPublisher(pub)
+	return pub
+}
+
+// stopAsync calls Stop in the background and returns a channel closed once
+// Stop returns.
+func stopAsync(pub *PublisherType) chan struct{} {
+	stopped := make(chan struct{})
+	go func() {
+		pub.Stop()
+		close(stopped)
+	}()
+	return stopped
+}
+
+func TestStopWaitsForPendingEvents(t *testing.T) {
+	out := &holdingOutputer{}
+	pub := newShutdownTestPublisher(out, 10*time.Second)
+
+	client := pub.Client()
+	assert.True(t, client.PublishEvents([]common.MapStr{testEvent(), testEvent()}))
+
+	stopped := stopAsync(pub)
+
+	// wait for the events to reach the output
+	for i := 0; i < 100 && pub.pending() != 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case <-stopped:
+		t.Fatal("publisher stopped with pending events")
+	case <-time.After(100 * time.Millisecond):
+	}
+	assert.False(t, out.isClosed())
+
+	assert.Equal(t, 2, out.release())
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("publisher not stopped")
+	}
+	assert.True(t, out.isClosed())
+	assert.Equal(t, int64(0), pub.pending())
+}
+
+func TestStopTimeout(t *testing.T) {
+	out := &holdingOutputer{}
+	pub := newShutdownTestPublisher(out, 100*time.Millisecond)
+
+	published := make(chan bool)
+	go func() {
+		published <- pub.Client().PublishEvent(testEvent(), Sync)
+	}()
+	for i := 0; i < 100 && pub.pending() == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	start := time.Now()
+	stopped := stopAsync(pub)
+	select {
+	case ok := <-published:
+		assert.False(t, ok)
+	case <-time.After(5 * time.Second):
+		t.Fatal("sync client not unblocked by stop")
+	}
+
+	<-stopped
+	assert.True(t, time.Since(start) >= 100*time.Millisecond)
+	assert.True(t, out.isClosed())
+}
+
+func TestShutdownStopsAfterTimeout(t *testing.T) {
+	out := &holdingOutputer{}
+	pub := newShutdownTestPublisher(out, 50*time.Millisecond)
+
+	published := make(chan bool)
+	go func() {
+		published <- pub.Client().PublishEvent(testEvent(), Sync)
+	}()
+
+	// Shutdown does not block, but stops the publisher in the background
+	pub.Shutdown()
+	select {
+	case ok := <-published:
+		assert.False(t, ok)
+	case <-time.After(5 * time.Second):
+		t.Fatal("sync client not unblocked after shutdown timeout")
+	}
+}
+
+func TestPublishAfterStop(t *testing.T) {
+	out := &holdingOutputer{}
+	pub := newShutdownTestPublisher(out, 0)
+	pub.Stop()
+
+	client := pub.Client()
+	assert.False(t, client.PublishEvent(testEvent(), Sync))
+	assert.False(t, client.PublishEvents([]common.MapStr{testEvent()}, Confirm))
+
+	// async events are dropped
+	assert.True(t, client.PublishEvent(testEvent()))
+}
+
+func TestShipperConfigValidate(t *testing.T) {
+	assert.NoError(t, ShipperConfig{}.Validate())
+	assert.NoError(t, ShipperConfig{Shutdown_timeout: "30s"}.Validate())
+	assert.Error(t, ShipperConfig{Shutdown_timeout: "30"}.Validate())
+	assert.Error(t, ShipperConfig{Shutdown_timeout: "-1s"}.Validate())
+}
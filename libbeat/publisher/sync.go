@@ -25,11 +25,11 @@ func (p *syncPublisher) client() eventPublisher {
 func (p *syncPublisher) onStop() {}
 
 func (p *syncPublisher) onMessage(m message) {
-	signal := outputs.NewSplitSignaler(m.context.signal, len(p.pub.Output))
-	m.context.signal = signal
-	for _, o := range p.pub.Output {
-		o.send(m)
+	workers := make([]worker, len(p.pub.Output))
+	for i, o := range p.pub.Output {
+		workers[i] = o
 	}
+	p.pub.currentRouter().forward(m, workers)
 }
 
 func (c syncClient) PublishEvent(ctx *context, event common.MapStr) bool {
@@ -0,0 +1,297 @@
+package publisher
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/logp"
+	"github.com/elastic/beats/libbeat/outputs"
+	"github.com/joeshaw/multierror"
+	"gopkg.in/yaml.v2"
+)
+
+// TopologyProvider resolves the IP addresses of servers to their names, used
+// to fill in the client_server and server fields of transactions. The outputs
+// storing the topology map (save_topology) are providers as well.
+type TopologyProvider interface {
+	// GetNameByIP returns the name of the server with the IP address ip or
+	// an empty string if ip is unknown.
+	GetNameByIP(ip string) string
+}
+
+// DefaultDNSCacheTTL is the time names resolved by reverse DNS are cached if
+// shipper.topology.dns.cache_ttl is not set.
+const DefaultDNSCacheTTL = time.Hour
+
+// maximum number of reverse DNS lookups running in the background
+const maxPendingDNSLookups = 20
+
+// TopologyConfig configures the topology providers consulted in addition to
+// the output storing the topology map. The static map is consulted first,
+// followed by the output and reverse DNS.
+type TopologyConfig struct {
+	Static StaticTopologyConfig
+	DNS    DNSTopologyConfig `yaml:"dns"`
+}
+
+// StaticTopologyConfig configures a fixed map of IP addresses to names.
+type StaticTopologyConfig struct {
+	Hosts map[string]string // Server names by IP address.
+	Path  string            // YAML or CSV (.csv) file mapping IP addresses to names.
+}
+
+// DNSTopologyConfig configures resolving names by reverse DNS lookups.
+type DNSTopologyConfig struct {
+	Enabled  bool
+	CacheTTL string `yaml:"cache_ttl"` // Time resolved names are cached.
+}
+
+// Validate checks the static topology map and returns an error describing
+// all problems or nil if there are none.
+func (c StaticTopologyConfig) Validate() error {
+	return validateTopologyMap(c.Hosts)
+}
+
+// Validate checks the DNS settings and returns an error describing any
+// problems or nil.
+func (c DNSTopologyConfig) Validate() error {
+	_, err := c.cacheTTL()
+	return err
+}
+
+func (c DNSTopologyConfig) cacheTTL() (time.Duration, error) {
+	if c.CacheTTL == "" {
+		return DefaultDNSCacheTTL, nil
+	}
+
+	ttl, err := time.ParseDuration(c.CacheTTL)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid cache_ttl value '%s' (%v)", c.CacheTTL, err)
+	}
+	if ttl <= 0 {
+		return 0, fmt.Errorf("cache_ttl must be positive but was '%s'", c.CacheTTL)
+	}
+	return ttl, nil
+}
+
+// validateTopologyMap checks that all keys are IP addresses mapped to
+// non-empty names.
+func validateTopologyMap(hosts map[string]string) error {
+	var errs multierror.Errors
+	for ip, name := range hosts {
+		if net.ParseIP(ip) == nil {
+			errs = append(errs, fmt.Errorf("Invalid IP address '%s' in topology map", ip))
+		}
+		if name == "" {
+			errs = append(errs, fmt.Errorf("Missing name of IP address '%s' in topology map", ip))
+		}
+	}
+	return errs.Err()
+}
+
+// initTopology creates the topology providers consulting the configured
+// providers and the output storing the topology, which might be nil. The
+// names are resolved by all providers. The shippers are only resolved by the
+// static map and the output, as any server might have a name in DNS. nil is
+// returned for providers not configured.
+func initTopology(
+	config TopologyConfig,
+	output outputs.TopologyOutputer,
+) (names TopologyProvider, shippers TopologyProvider, err error) {
+	var providers topologyProviders
+
+	static, err := newStaticTopology(config.Static)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(static) > 0 {
+		logp.Info("Topology map contains %d static entries", len(static))
+		providers = append(providers, static)
+	}
+
+	if output != nil {
+		providers = append(providers, output)
+	}
+	if len(providers) > 0 {
+		shippers = providers
+	}
+
+	if config.DNS.Enabled {
+		ttl, err := config.DNS.cacheTTL()
+		if err != nil {
+			return nil, nil, err
+		}
+		logp.Info("Resolving server names by reverse DNS, cached for %v", ttl)
+		providers = append(providers, newDNSTopology(ttl, net.LookupAddr))
+	}
+
+	if len(providers) == 0 {
+		return nil, nil, nil
+	}
+	return providers, shippers, nil
+}
+
+// topologyProviders consults all providers in order, returning the first name
+// found.
+type topologyProviders []TopologyProvider
+
+func (providers topologyProviders) GetNameByIP(ip string) string {
+	for _, provider := range providers {
+		if name := provider.GetNameByIP(ip); name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// staticTopology maps IP addresses to names as configured.
+type staticTopology map[string]string
+
+// newStaticTopology merges the configured hosts with the entries read from
+// the configured file. Configured hosts take precedence.
+func newStaticTopology(config StaticTopologyConfig) (staticTopology, error) {
+	topology := staticTopology{}
+	if config.Path != "" {
+		hosts, err := readTopologyFile(config.Path)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read topology map %s: %v",
+				config.Path, err)
+		}
+		for ip, name := range hosts {
+			topology[ip] = name
+		}
+	}
+
+	for ip, name := range config.Hosts {
+		topology[ip] = name
+	}
+	return topology, nil
+}
+
+func (t staticTopology) GetNameByIP(ip string) string {
+	return t[ip]
+}
+
+// readTopologyFile reads a map of IP addresses to names from a CSV file, with
+// one IP address and name per line, or from a YAML file.
+func readTopologyFile(path string) (map[string]string, error) {
+	if strings.ToLower(filepath.Ext(path)) != ".csv" {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		hosts := map[string]string{}
+		if err := yaml.Unmarshal(content, &hosts); err != nil {
+			return nil, err
+		}
+		return hosts, validateTopologyMap(hosts)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = 2
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := map[string]string{}
+	for _, record := range records {
+		hosts[strings.TrimSpace(record[0])] = strings.TrimSpace(record[1])
+	}
+	return hosts, validateTopologyMap(hosts)
+}
+
+// dnsTopology resolves names by reverse DNS lookups. Lookups are run in the
+// background so publishing is not blocked: the name of an IP address is
+// returned once it has been resolved. Names are refreshed after the cache TTL.
+type dnsTopology struct {
+	ttl        time.Duration
+	cache      *common.Cache
+	lookupAddr func(addr string) ([]string, error)
+
+	pendingLock sync.Mutex
+	pending     map[string]bool // IP addresses being resolved
+}
+
+// resolved name of an IP address, empty if no name was found
+type dnsEntry struct {
+	name    string
+	expires time.Time
+}
+
+func newDNSTopology(
+	ttl time.Duration,
+	lookupAddr func(addr string) ([]string, error),
+) *dnsTopology {
+	// Names of addresses still in use are returned while being refreshed
+	// after the TTL. Addresses not seen for twice the TTL are removed.
+	t := &dnsTopology{
+		ttl:        ttl,
+		cache:      common.NewCache(2*ttl, 100),
+		lookupAddr: lookupAddr,
+		pending:    map[string]bool{},
+	}
+	t.cache.StartJanitor(ttl)
+	return t
+}
+
+func (t *dnsTopology) GetNameByIP(ip string) string {
+	value := t.cache.Get(ip)
+	if value == nil {
+		t.resolveAsync(ip)
+		return ""
+	}
+
+	entry := value.(dnsEntry)
+	if time.Now().After(entry.expires) {
+		t.resolveAsync(ip)
+	}
+	return entry.name
+}
+
+// resolveAsync starts resolving the name of ip in the background, unless ip
+// is being resolved already or too many lookups are pending.
+func (t *dnsTopology) resolveAsync(ip string) {
+	t.pendingLock.Lock()
+	defer t.pendingLock.Unlock()
+
+	if t.pending[ip] || len(t.pending) >= maxPendingDNSLookups {
+		return
+	}
+	t.pending[ip] = true
+
+	go func() {
+		name := t.resolve(ip)
+		t.cache.Put(ip, dnsEntry{name: name, expires: time.Now().Add(t.ttl)})
+
+		t.pendingLock.Lock()
+		delete(t.pending, ip)
+		t.pendingLock.Unlock()
+	}()
+}
+
+func (t *dnsTopology) resolve(ip string) string {
+	names, err := t.lookupAddr(ip)
+	if err != nil || len(names) == 0 {
+		debug("No name found for %s by reverse DNS: %v", ip, err)
+		return ""
+	}
+	return strings.TrimSuffix(names[0], ".")
+}
@@ -0,0 +1,189 @@
+package publisher
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTopologyFile(t *testing.T, name, content string) (string, func()) {
+	dir, err := ioutil.TempDir("", "topology")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func TestStaticTopologyFiles(t *testing.T) {
+	var tests = []struct {
+		name    string
+		content string
+	}{
+		{"topology.yml", "10.0.0.1: web1\n192.0.2.1: db1\n"},
+		{"topology.csv", "# ip, name\n10.0.0.1, web1\n192.0.2.1,db1\n"},
+	}
+
+	for _, test := range tests {
+		path, cleanup := writeTopologyFile(t, test.name, test.content)
+		defer cleanup()
+
+		topology, err := newStaticTopology(StaticTopologyConfig{
+			Hosts: map[string]string{"192.0.2.1": "db2"},
+			Path:  path,
+		})
+		if !assert.NoError(t, err, test.name) {
+			continue
+		}
+		assert.Equal(t, "web1", topology.GetNameByIP("10.0.0.1"), test.name)
+		// configured hosts take precedence over the file
+		assert.Equal(t, "db2", topology.GetNameByIP("192.0.2.1"), test.name)
+		assert.Equal(t, "", topology.GetNameByIP("10.0.0.3"), test.name)
+	}
+}
+
+func TestStaticTopologyInvalidFile(t *testing.T) {
+	var tests = []struct {
+		name    string
+		content string
+	}{
+		{"topology.yml", "not an ip: web1\n"},
+		{"topology.yml", "- web1\n"},
+		{"topology.csv", "10.0.0.1\n"},
+		{"topology.csv", "10.0.0.1,\n"},
+	}
+
+	for _, test := range tests {
+		path, cleanup := writeTopologyFile(t, test.name, test.content)
+		defer cleanup()
+
+		_, err := newStaticTopology(StaticTopologyConfig{Path: path})
+		assert.Error(t, err, "%s: %q", test.name, test.content)
+	}
+
+	_, err := newStaticTopology(StaticTopologyConfig{Path: "/does/not/exist.yml"})
+	assert.Error(t, err)
+}
+
+func TestTopologyProvidersOrder(t *testing.T) {
+	output := testTopology{hostname: "fromOutput"}
+	topology, shippers, err := initTopology(TopologyConfig{
+		Static: StaticTopologyConfig{Hosts: map[string]string{"10.0.0.1": "web1"}},
+		DNS:    DNSTopologyConfig{Enabled: true},
+	}, output)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "web1", topology.GetNameByIP("10.0.0.1"))
+	assert.Equal(t, "fromOutput", topology.GetNameByIP("10.0.0.2"))
+
+	// shippers are not resolved by reverse DNS
+	assert.Len(t, topology, 3)
+	if assert.Len(t, shippers, 2) {
+		assert.Equal(t, "web1", shippers.GetNameByIP("10.0.0.1"))
+		assert.Equal(t, "fromOutput", shippers.GetNameByIP("10.0.0.2"))
+	}
+
+	topology, shippers, err = initTopology(TopologyConfig{}, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, topology)
+	assert.Nil(t, shippers)
+}
+
+// testResolver counts the lookups done and resolves addresses by names.
+type testResolver struct {
+	sync.Mutex
+	names   map[string]string
+	lookups int
+}
+
+func (r *testResolver) lookupAddr(addr string) ([]string, error) {
+	r.Lock()
+	defer r.Unlock()
+	r.lookups++
+	if name, ok := r.names[addr]; ok {
+		return []string{name + "."}, nil
+	}
+	return nil, errors.New("not found")
+}
+
+func (r *testResolver) count() int {
+	r.Lock()
+	defer r.Unlock()
+	return r.lookups
+}
+
+// waitForName polls the topology until ip is resolved.
+func waitForName(topology TopologyProvider, ip string) string {
+	for i := 0; i < 100; i++ {
+		if name := topology.GetNameByIP(ip); name != "" {
+			return name
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return ""
+}
+
+func TestDNSTopology(t *testing.T) {
+	resolver := &testResolver{names: map[string]string{"10.0.0.1": "web1.example.com"}}
+	topology := newDNSTopology(time.Hour, resolver.lookupAddr)
+
+	// names are resolved in the background, the trailing dot is removed
+	assert.Equal(t, "web1.example.com", waitForName(topology, "10.0.0.1"))
+	lookups := resolver.count()
+	assert.Equal(t, "web1.example.com", topology.GetNameByIP("10.0.0.1"))
+	assert.Equal(t, lookups, resolver.count(), "cached name not used")
+
+	// addresses without name are cached as well
+	topology.GetNameByIP("10.0.0.2")
+	for i := 0; i < 100 && topology.cache.Get("10.0.0.2") == nil; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	lookups = resolver.count()
+	assert.Equal(t, "", topology.GetNameByIP("10.0.0.2"))
+	assert.Equal(t, lookups, resolver.count(), "failed lookup not cached")
+}
+
+func TestDNSTopologyRefresh(t *testing.T) {
+	resolver := &testResolver{names: map[string]string{"10.0.0.1": "web1"}}
+	topology := newDNSTopology(50*time.Millisecond, resolver.lookupAddr)
+
+	assert.Equal(t, "web1", waitForName(topology, "10.0.0.1"))
+
+	resolver.Lock()
+	resolver.names["10.0.0.1"] = "web2"
+	resolver.Unlock()
+	time.Sleep(60 * time.Millisecond)
+
+	// the expired name is returned until the new name has been resolved
+	for i := 0; i < 100 && topology.GetNameByIP("10.0.0.1") != "web2"; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, "web2", topology.GetNameByIP("10.0.0.1"))
+}
+
+func TestTopologyConfigValidate(t *testing.T) {
+	assert.NoError(t, StaticTopologyConfig{}.Validate())
+	assert.NoError(t, StaticTopologyConfig{
+		Hosts: map[string]string{"10.0.0.1": "web1", "2001:db8::1": "web2"},
+	}.Validate())
+	assert.Error(t, StaticTopologyConfig{
+		Hosts: map[string]string{"web1": "10.0.0.1"},
+	}.Validate())
+	assert.Error(t, StaticTopologyConfig{
+		Hosts: map[string]string{"10.0.0.1": ""},
+	}.Validate())
+
+	assert.NoError(t, DNSTopologyConfig{}.Validate())
+	assert.NoError(t, DNSTopologyConfig{Enabled: true, CacheTTL: "10m"}.Validate())
+	assert.Error(t, DNSTopologyConfig{CacheTTL: "10"}.Validate())
+	assert.Error(t, DNSTopologyConfig{CacheTTL: "0s"}.Validate())
+}
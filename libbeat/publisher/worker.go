@@ -2,6 +2,7 @@ package publisher
 
 import (
 	"sync"
+	"sync/atomic"
 
 	"github.com/elastic/beats/libbeat/common"
 	"github.com/elastic/beats/libbeat/outputs"
@@ -12,9 +13,14 @@ type worker interface {
 }
 
 type messageWorker struct {
+	queued  int64 // number of events sent to the worker and not handled yet
 	queue   chan message
 	ws      *workerSignal
 	handler messageHandler
+
+	// guards the queue from being sent to after the worker has been stopped
+	sendLock sync.RWMutex
+	closed   bool
 }
 
 type message struct {
@@ -26,6 +32,7 @@ type message struct {
 type workerSignal struct {
 	done chan struct{}
 	wg   sync.WaitGroup
+	once sync.Once
 }
 
 type messageHandler interface {
@@ -55,25 +62,65 @@ func (p *messageWorker) run() {
 			return
 		case m := <-p.queue:
 			p.handler.onMessage(m)
+			atomic.AddInt64(&p.queued, -int64(m.count()))
 		}
 	}
 }
 
 func (p *messageWorker) shutdown() {
 	p.handler.onStop()
+
+	p.sendLock.Lock()
+	p.closed = true
+	p.sendLock.Unlock()
+
 	stopQueue(p.queue)
 	p.ws.wg.Done()
 }
 
+// send forwards m to the worker. If the worker has been stopped, m is
+// signaled as failed instead.
 func (p *messageWorker) send(m message) {
-	p.queue <- m
+	p.sendLock.RLock()
+	defer p.sendLock.RUnlock()
+
+	if p.closed {
+		outputs.SignalFailed(m.context.signal, nil)
+		return
+	}
+
+	atomic.AddInt64(&p.queued, int64(m.count()))
+	select {
+	case <-p.ws.done:
+		atomic.AddInt64(&p.queued, -int64(m.count()))
+		outputs.SignalFailed(m.context.signal, nil)
+	case p.queue <- m:
+	}
+}
+
+// pendingEvents returns the number of events queued or being handled by the worker.
+func (p *messageWorker) pendingEvents() int64 {
+	return atomic.LoadInt64(&p.queued)
+}
+
+// count returns the number of events in m.
+func (m *message) count() int {
+	if m.event != nil {
+		return 1
+	}
+	return len(m.events)
 }
 
 func (ws *workerSignal) stop() {
-	close(ws.done)
+	ws.stopAsync()
 	ws.wg.Wait()
 }
 
+// stopAsync signals the workers to stop without waiting for them to finish.
+func (ws *workerSignal) stopAsync() {
+	ws.once.Do(func() { close(ws.done) })
+}
+
 func newWorkerSignal() *workerSignal {
 	w := &workerSignal{}
 	w.Init()
@@ -35,6 +35,24 @@ func HandleSignals(stopFunction func()) {
 	})
 }
 
+// HandleReload calls reloadFunction each time the process is asked to reload
+// its configuration by receiving SIGHUP. Reload signals are not supported on
+// Windows.
+func HandleReload(reloadFunction func()) {
+	if len(reloadSignals) == 0 {
+		return
+	}
+
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, reloadSignals...)
+	go func() {
+		for range sigc {
+			logp.Info("Received sighup, reloading configuration")
+			reloadFunction()
+		}
+	}()
+}
+
 // cmdline flags
 var memprofile, cpuprofile *string
 
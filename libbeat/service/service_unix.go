@@ -2,6 +2,14 @@
 
 package service
 
+import (
+	"os"
+	"syscall"
+)
+
+// signals asking the process to reload its configuration
+var reloadSignals = []os.Signal{syscall.SIGHUP}
+
 // On non-windows platforms, this function does nothing.
 func ProcessWindowsControlEvents(stopCallback func()) {
 }
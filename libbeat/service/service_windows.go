@@ -9,6 +9,9 @@ import (
 	"golang.org/x/sys/windows/svc/debug"
 )
 
+// reload signals are not supported on Windows
+var reloadSignals []os.Signal
+
 type beatService struct{}
 
 func (m *beatService) Execute(args []string, r <-chan svc.ChangeRequest, changes chan<- svc.Status) (ssec bool, errno uint32) {
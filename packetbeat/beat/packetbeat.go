@@ -4,6 +4,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"reflect"
 	"runtime"
 	"time"
 
@@ -13,36 +14,18 @@ import (
 	"github.com/elastic/beats/libbeat/filters"
 	"github.com/elastic/beats/libbeat/filters/nop"
 	"github.com/elastic/beats/libbeat/logp"
+	"github.com/elastic/beats/libbeat/publisher"
 	"github.com/elastic/beats/libbeat/service"
 
 	"github.com/elastic/beats/packetbeat/config"
 	"github.com/elastic/beats/packetbeat/procs"
 	"github.com/elastic/beats/packetbeat/protos"
-	"github.com/elastic/beats/packetbeat/protos/dns"
-	"github.com/elastic/beats/packetbeat/protos/http"
 	"github.com/elastic/beats/packetbeat/protos/icmp"
-	"github.com/elastic/beats/packetbeat/protos/memcache"
-	"github.com/elastic/beats/packetbeat/protos/mongodb"
-	"github.com/elastic/beats/packetbeat/protos/mysql"
-	"github.com/elastic/beats/packetbeat/protos/pgsql"
-	"github.com/elastic/beats/packetbeat/protos/redis"
 	"github.com/elastic/beats/packetbeat/protos/tcp"
-	"github.com/elastic/beats/packetbeat/protos/thrift"
 	"github.com/elastic/beats/packetbeat/protos/udp"
 	"github.com/elastic/beats/packetbeat/sniffer"
 )
 
-var EnabledProtocolPlugins map[protos.Protocol]protos.ProtocolPlugin = map[protos.Protocol]protos.ProtocolPlugin{
-	protos.HttpProtocol:     new(http.HTTP),
-	protos.MemcacheProtocol: new(memcache.Memcache),
-	protos.MysqlProtocol:    new(mysql.Mysql),
-	protos.PgsqlProtocol:    new(pgsql.Pgsql),
-	protos.RedisProtocol:    new(redis.Redis),
-	protos.ThriftProtocol:   new(thrift.Thrift),
-	protos.MongodbProtocol:  new(mongodb.Mongodb),
-	protos.DnsProtocol:      new(dns.Dns),
-}
-
 var EnabledFilterPlugins map[filters.Filter]filters.FilterPlugin = map[filters.Filter]filters.FilterPlugin{
 	filters.NopFilter: new(nop.Nop),
 }
@@ -118,26 +101,52 @@ func (pb *Packetbeat) Config(b *beat.Beat) error {
 	err := cfgfile.Read(&pb.PbConfig, "")
 
 	// CLI flags over-riding config
-	if *pb.CmdLineArgs.TopSpeed {
-		pb.PbConfig.Interfaces.TopSpeed = true
+	pb.CmdLineArgs.apply(&pb.PbConfig.Interfaces)
+
+	// assign global singleton as it is used in protocols
+	// TODO: Refactor
+	config.ConfigSingleton = pb.PbConfig
+
+	return err
+}
+
+// apply overrides the interfaces settings with the command line flags.
+func (args CmdLineArgs) apply(interfaces *config.InterfacesConfig) {
+	if *args.TopSpeed {
+		interfaces.TopSpeed = true
 	}
 
-	if len(*pb.CmdLineArgs.File) > 0 {
-		pb.PbConfig.Interfaces.File = *pb.CmdLineArgs.File
+	if len(*args.File) > 0 {
+		interfaces.File = *args.File
 	}
 
-	pb.PbConfig.Interfaces.Loop = *pb.CmdLineArgs.Loop
-	pb.PbConfig.Interfaces.OneAtATime = *pb.CmdLineArgs.OneAtAtime
+	interfaces.Loop = *args.Loop
+	interfaces.OneAtATime = *args.OneAtAtime
 
-	if len(*pb.CmdLineArgs.Dumpfile) > 0 {
-		pb.PbConfig.Interfaces.Dumpfile = *pb.CmdLineArgs.Dumpfile
+	if len(*args.Dumpfile) > 0 {
+		interfaces.Dumpfile = *args.Dumpfile
 	}
+}
 
-	// assign global singleton as it is used in protocols
-	// TODO: Refactor
-	config.ConfigSingleton = pb.PbConfig
-
-	return err
+// Reload reads the packetbeat configuration again. The sniffer and the
+// protocol plugins are only set up on startup, so changes of the protocol
+// ports, the BPF filter and the other packetbeat settings are logged as
+// requiring a restart.
+func (pb *Packetbeat) Reload(b *beat.Beat) error {
+	var reloaded config.Config
+	if err := cfgfile.Read(&reloaded, ""); err != nil {
+		return err
+	}
+	pb.CmdLineArgs.apply(&reloaded.Interfaces)
+
+	current := pb.PbConfig
+	if !reflect.DeepEqual(current.Interfaces, reloaded.Interfaces) ||
+		!reflect.DeepEqual(current.Protocols, reloaded.Protocols) ||
+		!reflect.DeepEqual(current.ProtocolDetection, reloaded.ProtocolDetection) ||
+		!reflect.DeepEqual(current.Procs, reloaded.Procs) {
+		logp.Warn("Changes of the interfaces, protocols, protocol_detection and procs settings require a restart")
+	}
+	return nil
 }
 
 // Setup packetbeat
@@ -148,33 +157,33 @@ func (pb *Packetbeat) Setup(b *beat.Beat) error {
 		os.Exit(1)
 	}
 
+	// transactions published more than once are indexed only once
+	publisher.Publisher.SetEventIDFunc(protos.TransactionID)
+
 	pb.Sniff = new(sniffer.SnifferSetup)
 
 	logp.Debug("main", "Initializing protocol plugins")
-	for proto, plugin := range EnabledProtocolPlugins {
-		err := plugin.Init(false, b.Events)
-		if err != nil {
-			logp.Critical("Initializing plugin %s failed: %v", proto, err)
-			os.Exit(1)
-		}
-		protos.Protos.Register(proto, plugin)
+	err := initProtocolPlugins(pb.PbConfig.Protocols, b.Events)
+	if err != nil {
+		logp.Critical(err.Error())
+		os.Exit(1)
 	}
 
-	var err error
-
-	icmpProc, err := icmp.NewIcmp(false, b.Events)
+	icmpProc, err := icmp.NewIcmp(false, protos.CountTransactions("icmp", b.Events))
 	if err != nil {
 		logp.Critical(err.Error())
 		os.Exit(1)
 	}
 
-	tcpProc, err := tcp.NewTcp(&protos.Protos)
+	detection := detectionConfig(pb.PbConfig.ProtocolDetection)
+
+	tcpProc, err := tcp.NewTcp(&protos.Protos, detection)
 	if err != nil {
 		logp.Critical(err.Error())
 		os.Exit(1)
 	}
 
-	udpProc, err := udp.NewUdp(&protos.Protos)
+	udpProc, err := udp.NewUdp(&protos.Protos, detection)
 	if err != nil {
 		logp.Critical(err.Error())
 		os.Exit(1)
@@ -212,6 +221,44 @@ func (pb *Packetbeat) Setup(b *beat.Beat) error {
 	return err
 }
 
+// initProtocolPlugins creates and registers the plugins of all protocols
+// having a section in the protocols configuration.
+func initProtocolPlugins(protocols config.Protocols, results publisher.Client) error {
+	for _, proto := range protos.Registered() {
+		info := proto.Info()
+
+		cfg := info.Config()
+		configured, err := protocols.Unpack(info.Name, cfg)
+		if err != nil {
+			return fmt.Errorf("Invalid %s configuration: %v", info.Name, err)
+		}
+		if !configured {
+			logp.Debug("main", "Protocol %s not configured", info.Name)
+			continue
+		}
+
+		plugin, err := info.NewPlugin(cfg, false, results)
+		if err != nil {
+			return fmt.Errorf("Initializing plugin %s failed: %v", info.Name, err)
+		}
+		protos.Protos.Register(proto, plugin)
+	}
+	return nil
+}
+
+// detectionConfig converts the protocol_detection settings to the protocol
+// detection configuration used by the tcp and udp processors.
+func detectionConfig(cfg config.ProtocolDetection) protos.DetectionConfig {
+	detection := protos.DetectionConfig{Enabled: cfg.Enabled}
+	if cfg.Max_bytes != nil {
+		detection.MaxBytes = *cfg.Max_bytes
+	}
+	if cfg.Max_packets != nil {
+		detection.MaxPackets = *cfg.Max_packets
+	}
+	return detection
+}
+
 func (pb *Packetbeat) Run(b *beat.Beat) error {
 
 	// run the sniffer in background
@@ -0,0 +1,16 @@
+package beat
+
+// Protocol plugins register themselves with the protos package when their
+// package is imported. Additional plugins, including ones maintained outside
+// of this repository, are compiled in by adding a blank import here or to
+// the main package.
+import (
+	_ "github.com/elastic/beats/packetbeat/protos/dns"
+	_ "github.com/elastic/beats/packetbeat/protos/http"
+	_ "github.com/elastic/beats/packetbeat/protos/memcache"
+	_ "github.com/elastic/beats/packetbeat/protos/mongodb"
+	_ "github.com/elastic/beats/packetbeat/protos/mysql"
+	_ "github.com/elastic/beats/packetbeat/protos/pgsql"
+	_ "github.com/elastic/beats/packetbeat/protos/redis"
+	_ "github.com/elastic/beats/packetbeat/protos/thrift"
+)
@@ -1,22 +1,29 @@
 package config
 
 import (
+	"fmt"
+	"sort"
+
+	"github.com/elastic/beats/libbeat/cfgfile"
 	"github.com/elastic/beats/libbeat/common/droppriv"
 	"github.com/elastic/beats/libbeat/logp"
 	"github.com/elastic/beats/libbeat/outputs"
 	"github.com/elastic/beats/libbeat/publisher"
 	"github.com/elastic/beats/packetbeat/procs"
+	"github.com/elastic/beats/packetbeat/protos"
+	"github.com/joeshaw/multierror"
 )
 
 type Config struct {
-	Interfaces InterfacesConfig
-	Protocols  Protocols
-	Output     map[string]outputs.MothershipConfig
-	Shipper    publisher.ShipperConfig
-	Procs      procs.ProcsConfig
-	RunOptions droppriv.RunOptions
-	Logging    logp.Logging
-	Filter     map[string]interface{}
+	Interfaces        InterfacesConfig
+	Protocols         Protocols
+	ProtocolDetection ProtocolDetection `yaml:"protocol_detection"`
+	Output            map[string]outputs.MothershipConfig
+	Shipper           publisher.ShipperConfig
+	Procs             procs.ProcsConfig
+	RunOptions        droppriv.RunOptions
+	Logging           logp.Logging
+	Filter            map[string]interface{}
 }
 
 type InterfacesConfig struct {
@@ -33,16 +40,61 @@ type InterfacesConfig struct {
 	Loop           int
 }
 
-type Protocols struct {
-	Icmp     Icmp
-	Dns      Dns
-	Http     Http
-	Memcache Memcache
-	Mysql    Mysql
-	Mongodb  Mongodb
-	Pgsql    Pgsql
-	Redis    Redis
-	Thrift   Thrift
+// Protocols maps protocol names to their raw configuration section. The
+// sections are decoded by Unpack into the configuration type of the
+// protocol.
+type Protocols map[string]interface{}
+
+// Unpack decodes the configuration section of the named protocol into out.
+// It returns false if the section does not exist, in which case out is left
+// unchanged. Unknown settings are rejected.
+func (p Protocols) Unpack(name string, out interface{}) (bool, error) {
+	raw, exists := p[name]
+	if !exists {
+		return false, nil
+	}
+	return true, cfgfile.UnpackSection(raw, out, "protocols."+name)
+}
+
+// Validate checks each section configures a registered protocol and decodes
+// into the configuration type of the protocol. It returns an error describing
+// all problems or nil if there are none.
+func (p Protocols) Validate() error {
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs multierror.Errors
+	for _, name := range names {
+		var err error
+		if name == "icmp" {
+			_, err = p.Icmp()
+		} else if proto, exists := protos.Lookup(name); exists {
+			_, err = p.Unpack(name, proto.Info().Config())
+		} else {
+			err = fmt.Errorf("unknown protocol '%s'", name)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs.Err()
+}
+
+// Icmp returns the configuration of the icmp protocol, which is not
+// implemented by a protocol plugin.
+func (p Protocols) Icmp() (Icmp, error) {
+	var icmp Icmp
+	_, err := p.Unpack("icmp", &icmp)
+	return icmp, err
+}
+
+type ProtocolDetection struct {
+	Enabled     bool
+	Max_bytes   *int
+	Max_packets *int
 }
 
 type ProtocolCommon struct {
@@ -52,6 +104,29 @@ type ProtocolCommon struct {
 	TransactionTimeout *int  `yaml:"transaction_timeout"`
 }
 
+// Validate checks the ports and the transaction timeout.
+func (c ProtocolCommon) Validate() error {
+	var errs multierror.Errors
+	for _, port := range c.Ports {
+		if port < 1 || port > 65535 {
+			errs = append(errs, fmt.Errorf("ports must be within [1-65535] but was '%d'", port))
+		}
+	}
+	if c.TransactionTimeout != nil && *c.TransactionTimeout < 0 {
+		errs = append(errs, fmt.Errorf("transaction_timeout must not be negative but was '%d'",
+			*c.TransactionTimeout))
+	}
+	return errs.Err()
+}
+
+// SetDefaultPorts sets the ports if the ports option is not set. An empty
+// list of ports is kept.
+func (c *ProtocolCommon) SetDefaultPorts(ports []int) {
+	if c.Ports == nil {
+		c.Ports = ports
+	}
+}
+
 type Icmp struct {
 	Enabled            bool
 	SendRequest        *bool `yaml:"send_request"`
@@ -60,9 +135,10 @@ type Icmp struct {
 }
 
 type Dns struct {
-	ProtocolCommon      `yaml:",inline"`
-	Include_authorities *bool
-	Include_additionals *bool
+	ProtocolCommon        `yaml:",inline"`
+	Include_authorities   *bool
+	Include_additionals   *bool
+	Correlate_tcp_retries *bool
 }
 
 type Http struct {
@@ -85,9 +161,10 @@ type Memcache struct {
 }
 
 type Mysql struct {
-	ProtocolCommon `yaml:",inline"`
-	Max_row_length *int
-	Max_rows       *int
+	ProtocolCommon     `yaml:",inline"`
+	QueryNormalization `yaml:",inline"`
+	Max_row_length     *int
+	Max_rows           *int
 }
 
 type Mongodb struct {
@@ -97,9 +174,17 @@ type Mongodb struct {
 }
 
 type Pgsql struct {
-	ProtocolCommon `yaml:",inline"`
-	Max_row_length *int
-	Max_rows       *int
+	ProtocolCommon     `yaml:",inline"`
+	QueryNormalization `yaml:",inline"`
+	Max_row_length     *int
+	Max_rows           *int
+}
+
+// QueryNormalization contains the SQL query normalization options shared by
+// the database protocols.
+type QueryNormalization struct {
+	Normalize_queries *bool
+	Drop_raw_queries  *bool
 }
 
 type Thrift struct {
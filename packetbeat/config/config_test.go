@@ -0,0 +1,120 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func readProtocols(t *testing.T, s string) Protocols {
+	var config Config
+	if err := yaml.Unmarshal([]byte(s), &config); err != nil {
+		t.Fatal(err)
+	}
+	return config.Protocols
+}
+
+func TestProtocolsUnpack(t *testing.T) {
+	protocols := readProtocols(t, `
+protocols:
+  icmp:
+    enabled: true
+  http:
+    ports: [80, 8080]
+    send_headers: ["Host"]
+  redis:
+  mysql:
+    ports: []
+`)
+
+	var http Http
+	configured, err := protocols.Unpack("http", &http)
+	assert.NoError(t, err)
+	assert.True(t, configured)
+	assert.Equal(t, []int{80, 8080}, http.Ports)
+	assert.Equal(t, []string{"Host"}, http.Send_headers)
+
+	// section without settings
+	var redis Redis
+	configured, err = protocols.Unpack("redis", &redis)
+	assert.NoError(t, err)
+	assert.True(t, configured)
+	redis.SetDefaultPorts([]int{6379})
+	assert.Equal(t, []int{6379}, redis.Ports)
+
+	// empty list of ports is kept
+	var mysql Mysql
+	configured, err = protocols.Unpack("mysql", &mysql)
+	assert.NoError(t, err)
+	assert.True(t, configured)
+	mysql.SetDefaultPorts([]int{3306})
+	assert.Empty(t, mysql.Ports)
+
+	var pgsql Pgsql
+	configured, err = protocols.Unpack("pgsql", &pgsql)
+	assert.NoError(t, err)
+	assert.False(t, configured)
+
+	icmp, err := protocols.Icmp()
+	assert.NoError(t, err)
+	assert.True(t, icmp.Enabled)
+}
+
+func TestProtocolsUnpack_invalid(t *testing.T) {
+	protocols := readProtocols(t, `
+protocols:
+  http:
+    ports: "eighty"
+`)
+
+	var http Http
+	_, err := protocols.Unpack("http", &http)
+	assert.Error(t, err)
+}
+
+func TestProtocolsUnpack_unknownSetting(t *testing.T) {
+	protocols := readProtocols(t, `
+protocols:
+  http:
+    ports: [80]
+    send_heders: ["Host"]
+`)
+
+	var http Http
+	_, err := protocols.Unpack("http", &http)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "unknown setting 'protocols.http.send_heders'")
+	}
+}
+
+func TestProtocolCommonValidate(t *testing.T) {
+	timeout := -1
+	assert.NoError(t, ProtocolCommon{Ports: []int{80, 8080}}.Validate())
+	assert.Error(t, ProtocolCommon{Ports: []int{0}}.Validate())
+	assert.Error(t, ProtocolCommon{TransactionTimeout: &timeout}.Validate())
+
+	protocols := readProtocols(t, `
+protocols:
+  dns:
+    ports: [70000]
+`)
+	var dns Dns
+	_, err := protocols.Unpack("dns", &dns)
+	assert.Error(t, err)
+}
+
+func TestProtocolsValidate(t *testing.T) {
+	protocols := readProtocols(t, `
+protocols:
+  icmp:
+    enabld: true
+  unknown:
+    ports: [80]
+`)
+	err := protocols.Validate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "unknown setting 'protocols.icmp.enabld'")
+		assert.Contains(t, err.Error(), "unknown protocol 'unknown'")
+	}
+}
@@ -1,15 +1,14 @@
 // Package dns provides support for parsing DNS messages and reporting the
 // results. This package supports the DNS protocol as defined by RFC 1034
-// and RFC 1035. It does not have any special support for RFC 2671 (EDNS) or
-// RFC 4035 (DNS Security Extensions), but since those specifications only
-// add backwards compatible features there will be no issues handling the
-// messages.
+// and RFC 1035 over UDP and TCP. The OPT pseudo resource record of EDNS0
+// (RFC 6891) and the resource records and header flags of the DNS Security
+// Extensions (RFC 4034, RFC 4035 and RFC 5155) are decoded as well.
+//
+// Truncated UDP responses can optionally be correlated with the query the
+// client retries over TCP.
 //
 // Future Additions:
-//   * Implement TcpProtocolPlugin.
 //   * Publish a message when packets are received that cannot be decoded.
-//   * Add EDNS and DNSSEC support (consider using miekg/dns instead
-//     of gopacket).
 //   * Consider adding ICMP support to
 //       - correlate ICMP type 3, code 4 (datagram too big) with DNS messages,
 //       - correlate ICMP type 3, code 13 (administratively prohibited) or
@@ -56,7 +55,7 @@ const (
 type Transport uint8
 
 const (
-	TransportTcp = iota
+	TransportTcp Transport = iota
 	TransportUdp
 )
 
@@ -215,19 +214,35 @@ func newTransaction(ts time.Time, tuple DnsTuple, cmd common.CmdlineTuple) *DnsT
 	return trans
 }
 
+func init() {
+	protos.Register(protos.ProtocolInfo{
+		Name:         "dns",
+		Tcp:          true,
+		Udp:          true,
+		DefaultPorts: []int{53},
+		Config:       func() interface{} { return &config.Dns{} },
+		New:          New,
+	})
+}
+
 type Dns struct {
 	// Configuration data.
-	Ports               []int
-	Send_request        bool
-	Send_response       bool
-	Include_authorities bool
-	Include_additionals bool
+	Ports                 []int
+	Send_request          bool
+	Send_response         bool
+	Include_authorities   bool
+	Include_additionals   bool
+	Correlate_tcp_retries bool
 
 	// Cache of active DNS transactions. The map key is the HashableDnsTuple
 	// associated with the request.
 	transactions       *common.Cache
 	transactionTimeout time.Duration
 
+	// Cache of queries answered by a truncated UDP response. The map key is
+	// the retryKey of the query. Only used if Correlate_tcp_retries is set.
+	truncated *common.Cache
+
 	results publisher.Client // Channel where results are pushed.
 }
 
@@ -258,6 +273,7 @@ func (dns *Dns) initDefaults() {
 	dns.Send_response = false
 	dns.Include_authorities = false
 	dns.Include_additionals = false
+	dns.Correlate_tcp_retries = false
 	dns.transactionTimeout = protos.DefaultTransactionExpiration
 }
 
@@ -277,6 +293,9 @@ func (dns *Dns) setFromConfig(config config.Dns) error {
 	if config.Include_additionals != nil {
 		dns.Include_additionals = *config.Include_additionals
 	}
+	if config.Correlate_tcp_retries != nil {
+		dns.Correlate_tcp_retries = *config.Correlate_tcp_retries
+	}
 	if config.TransactionTimeout != nil && *config.TransactionTimeout > 0 {
 		dns.transactionTimeout = time.Duration(*config.TransactionTimeout) * time.Second
 	}
@@ -284,10 +303,27 @@ func (dns *Dns) setFromConfig(config config.Dns) error {
 	return nil
 }
 
+// New creates a DNS protocol plugin from its configuration.
+func New(cfg interface{}, testMode bool, results publisher.Client) (protos.ProtocolPlugin, error) {
+	dns := &Dns{}
+	if err := dns.InitWithConfig(*cfg.(*config.Dns), testMode, results); err != nil {
+		return nil, err
+	}
+	return dns, nil
+}
+
 func (dns *Dns) Init(test_mode bool, results publisher.Client) error {
+	return dns.InitWithConfig(config.Dns{}, test_mode, results)
+}
+
+func (dns *Dns) InitWithConfig(
+	config config.Dns,
+	test_mode bool,
+	results publisher.Client,
+) error {
 	dns.initDefaults()
 	if !test_mode {
-		dns.setFromConfig(config.ConfigSingleton.Protocols.Dns)
+		dns.setFromConfig(config)
 	}
 
 	dns.transactions = common.NewCacheWithRemovalListener(
@@ -303,6 +339,10 @@ func (dns *Dns) Init(test_mode bool, results publisher.Client) error {
 		})
 	dns.transactions.StartJanitor(dns.transactionTimeout)
 
+	dns.truncated = common.NewCache(dns.transactionTimeout,
+		protos.DefaultTransactionHashSize)
+	dns.truncated.StartJanitor(dns.transactionTimeout)
+
 	dns.results = results
 
 	return nil
@@ -312,6 +352,21 @@ func (dns *Dns) GetPorts() []int {
 	return dns.Ports
 }
 
+// DetectUdp checks if a UDP packet on an unknown port is a valid DNS
+// message containing exactly one question.
+func (dns *Dns) DetectUdp(data []byte) protos.DetectResult {
+	// 12 bytes header + at least the root name, type and class of the question
+	if len(data) < 17 {
+		return protos.DetectNoMatch
+	}
+
+	dnsPkt, err := decodeDnsPacket(data)
+	if err != nil || len(dnsPkt.Questions) != 1 {
+		return protos.DetectNoMatch
+	}
+	return protos.DetectMatch
+}
+
 func (dns *Dns) ParseUdp(pkt *protos.Packet) {
 	defer logp.Recover("Dns ParseUdp")
 
@@ -412,11 +467,16 @@ func (dns *Dns) publishTransaction(t *DnsTransaction) {
 		}
 		addDnsToMapStr(dnsEvent, t.Response.Data, dns.Include_authorities,
 			dns.Include_additionals)
+		dnsEvent["response_size"] = t.Response.Length
 
-		if t.Response.Data.ResponseCode == 0 {
+		if rcode, _ := extendedResponseCode(t.Response.Data); rcode == 0 {
 			event["status"] = common.OK_STATUS
 		}
 
+		if dns.Correlate_tcp_retries {
+			dns.correlateRetry(t, dnsEvent)
+		}
+
 		if dns.Send_request {
 			event["request"] = dnsToString(t.Request.Data)
 		}
@@ -445,6 +505,7 @@ func (dns *Dns) publishTransaction(t *DnsTransaction) {
 		}
 		addDnsToMapStr(dnsEvent, t.Response.Data, dns.Include_authorities,
 			dns.Include_additionals)
+		dnsEvent["response_size"] = t.Response.Length
 		if dns.Send_response {
 			event["response"] = dnsToString(t.Response.Data)
 		}
@@ -453,6 +514,56 @@ func (dns *Dns) publishTransaction(t *DnsTransaction) {
 	dns.results.PublishEvent(event)
 }
 
+// retryKey identifies a query of a client to a server independent of the
+// transport and DNS ID used.
+type retryKey struct {
+	client, server string
+	name           string
+	qtype          layers.DNSType
+	qclass         layers.DNSClass
+}
+
+// truncatedQuery is a query answered by a truncated UDP response.
+type truncatedQuery struct {
+	ts time.Time // Time when the query was received.
+	id uint16
+}
+
+// correlateRetry remembers queries answered by a truncated UDP response and
+// links the query the client retries over TCP to them. The retry
+// information is added to the event of the TCP transaction.
+func (dns *Dns) correlateRetry(t *DnsTransaction, event common.MapStr) {
+	if len(t.Request.Data.Questions) == 0 {
+		return
+	}
+	q := t.Request.Data.Questions[0]
+	key := retryKey{
+		client: t.Src.Ip,
+		server: t.Dst.Ip,
+		name:   string(q.Name),
+		qtype:  q.Type,
+		qclass: q.Class,
+	}
+
+	switch t.Transport {
+	case TransportUdp:
+		if t.Response.Data.TC {
+			dns.truncated.Put(key, &truncatedQuery{ts: t.ts, id: t.tuple.Id})
+		}
+	case TransportTcp:
+		v := dns.truncated.Delete(key)
+		if v == nil {
+			return
+		}
+		udp := v.(*truncatedQuery)
+		event["retry"] = common.MapStr{
+			"transport": TransportUdp.String(),
+			"id":        udp.id,
+			"elapsed":   int32(t.ts.Sub(udp.ts).Nanoseconds() / 1e6),
+		}
+	}
+}
+
 func (dns *Dns) expireTransaction(t *DnsTransaction) {
 	t.Notes = append(t.Notes, NoResponse)
 	logp.Debug("dns", NoResponse+" %s", t.tuple.String())
@@ -469,11 +580,18 @@ func addDnsToMapStr(m common.MapStr, dns *layers.DNS, authority bool, additional
 		"truncated_response": dns.TC,
 		"recursion_desired":  dns.RD,
 		"recursion_allowed":  dns.RA,
-		// Need to add RFC4035 flag parsing to gopacket.
-		//"authentic_data":     dns.AD
-		//"checking_disabled":  dns.CD
+		"authentic_data":     authenticData(dns),
+		"checking_disabled":  checkingDisabled(dns),
+	}
+	m["response_code"] = responseCodeToString(dns)
+
+	if rr := getOpt(dns); rr != nil {
+		if opt, err := decodeOpt(rr); err == nil {
+			m["opt"] = optToMapStr(opt)
+		} else {
+			logp.Debug("dns", "Failed to decode OPT record: %v", err)
+		}
 	}
-	m["response_code"] = dnsResponseCodeToString(dns.ResponseCode)
 
 	if len(dns.Questions) > 0 {
 		q := dns.Questions[0]
@@ -541,6 +659,26 @@ func rrToMapStr(records []layers.DNSResourceRecord) []common.MapStr {
 			mapStr["data"] = nameToString(r.PTR)
 		case layers.DNSTypeNS:
 			mapStr["data"] = nameToString(r.NS)
+		case dnsTypeOPT:
+			// The class and TTL fields of the OPT record are redefined.
+			delete(mapStr, "class")
+			delete(mapStr, "ttl")
+			opt, err := decodeOpt(&r)
+			if err != nil {
+				mapStr["data"] = nameToString(r.Data)
+				continue
+			}
+			mapStr.Update(optToMapStr(opt))
+			mapStr["data"] = optToString(opt)
+		case dnsTypeDS, dnsTypeRRSIG, dnsTypeNSEC, dnsTypeDNSKEY, dnsTypeNSEC3:
+			fields, err := rdataDecoders[r.Type](r.Data)
+			if err != nil {
+				logp.Debug("dns", "Failed to decode %s record: %v",
+					dnsTypeToString(r.Type), err)
+				mapStr["data"] = nameToString(r.Data)
+				continue
+			}
+			mapStr.Update(fields)
 		}
 	}
 
@@ -587,6 +725,16 @@ func dnsResourceRecordToString(rr *layers.DNSResourceRecord) string {
 		data = nameToString(rr.PTR)
 	case layers.DNSTypeNS:
 		data = nameToString(rr.NS)
+	case dnsTypeOPT:
+		data = nameToString(rr.Data)
+		if opt, err := decodeOpt(rr); err == nil {
+			data = optToString(opt)
+		}
+	case dnsTypeDS, dnsTypeRRSIG, dnsTypeNSEC, dnsTypeDNSKEY, dnsTypeNSEC3:
+		data = nameToString(rr.Data)
+		if fields, err := rdataDecoders[rr.Type](rr.Data); err == nil {
+			data = fields["data"].(string)
+		}
 	}
 
 	return fmt.Sprintf("%s: ttl %d, class %s, type %s, %s", name,
@@ -626,15 +774,18 @@ func dnsToString(dns *layers.DNS) string {
 	if dns.RA {
 		t = append(t, "ra")
 	}
-	// Need to add RFC4035 flag parsing to gopacket.
-	//if dns.AD { t = append(t, "ad") }
-	//if dns.CD { t = append(t, "cd") }
+	if authenticData(dns) {
+		t = append(t, "ad")
+	}
+	if checkingDisabled(dns) {
+		t = append(t, "cd")
+	}
 	flags := strings.Join(t, " ")
 
 	var a []string
 	a = append(a, fmt.Sprintf("ID %d; QR %s; OPCODE %s; FLAGS %s; RCODE %s",
 		dns.ID, msgType, dnsOpCodeToString(dns.OpCode), flags,
-		dnsResponseCodeToString(dns.ResponseCode)))
+		responseCodeToString(dns)))
 
 	if len(dns.Questions) > 0 {
 		t = []string{}
@@ -662,6 +813,18 @@ func dnsToString(dns *layers.DNS) string {
 	return strings.Join(a, "; ")
 }
 
+// authenticData returns the value of the AD flag (RFC 4035). gopacket
+// returns the flag as part of the reserved Z field.
+func authenticData(dns *layers.DNS) bool {
+	return dns.Z&0x2 != 0
+}
+
+// checkingDisabled returns the value of the CD flag (RFC 4035). gopacket
+// returns the flag as part of the reserved Z field.
+func checkingDisabled(dns *layers.DNS) bool {
+	return dns.Z&0x1 != 0
+}
+
 // nameToString converts bytes representing a domain name to a string. Bytes
 // below 32 or above 126 are represented as an escaped base10 integer (\DDD).
 // Back slashes and quotes are escaped. Tabs, carriage returns, and line feeds
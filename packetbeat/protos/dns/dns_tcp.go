@@ -0,0 +1,126 @@
+package dns
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/logp"
+
+	"github.com/elastic/beats/packetbeat/procs"
+	"github.com/elastic/beats/packetbeat/protos"
+)
+
+// Size of the length field prefixing DNS messages sent over TCP.
+const dnsTcpLengthSize = 2
+
+func (dns *Dns) ConnectionTimeout() time.Duration {
+	return dns.transactionTimeout
+}
+
+// Parse is called when TCP payload data is available. Each DNS message sent
+// over TCP is prefixed with a two byte length field (RFC 1035, section
+// 4.2.2), messages can span multiple segments and a segment can contain
+// multiple messages.
+func (dns *Dns) Parse(pkt *protos.Packet, tcptuple *common.TcpTuple,
+	dir uint8, private protos.ProtocolData) protos.ProtocolData {
+
+	defer logp.Recover("Dns Parse")
+
+	logp.Debug("dns", "Parsing packet addressed with %s of length %d.",
+		pkt.Tuple.String(), len(pkt.Payload))
+
+	priv := dnsPrivateData{}
+	if private != nil {
+		var ok bool
+		priv, ok = private.(dnsPrivateData)
+		if !ok {
+			priv = dnsPrivateData{}
+		}
+	}
+
+	stream := priv.Data[dir]
+	if stream == nil {
+		stream = &DnsStream{tcptuple: tcptuple}
+		priv.Data[dir] = stream
+	}
+	stream.data = append(stream.data, pkt.Payload...)
+	stream.bytesReceived += len(pkt.Payload)
+
+	for len(stream.data) > 0 {
+		if stream.message == nil {
+			stream.message = &DnsMessage{
+				Ts:           pkt.Ts,
+				Tuple:        pkt.Tuple,
+				CmdlineTuple: procs.ProcWatcher.FindProcessesTuple(&pkt.Tuple),
+			}
+		}
+
+		if len(stream.data) < dnsTcpLengthSize {
+			break
+		}
+		length := int(binary.BigEndian.Uint16(stream.data[:dnsTcpLengthSize]))
+		end := dnsTcpLengthSize + length
+		if len(stream.data) < end {
+			// wait for more data
+			break
+		}
+
+		// copy the message, the stream buffer is reused for the following
+		// messages
+		payload := make([]byte, length)
+		copy(payload, stream.data[dnsTcpLengthSize:end])
+		stream.data = stream.data[end:]
+
+		if !dns.messageComplete(stream, payload) {
+			priv.Data[dir] = nil
+			return priv
+		}
+	}
+
+	return priv
+}
+
+// messageComplete decodes a complete DNS message received over TCP and
+// handles it as request or response. It returns false if the message could
+// not be decoded.
+func (dns *Dns) messageComplete(stream *DnsStream, payload []byte) bool {
+	msg := stream.message
+	stream.message = nil
+
+	dnsPkt, err := decodeDnsPacket(payload)
+	if err != nil {
+		logp.Debug("dns", NonDnsPacketMsg+" addresses %s, length %d",
+			msg.Tuple.String(), len(payload))
+		return false
+	}
+
+	msg.Data = dnsPkt
+	msg.Length = len(payload)
+
+	dnsTuple := DnsTupleFromIpPort(&msg.Tuple, TransportTcp, dnsPkt.ID)
+	if dnsPkt.QR == Query {
+		dns.receivedDnsRequest(&dnsTuple, msg)
+	} else /* Response */ {
+		dns.receivedDnsResponse(&dnsTuple, msg)
+	}
+	return true
+}
+
+func (dns *Dns) ReceivedFin(tcptuple *common.TcpTuple, dir uint8,
+	private protos.ProtocolData) protos.ProtocolData {
+
+	// Incomplete messages can not be decoded, unanswered queries are
+	// published when the transaction expires.
+	return private
+}
+
+// GapInStream drops the stream, because the message boundaries are lost
+// if packets are missing.
+func (dns *Dns) GapInStream(tcptuple *common.TcpTuple, dir uint8, nbytes int,
+	private protos.ProtocolData) (priv protos.ProtocolData, drop bool) {
+
+	logp.Debug("dns", "Gap of %d bytes in stream %s, dropping stream.",
+		nbytes, tcptuple.String())
+	return private, true
+}
@@ -0,0 +1,131 @@
+// Unit tests for DNS over TCP and the correlation of truncated UDP
+// responses with TCP retries.
+
+package dns
+
+import (
+	"testing"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/packetbeat/protos"
+	"github.com/stretchr/testify/assert"
+	"github.com/tsg/gopacket/layers"
+)
+
+var tcptuple = common.TcpTupleFromIpPort(&forward, 0)
+
+// frameTcp prefixes DNS messages with their two byte length.
+func frameTcp(msgs ...[]byte) []byte {
+	var b []byte
+	for _, msg := range msgs {
+		b = append(b, byte(len(msg)>>8), byte(len(msg)))
+		b = append(b, msg...)
+	}
+	return b
+}
+
+// parseTcp passes each segment to the Dns Parse method.
+func parseTcp(dns *Dns, tuple common.IpPortTuple, dir uint8,
+	private protos.ProtocolData, segments ...[]byte) protos.ProtocolData {
+
+	for _, segment := range segments {
+		private = dns.Parse(newPacket(tuple, segment), &tcptuple, dir, private)
+	}
+	return private
+}
+
+// Verify that a request and response split over several segments are
+// reassembled.
+func TestParseTcp_requestResponse(t *testing.T) {
+	dns := newDns(testing.Verbose())
+	q := elasticA
+
+	request := frameTcp(q.request)
+	response := frameTcp(q.response)
+
+	var private protos.ProtocolData
+	private = parseTcp(dns, forward, 0, private, request[:1], request[1:10], request[10:])
+	private = parseTcp(dns, reverse, 1, private, response[:20], response[20:])
+	assert.Empty(t, dns.transactions.Size(), "There should be no transactions.")
+
+	m := expectResult(t, dns)
+	assert.Equal(t, "tcp", mapValue(t, m, "transport"))
+	assert.Equal(t, len(q.request), mapValue(t, m, "bytes_in"))
+	assert.Equal(t, len(q.response), mapValue(t, m, "bytes_out"))
+	assert.Equal(t, common.OK_STATUS, mapValue(t, m, "status"))
+	assertMapStrData(t, m, q)
+}
+
+// Verify that multiple messages contained in one segment are parsed.
+func TestParseTcp_multipleMessages(t *testing.T) {
+	dns := newDns(testing.Verbose())
+
+	segment := frameTcp(elasticA.request, githubPtr.request)
+	private := parseTcp(dns, forward, 0, nil, segment)
+	assert.Equal(t, 2, dns.transactions.Size())
+
+	segment = frameTcp(elasticA.response, githubPtr.response)
+	parseTcp(dns, reverse, 1, private, segment)
+	assert.Empty(t, dns.transactions.Size(), "There should be no transactions.")
+
+	assertMapStrData(t, expectResult(t, dns), elasticA)
+	assertMapStrData(t, expectResult(t, dns), githubPtr)
+}
+
+// Verify that the stream is dropped if it does not contain DNS messages.
+func TestParseTcp_malformedMessage(t *testing.T) {
+	dns := newDns(testing.Verbose())
+
+	private := parseTcp(dns, forward, 0, nil, frameTcp([]byte{1, 2, 3}))
+	assert.Nil(t, private.(dnsPrivateData).Data[0])
+	assert.Empty(t, dns.transactions.Size(), "There should be no transactions.")
+}
+
+// Verify that a query retried over TCP after receiving a truncated UDP
+// response is linked to the UDP query.
+func TestCorrelateTcpRetry(t *testing.T) {
+	dns := newDns(testing.Verbose())
+	dns.Correlate_tcp_retries = true
+
+	udpQuery := buildDnsMessage(10, 0x0100, "example.com", layers.DNSTypeTXT, nil, nil)
+	udpResponse := buildDnsMessage(10, 0x8380, "example.com", layers.DNSTypeTXT, nil, nil)
+	dns.ParseUdp(newPacket(forward, udpQuery))
+	dns.ParseUdp(newPacket(reverse, udpResponse))
+
+	m := expectResult(t, dns)
+	assert.Equal(t, true, mapValue(t, m, "dns.flags.truncated_response"))
+	assert.Equal(t, len(udpResponse), mapValue(t, m, "dns.response_size"))
+	assert.Nil(t, mapValue(t, m, "dns.retry"))
+
+	tcpQuery := buildDnsMessage(11, 0x0100, "example.com", layers.DNSTypeTXT, nil, nil)
+	tcpResponse := buildDnsMessage(11, 0x8180, "example.com", layers.DNSTypeTXT,
+		[]testRR{{"example.com", layers.DNSTypeTXT, 1, 60, []byte("\x03abc")}}, nil)
+	private := parseTcp(dns, forward, 0, nil, frameTcp(tcpQuery))
+	parseTcp(dns, reverse, 1, private, frameTcp(tcpResponse))
+
+	m = expectResult(t, dns)
+	assert.Equal(t, "tcp", mapValue(t, m, "transport"))
+	assert.Equal(t, "udp", mapValue(t, m, "dns.retry.transport"))
+	assert.Equal(t, uint16(10), mapValue(t, m, "dns.retry.id"))
+	assert.NotNil(t, mapValue(t, m, "dns.retry.elapsed"))
+	assert.Equal(t, 0, dns.truncated.Size())
+}
+
+// Verify that TCP queries are not linked if correlation is disabled.
+func TestCorrelateTcpRetry_disabled(t *testing.T) {
+	dns := newDns(testing.Verbose())
+
+	udpQuery := buildDnsMessage(10, 0x0100, "example.com", layers.DNSTypeTXT, nil, nil)
+	udpResponse := buildDnsMessage(10, 0x8380, "example.com", layers.DNSTypeTXT, nil, nil)
+	dns.ParseUdp(newPacket(forward, udpQuery))
+	dns.ParseUdp(newPacket(reverse, udpResponse))
+	expectResult(t, dns)
+
+	tcpQuery := buildDnsMessage(11, 0x0100, "example.com", layers.DNSTypeTXT, nil, nil)
+	tcpResponse := buildDnsMessage(11, 0x8180, "example.com", layers.DNSTypeTXT, nil, nil)
+	private := parseTcp(dns, forward, 0, nil, frameTcp(tcpQuery))
+	parseTcp(dns, reverse, 1, private, frameTcp(tcpResponse))
+
+	m := expectResult(t, dns)
+	assert.Nil(t, mapValue(t, m, "dns.retry"))
+}
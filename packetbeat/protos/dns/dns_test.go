@@ -225,9 +225,7 @@ var (
 
 // Verify that the interfaces for UDP and TCP have been satisfied.
 var _ protos.UdpProtocolPlugin = &Dns{}
-
-// TODO: Uncomment when TCP is implemented.
-//var _ protos.TcpProtocolPlugin = &Dns{}
+var _ protos.TcpProtocolPlugin = &Dns{}
 
 func newDns(verbose bool) *Dns {
 	if verbose {
@@ -649,3 +647,16 @@ func assertAddress(t testing.TB, expected common.IpPortTuple, endpoint interface
 	assert.Equal(t, expected.Src_ip.String(), e.Ip)
 	assert.Equal(t, expected.Src_port, e.Port)
 }
+
+// Verify that DetectUdp claims DNS requests and responses only.
+func TestDetectUdp(t *testing.T) {
+	dns := newDns(testing.Verbose())
+
+	for _, q := range messages {
+		assert.Equal(t, protos.DetectMatch, dns.DetectUdp(q.request), q.q_name)
+		assert.Equal(t, protos.DetectMatch, dns.DetectUdp(q.response), q.q_name)
+	}
+	assert.Equal(t, protos.DetectNoMatch, dns.DetectUdp([]byte{1, 2, 3}))
+	assert.Equal(t, protos.DetectNoMatch,
+		dns.DetectUdp([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n")))
+}
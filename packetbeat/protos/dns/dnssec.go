@@ -0,0 +1,262 @@
+package dns
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+
+	"github.com/tsg/gopacket/layers"
+)
+
+// DNSTypes of the DNSSEC resource records (RFC 4034, RFC 5155).
+const (
+	dnsTypeDS     layers.DNSType = 43
+	dnsTypeRRSIG  layers.DNSType = 46
+	dnsTypeNSEC   layers.DNSType = 47
+	dnsTypeDNSKEY layers.DNSType = 48
+	dnsTypeNSEC3  layers.DNSType = 50
+)
+
+var errRdataTruncated = errors.New("record data is truncated")
+
+// rdataDecoder decodes the RDATA of a resource record into a MapStr. The
+// "data" key holds the presentation format of the record data.
+type rdataDecoder func(data []byte) (common.MapStr, error)
+
+var rdataDecoders = map[layers.DNSType]rdataDecoder{
+	dnsTypeDS:     decodeDS,
+	dnsTypeRRSIG:  decodeRRSIG,
+	dnsTypeNSEC:   decodeNSEC,
+	dnsTypeDNSKEY: decodeDNSKEY,
+	dnsTypeNSEC3:  decodeNSEC3,
+}
+
+// base32 encoding with the extended hex alphabet used for NSEC3 hashes.
+var base32HexEncoding = base32.NewEncoding("0123456789abcdefghijklmnopqrstuv")
+
+// decodeRRSIG decodes the RDATA of a RRSIG record (RFC 4034, section 3.1).
+func decodeRRSIG(data []byte) (common.MapStr, error) {
+	if len(data) < 18 {
+		return nil, errRdataTruncated
+	}
+
+	typeCovered := dnsTypeToString(layers.DNSType(binary.BigEndian.Uint16(data[0:2])))
+	algorithm := data[2]
+	labels := data[3]
+	originalTtl := binary.BigEndian.Uint32(data[4:8])
+	expiration := binary.BigEndian.Uint32(data[8:12])
+	inception := binary.BigEndian.Uint32(data[12:16])
+	keyTag := binary.BigEndian.Uint16(data[16:18])
+	signerName, offset, err := decodeUncompressedName(data, 18)
+	if err != nil {
+		return nil, err
+	}
+	signature := base64.StdEncoding.EncodeToString(data[offset:])
+
+	return common.MapStr{
+		"type_covered": typeCovered,
+		"algorithm":    algorithm,
+		"labels":       labels,
+		"original_ttl": originalTtl,
+		"expiration":   common.Time(time.Unix(int64(expiration), 0).UTC()),
+		"inception":    common.Time(time.Unix(int64(inception), 0).UTC()),
+		"key_tag":      keyTag,
+		"signer_name":  signerName,
+		"signature":    signature,
+		"data": fmt.Sprintf("%s %d %d %d %s %s %d %s %s", typeCovered,
+			algorithm, labels, originalTtl, dnssecTimeToString(expiration),
+			dnssecTimeToString(inception), keyTag, signerName, signature),
+	}, nil
+}
+
+// decodeDNSKEY decodes the RDATA of a DNSKEY record (RFC 4034, section 2.1).
+func decodeDNSKEY(data []byte) (common.MapStr, error) {
+	if len(data) < 4 {
+		return nil, errRdataTruncated
+	}
+
+	flags := binary.BigEndian.Uint16(data[0:2])
+	protocol := data[2]
+	algorithm := data[3]
+	publicKey := base64.StdEncoding.EncodeToString(data[4:])
+
+	return common.MapStr{
+		"flags":      flags,
+		"protocol":   protocol,
+		"algorithm":  algorithm,
+		"key_tag":    keyTag(data),
+		"public_key": publicKey,
+		"data": fmt.Sprintf("%d %d %d %s", flags, protocol, algorithm,
+			publicKey),
+	}, nil
+}
+
+// decodeDS decodes the RDATA of a DS record (RFC 4034, section 5.1).
+func decodeDS(data []byte) (common.MapStr, error) {
+	if len(data) < 4 {
+		return nil, errRdataTruncated
+	}
+
+	keyTag := binary.BigEndian.Uint16(data[0:2])
+	algorithm := data[2]
+	digestType := data[3]
+	digest := strings.ToUpper(hex.EncodeToString(data[4:]))
+
+	return common.MapStr{
+		"key_tag":     keyTag,
+		"algorithm":   algorithm,
+		"digest_type": digestType,
+		"digest":      digest,
+		"data": fmt.Sprintf("%d %d %d %s", keyTag, algorithm, digestType,
+			digest),
+	}, nil
+}
+
+// decodeNSEC decodes the RDATA of a NSEC record (RFC 4034, section 4.1).
+func decodeNSEC(data []byte) (common.MapStr, error) {
+	nextName, offset, err := decodeUncompressedName(data, 0)
+	if err != nil {
+		return nil, err
+	}
+	types, err := decodeTypeBitmaps(data[offset:])
+	if err != nil {
+		return nil, err
+	}
+
+	return common.MapStr{
+		"next_domain_name": nextName,
+		"types":            types,
+		"data":             strings.TrimSpace(nextName + " " + strings.Join(types, " ")),
+	}, nil
+}
+
+// decodeNSEC3 decodes the RDATA of a NSEC3 record (RFC 5155, section 3.2).
+func decodeNSEC3(data []byte) (common.MapStr, error) {
+	if len(data) < 5 {
+		return nil, errRdataTruncated
+	}
+
+	hashAlgorithm := data[0]
+	flags := data[1]
+	iterations := binary.BigEndian.Uint16(data[2:4])
+
+	offset := 4
+	saltLength := int(data[offset])
+	offset++
+	if len(data) < offset+saltLength+1 {
+		return nil, errRdataTruncated
+	}
+	salt := "-"
+	if saltLength > 0 {
+		salt = strings.ToUpper(hex.EncodeToString(data[offset : offset+saltLength]))
+	}
+	offset += saltLength
+
+	hashLength := int(data[offset])
+	offset++
+	if len(data) < offset+hashLength {
+		return nil, errRdataTruncated
+	}
+	nextHash := base32HexEncoding.EncodeToString(data[offset : offset+hashLength])
+	nextHash = strings.TrimRight(nextHash, "=")
+	offset += hashLength
+
+	types, err := decodeTypeBitmaps(data[offset:])
+	if err != nil {
+		return nil, err
+	}
+
+	return common.MapStr{
+		"hash_algorithm":    hashAlgorithm,
+		"flags":             flags,
+		"iterations":        iterations,
+		"salt":              salt,
+		"next_hashed_owner": nextHash,
+		"types":             types,
+		"data": strings.TrimSpace(fmt.Sprintf("%d %d %d %s %s %s",
+			hashAlgorithm, flags, iterations, salt, nextHash,
+			strings.Join(types, " "))),
+	}, nil
+}
+
+// decodeUncompressedName decodes a domain name starting at offset. DNSSEC
+// records must not use name compression within their RDATA, therefore
+// compression pointers are treated as an error. It returns the name and the
+// offset of the first byte following the name.
+func decodeUncompressedName(data []byte, offset int) (string, int, error) {
+	var labels []string
+	for {
+		if offset >= len(data) {
+			return "", 0, errRdataTruncated
+		}
+		length := int(data[offset])
+		offset++
+		if length == 0 {
+			break
+		}
+		if length&0xc0 != 0 {
+			return "", 0, errors.New("compressed name in record data")
+		}
+		if offset+length > len(data) {
+			return "", 0, errRdataTruncated
+		}
+		labels = append(labels, nameToString(data[offset:offset+length]))
+		offset += length
+	}
+	return strings.Join(labels, "."), offset, nil
+}
+
+// decodeTypeBitmaps decodes the type bit maps field of NSEC and NSEC3
+// records into the list of types it contains.
+func decodeTypeBitmaps(data []byte) ([]string, error) {
+	var types []string
+	for len(data) > 0 {
+		if len(data) < 2 {
+			return nil, errRdataTruncated
+		}
+		window := int(data[0])
+		length := int(data[1])
+		if length == 0 || length > 32 || len(data) < 2+length {
+			return nil, errRdataTruncated
+		}
+
+		for i, b := range data[2 : 2+length] {
+			for bit := 0; bit < 8; bit++ {
+				if b&(0x80>>uint(bit)) != 0 {
+					t := window<<8 | i<<3 | bit
+					types = append(types, dnsTypeToString(layers.DNSType(t)))
+				}
+			}
+		}
+		data = data[2+length:]
+	}
+	return types, nil
+}
+
+// keyTag computes the key tag of a DNSKEY record from its RDATA as
+// described in RFC 4034, appendix B.
+func keyTag(data []byte) uint16 {
+	var ac uint32
+	for i, b := range data {
+		if i&1 == 0 {
+			ac += uint32(b) << 8
+		} else {
+			ac += uint32(b)
+		}
+	}
+	ac += ac >> 16 & 0xffff
+	return uint16(ac & 0xffff)
+}
+
+// dnssecTimeToString formats RRSIG expiration and inception times as
+// YYYYMMDDHHmmSS.
+func dnssecTimeToString(t uint32) string {
+	return time.Unix(int64(t), 0).UTC().Format("20060102150405")
+}
@@ -0,0 +1,230 @@
+// Unit tests for the EDNS0 and DNSSEC record decoding.
+
+package dns
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/tsg/gopacket/layers"
+)
+
+// testRR is a resource record used to build DNS messages for testing.
+type testRR struct {
+	name  string
+	typ   layers.DNSType
+	class uint16
+	ttl   uint32
+	data  []byte
+}
+
+// encodeName encodes a domain name as uncompressed sequence of labels.
+func encodeName(name string) []byte {
+	var b []byte
+	for _, label := range strings.Split(name, ".") {
+		if label == "" {
+			continue
+		}
+		b = append(b, byte(len(label)))
+		b = append(b, label...)
+	}
+	return append(b, 0)
+}
+
+// buildDnsMessage encodes a DNS message with a single question of class IN.
+func buildDnsMessage(id uint16, flags uint16, qname string, qtype layers.DNSType,
+	answers []testRR, additionals []testRR) []byte {
+
+	b := make([]byte, 12)
+	binary.BigEndian.PutUint16(b[0:2], id)
+	binary.BigEndian.PutUint16(b[2:4], flags)
+	binary.BigEndian.PutUint16(b[4:6], 1)
+	binary.BigEndian.PutUint16(b[6:8], uint16(len(answers)))
+	binary.BigEndian.PutUint16(b[10:12], uint16(len(additionals)))
+
+	b = append(b, encodeName(qname)...)
+	b = append(b, byte(qtype>>8), byte(qtype), 0, 1)
+
+	for _, rr := range append(answers, additionals...) {
+		b = append(b, encodeName(rr.name)...)
+		field := make([]byte, 10)
+		binary.BigEndian.PutUint16(field[0:2], uint16(rr.typ))
+		binary.BigEndian.PutUint16(field[2:4], rr.class)
+		binary.BigEndian.PutUint32(field[4:8], rr.ttl)
+		binary.BigEndian.PutUint16(field[8:10], uint16(len(rr.data)))
+		b = append(b, field...)
+		b = append(b, rr.data...)
+	}
+	return b
+}
+
+// optRR returns an OPT record advertising a UDP size of 4096 bytes with the
+// DO bit set and a client subnet option for 192.0.2.0/24.
+func optRR(extRcode uint8) testRR {
+	return testRR{
+		typ:   dnsTypeOPT,
+		class: 4096,
+		ttl:   uint32(extRcode)<<24 | 0x8000,
+		data:  []byte{0, 8, 0, 7, 0, 1, 24, 0, 192, 0, 2},
+	}
+}
+
+// Verify that the OPT record of a request and response is decoded.
+func TestParseUdp_edns(t *testing.T) {
+	dns := newDns(testing.Verbose())
+
+	q := buildDnsMessage(1, 0x0120, "example.com", layers.DNSTypeA,
+		nil, []testRR{optRR(0)})
+	r := buildDnsMessage(1, 0x81a0, "example.com", layers.DNSTypeA,
+		[]testRR{{"example.com", layers.DNSTypeA, 1, 60, []byte{192, 0, 2, 1}}},
+		[]testRR{optRR(0)})
+
+	dns.ParseUdp(newPacket(forward, q))
+	dns.ParseUdp(newPacket(reverse, r))
+	m := expectResult(t, dns)
+
+	assert.Equal(t, "NOERROR", mapValue(t, m, "dns.response_code"))
+	assert.Equal(t, common.OK_STATUS, mapValue(t, m, "status"))
+	assert.Equal(t, true, mapValue(t, m, "dns.flags.authentic_data"))
+	assert.Equal(t, false, mapValue(t, m, "dns.flags.checking_disabled"))
+	assert.Equal(t, uint8(0), mapValue(t, m, "dns.opt.version"))
+	assert.Equal(t, uint16(4096), mapValue(t, m, "dns.opt.udp_size"))
+	assert.Equal(t, true, mapValue(t, m, "dns.opt.do"))
+	assert.Equal(t, "192.0.2.0/24", mapValue(t, m, "dns.opt.client_subnet"))
+	assert.Equal(t, len(r), mapValue(t, m, "dns.response_size"))
+	assert.Equal(t, []interface{}{"OPT"}, mapValue(t, m, "dns.additionals.type"))
+	assert.Contains(t, mapValue(t, m, "request"), "FLAGS rd ad;")
+}
+
+// Verify that the extended response code of the OPT record is used.
+func TestParseUdp_ednsExtendedRcode(t *testing.T) {
+	dns := newDns(testing.Verbose())
+
+	q := buildDnsMessage(2, 0x0100, "example.com", layers.DNSTypeA,
+		nil, []testRR{optRR(0)})
+	r := buildDnsMessage(2, 0x8180, "example.com", layers.DNSTypeA,
+		nil, []testRR{optRR(1)})
+
+	dns.ParseUdp(newPacket(forward, q))
+	dns.ParseUdp(newPacket(reverse, r))
+	m := expectResult(t, dns)
+
+	assert.Equal(t, "BADVERS", mapValue(t, m, "dns.response_code"))
+	assert.Equal(t, uint8(1), mapValue(t, m, "dns.opt.ext_rcode"))
+	assert.Equal(t, common.ERROR_STATUS, mapValue(t, m, "status"))
+}
+
+// Verify that DNSSEC records are decoded into structured fields.
+func TestParseUdp_dnssecRecords(t *testing.T) {
+	dns := newDns(testing.Verbose())
+
+	dnskey := []byte{1, 1, 3, 8, 0xaa, 0xbb, 0xcc}
+	ds := []byte{0x7a, 0xc1, 8, 2, 0xde, 0xad, 0xbe, 0xef}
+	q := buildDnsMessage(3, 0x0100, "example.com", layers.DNSTypeA, nil, nil)
+	r := buildDnsMessage(3, 0x8180, "example.com", layers.DNSTypeA,
+		[]testRR{
+			{"example.com", dnsTypeDNSKEY, 1, 3600, dnskey},
+			{"example.com", dnsTypeDS, 1, 3600, ds},
+		}, nil)
+
+	dns.ParseUdp(newPacket(forward, q))
+	dns.ParseUdp(newPacket(reverse, r))
+	m := expectResult(t, dns)
+
+	assert.Equal(t, []interface{}{"DNSKEY", "DS"}, mapValue(t, m, "dns.answers.type"))
+	assert.Equal(t, []interface{}{"257 3 8 qrvM", "31425 8 2 DEADBEEF"},
+		mapValue(t, m, "dns.answers.data"))
+	assert.Equal(t, []interface{}{uint16(257), nil}, mapValue(t, m, "dns.answers.flags"))
+	assert.Equal(t, []interface{}{"qrvM", nil}, mapValue(t, m, "dns.answers.public_key"))
+	assert.Equal(t, []interface{}{nil, "DEADBEEF"}, mapValue(t, m, "dns.answers.digest"))
+	assert.Contains(t, mapValue(t, m, "response"), "type DS, 31425 8 2 DEADBEEF")
+}
+
+func TestDecodeRRSIG(t *testing.T) {
+	expiration := time.Date(2003, 3, 22, 17, 31, 3, 0, time.UTC)
+	inception := time.Date(2003, 2, 20, 17, 31, 3, 0, time.UTC)
+
+	data := []byte{0, 1, 5, 3, 0, 1, 0x51, 0x80}
+	data = append(data, 0, 0, 0, 0, 0, 0, 0, 0, 0x0a, 0x52)
+	binary.BigEndian.PutUint32(data[8:12], uint32(expiration.Unix()))
+	binary.BigEndian.PutUint32(data[12:16], uint32(inception.Unix()))
+	data = append(data, encodeName("example.com")...)
+	data = append(data, 0x01, 0x02, 0x03)
+
+	m, err := decodeRRSIG(data)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "A", m["type_covered"])
+		assert.Equal(t, uint8(5), m["algorithm"])
+		assert.Equal(t, uint8(3), m["labels"])
+		assert.Equal(t, uint32(86400), m["original_ttl"])
+		assert.Equal(t, common.Time(expiration), m["expiration"])
+		assert.Equal(t, common.Time(inception), m["inception"])
+		assert.Equal(t, uint16(2642), m["key_tag"])
+		assert.Equal(t, "example.com", m["signer_name"])
+		assert.Equal(t, "AQID", m["signature"])
+		assert.Equal(t, "A 5 3 86400 20030322173103 20030220173103 2642 example.com AQID",
+			m["data"])
+	}
+
+	_, err = decodeRRSIG(data[:10])
+	assert.Error(t, err)
+}
+
+func TestDecodeNSEC(t *testing.T) {
+	// A, MX, RRSIG, NSEC and TYPE1234
+	data := encodeName("host.example.com")
+	data = append(data, 0, 6, 0x40, 0x01, 0, 0, 0, 0x03, 4, 27)
+	data = append(data, make([]byte, 27)...)
+	data[len(data)-1] = 0x20
+
+	m, err := decodeNSEC(data)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "host.example.com", m["next_domain_name"])
+		assert.Equal(t, []string{"A", "MX", "RRSIG", "NSEC", "1234"}, m["types"])
+		assert.Equal(t, "host.example.com A MX RRSIG NSEC 1234", m["data"])
+	}
+
+	_, err = decodeNSEC(encodeName("host")[:3])
+	assert.Error(t, err)
+}
+
+func TestDecodeNSEC3(t *testing.T) {
+	data := []byte{1, 1, 0, 12, 4, 0xaa, 0xbb, 0xcc, 0xdd, 5, 0, 0, 0, 0, 0, 0, 1, 0x22}
+
+	m, err := decodeNSEC3(data)
+	if assert.NoError(t, err) {
+		assert.Equal(t, uint8(1), m["hash_algorithm"])
+		assert.Equal(t, uint8(1), m["flags"])
+		assert.Equal(t, uint16(12), m["iterations"])
+		assert.Equal(t, "AABBCCDD", m["salt"])
+		assert.Equal(t, "00000000", m["next_hashed_owner"])
+		assert.Equal(t, []string{"NS", "SOA"}, m["types"])
+		assert.Equal(t, "1 1 12 AABBCCDD 00000000 NS SOA", m["data"])
+	}
+
+	_, err = decodeNSEC3(data[:7])
+	assert.Error(t, err)
+}
+
+// Verify the key tag computation of RFC 4034, appendix B.
+func TestKeyTag(t *testing.T) {
+	assert.Equal(t, uint16(31425), keyTag([]byte{1, 0, 3, 5, 0xaa, 0xbb, 0xcc}))
+}
+
+func TestDecodeOpt_invalidClientSubnet(t *testing.T) {
+	rr := &layers.DNSResourceRecord{
+		Type:  dnsTypeOPT,
+		Class: 512,
+		Data:  []byte{0, 8, 0, 5, 0, 3, 24, 0, 192},
+	}
+	_, err := decodeOpt(rr)
+	assert.Error(t, err)
+
+	rr.Data = []byte{0, 8, 0, 7}
+	_, err = decodeOpt(rr)
+	assert.Error(t, err)
+}
@@ -0,0 +1,158 @@
+package dns
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/elastic/beats/libbeat/common"
+
+	"github.com/tsg/gopacket/layers"
+)
+
+// DNSType of the EDNS0 OPT pseudo resource record (RFC 6891).
+const dnsTypeOPT layers.DNSType = 41
+
+// EDNS0 option codes.
+const (
+	ednsOptionClientSubnet = 8 // Client Subnet [RFC7871]
+)
+
+// Response code signalling an unsupported EDNS version. It shares the
+// value 16 with BADSIG, which is only used in TSIG records.
+const rcodeBadVers = 16
+
+var errOptTruncated = errors.New("OPT record data is truncated")
+
+// ednsOpt contains the data of an EDNS0 OPT pseudo resource record.
+type ednsOpt struct {
+	UdpSize      uint16 // Requestor's UDP payload size, stored in the class.
+	ExtRcode     uint8  // Upper 8 bits of the extended 12-bit response code.
+	Version      uint8
+	Do           bool   // DNSSEC OK bit.
+	ClientSubnet string // Client subnet option in CIDR notation.
+}
+
+// getOpt returns the OPT pseudo resource record contained in the additional
+// section of the given message. Nil is returned if the message does not
+// use EDNS0.
+func getOpt(dns *layers.DNS) *layers.DNSResourceRecord {
+	for i := range dns.Additionals {
+		if dns.Additionals[i].Type == dnsTypeOPT {
+			return &dns.Additionals[i]
+		}
+	}
+	return nil
+}
+
+// decodeOpt decodes the fields of an OPT pseudo resource record. The
+// extended response code, version and flags are stored in the TTL field.
+func decodeOpt(rr *layers.DNSResourceRecord) (*ednsOpt, error) {
+	opt := &ednsOpt{
+		UdpSize:  uint16(rr.Class),
+		ExtRcode: uint8(rr.TTL >> 24),
+		Version:  uint8(rr.TTL >> 16),
+		Do:       rr.TTL&0x8000 != 0,
+	}
+
+	data := rr.Data
+	for len(data) > 0 {
+		if len(data) < 4 {
+			return opt, errOptTruncated
+		}
+		code := binary.BigEndian.Uint16(data[0:2])
+		length := int(binary.BigEndian.Uint16(data[2:4]))
+		if len(data) < 4+length {
+			return opt, errOptTruncated
+		}
+
+		if code == ednsOptionClientSubnet {
+			subnet, err := decodeClientSubnet(data[4 : 4+length])
+			if err != nil {
+				return opt, err
+			}
+			opt.ClientSubnet = subnet
+		}
+		data = data[4+length:]
+	}
+
+	return opt, nil
+}
+
+// decodeClientSubnet decodes the client subnet option data into a subnet
+// in CIDR notation. The address is truncated to the source prefix length.
+func decodeClientSubnet(data []byte) (string, error) {
+	if len(data) < 4 {
+		return "", errOptTruncated
+	}
+
+	var ip net.IP
+	switch family := binary.BigEndian.Uint16(data[0:2]); family {
+	case 1:
+		ip = make(net.IP, net.IPv4len)
+	case 2:
+		ip = make(net.IP, net.IPv6len)
+	default:
+		return "", fmt.Errorf("unknown client subnet address family %d", family)
+	}
+
+	prefix := int(data[2])
+	addr := data[4:]
+	if len(addr) > len(ip) || prefix > len(ip)*8 {
+		return "", fmt.Errorf("invalid client subnet address length %d", len(addr))
+	}
+	copy(ip, addr)
+
+	return fmt.Sprintf("%s/%d", ip, prefix), nil
+}
+
+// extendedResponseCode returns the response code of the message. If the
+// message contains an OPT record then the upper 8 bits of the 12-bit
+// extended response code are taken from the OPT record.
+func extendedResponseCode(dns *layers.DNS) (rcode int, edns bool) {
+	rcode = int(dns.ResponseCode)
+	rr := getOpt(dns)
+	if rr == nil {
+		return rcode, false
+	}
+	opt, _ := decodeOpt(rr)
+	return int(opt.ExtRcode)<<4 | rcode, true
+}
+
+// responseCodeToString converts the (extended) response code of the message
+// to a string.
+func responseCodeToString(dns *layers.DNS) string {
+	rcode, edns := extendedResponseCode(dns)
+	if edns && rcode == rcodeBadVers {
+		return "BADVERS"
+	}
+	if rcode > 0xff {
+		return fmt.Sprintf("Unknown %d", rcode)
+	}
+	return dnsResponseCodeToString(layers.DNSResponseCode(rcode))
+}
+
+// optToMapStr converts the OPT record data to a MapStr.
+func optToMapStr(opt *ednsOpt) common.MapStr {
+	m := common.MapStr{
+		"version":   opt.Version,
+		"udp_size":  opt.UdpSize,
+		"do":        opt.Do,
+		"ext_rcode": opt.ExtRcode,
+	}
+	if opt.ClientSubnet != "" {
+		m["client_subnet"] = opt.ClientSubnet
+	}
+	return m
+}
+
+// optToString converts the OPT record data to a string.
+func optToString(opt *ednsOpt) string {
+	s := fmt.Sprintf("version %d, do %t, ext_rcode %d, udp_size %d",
+		opt.Version, opt.Do, opt.ExtRcode, opt.UdpSize)
+	if opt.ClientSubnet != "" {
+		s += ", client_subnet " + opt.ClientSubnet
+	}
+	return s
+}
@@ -0,0 +1,27 @@
+package protos
+
+import (
+	"errors"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+// TransactionID derives the ID of a transaction event from its type, the
+// client and server addresses and the time of the request. It is installed
+// as event ID function of the publisher, so a transaction published more than
+// once is indexed only once.
+func TransactionID(event common.MapStr) (string, error) {
+	ts, ok := event["@timestamp"].(common.Time)
+	if !ok {
+		return "", errors.New("transaction has no valid @timestamp")
+	}
+
+	return common.EventID(
+		event["type"],
+		event["transport"],
+		event["client_ip"], event["client_port"],
+		event["ip"], event["port"],
+		time.Time(ts).UTC().Format(time.RFC3339Nano),
+	), nil
+}
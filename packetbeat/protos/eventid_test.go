@@ -0,0 +1,45 @@
+package protos
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func transactionEvent(ts time.Time, clientPort int) common.MapStr {
+	return common.MapStr{
+		"@timestamp":  common.Time(ts),
+		"type":        "http",
+		"transport":   "tcp",
+		"client_ip":   "192.168.0.1",
+		"client_port": clientPort,
+		"ip":          "192.168.0.2",
+		"port":        80,
+		"status":      common.OK_STATUS,
+	}
+}
+
+func TestTransactionID(t *testing.T) {
+	ts := time.Date(2015, 12, 1, 10, 0, 0, 123456789, time.UTC)
+
+	id, err := TransactionID(transactionEvent(ts, 50000))
+	assert.NoError(t, err)
+	assert.Len(t, id, 40)
+
+	// the same transaction published again gets the same ID
+	again, err := TransactionID(transactionEvent(ts.In(time.Local), 50000))
+	assert.NoError(t, err)
+	assert.Equal(t, id, again)
+
+	other, _ := TransactionID(transactionEvent(ts, 50001))
+	assert.NotEqual(t, id, other, "different client port")
+	other, _ = TransactionID(transactionEvent(ts.Add(time.Nanosecond), 50000))
+	assert.NotEqual(t, id, other, "different request time")
+
+	event := transactionEvent(ts, 50000)
+	delete(event, "@timestamp")
+	_, err = TransactionID(event)
+	assert.Error(t, err)
+}
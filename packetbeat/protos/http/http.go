@@ -20,6 +20,16 @@ import (
 var debugf = logp.MakeDebug("http")
 var detailedf = logp.MakeDebug("httpdetailed")
 
+func init() {
+	protos.Register(protos.ProtocolInfo{
+		Name:         "http",
+		Tcp:          true,
+		DefaultPorts: []int{80, 8080, 8000, 5000, 8002},
+		Config:       func() interface{} { return &config.Http{} },
+		New:          New,
+	})
+}
+
 type parserState uint8
 
 const (
@@ -82,6 +92,7 @@ type HTTP struct {
 	SplitCookie         bool
 	HideKeywords        []string
 	RedactAuthorization bool
+	IncludeBodyFor      []string
 
 	parserConfig parserConfig
 
@@ -117,6 +128,7 @@ func (http *HTTP) setFromConfig(config config.Http) (err error) {
 		http.SendResponse = *config.SendResponse
 	}
 	http.HideKeywords = config.Hide_keywords
+	http.IncludeBodyFor = config.Include_body_for
 	if config.Redact_authorization != nil {
 		http.RedactAuthorization = *config.Redact_authorization
 	}
@@ -155,12 +167,32 @@ func (http *HTTP) GetPorts() []int {
 	return http.Ports
 }
 
-// Init initializes the HTTP protocol analyser.
+// New creates a HTTP protocol analyser from its configuration.
+func New(cfg interface{}, testMode bool, results publisher.Client) (protos.ProtocolPlugin, error) {
+	http := &HTTP{}
+	if err := http.InitWithConfig(*cfg.(*config.Http), testMode, results); err != nil {
+		return nil, err
+	}
+	return http, nil
+}
+
+// Init initializes the HTTP protocol analyser with the default
+// configuration.
 func (http *HTTP) Init(testMode bool, results publisher.Client) error {
+	return http.InitWithConfig(config.Http{}, testMode, results)
+}
+
+// InitWithConfig initializes the HTTP protocol analyser. The configuration
+// is ignored in test mode.
+func (http *HTTP) InitWithConfig(
+	config config.Http,
+	testMode bool,
+	results publisher.Client,
+) error {
 	http.initDefaults()
 
 	if !testMode {
-		err := http.setFromConfig(config.ConfigSingleton.Protocols.Http)
+		err := http.setFromConfig(config)
 		if err != nil {
 			return err
 		}
@@ -236,6 +268,37 @@ func (http *HTTP) ConnectionTimeout() time.Duration {
 	return http.transactionTimeout
 }
 
+// detectPrefixes lists the request methods and the response version prefix
+// used to detect HTTP on unknown ports.
+var detectPrefixes = [][]byte{
+	[]byte("GET "),
+	[]byte("POST "),
+	[]byte("PUT "),
+	[]byte("HEAD "),
+	[]byte("DELETE "),
+	[]byte("OPTIONS "),
+	[]byte("PATCH "),
+	[]byte("TRACE "),
+	[]byte("CONNECT "),
+	[]byte("HTTP/1."),
+}
+
+// DetectTcp checks if a TCP stream on an unknown port starts with a HTTP
+// request or status line.
+func (http *HTTP) DetectTcp(data []byte, dir uint8) protos.DetectResult {
+	result := protos.DetectNoMatch
+	for _, prefix := range detectPrefixes {
+		if len(data) >= len(prefix) {
+			if bytes.HasPrefix(data, prefix) {
+				return protos.DetectMatch
+			}
+		} else if bytes.HasPrefix(prefix, data) {
+			result = protos.DetectNeedMoreData
+		}
+	}
+	return result
+}
+
 // Parse function is used to process TCP payloads.
 func (http *HTTP) Parse(pkt *protos.Packet, tcptuple *common.TcpTuple,
 	dir uint8, private protos.ProtocolData) protos.ProtocolData {
@@ -593,8 +656,7 @@ func (http *HTTP) cutMessageBody(m *message) []byte {
 }
 
 func (http *HTTP) shouldIncludeInBody(contenttype string) bool {
-	includedBodies := config.ConfigSingleton.Protocols.Http.Include_body_for
-	for _, include := range includedBodies {
+	for _, include := range http.IncludeBodyFor {
 		if strings.Contains(contenttype, include) {
 			debugf("Should Include Body = true Content-Type " + contenttype + " include_body " + include)
 			return true
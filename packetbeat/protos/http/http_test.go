@@ -1099,3 +1099,23 @@ func TestHttp_configsSettingHeaders(t *testing.T) {
 		assert.True(t, val)
 	}
 }
+
+func TestHttpDetectTcp(t *testing.T) {
+	http := httpModForTests()
+
+	tests := []struct {
+		data   string
+		result protos.DetectResult
+	}{
+		{"GET /index.html HTTP/1.1\r\n", protos.DetectMatch},
+		{"POST /api HTTP/1.0\r\n", protos.DetectMatch},
+		{"HTTP/1.1 200 OK\r\n", protos.DetectMatch},
+		{"GE", protos.DetectNeedMoreData},
+		{"HTTP/", protos.DetectNeedMoreData},
+		{"GETS / HTTP/1.1\r\n", protos.DetectNoMatch},
+		{"*2\r\n$3\r\nGET\r\n", protos.DetectNoMatch},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.result, http.DetectTcp([]byte(test.data), 0), test.data)
+	}
+}
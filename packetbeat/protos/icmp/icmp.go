@@ -54,7 +54,11 @@ func NewIcmp(testMode bool, results publisher.Client) (*Icmp, error) {
 	icmp.initDefaults()
 
 	if !testMode {
-		err := icmp.setFromConfig(config.ConfigSingleton.Protocols.Icmp)
+		cfg, err := config.ConfigSingleton.Protocols.Icmp()
+		if err != nil {
+			return nil, err
+		}
+		err = icmp.setFromConfig(cfg)
 		if err != nil {
 			return nil, err
 		}
@@ -16,6 +16,17 @@ import (
 	"github.com/elastic/beats/packetbeat/protos/applayer"
 )
 
+func init() {
+	protos.Register(protos.ProtocolInfo{
+		Name:         "memcache",
+		Tcp:          true,
+		Udp:          true,
+		DefaultPorts: []int{11211},
+		Config:       func() interface{} { return &config.Memcache{} },
+		New:          New,
+	})
+}
+
 // memcache types
 type Memcache struct {
 	Ports   protos.PortsConfig
@@ -101,13 +112,18 @@ type memcacheStat struct {
 var debug = logp.MakeDebug("memcache")
 
 // Called to initialize the Plugin
+// New creates a memcache plugin from its configuration.
+func New(cfg interface{}, testMode bool, results publisher.Client) (protos.ProtocolPlugin, error) {
+	mc := &Memcache{}
+	if err := mc.InitWithConfig(*cfg.(*config.Memcache), testMode, results); err != nil {
+		return nil, err
+	}
+	return mc, nil
+}
+
 func (mc *Memcache) Init(testMode bool, results publisher.Client) error {
 	debug("init memcache plugin")
-	return mc.InitWithConfig(
-		config.ConfigSingleton.Protocols.Memcache,
-		testMode,
-		results,
-	)
+	return mc.InitWithConfig(config.Memcache{}, testMode, results)
 }
 
 func (mc *Memcache) InitDefaults() {
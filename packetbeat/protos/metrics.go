@@ -0,0 +1,47 @@
+package protos
+
+import (
+	"expvar"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/publisher"
+)
+
+// Metrics that can be retrieved through the expvar web interface.
+var (
+	// number of transactions published by protocol
+	publishedTransactions = expvar.NewMap("packetbeatTransactions")
+)
+
+// transactionCounter counts the transactions published by a protocol plugin.
+type transactionCounter struct {
+	name    string
+	results publisher.Client
+}
+
+// CountTransactions returns a client counting all events published through
+// results as transactions of the named protocol.
+func CountTransactions(name string, results publisher.Client) publisher.Client {
+	if results == nil {
+		return nil
+	}
+
+	publishedTransactions.Add(name, 0)
+	return &transactionCounter{name: name, results: results}
+}
+
+func (c *transactionCounter) PublishEvent(
+	event common.MapStr,
+	opts ...publisher.ClientOption,
+) bool {
+	publishedTransactions.Add(c.name, 1)
+	return c.results.PublishEvent(event, opts...)
+}
+
+func (c *transactionCounter) PublishEvents(
+	events []common.MapStr,
+	opts ...publisher.ClientOption,
+) bool {
+	publishedTransactions.Add(c.name, int64(len(events)))
+	return c.results.PublishEvents(events, opts...)
+}
@@ -0,0 +1,27 @@
+package protos
+
+import (
+	"testing"
+
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/publisher"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCountTransactions(t *testing.T) {
+	events := make(chan common.MapStr, 10)
+	results := CountTransactions("countTest", publisher.ChanClient{Channel: events})
+
+	assert.Equal(t, "0", publishedTransactions.Get("countTest").String())
+
+	assert.True(t, results.PublishEvent(common.MapStr{"type": "countTest"}))
+	assert.True(t, results.PublishEvents([]common.MapStr{{}, {}}))
+
+	assert.Len(t, events, 3)
+	assert.Equal(t, "3", publishedTransactions.Get("countTest").String())
+}
+
+func TestCountTransactionsNil(t *testing.T) {
+	assert.Nil(t, CountTransactions("nilTest", nil))
+}
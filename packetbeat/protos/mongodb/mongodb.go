@@ -1,6 +1,7 @@
 package mongodb
 
 import (
+	"encoding/binary"
 	"fmt"
 	"strings"
 	"time"
@@ -16,6 +17,16 @@ import (
 
 var debugf = logp.MakeDebug("mongodb")
 
+func init() {
+	protos.Register(protos.ProtocolInfo{
+		Name:         "mongodb",
+		Tcp:          true,
+		DefaultPorts: []int{27017},
+		Config:       func() interface{} { return &config.Mongodb{} },
+		New:          New,
+	})
+}
+
 type Mongodb struct {
 	// config
 	Ports        []int
@@ -69,12 +80,29 @@ func (mongodb *Mongodb) GetPorts() []int {
 	return mongodb.Ports
 }
 
+// New creates a MongoDB protocol plugin from its configuration.
+func New(cfg interface{}, testMode bool, results publisher.Client) (protos.ProtocolPlugin, error) {
+	mongodb := &Mongodb{}
+	if err := mongodb.InitWithConfig(*cfg.(*config.Mongodb), testMode, results); err != nil {
+		return nil, err
+	}
+	return mongodb, nil
+}
+
 func (mongodb *Mongodb) Init(test_mode bool, results publisher.Client) error {
+	return mongodb.InitWithConfig(config.Mongodb{}, test_mode, results)
+}
+
+func (mongodb *Mongodb) InitWithConfig(
+	config config.Mongodb,
+	test_mode bool,
+	results publisher.Client,
+) error {
 	debugf("Init a MongoDB protocol parser")
 
 	mongodb.InitDefaults()
 	if !test_mode {
-		err := mongodb.setFromConfig(config.ConfigSingleton.Protocols.Mongodb)
+		err := mongodb.setFromConfig(config)
 		if err != nil {
 			return err
 		}
@@ -97,6 +125,27 @@ func (mongodb *Mongodb) ConnectionTimeout() time.Duration {
 	return mongodb.transactionTimeout
 }
 
+// DetectTcp checks if a TCP stream on an unknown port starts with a valid
+// MongoDB message header.
+func (mongodb *Mongodb) DetectTcp(data []byte, dir uint8) protos.DetectResult {
+	if len(data) < 16 {
+		return protos.DetectNeedMoreData
+	}
+
+	length := int32(binary.LittleEndian.Uint32(data[0:4]))
+	responseTo := int32(binary.LittleEndian.Uint32(data[8:12]))
+	code := opCode(binary.LittleEndian.Uint32(data[12:16]))
+	if length < 16 || length > 48*1024*1024 || !validOpcode(code) {
+		return protos.DetectNoMatch
+	}
+
+	// requests are not a response to another message
+	if code != opReply && responseTo > 0 {
+		return protos.DetectNoMatch
+	}
+	return protos.DetectMatch
+}
+
 func (mongodb *Mongodb) Parse(
 	pkt *protos.Packet,
 	tcptuple *common.TcpTuple,
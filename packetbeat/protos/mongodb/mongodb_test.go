@@ -330,3 +330,26 @@ func TestMaxDocSize(t *testing.T) {
 
 	assert.Equal(t, "\"1234 ...\n\"123\"\n\"12\"", res["response"])
 }
+
+func TestDetectTcp(t *testing.T) {
+	mongodb := MongodbModForTests()
+
+	// OP_QUERY request from tests/pcaps/mongo_one_row.pcap
+	req_data, err := hex.DecodeString(
+		"320000000a000000ffffffffd4070000" +
+			"00000000746573742e72667374617572" +
+			"616e7473000000000001000000050000" +
+			"0000")
+	assert.Nil(t, err)
+
+	assert.Equal(t, protos.DetectMatch, mongodb.DetectTcp(req_data, 0))
+	assert.Equal(t, protos.DetectNeedMoreData, mongodb.DetectTcp(req_data[:10], 0))
+
+	// unknown opcode
+	invalid := append([]byte{}, req_data...)
+	invalid[12] = 0xff
+	assert.Equal(t, protos.DetectNoMatch, mongodb.DetectTcp(invalid, 0))
+
+	assert.Equal(t, protos.DetectNoMatch,
+		mongodb.DetectTcp([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n"), 0))
+}
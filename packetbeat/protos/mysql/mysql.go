@@ -13,6 +13,7 @@ import (
 	"github.com/elastic/beats/packetbeat/config"
 	"github.com/elastic/beats/packetbeat/procs"
 	"github.com/elastic/beats/packetbeat/protos"
+	"github.com/elastic/beats/packetbeat/protos/sqlnorm"
 	"github.com/elastic/beats/packetbeat/protos/tcp"
 )
 
@@ -111,6 +112,16 @@ func (state parseState) String() string {
 	return stateStrings[state]
 }
 
+func init() {
+	protos.Register(protos.ProtocolInfo{
+		Name:         "mysql",
+		Tcp:          true,
+		DefaultPorts: []int{3306},
+		Config:       func() interface{} { return &config.Mysql{} },
+		New:          New,
+	})
+}
+
 type Mysql struct {
 
 	// config
@@ -120,6 +131,9 @@ type Mysql struct {
 	Send_request  bool
 	Send_response bool
 
+	Normalize_queries bool
+	Drop_raw_queries  bool
+
 	transactions       *common.Cache
 	transactionTimeout time.Duration
 
@@ -143,6 +157,8 @@ func (mysql *Mysql) InitDefaults() {
 	mysql.maxStoreRows = 10
 	mysql.Send_request = false
 	mysql.Send_response = false
+	mysql.Normalize_queries = false
+	mysql.Drop_raw_queries = false
 	mysql.transactionTimeout = protos.DefaultTransactionExpiration
 }
 
@@ -162,6 +178,12 @@ func (mysql *Mysql) setFromConfig(config config.Mysql) error {
 	if config.SendResponse != nil {
 		mysql.Send_response = *config.SendResponse
 	}
+	if config.Normalize_queries != nil {
+		mysql.Normalize_queries = *config.Normalize_queries
+	}
+	if config.Drop_raw_queries != nil {
+		mysql.Drop_raw_queries = *config.Drop_raw_queries
+	}
 	if config.TransactionTimeout != nil && *config.TransactionTimeout > 0 {
 		mysql.transactionTimeout = time.Duration(*config.TransactionTimeout) * time.Second
 	}
@@ -172,11 +194,28 @@ func (mysql *Mysql) GetPorts() []int {
 	return mysql.Ports
 }
 
+// New creates a MySQL protocol plugin from its configuration.
+func New(cfg interface{}, testMode bool, results publisher.Client) (protos.ProtocolPlugin, error) {
+	mysql := &Mysql{}
+	if err := mysql.InitWithConfig(*cfg.(*config.Mysql), testMode, results); err != nil {
+		return nil, err
+	}
+	return mysql, nil
+}
+
 func (mysql *Mysql) Init(test_mode bool, results publisher.Client) error {
+	return mysql.InitWithConfig(config.Mysql{}, test_mode, results)
+}
+
+func (mysql *Mysql) InitWithConfig(
+	config config.Mysql,
+	test_mode bool,
+	results publisher.Client,
+) error {
 
 	mysql.InitDefaults()
 	if !test_mode {
-		err := mysql.setFromConfig(config.ConfigSingleton.Protocols.Mysql)
+		err := mysql.setFromConfig(config)
 		if err != nil {
 			return err
 		}
@@ -478,6 +517,37 @@ func (mysql *Mysql) ConnectionTimeout() time.Duration {
 	return mysql.transactionTimeout
 }
 
+// DetectTcp checks if a TCP stream on an unknown port starts with the MySQL
+// server greeting (protocol version 10) sent by the server on connect.
+func (mysql *Mysql) DetectTcp(data []byte, dir uint8) protos.DetectResult {
+	// 3 bytes length, 1 byte sequence ID, 1 byte protocol version
+	if len(data) < 5 {
+		return protos.DetectNeedMoreData
+	}
+
+	length := int(data[0]) | int(data[1])<<8 | int(data[2])<<16
+	if data[3] != 0 || data[4] != 10 || length < 2 || length > 1024 {
+		return protos.DetectNoMatch
+	}
+
+	// the server version is a null terminated string starting with a digit
+	if len(data) < 6 {
+		return protos.DetectNeedMoreData
+	}
+	if data[5] < '0' || data[5] > '9' {
+		return protos.DetectNoMatch
+	}
+	for i := 6; i < len(data) && i < length+4; i++ {
+		if data[i] == 0 {
+			return protos.DetectMatch
+		}
+	}
+	if len(data) < length+4 {
+		return protos.DetectNeedMoreData
+	}
+	return protos.DetectNoMatch
+}
+
 func (mysql *Mysql) Parse(pkt *protos.Packet, tcptuple *common.TcpTuple,
 	dir uint8, private protos.ProtocolData) protos.ProtocolData {
 
@@ -635,6 +705,17 @@ func (mysql *Mysql) receivedMysqlRequest(msg *MysqlMessage) {
 
 	// save Raw message
 	trans.Request_raw = msg.Query
+
+	// The raw query is replaced by the normalized query if it must not
+	// be published.
+	if mysql.Normalize_queries || mysql.Drop_raw_queries {
+		stmt := sqlnorm.Normalize(query, sqlnorm.MySQL)
+		trans.Mysql.Update(stmt.ToMapStr())
+		if mysql.Drop_raw_queries {
+			trans.Query = stmt.Query
+			trans.Request_raw = stmt.Query
+		}
+	}
 	trans.BytesIn = msg.Size
 }
 
@@ -627,3 +627,59 @@ func Test_parseMysqlResponse_invalid(t *testing.T) {
 		assert.Equal(t, [][]string{}, rows)
 	}
 }
+
+func TestMySQLDetectTcp(t *testing.T) {
+	mysql := MysqlModForTests()
+
+	// server greeting sent by MySQL 5.5
+	greeting, err := hex.DecodeString(
+		"4a0000000a352e352e34342d307562756e7475302e31342e3400" +
+			"2d0000004d5a3c6c3f37662800fff7080200" +
+			"0f8015000000000000000000004b2a7c4f263c3f6b" +
+			"79555e24006d7973716c5f6e61746976655f70617373776f726400")
+	assert.Nil(t, err)
+
+	assert.Equal(t, protos.DetectMatch, mysql.DetectTcp(greeting, 1))
+	assert.Equal(t, protos.DetectNeedMoreData, mysql.DetectTcp(greeting[:4], 1))
+	assert.Equal(t, protos.DetectNeedMoreData, mysql.DetectTcp(greeting[:10], 1))
+	assert.Equal(t, protos.DetectNoMatch,
+		mysql.DetectTcp([]byte("GET / HTTP/1.1\r\n"), 0))
+}
+
+// Test that the query is normalized and the raw query dropped if configured.
+func TestMySQL_normalizeQuery(t *testing.T) {
+	if testing.Verbose() {
+		logp.LogInit(logp.LOG_DEBUG, "", false, true, []string{"mysql", "mysqldetailed"})
+	}
+
+	mysql := MysqlModForTests()
+	mysql.Send_request = true
+	mysql.Drop_raw_queries = true
+
+	query := "SELECT * FROM test WHERE name = 'secret' AND id IN (1, 2)"
+	req_data := append([]byte{byte(len(query) + 1), 0, 0, 0, MYSQL_CMD_QUERY}, query...)
+	resp_data, err := hex.DecodeString("0700000100000000000000")
+	assert.Nil(t, err)
+
+	tcptuple := testTcpTuple()
+	req := protos.Packet{Payload: req_data}
+	resp := protos.Packet{Payload: resp_data}
+
+	private := protos.ProtocolData(new(mysqlPrivateData))
+	private = mysql.Parse(&req, tcptuple, 0, private)
+	mysql.Parse(&resp, tcptuple, 1, private)
+
+	trans := expectTransaction(t, mysql)
+	if assert.NotNil(t, trans) {
+		normalized := "select * from test where name = ? and id in (?+)"
+		assert.Equal(t, normalized, trans["query"])
+		assert.Equal(t, normalized, trans["request"])
+		assert.Equal(t, "SELECT", trans["method"])
+
+		fields := trans["mysql"].(common.MapStr)
+		assert.Equal(t, normalized, fields["normalized_query"])
+		assert.Equal(t, "SELECT", fields["statement"])
+		assert.Equal(t, []string{"test"}, fields["tables"])
+		assert.NotEmpty(t, fields["fingerprint"])
+	}
+}
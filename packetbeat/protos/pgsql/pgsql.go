@@ -11,6 +11,7 @@ import (
 	"github.com/elastic/beats/packetbeat/config"
 	"github.com/elastic/beats/packetbeat/procs"
 	"github.com/elastic/beats/packetbeat/protos"
+	"github.com/elastic/beats/packetbeat/protos/sqlnorm"
 	"github.com/elastic/beats/packetbeat/protos/tcp"
 )
 
@@ -87,6 +88,16 @@ const (
 	CancelRequest
 )
 
+func init() {
+	protos.Register(protos.ProtocolInfo{
+		Name:         "pgsql",
+		Tcp:          true,
+		DefaultPorts: []int{5432},
+		Config:       func() interface{} { return &config.Pgsql{} },
+		New:          New,
+	})
+}
+
 type Pgsql struct {
 
 	// config
@@ -96,6 +107,9 @@ type Pgsql struct {
 	Send_request  bool
 	Send_response bool
 
+	Normalize_queries bool
+	Drop_raw_queries  bool
+
 	transactions       *common.Cache
 	transactionTimeout time.Duration
 
@@ -119,6 +133,8 @@ func (pgsql *Pgsql) InitDefaults() {
 	pgsql.maxStoreRows = 10
 	pgsql.Send_request = false
 	pgsql.Send_response = false
+	pgsql.Normalize_queries = false
+	pgsql.Drop_raw_queries = false
 	pgsql.transactionTimeout = protos.DefaultTransactionExpiration
 }
 
@@ -138,6 +154,12 @@ func (pgsql *Pgsql) setFromConfig(config config.Pgsql) error {
 	if config.SendResponse != nil {
 		pgsql.Send_response = *config.SendResponse
 	}
+	if config.Normalize_queries != nil {
+		pgsql.Normalize_queries = *config.Normalize_queries
+	}
+	if config.Drop_raw_queries != nil {
+		pgsql.Drop_raw_queries = *config.Drop_raw_queries
+	}
 	if config.TransactionTimeout != nil && *config.TransactionTimeout > 0 {
 		pgsql.transactionTimeout = time.Duration(*config.TransactionTimeout) * time.Second
 	}
@@ -148,11 +170,28 @@ func (pgsql *Pgsql) GetPorts() []int {
 	return pgsql.Ports
 }
 
+// New creates a PostgreSQL protocol plugin from its configuration.
+func New(cfg interface{}, testMode bool, results publisher.Client) (protos.ProtocolPlugin, error) {
+	pgsql := &Pgsql{}
+	if err := pgsql.InitWithConfig(*cfg.(*config.Pgsql), testMode, results); err != nil {
+		return nil, err
+	}
+	return pgsql, nil
+}
+
 func (pgsql *Pgsql) Init(test_mode bool, results publisher.Client) error {
+	return pgsql.InitWithConfig(config.Pgsql{}, test_mode, results)
+}
+
+func (pgsql *Pgsql) InitWithConfig(
+	config config.Pgsql,
+	test_mode bool,
+	results publisher.Client,
+) error {
 
 	pgsql.InitDefaults()
 	if !test_mode {
-		err := pgsql.setFromConfig(config.ConfigSingleton.Protocols.Pgsql)
+		err := pgsql.setFromConfig(config)
 		if err != nil {
 			return err
 		}
@@ -660,6 +699,30 @@ func (pgsql *Pgsql) ConnectionTimeout() time.Duration {
 	return pgsql.transactionTimeout
 }
 
+// Protocol codes found in the first message sent by the client.
+const (
+	pgsqlProtocolVersion3 = 196608
+	pgsqlSSLRequestCode   = 80877103
+)
+
+// DetectTcp checks if a TCP stream on an unknown port starts with a
+// PostgreSQL startup message or SSL request.
+func (pgsql *Pgsql) DetectTcp(data []byte, dir uint8) protos.DetectResult {
+	if len(data) < 8 {
+		return protos.DetectNeedMoreData
+	}
+
+	length := int(common.Bytes_Ntohl(data[0:4]))
+	code := int(common.Bytes_Ntohl(data[4:8]))
+	if length < 8 || length > 10000 {
+		return protos.DetectNoMatch
+	}
+	if code == pgsqlProtocolVersion3 || code == pgsqlSSLRequestCode {
+		return protos.DetectMatch
+	}
+	return protos.DetectNoMatch
+}
+
 func (pgsql *Pgsql) Parse(pkt *protos.Packet, tcptuple *common.TcpTuple,
 	dir uint8, private protos.ProtocolData) protos.ProtocolData {
 
@@ -862,6 +925,17 @@ func (pgsql *Pgsql) receivedPgsqlRequest(msg *PgsqlMessage) {
 
 		trans.Request_raw = query
 
+		// The raw query is replaced by the normalized query if it must
+		// not be published.
+		if pgsql.Normalize_queries || pgsql.Drop_raw_queries {
+			stmt := sqlnorm.Normalize(query, sqlnorm.PostgreSQL)
+			trans.Pgsql.Update(stmt.ToMapStr())
+			if pgsql.Drop_raw_queries {
+				trans.Query = stmt.Query
+				trans.Request_raw = stmt.Query
+			}
+		}
+
 		transList = append(transList, trans)
 	}
 	pgsql.transactions.Put(tuple.Hashable(), transList)
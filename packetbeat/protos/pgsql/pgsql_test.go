@@ -350,3 +350,60 @@ func Test_gap_in_response(t *testing.T) {
 	assert.NotNil(t, trans)
 	assert.Equal(t, trans["notes"], []string{"Packet loss while capturing the response"})
 }
+
+func TestPgsqlDetectTcp(t *testing.T) {
+	pgsql := PgsqlModForTests()
+
+	// startup message with protocol version 3.0
+	startup, err := hex.DecodeString(
+		"0000002a00030000" +
+			"7573657200706f737467726573" +
+			"0064617461626173650074657374000000")
+	assert.Nil(t, err)
+	assert.Equal(t, protos.DetectMatch, pgsql.DetectTcp(startup, 0))
+
+	// SSL request
+	sslRequest, err := hex.DecodeString("0000000804d2162f")
+	assert.Nil(t, err)
+	assert.Equal(t, protos.DetectMatch, pgsql.DetectTcp(sslRequest, 0))
+
+	assert.Equal(t, protos.DetectNeedMoreData, pgsql.DetectTcp(startup[:4], 0))
+	assert.Equal(t, protos.DetectNoMatch,
+		pgsql.DetectTcp([]byte("GET / HTTP/1.1\r\n"), 0))
+}
+
+// Test that the query is normalized and the raw query kept by default.
+func TestPgsql_normalizeQuery(t *testing.T) {
+	if testing.Verbose() {
+		logp.LogInit(logp.LOG_DEBUG, "", false, true, []string{"pgsql", "pgsqldetailed"})
+	}
+
+	pgsql := PgsqlModForTests()
+	pgsql.Normalize_queries = true
+
+	query := "select * from test where name = 'secret'"
+	req_data := []byte{'Q', 0, 0, 0, byte(len(query) + 5)}
+	req_data = append(req_data, query...)
+	req_data = append(req_data, 0)
+	resp_data, err := hex.DecodeString(
+		"430000000d53454c4543542030005a0000000549")
+	assert.Nil(t, err)
+
+	tcptuple := testTcpTuple()
+	req := protos.Packet{Payload: req_data}
+	resp := protos.Packet{Payload: resp_data}
+
+	private := protos.ProtocolData(new(pgsqlPrivateData))
+	private = pgsql.Parse(&req, tcptuple, 0, private)
+	pgsql.Parse(&resp, tcptuple, 1, private)
+
+	trans := expectTransaction(t, pgsql)
+	if assert.NotNil(t, trans) {
+		assert.Equal(t, query, trans["query"])
+
+		fields := trans["pgsql"].(common.MapStr)
+		assert.Equal(t, "select * from test where name = ?", fields["normalized_query"])
+		assert.Equal(t, "SELECT", fields["statement"])
+		assert.Equal(t, []string{"test"}, fields["tables"])
+	}
+}
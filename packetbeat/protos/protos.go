@@ -17,6 +17,12 @@ const (
 	DefaultTransactionExpiration time.Duration = 10 * time.Second
 )
 
+// Default limits for port independent protocol detection.
+const (
+	DefaultDetectionMaxBytes   = 1024
+	DefaultDetectionMaxPackets = 4
+)
+
 // ProtocolData interface to represent an upper
 // protocol private data. Used with types like
 // HttpStream, MysqlStream, etc.
@@ -93,44 +99,69 @@ type UdpProtocolPlugin interface {
 	ParseUdp(pkt *Packet)
 }
 
-// Protocol identifier.
-type Protocol uint16
+// DetectResult is returned by protocol detectors to report whether the
+// payload offered belongs to the protocol.
+type DetectResult uint8
 
-// Protocol constants.
 const (
-	UnknownProtocol Protocol = iota
-	HttpProtocol
-	MysqlProtocol
-	RedisProtocol
-	PgsqlProtocol
-	ThriftProtocol
-	MongodbProtocol
-	DnsProtocol
-	MemcacheProtocol
+	// DetectNoMatch signals the payload does not belong to the protocol.
+	DetectNoMatch DetectResult = iota
+
+	// DetectNeedMoreData signals that more payload is required to decide.
+	DetectNeedMoreData
+
+	// DetectMatch signals the payload belongs to the protocol.
+	DetectMatch
 )
 
-// Protocol names
-var ProtocolNames = []string{
-	"unknown",
-	"http",
-	"mysql",
-	"redis",
-	"pgsql",
-	"thrift",
-	"mongodb",
-	"dns",
-	"memcache",
+// TcpProtocolDetector is implemented by TCP protocol plugins supporting
+// port independent protocol detection.
+type TcpProtocolDetector interface {
+	TcpProtocolPlugin
+
+	// DetectTcp is called with the first payload bytes seen in the given
+	// direction of a TCP stream on an unknown port.
+	DetectTcp(data []byte, dir uint8) DetectResult
+}
+
+// UdpProtocolDetector is implemented by UDP protocol plugins supporting
+// port independent protocol detection.
+type UdpProtocolDetector interface {
+	UdpProtocolPlugin
+
+	// DetectUdp is called with the payload of a UDP packet on an unknown
+	// port.
+	DetectUdp(data []byte) DetectResult
+}
+
+// DetectionConfig holds the settings for port independent protocol detection.
+// MaxBytes and MaxPackets limit the amount of data per stream offered to
+// the protocol detectors before giving up.
+type DetectionConfig struct {
+	Enabled    bool
+	MaxBytes   int
+	MaxPackets int
 }
 
+// Protocol identifier. Identifiers are assigned to protocol plugins by
+// Register.
+type Protocol uint16
+
+// UnknownProtocol is the identifier of traffic not claimed by any plugin.
+const UnknownProtocol Protocol = 0
+
 func (p Protocol) String() string {
-	if int(p) >= len(ProtocolNames) {
-		return "impossible"
+	if p == UnknownProtocol {
+		return "unknown"
+	}
+	if info := p.Info(); info != nil {
+		return info.Name
 	}
-	return ProtocolNames[p]
+	return "impossible"
 }
 
 type Protocols interface {
-	BpfFilter(with_vlans bool, with_icmp bool) string
+	BpfFilter(with_vlans bool, with_icmp bool, with_detection bool) string
 	GetTcp(proto Protocol) TcpProtocolPlugin
 	GetUdp(proto Protocol) UdpProtocolPlugin
 	GetAll() map[Protocol]ProtocolPlugin
@@ -179,11 +210,36 @@ func (protocols ProtocolsStruct) GetAllUdp() map[Protocol]UdpProtocolPlugin {
 	return protocols.udp
 }
 
+// hasTcpDetectors and hasUdpDetectors check if any of the given plugins
+// supports port independent protocol detection.
+func hasTcpDetectors(plugins map[Protocol]TcpProtocolPlugin) bool {
+	for _, plugin := range plugins {
+		if _, ok := plugin.(TcpProtocolDetector); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func hasUdpDetectors(plugins map[Protocol]UdpProtocolPlugin) bool {
+	for _, plugin := range plugins {
+		if _, ok := plugin.(UdpProtocolDetector); ok {
+			return true
+		}
+	}
+	return false
+}
+
 // BpfFilter returns a Berkeley Packer Filter (BFP) expression that
 // will match against packets for the registered protocols. If with_vlans is
 // true the filter will match against both IEEE 802.1Q VLAN encapsulated
-// and unencapsulated packets
-func (protocols ProtocolsStruct) BpfFilter(with_vlans bool, with_icmp bool) string {
+// and unencapsulated packets. If with_detection is true, all TCP or UDP
+// traffic is matched as long as a registered plugin supports protocol
+// detection for the transport.
+func (protocols ProtocolsStruct) BpfFilter(with_vlans bool, with_icmp bool, with_detection bool) string {
+	detect_tcp := with_detection && hasTcpDetectors(protocols.tcp)
+	detect_udp := with_detection && hasUdpDetectors(protocols.udp)
+
 	// Sort the protocol IDs so that the return value is consistent.
 	var protos []int
 	for proto := range protocols.all {
@@ -206,6 +262,11 @@ func (protocols ProtocolsStruct) BpfFilter(with_vlans bool, with_icmp bool) stri
 				has_udp = true
 			}
 
+			// ports already covered by the detection expressions below
+			if (!has_tcp || detect_tcp) && (!has_udp || detect_udp) {
+				continue
+			}
+
 			var expr string
 			if has_tcp && !has_udp {
 				expr = "tcp port %d"
@@ -219,6 +280,13 @@ func (protocols ProtocolsStruct) BpfFilter(with_vlans bool, with_icmp bool) stri
 		}
 	}
 
+	if detect_tcp {
+		expressions = append(expressions, "tcp")
+	}
+	if detect_udp {
+		expressions = append(expressions, "udp")
+	}
+
 	filter := strings.Join(expressions, " or ")
 	if with_icmp {
 		filter = fmt.Sprintf("%s or icmp or icmp6", filter)
@@ -229,12 +297,19 @@ func (protocols ProtocolsStruct) BpfFilter(with_vlans bool, with_icmp bool) stri
 	return filter
 }
 
+// Register adds the plugin for the given protocol. For registered protocols
+// the plugin is only used for the transports announced in the ProtocolInfo.
 func (protos ProtocolsStruct) Register(proto Protocol, plugin ProtocolPlugin) {
+	useTcp, useUdp := true, true
+	if info := proto.Info(); info != nil {
+		useTcp, useUdp = info.Tcp, info.Udp
+	}
+
 	protos.all[proto] = plugin
-	if tcp, ok := plugin.(TcpProtocolPlugin); ok {
+	if tcp, ok := plugin.(TcpProtocolPlugin); ok && useTcp {
 		protos.tcp[proto] = tcp
 	}
-	if udp, ok := plugin.(UdpProtocolPlugin); ok {
+	if udp, ok := plugin.(UdpProtocolPlugin); ok && useUdp {
 		protos.udp[proto] = udp
 	}
 }
@@ -86,16 +86,100 @@ func (proto *TcpUdpProtocol) ParseUdp(pkt *Packet) {
 
 func (proto *TcpUdpProtocol) ConnectionTimeout() time.Duration { return 0 }
 
+// withRegistry runs f with an empty protocol registry.
+func withRegistry(f func()) {
+	savedRegistry, savedByName := registry, registryByName
+	defer func() {
+		registry, registryByName = savedRegistry, savedByName
+	}()
+
+	registry = []*ProtocolInfo{nil}
+	registryByName = map[string]Protocol{}
+	f()
+}
+
 func TestProtocolNames(t *testing.T) {
-	assert.Equal(t, "unknown", UnknownProtocol.String())
-	assert.Equal(t, "http", HttpProtocol.String())
-	assert.Equal(t, "mysql", MysqlProtocol.String())
-	assert.Equal(t, "redis", RedisProtocol.String())
-	assert.Equal(t, "pgsql", PgsqlProtocol.String())
-	assert.Equal(t, "thrift", ThriftProtocol.String())
-	assert.Equal(t, "mongodb", MongodbProtocol.String())
+	withRegistry(func() {
+		http := Register(ProtocolInfo{Name: "http", Tcp: true})
+
+		assert.Equal(t, "unknown", UnknownProtocol.String())
+		assert.Equal(t, "http", http.String())
+		assert.Equal(t, "impossible", Protocol(100).String())
+	})
+}
+
+func TestRegister(t *testing.T) {
+	withRegistry(func() {
+		http := Register(ProtocolInfo{Name: "http", Tcp: true})
+		dns := Register(ProtocolInfo{Name: "dns", Tcp: true, Udp: true})
+
+		assert.NotEqual(t, UnknownProtocol, http)
+		assert.NotEqual(t, http, dns)
+		assert.Equal(t, []Protocol{http, dns}, Registered())
+
+		proto, exists := Lookup("dns")
+		assert.True(t, exists)
+		assert.Equal(t, dns, proto)
+		assert.Equal(t, "dns", proto.Info().Name)
+
+		_, exists = Lookup("mysql")
+		assert.False(t, exists)
+		assert.Nil(t, UnknownProtocol.Info())
+
+		assert.Panics(t, func() { Register(ProtocolInfo{Name: "http"}) })
+		assert.Panics(t, func() { Register(ProtocolInfo{}) })
+	})
+}
+
+type testConfig struct {
+	Ports []int
+}
+
+func (c *testConfig) SetDefaultPorts(ports []int) {
+	if c.Ports == nil {
+		c.Ports = ports
+	}
+}
+
+func newTestPlugin(config interface{}, testMode bool, results publisher.Client) (ProtocolPlugin, error) {
+	return &TcpProtocol{Ports: config.(*testConfig).Ports}, nil
+}
 
-	assert.Equal(t, "impossible", Protocol(100).String())
+func TestNewPlugin(t *testing.T) {
+	info := &ProtocolInfo{
+		Name:         "test",
+		Tcp:          true,
+		DefaultPorts: []int{80},
+		Config:       func() interface{} { return &testConfig{} },
+		New:          newTestPlugin,
+	}
+
+	plugin, err := info.NewPlugin(info.Config(), true, nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []int{80}, plugin.GetPorts())
+	}
+
+	plugin, err = info.NewPlugin(&testConfig{Ports: []int{8080}}, true, nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []int{8080}, plugin.GetPorts())
+	}
+
+	// plugin does not support the announced UDP transport
+	info.Udp = true
+	_, err = info.NewPlugin(info.Config(), true, nil)
+	assert.Error(t, err)
+}
+
+// Verify that registered protocols only use the announced transports.
+func TestRegisterPluginTransports(t *testing.T) {
+	withRegistry(func() {
+		proto := Register(ProtocolInfo{Name: "test", Udp: true})
+
+		p := newProtocols()
+		p.Register(proto, &TcpUdpProtocol{Ports: []int{1234}})
+		assert.Nil(t, p.GetTcp(proto))
+		assert.NotNil(t, p.GetUdp(proto))
+	})
 }
 
 func newProtocols() Protocols {
@@ -116,30 +200,56 @@ func newProtocols() Protocols {
 
 func TestBpfFilterWithoutVlanWithoutIcmp(t *testing.T) {
 	p := newProtocols()
-	filter := p.BpfFilter(false, false)
+	filter := p.BpfFilter(false, false, false)
 	assert.Equal(t, "tcp port 80 or udp port 5060 or port 53", filter)
 }
 
 func TestBpfFilterWithVlanWithoutIcmp(t *testing.T) {
 	p := newProtocols()
-	filter := p.BpfFilter(true, false)
+	filter := p.BpfFilter(true, false, false)
 	assert.Equal(t, "tcp port 80 or udp port 5060 or port 53 or "+
 		"(vlan and (tcp port 80 or udp port 5060 or port 53))", filter)
 }
 
 func TestBpfFilterWithoutVlanWithIcmp(t *testing.T) {
 	p := newProtocols()
-	filter := p.BpfFilter(false, true)
+	filter := p.BpfFilter(false, true, false)
 	assert.Equal(t, "tcp port 80 or udp port 5060 or port 53 or icmp or icmp6", filter)
 }
 
 func TestBpfFilterWithVlanWithIcmp(t *testing.T) {
 	p := newProtocols()
-	filter := p.BpfFilter(true, true)
+	filter := p.BpfFilter(true, true, false)
 	assert.Equal(t, "tcp port 80 or udp port 5060 or port 53 or icmp or icmp6 or "+
 		"(vlan and (tcp port 80 or udp port 5060 or port 53 or icmp or icmp6))", filter)
 }
 
+type TcpDetectProtocol struct {
+	TcpProtocol
+}
+
+func (proto *TcpDetectProtocol) DetectTcp(data []byte, dir uint8) DetectResult {
+	return DetectNoMatch
+}
+
+func TestBpfFilterWithDetectionWithoutDetectors(t *testing.T) {
+	p := newProtocols()
+	filter := p.BpfFilter(false, false, true)
+	assert.Equal(t, "tcp port 80 or udp port 5060 or port 53", filter)
+}
+
+func TestBpfFilterWithDetection(t *testing.T) {
+	p := newProtocols()
+	p.Register(4, &TcpDetectProtocol{TcpProtocol{Ports: []int{6379}}})
+
+	filter := p.BpfFilter(false, false, true)
+	assert.Equal(t, "udp port 5060 or port 53 or tcp", filter)
+
+	filter = p.BpfFilter(true, true, true)
+	assert.Equal(t, "udp port 5060 or port 53 or tcp or icmp or icmp6 or "+
+		"(vlan and (udp port 5060 or port 53 or tcp or icmp or icmp6))", filter)
+}
+
 func TestGetAll(t *testing.T) {
 	p := newProtocols()
 	all := p.GetAll()
@@ -1,6 +1,7 @@
 package redis
 
 import (
+	"strconv"
 	"strings"
 	"time"
 
@@ -48,10 +49,32 @@ type redisConnectionData struct {
 	Streams   [2]*stream
 	requests  messageList
 	responses messageList
+
+	// number of channels and patterns the client is subscribed to
+	subscriptions int
+
+	// number of replies to requests dropped from the full request queue,
+	// discarded before correlating further responses
+	droppedReplies int
 }
 
 type messageList struct {
 	head, tail *redisMessage
+	count      int
+}
+
+// maxQueuedRequests limits the number of pipelined requests waiting for a
+// response per connection.
+const maxQueuedRequests = 1000
+
+func init() {
+	protos.Register(protos.ProtocolInfo{
+		Name:         "redis",
+		Tcp:          true,
+		DefaultPorts: []int{6379},
+		Config:       func() interface{} { return &config.Redis{} },
+		New:          New,
+	})
 }
 
 // Redis protocol plugin
@@ -93,10 +116,27 @@ func (redis *Redis) GetPorts() []int {
 	return redis.Ports
 }
 
+// New creates a Redis protocol plugin from its configuration.
+func New(cfg interface{}, testMode bool, results publisher.Client) (protos.ProtocolPlugin, error) {
+	redis := &Redis{}
+	if err := redis.InitWithConfig(*cfg.(*config.Redis), testMode, results); err != nil {
+		return nil, err
+	}
+	return redis, nil
+}
+
 func (redis *Redis) Init(test_mode bool, results publisher.Client) error {
+	return redis.InitWithConfig(config.Redis{}, test_mode, results)
+}
+
+func (redis *Redis) InitWithConfig(
+	config config.Redis,
+	test_mode bool,
+	results publisher.Client,
+) error {
 	redis.InitDefaults()
 	if !test_mode {
-		redis.setFromConfig(config.ConfigSingleton.Protocols.Redis)
+		redis.setFromConfig(config)
 	}
 
 	redis.results = results
@@ -114,6 +154,32 @@ func (redis *Redis) ConnectionTimeout() time.Duration {
 	return redis.transactionTimeout
 }
 
+// DetectTcp checks if a TCP stream on an unknown port starts with a redis
+// command, that is a RESP array of bulk strings like "*2\r\n$3\r\n...".
+func (redis *Redis) DetectTcp(data []byte, dir uint8) protos.DetectResult {
+	if len(data) == 0 || data[0] != '*' {
+		return protos.DetectNoMatch
+	}
+
+	i := 1
+	for ; i < len(data) && data[i] >= '0' && data[i] <= '9'; i++ {
+	}
+	if i == 1 && i < len(data) {
+		return protos.DetectNoMatch
+	}
+
+	for _, c := range []byte("\r\n$") {
+		if i == len(data) {
+			return protos.DetectNeedMoreData
+		}
+		if data[i] != c {
+			return protos.DetectNoMatch
+		}
+		i++
+	}
+	return protos.DetectMatch
+}
+
 func (redis *Redis) Parse(
 	pkt *protos.Packet,
 	tcptuple *common.TcpTuple,
@@ -226,14 +292,36 @@ func (redis *Redis) handleRedis(
 	m.CmdlineTuple = procs.ProcWatcher.FindProcessesTuple(tcptuple.IpPort())
 
 	if m.IsRequest {
+		m.replies = expectedReplies(conn, m)
+		if conn.requests.count >= maxQueuedRequests {
+			logp.Warn("Too many pipelined requests. Dropping oldest request")
+			dropped := conn.requests.pop()
+			conn.droppedReplies += dropped.replies
+		}
 		conn.requests.append(m) // wait for response
-	} else {
-		conn.responses.append(m)
-		redis.correlate(conn)
+		return
+	}
+
+	if isPubSubMessage(conn, m) {
+		redis.publishPubSubMessage(m)
+		return
+	}
+
+	if count, ok := subscriptionCount(m); ok {
+		conn.subscriptions = count
 	}
+	conn.responses.append(m)
+	redis.correlate(conn)
 }
 
 func (redis *Redis) correlate(conn *redisConnectionData) {
+	// drop replies to requests dropped from the request queue
+	for conn.droppedReplies > 0 && !conn.responses.empty() {
+		debug("REDIS (%p) dropping reply to dropped request", conn)
+		conn.responses.pop()
+		conn.droppedReplies--
+	}
+
 	// drop responses with missing requests
 	if conn.requests.empty() {
 		for !conn.responses.empty() {
@@ -245,15 +333,111 @@ func (redis *Redis) correlate(conn *redisConnectionData) {
 
 	// merge requests with responses into transactions
 	for !conn.responses.empty() && !conn.requests.empty() {
-		requ := conn.requests.pop()
+		requ := conn.requests.first()
 		resp := conn.responses.pop()
-		trans := newTransaction(requ, resp)
+		if requ.response == nil {
+			requ.response = resp
+		} else {
+			// (un)subscribe requests get one reply per channel
+			requ.response.Message += " " + resp.Message
+			requ.response.Size += resp.Size
+			requ.response.Ts = resp.Ts
+		}
+
+		requ.replies--
+		if requ.replies > 0 {
+			continue
+		}
+
+		conn.requests.pop()
+		trans := newTransaction(requ, requ.response)
 
 		debug("REDIS (%p) transaction completed: %s", conn, trans.Redis)
 		redis.publishTransaction(trans)
 	}
 }
 
+// expectedReplies returns the number of replies the server sends for a
+// request. (P)SUBSCRIBE and (P)UNSUBSCRIBE get one reply per channel or
+// pattern, all other commands get exactly one reply.
+func expectedReplies(conn *redisConnectionData, m *redisMessage) int {
+	switch strings.ToUpper(m.Method) {
+	case "SUBSCRIBE", "PSUBSCRIBE", "UNSUBSCRIBE", "PUNSUBSCRIBE":
+		if n := len(m.values) - 1; n > 0 {
+			return n
+		}
+		// unsubscribing from all channels gets one reply per subscription
+		if conn.subscriptions > 0 {
+			return conn.subscriptions
+		}
+	}
+	return 1
+}
+
+// isPubSubMessage checks if a message send by the server is a message
+// published to a subscribed channel instead of a reply to a request.
+// RESP3 push messages other than subscription confirmations are always
+// published messages. In RESP2, published messages are arrays starting with
+// "message" or "pmessage", received while the client is subscribed.
+func isPubSubMessage(conn *redisConnectionData, m *redisMessage) bool {
+	if _, ok := subscriptionCount(m); ok {
+		return false
+	}
+	if m.IsPush {
+		return true
+	}
+
+	if conn.subscriptions == 0 && !conn.requests.empty() {
+		return false
+	}
+	switch {
+	case len(m.values) == 3 && strings.ToLower(m.values[0]) == "message":
+		return true
+	case len(m.values) == 4 && strings.ToLower(m.values[0]) == "pmessage":
+		return true
+	}
+	return false
+}
+
+// subscriptionCount returns the number of active subscriptions reported
+// by the server in reply to a (P)SUBSCRIBE or (P)UNSUBSCRIBE request.
+func subscriptionCount(m *redisMessage) (int, bool) {
+	if len(m.values) != 3 {
+		return 0, false
+	}
+
+	switch strings.ToLower(m.values[0]) {
+	case "subscribe", "psubscribe", "unsubscribe", "punsubscribe":
+		count, err := strconv.Atoi(m.values[2])
+		if err != nil {
+			return 0, false
+		}
+		return count, true
+	}
+	return 0, false
+}
+
+// parseRedirect parses MOVED and ASK errors returned by redis cluster nodes,
+// e.g. "MOVED 3999 127.0.0.1:6381". It returns nil if the error is not a
+// redirection.
+func parseRedirect(msg string) common.MapStr {
+	fields := strings.Fields(msg)
+	if len(fields) != 3 || (fields[0] != "MOVED" && fields[0] != "ASK") {
+		return nil
+	}
+
+	slot, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return nil
+	}
+
+	return common.MapStr{
+		"type": strings.ToLower(fields[0]),
+		"slot": slot,
+		"node": fields[2],
+	}
+}
+
 func newTransaction(requ, resp *redisMessage) *transaction {
 	trans := &transaction{Type: "redis", tuple: requ.TcpTuple}
 
@@ -285,7 +469,12 @@ func newTransaction(requ, resp *redisMessage) *transaction {
 
 	// init from response
 	trans.IsError = resp.IsError
-	if resp.IsError {
+	if redirect := parseRedirect(resp.Message); resp.IsError && redirect != nil {
+		// cluster redirections are no failures, the client is expected to
+		// retry the command on the given node
+		trans.IsError = false
+		trans.Redis["redirect"] = redirect
+	} else if resp.IsError {
 		trans.Redis["error"] = resp.Message
 	} else {
 		trans.Redis["return_value"] = resp.Message
@@ -349,6 +538,64 @@ func (redis *Redis) publishTransaction(t *transaction) {
 	redis.results.PublishEvent(event)
 }
 
+// publishPubSubMessage publishes a message pushed by the server to a
+// subscribed client as an event of its own.
+func (redis *Redis) publishPubSubMessage(m *redisMessage) {
+	if redis.results == nil {
+		return
+	}
+
+	pubsub := common.MapStr{}
+	kind := ""
+	if len(m.values) > 0 {
+		kind = strings.ToLower(m.values[0])
+		pubsub["type"] = kind
+	}
+	switch {
+	case kind == "message" && len(m.values) == 3:
+		pubsub["channel"] = m.values[1]
+		pubsub["payload_size"] = len(m.values[2])
+	case kind == "pmessage" && len(m.values) == 4:
+		pubsub["pattern"] = m.values[1]
+		pubsub["channel"] = m.values[2]
+		pubsub["payload_size"] = len(m.values[3])
+	}
+
+	// messages are send by the server, report the subscribed client as source
+	src := common.Endpoint{
+		Ip:   m.TcpTuple.Src_ip.String(),
+		Port: m.TcpTuple.Src_port,
+		Proc: string(m.CmdlineTuple.Src),
+	}
+	dst := common.Endpoint{
+		Ip:   m.TcpTuple.Dst_ip.String(),
+		Port: m.TcpTuple.Dst_port,
+		Proc: string(m.CmdlineTuple.Dst),
+	}
+	if m.Direction == tcp.TcpDirectionOriginal {
+		src, dst = dst, src
+	}
+
+	event := common.MapStr{}
+	event["type"] = "redis"
+	event["status"] = common.OK_STATUS
+	if redis.SendResponse {
+		event["response"] = m.Message
+	}
+	event["redis"] = common.MapStr{"pubsub": pubsub}
+	event["method"] = strings.ToUpper(kind)
+	if channel, ok := pubsub["channel"]; ok {
+		event["resource"] = channel
+	}
+	event["bytes_out"] = uint64(m.Size)
+
+	event["@timestamp"] = common.Time(m.Ts)
+	event["src"] = &src
+	event["dst"] = &dst
+
+	redis.results.PublishEvent(event)
+}
+
 func (ml *messageList) append(msg *redisMessage) {
 	if ml.tail == nil {
 		ml.head = msg
@@ -357,6 +604,7 @@ func (ml *messageList) append(msg *redisMessage) {
 	}
 	msg.next = nil
 	ml.tail = msg
+	ml.count++
 }
 
 func (ml *messageList) empty() bool {
@@ -373,10 +621,15 @@ func (ml *messageList) pop() *redisMessage {
 	if ml.head == nil {
 		ml.tail = nil
 	}
+	ml.count--
 	debug("new head=%p", ml.head)
 	return msg
 }
 
+func (ml *messageList) first() *redisMessage {
+	return ml.head
+}
+
 func (ml *messageList) last() *redisMessage {
 	return ml.tail
 }
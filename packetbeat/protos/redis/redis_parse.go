@@ -25,11 +25,21 @@ type redisMessage struct {
 
 	IsRequest bool
 	IsError   bool
+	IsPush    bool
 	Message   string
 	Method    string
 	Path      string
 	Size      int
 
+	// top level elements of array, set or push messages
+	values []string
+
+	// number of replies expected for a request
+	replies int
+
+	// reply of a request waiting for additional replies
+	response *redisMessage
+
 	next *redisMessage
 }
 
@@ -42,6 +52,7 @@ const (
 // Keep sorted for future command addition
 var redisCommands = map[string]struct{}{
 	"APPEND":           {},
+	"ASKING":           {},
 	"AUTH":             {},
 	"BGREWRITEAOF":     {},
 	"BGSAVE":           {},
@@ -56,6 +67,7 @@ var redisCommands = map[string]struct{}{
 	"CLIENT LIST":      {},
 	"CLIENT PAUSE":     {},
 	"CLIENT SETNAME":   {},
+	"CLUSTER":          {},
 	"CONFIG GET":       {},
 	"CONFIG RESETSTAT": {},
 	"CONFIG REWRITE":   {},
@@ -81,6 +93,7 @@ var redisCommands = map[string]struct{}{
 	"GETBIT":           {},
 	"GETRANGE":         {},
 	"GETSET":           {},
+	"HELLO":            {},
 	"HDEL":             {},
 	"HEXISTS":          {},
 	"HGET":             {},
@@ -134,6 +147,8 @@ var redisCommands = map[string]struct{}{
 	"PUNSUBSCRIBE":     {},
 	"QUIT":             {},
 	"RANDOMKEY":        {},
+	"READONLY":         {},
+	"READWRITE":        {},
 	"RENAME":           {},
 	"RENAMENX":         {},
 	"RESTORE":          {},
@@ -239,14 +254,36 @@ func (p *parser) dispatch(depth int, buf *streambuf.Buffer) (string, bool, bool,
 	snapshot := buf.Snapshot()
 
 	switch buf.Bytes()[0] {
-	case '*':
+	case '*', '~':
+		value, iserror, ok, complete = p.parseArray(depth, buf)
+	case '>':
+		if depth == 0 {
+			p.message.IsPush = true
+		}
 		value, iserror, ok, complete = p.parseArray(depth, buf)
+	case '%':
+		value, iserror, ok, complete = p.parseMap(depth, buf)
+	case '|':
+		// attributes carry auxiliary data and are followed by the actual reply
+		_, _, ok, complete = p.parseMap(depth+1, buf)
+		if ok && complete {
+			value, iserror, ok, complete = p.dispatch(depth, buf)
+		}
 	case '$':
 		value, ok, complete = p.parseString(buf)
+	case '=':
+		value, ok, complete = p.parseVerbatimString(buf)
+	case '!':
+		iserror = true
+		value, ok, complete = p.parseString(buf)
 	case ':':
 		value, ok, complete = p.parseInt(buf)
-	case '+':
+	case '+', ',', '(':
 		value, ok, complete = p.parseSimpleString(buf)
+	case '#':
+		value, ok, complete = p.parseBoolean(buf)
+	case '_':
+		value, ok, complete = p.parseNull(buf)
 	case '-':
 		iserror = true
 		value, ok, complete = p.parseSimpleString(buf)
@@ -284,6 +321,44 @@ func (p *parser) parseSimpleString(buf *streambuf.Buffer) (string, bool, bool) {
 	return string(line[1:]), true, true
 }
 
+func (p *parser) parseBoolean(buf *streambuf.Buffer) (string, bool, bool) {
+	line, err := buf.UntilCRLF()
+	if err != nil {
+		return "", true, false
+	}
+
+	switch string(line[1:]) {
+	case "t":
+		return "true", true, true
+	case "f":
+		return "false", true, true
+	}
+	logp.Err("Failed to read boolean reply: %s", line[1:])
+	return "", false, false
+}
+
+func (p *parser) parseNull(buf *streambuf.Buffer) (string, bool, bool) {
+	_, err := buf.UntilCRLF()
+	if err != nil {
+		return "", true, false
+	}
+	return "nil", true, true
+}
+
+// parseVerbatimString parses a RESP3 verbatim string, dropping the 3 bytes
+// format prefix (e.g. "txt:") from the content.
+func (p *parser) parseVerbatimString(buf *streambuf.Buffer) (string, bool, bool) {
+	value, ok, complete := p.parseString(buf)
+	if !ok || !complete {
+		return value, ok, complete
+	}
+
+	if len(value) >= 4 && value[3] == ':' {
+		value = value[4:]
+	}
+	return value, true, true
+}
+
 func (p *parser) parseString(buf *streambuf.Buffer) (string, bool, bool) {
 	line, err := buf.UntilCRLF()
 	if err != nil {
@@ -335,15 +410,25 @@ func (p *parser) parseArray(depth int, buf *streambuf.Buffer) (string, bool, boo
 	if count < 0 {
 		return "nil", false, true, true
 	}
+	if count > int64(buf.Len()) {
+		// each element needs at least 1 byte
+		debug("Array incomplete")
+		return "", false, true, false
+	}
 
 	content := make([]string, 0, count)
 	// read sub elements
 
 	iserror := false
+	bulkOnly := true // requests are send as array of bulk strings
 	for i := 0; i < int(count); i++ {
 		var value string
 		var ok, complete bool
 
+		if buf.Len() > 0 && buf.Bytes()[0] != '$' {
+			bulkOnly = false
+		}
+
 		value, iserror, ok, complete := p.dispatch(depth+1, buf)
 		if !ok || !complete {
 			debug("Array incomplete")
@@ -353,10 +438,16 @@ func (p *parser) parseArray(depth int, buf *streambuf.Buffer) (string, bool, boo
 		content = append(content, value)
 	}
 
-	if depth == 0 && isRedisCommand(content[0]) { // we've got a request
+	if depth == 0 {
+		p.message.values = content
+	}
+
+	if depth == 0 && bulkOnly && !p.message.IsPush && isRedisCommand(content[0]) { // we've got a request
 		p.message.IsRequest = true
 		p.message.Method = content[0]
-		p.message.Path = content[1]
+		if len(content) > 1 {
+			p.message.Path = content[1]
+		}
 	}
 
 	var value string
@@ -367,3 +458,44 @@ func (p *parser) parseArray(depth int, buf *streambuf.Buffer) (string, bool, boo
 	}
 	return value, iserror, true, true
 }
+
+// parseMap parses RESP3 maps and attributes. Both are encoded as the number
+// of entries followed by the alternating keys and values.
+func (p *parser) parseMap(depth int, buf *streambuf.Buffer) (string, bool, bool, bool) {
+	line, err := buf.UntilCRLF()
+	if err != nil {
+		debug("End of line not found, waiting for more data")
+		return "", false, false, false
+	}
+
+	count, err := strconv.ParseInt(string(line[1:]), 10, 64)
+	if err != nil {
+		logp.Err("Failed to read number of map entries: %s", err)
+		return "", false, false, false
+	}
+	if count < 0 {
+		return "nil", false, true, true
+	}
+	if 2*count > int64(buf.Len()) {
+		debug("Map incomplete")
+		return "", false, true, false
+	}
+
+	entries := make([]string, 0, count)
+	for i := 0; i < int(count); i++ {
+		key, _, ok, complete := p.dispatch(depth+1, buf)
+		if !ok || !complete {
+			debug("Map incomplete")
+			return "", false, ok, complete
+		}
+
+		value, _, ok, complete := p.dispatch(depth+1, buf)
+		if !ok || !complete {
+			debug("Map incomplete")
+			return "", false, ok, complete
+		}
+
+		entries = append(entries, key+": "+value)
+	}
+	return "{" + strings.Join(entries, ", ") + "}", false, true, true
+}
@@ -1,9 +1,18 @@
 package redis
 
 import (
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
 	"testing"
 	"time"
 
+	"github.com/elastic/beats/libbeat/common"
+	"github.com/elastic/beats/libbeat/publisher"
+	"github.com/elastic/beats/packetbeat/protos"
+	"github.com/elastic/beats/packetbeat/protos/tcp"
+
 	"github.com/stretchr/testify/assert"
 )
 
@@ -161,3 +170,234 @@ func TestRedisParser_Array2Passes(t *testing.T) {
 	assert.Equal(t, "SET key1 Hello", msg.Message)
 	assert.Equal(t, len(part1)+len(part2), msg.Size)
 }
+
+func TestRedisDetectTcp(t *testing.T) {
+	redis := Redis{}
+
+	tests := []struct {
+		data   string
+		result protos.DetectResult
+	}{
+		{"*2\r\n$3\r\nGET\r\n$3\r\nkey\r\n", protos.DetectMatch},
+		{"*", protos.DetectNeedMoreData},
+		{"*12", protos.DetectNeedMoreData},
+		{"*2\r\n", protos.DetectNeedMoreData},
+		{"*\r\n", protos.DetectNoMatch},
+		{"*2\r\n:1\r\n", protos.DetectNoMatch},
+		{"+OK\r\n", protos.DetectNoMatch},
+		{"GET / HTTP/1.1\r\n", protos.DetectNoMatch},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.result, redis.DetectTcp([]byte(test.data), 0), test.data)
+	}
+}
+
+func TestRedisParser_Resp3Types(t *testing.T) {
+	tests := []struct {
+		message string
+		value   string
+		isError bool
+	}{
+		{"_\r\n", "nil", false},
+		{",3.14\r\n", "3.14", false},
+		{"#t\r\n", "true", false},
+		{"#f\r\n", "false", false},
+		{"(3492890328409238509324850943850943825024385\r\n",
+			"3492890328409238509324850943850943825024385", false},
+		{"!21\r\nSYNTAX invalid syntax\r\n", "SYNTAX invalid syntax", true},
+		{"=15\r\ntxt:Some string\r\n", "Some string", false},
+		{"%2\r\n+first\r\n:1\r\n+second\r\n:2\r\n", "{first: 1, second: 2}", false},
+		{"~2\r\n+orange\r\n+apple\r\n", "[orange, apple]", false},
+		{"|1\r\n+key-popularity\r\n%1\r\n$1\r\na\r\n,0.1923\r\n" +
+			"*2\r\n:2039123\r\n:9543892\r\n", "[2039123, 9543892]", false},
+	}
+
+	for _, test := range tests {
+		msg, ok, complete := parse([]byte(test.message))
+
+		assert.True(t, ok, test.message)
+		assert.True(t, complete, test.message)
+		assert.False(t, msg.IsRequest, test.message)
+		assert.Equal(t, test.isError, msg.IsError, test.message)
+		assert.Equal(t, test.value, msg.Message, test.message)
+		assert.Equal(t, len(test.message), msg.Size, test.message)
+	}
+}
+
+func TestRedisParser_Push(t *testing.T) {
+	message := []byte(">3\r\n" +
+		"$7\r\nmessage\r\n" +
+		"$4\r\nnews\r\n" +
+		"$5\r\nhello\r\n")
+	msg, ok, complete := parse(message)
+
+	assert.True(t, ok)
+	assert.True(t, complete)
+	assert.True(t, msg.IsPush)
+	assert.False(t, msg.IsRequest)
+	assert.Equal(t, []string{"message", "news", "hello"}, msg.values)
+}
+
+func TestRedisParser_SingleElementRequest(t *testing.T) {
+	message := []byte("*1\r\n$4\r\nPING\r\n")
+	msg, ok, complete := parse(message)
+
+	assert.True(t, ok)
+	assert.True(t, complete)
+	assert.True(t, msg.IsRequest)
+	assert.Equal(t, "PING", msg.Method)
+	assert.Equal(t, "", msg.Path)
+}
+
+func redisModForTests() *Redis {
+	var redis Redis
+	results := publisher.ChanClient{make(chan common.MapStr, 10)}
+	redis.Init(true, results)
+	return &redis
+}
+
+func testTcpTuple() *common.TcpTuple {
+	t := &common.TcpTuple{
+		Ip_length: 4,
+		Src_ip:    net.IPv4(192, 168, 0, 1), Dst_ip: net.IPv4(192, 168, 0, 2),
+		Src_port: 6512, Dst_port: 6379,
+	}
+	t.ComputeHashebles()
+	return t
+}
+
+// send passes a payload to the redis module. Requests are send by the client
+// in the original direction of the tcp stream.
+func send(redis *Redis, private protos.ProtocolData, dir uint8, payload string) protos.ProtocolData {
+	pkt := &protos.Packet{Ts: time.Now(), Payload: []byte(payload)}
+	return redis.Parse(pkt, testTcpTuple(), dir, private)
+}
+
+func expectEvents(t *testing.T, redis *Redis, n int) []common.MapStr {
+	client := redis.results.(publisher.ChanClient)
+	var events []common.MapStr
+	for i := 0; i < n; i++ {
+		select {
+		case event := <-client.Channel:
+			events = append(events, event)
+		default:
+			t.Fatalf("Expected %d events, got %d", n, i)
+		}
+	}
+	select {
+	case event := <-client.Channel:
+		t.Errorf("Unexpected event: %v", event)
+	default:
+	}
+	return events
+}
+
+func TestRedis_pipelining(t *testing.T) {
+	redis := redisModForTests()
+
+	private := send(redis, nil, tcp.TcpDirectionOriginal,
+		"*2\r\n$3\r\nGET\r\n$1\r\na\r\n"+
+			"*2\r\n$3\r\nGET\r\n$1\r\nb\r\n"+
+			"*2\r\n$4\r\nINCR\r\n$1\r\nc\r\n")
+	private = send(redis, private, tcp.TcpDirectionReverse, "$2\r\nv1\r\n$-1\r\n")
+	send(redis, private, tcp.TcpDirectionReverse, ":42\r\n")
+
+	events := expectEvents(t, redis, 3)
+	assert.Equal(t, "GET a", events[0]["query"])
+	assert.Equal(t, "v1", events[0]["redis"].(common.MapStr)["return_value"])
+	assert.Equal(t, "GET b", events[1]["query"])
+	assert.Equal(t, "nil", events[1]["redis"].(common.MapStr)["return_value"])
+	assert.Equal(t, "INCR c", events[2]["query"])
+	assert.Equal(t, "42", events[2]["redis"].(common.MapStr)["return_value"])
+}
+
+func TestRedis_pubsub(t *testing.T) {
+	redis := redisModForTests()
+
+	private := send(redis, nil, tcp.TcpDirectionOriginal,
+		"*3\r\n$9\r\nSUBSCRIBE\r\n$4\r\nnews\r\n$6\r\nsports\r\n")
+	private = send(redis, private, tcp.TcpDirectionReverse,
+		"*3\r\n$9\r\nsubscribe\r\n$4\r\nnews\r\n:1\r\n"+
+			"*3\r\n$9\r\nsubscribe\r\n$6\r\nsports\r\n:2\r\n")
+	private = send(redis, private, tcp.TcpDirectionReverse,
+		"*3\r\n$7\r\nmessage\r\n$4\r\nnews\r\n$5\r\nhello\r\n")
+	send(redis, private, tcp.TcpDirectionReverse,
+		"*4\r\n$8\r\npmessage\r\n$2\r\nn*\r\n$4\r\nnews\r\n$3\r\nbye\r\n")
+
+	events := expectEvents(t, redis, 3)
+	assert.Equal(t, "SUBSCRIBE", events[0]["method"])
+	assert.Equal(t, "SUBSCRIBE news sports", events[0]["query"])
+
+	assert.Equal(t, "MESSAGE", events[1]["method"])
+	assert.Equal(t, "news", events[1]["resource"])
+	assert.Equal(t, common.MapStr{
+		"type":         "message",
+		"channel":      "news",
+		"payload_size": 5,
+	}, events[1]["redis"].(common.MapStr)["pubsub"])
+	src := events[1]["src"].(*common.Endpoint)
+	assert.Equal(t, uint16(6512), src.Port)
+
+	assert.Equal(t, "PMESSAGE", events[2]["method"])
+	assert.Equal(t, common.MapStr{
+		"type":         "pmessage",
+		"pattern":      "n*",
+		"channel":      "news",
+		"payload_size": 3,
+	}, events[2]["redis"].(common.MapStr)["pubsub"])
+}
+
+func TestRedis_resp3Push(t *testing.T) {
+	redis := redisModForTests()
+
+	private := send(redis, nil, tcp.TcpDirectionOriginal,
+		"*2\r\n$3\r\nGET\r\n$1\r\na\r\n")
+	private = send(redis, private, tcp.TcpDirectionReverse,
+		">3\r\n$7\r\nmessage\r\n$4\r\nnews\r\n$5\r\nhello\r\n")
+	send(redis, private, tcp.TcpDirectionReverse, "$2\r\nv1\r\n")
+
+	events := expectEvents(t, redis, 2)
+	assert.Equal(t, "MESSAGE", events[0]["method"])
+	assert.Equal(t, "GET a", events[1]["query"])
+	assert.Equal(t, "v1", events[1]["redis"].(common.MapStr)["return_value"])
+}
+
+func TestRedis_clusterRedirect(t *testing.T) {
+	redis := redisModForTests()
+
+	private := send(redis, nil, tcp.TcpDirectionOriginal,
+		"*2\r\n$3\r\nGET\r\n$3\r\nfoo\r\n*2\r\n$3\r\nGET\r\n$3\r\nbar\r\n")
+	send(redis, private, tcp.TcpDirectionReverse,
+		"-MOVED 12182 127.0.0.1:7002\r\n-ASK 5061 127.0.0.1:7001\r\n")
+
+	events := expectEvents(t, redis, 2)
+	assert.Equal(t, common.OK_STATUS, events[0]["status"])
+	assert.Equal(t, common.MapStr{
+		"type": "moved",
+		"slot": 12182,
+		"node": "127.0.0.1:7002",
+	}, events[0]["redis"].(common.MapStr)["redirect"])
+	assert.Equal(t, common.MapStr{
+		"type": "ask",
+		"slot": 5061,
+		"node": "127.0.0.1:7001",
+	}, events[1]["redis"].(common.MapStr)["redirect"])
+}
+
+func TestRedis_requestQueueOverflow(t *testing.T) {
+	redis := redisModForTests()
+
+	var requests bytes.Buffer
+	for i := 0; i <= maxQueuedRequests; i++ {
+		key := strconv.Itoa(i)
+		fmt.Fprintf(&requests, "*2\r\n$3\r\nGET\r\n$%d\r\n%s\r\n", len(key), key)
+	}
+	private := send(redis, nil, tcp.TcpDirectionOriginal, requests.String())
+
+	// the reply to the dropped request GET 0 must not be paired with GET 1
+	send(redis, private, tcp.TcpDirectionReverse, "$2\r\nv0\r\n$2\r\nv1\r\n")
+
+	events := expectEvents(t, redis, 1)
+	assert.Equal(t, "GET 1", events[0]["query"])
+	assert.Equal(t, "v1", events[0]["redis"].(common.MapStr)["return_value"])
+}
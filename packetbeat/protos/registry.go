@@ -0,0 +1,122 @@
+package protos
+
+import (
+	"fmt"
+
+	"github.com/elastic/beats/libbeat/publisher"
+)
+
+// ProtocolInfo describes a protocol plugin. Plugins register their
+// ProtocolInfo from init() by calling Register, so a plugin is compiled
+// into the beat by importing its package. This works for plugins maintained
+// outside of this repository as well.
+type ProtocolInfo struct {
+	// Name of the protocol. The configuration of the plugin is read from
+	// the section of this name in the protocols section.
+	Name string
+
+	// Transports supported by the plugin. The plugin created by New must
+	// implement TcpProtocolPlugin if Tcp is set and UdpProtocolPlugin if Udp
+	// is set.
+	Tcp bool
+	Udp bool
+
+	// DefaultPorts are used if the ports option of the protocol
+	// configuration is not set.
+	DefaultPorts []int
+
+	// Config returns a pointer to a new configuration object the protocol
+	// configuration section is decoded into.
+	Config func() interface{}
+
+	// New creates and initializes the plugin from the configuration
+	// object returned by Config.
+	New func(config interface{}, testMode bool, results publisher.Client) (ProtocolPlugin, error)
+}
+
+// DefaultPortsSetter is implemented by configuration objects supporting
+// default ports.
+type DefaultPortsSetter interface {
+	// SetDefaultPorts sets the ports if the ports option is not set.
+	SetDefaultPorts(ports []int)
+}
+
+// registry of protocol plugins indexed by Protocol. Index 0 is reserved for
+// UnknownProtocol.
+var (
+	registry       = []*ProtocolInfo{nil}
+	registryByName = map[string]Protocol{}
+)
+
+// Register makes a protocol plugin available under the given name and
+// returns the identifier assigned to the protocol. Register panics if the
+// name is empty or already registered.
+func Register(info ProtocolInfo) Protocol {
+	if info.Name == "" {
+		panic("protos: Register called without protocol name")
+	}
+	if _, exists := registryByName[info.Name]; exists {
+		panic("protos: Register called twice for protocol " + info.Name)
+	}
+
+	proto := Protocol(len(registry))
+	registry = append(registry, &info)
+	registryByName[info.Name] = proto
+	return proto
+}
+
+// Lookup returns the identifier of the protocol registered under name.
+func Lookup(name string) (Protocol, bool) {
+	proto, exists := registryByName[name]
+	return proto, exists
+}
+
+// Registered returns the identifiers of all registered protocols in order of
+// registration.
+func Registered() []Protocol {
+	protos := make([]Protocol, 0, len(registry)-1)
+	for i := 1; i < len(registry); i++ {
+		protos = append(protos, Protocol(i))
+	}
+	return protos
+}
+
+// Info returns the registration information of the protocol or nil if the
+// protocol is not registered.
+func (p Protocol) Info() *ProtocolInfo {
+	if p == UnknownProtocol || int(p) >= len(registry) {
+		return nil
+	}
+	return registry[p]
+}
+
+// NewPlugin creates a plugin of the protocol from the configuration object,
+// which must have been created by the Config factory. Default ports are
+// applied before the plugin is created. Unless in test mode, the transactions
+// published by the plugin are counted.
+func (info *ProtocolInfo) NewPlugin(
+	config interface{},
+	testMode bool,
+	results publisher.Client,
+) (ProtocolPlugin, error) {
+	if setter, ok := config.(DefaultPortsSetter); ok {
+		setter.SetDefaultPorts(info.DefaultPorts)
+	}
+
+	if !testMode {
+		results = CountTransactions(info.Name, results)
+	}
+
+	plugin, err := info.New(config, testMode, results)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := plugin.(TcpProtocolPlugin); info.Tcp && !ok {
+		return nil, fmt.Errorf("protocol %s does not implement TCP parsing", info.Name)
+	}
+	if _, ok := plugin.(UdpProtocolPlugin); info.Udp && !ok {
+		return nil, fmt.Errorf("protocol %s does not implement UDP parsing", info.Name)
+	}
+	return plugin, nil
+}
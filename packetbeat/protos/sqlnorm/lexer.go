@@ -0,0 +1,265 @@
+package sqlnorm
+
+import (
+	"strings"
+)
+
+type tokenKind uint8
+
+const (
+	tokenWord       tokenKind = iota // keyword or unquoted identifier
+	tokenIdentifier                  // quoted identifier
+	tokenLiteral                     // string, number or placeholder
+	tokenPunct                       // ( ) , ; .
+	tokenOperator
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// isKeyword returns true if the token is the given lower case keyword.
+func (t token) isKeyword(keyword string) bool {
+	return t.kind == tokenWord && strings.ToLower(t.text) == keyword
+}
+
+func (t token) isPunct(punct string) bool {
+	return t.kind == tokenPunct && t.text == punct
+}
+
+const operatorChars = "<>=!|&+-*/%^~:@"
+
+// lexer splits a SQL query into tokens. Comments and white space are
+// dropped.
+type lexer struct {
+	dialect Dialect
+	query   string
+	pos     int
+	tokens  []token
+}
+
+func tokenize(query string, dialect Dialect) []token {
+	l := &lexer{dialect: dialect, query: query}
+	l.run()
+	return l.tokens
+}
+
+func (l *lexer) emit(kind tokenKind, start int) {
+	l.tokens = append(l.tokens, token{kind: kind, text: l.query[start:l.pos]})
+}
+
+func (l *lexer) peek(offset int) byte {
+	if l.pos+offset < len(l.query) {
+		return l.query[l.pos+offset]
+	}
+	return 0
+}
+
+func (l *lexer) run() {
+	for l.pos < len(l.query) {
+		c := l.query[l.pos]
+		start := l.pos
+
+		switch {
+		case isSpace(c):
+			l.pos++
+		case c == '-' && l.peek(1) == '-',
+			c == '#' && l.dialect == MySQL:
+			l.skipLine()
+		case c == '/' && l.peek(1) == '*':
+			l.skipBlockComment()
+		case c == '\'':
+			l.scanQuoted('\'', l.dialect == MySQL)
+			l.emit(tokenLiteral, start)
+		case c == '"':
+			// double quotes enclose strings in MySQL and identifiers in
+			// standard SQL
+			if l.dialect == MySQL {
+				l.scanQuoted('"', true)
+				l.emit(tokenLiteral, start)
+			} else {
+				l.scanQuoted('"', false)
+				l.emit(tokenIdentifier, start)
+			}
+		case c == '`':
+			l.scanQuoted('`', false)
+			l.emit(tokenIdentifier, start)
+		case c == '$' && l.dialect == PostgreSQL:
+			l.scanDollar()
+		case c == '@' && l.dialect == MySQL:
+			// user (@name) and system (@@name) variables
+			for l.pos < len(l.query) && l.query[l.pos] == '@' {
+				l.pos++
+			}
+			for l.pos < len(l.query) && isWordChar(l.query[l.pos]) {
+				l.pos++
+			}
+			l.emit(tokenWord, start)
+		case c == '?':
+			l.pos++
+			l.emit(tokenLiteral, start)
+		case isDigit(c), c == '.' && isDigit(l.peek(1)):
+			l.scanNumber()
+			l.emit(tokenLiteral, start)
+		case isWordStart(c):
+			l.scanWord()
+		case strings.IndexByte("(),;.[]", c) >= 0:
+			l.pos++
+			l.emit(tokenPunct, start)
+		case strings.IndexByte(operatorChars, c) >= 0:
+			for l.pos < len(l.query) &&
+				strings.IndexByte(operatorChars, l.query[l.pos]) >= 0 {
+				// stop in front of a comment
+				if l.query[l.pos] == '-' && l.peek(1) == '-' ||
+					l.query[l.pos] == '/' && l.peek(1) == '*' {
+					break
+				}
+				l.pos++
+			}
+			l.emit(tokenOperator, start)
+		default:
+			l.pos++
+			l.emit(tokenOperator, start)
+		}
+	}
+}
+
+func (l *lexer) skipLine() {
+	for l.pos < len(l.query) && l.query[l.pos] != '\n' {
+		l.pos++
+	}
+}
+
+func (l *lexer) skipBlockComment() {
+	end := strings.Index(l.query[l.pos+2:], "*/")
+	if end < 0 {
+		l.pos = len(l.query)
+		return
+	}
+	l.pos += 2 + end + 2
+}
+
+// scanQuoted advances past a quoted string. The quote character is escaped
+// by doubling it, or by a backslash if backslash is set.
+func (l *lexer) scanQuoted(quote byte, backslash bool) {
+	l.pos++
+	for l.pos < len(l.query) {
+		c := l.query[l.pos]
+		switch {
+		case backslash && c == '\\':
+			l.pos += 2
+			continue
+		case c == quote:
+			if l.peek(1) == quote {
+				l.pos += 2
+				continue
+			}
+			l.pos++
+			return
+		}
+		l.pos++
+	}
+	if l.pos > len(l.query) {
+		l.pos = len(l.query)
+	}
+}
+
+// scanDollar scans a PostgreSQL positional parameter ($1) or a dollar quoted
+// string ($$...$$ or $tag$...$tag$).
+func (l *lexer) scanDollar() {
+	start := l.pos
+	l.pos++
+	if isDigit(l.peek(0)) {
+		for l.pos < len(l.query) && isDigit(l.query[l.pos]) {
+			l.pos++
+		}
+		l.emit(tokenLiteral, start)
+		return
+	}
+
+	for l.pos < len(l.query) && isWordChar(l.query[l.pos]) && l.query[l.pos] != '$' {
+		l.pos++
+	}
+	if l.peek(0) != '$' {
+		// not a dollar quote
+		l.emit(tokenOperator, start)
+		return
+	}
+	l.pos++
+	tag := l.query[start:l.pos]
+
+	end := strings.Index(l.query[l.pos:], tag)
+	if end < 0 {
+		l.pos = len(l.query)
+	} else {
+		l.pos += end + len(tag)
+	}
+	l.emit(tokenLiteral, start)
+}
+
+func (l *lexer) scanNumber() {
+	if l.query[l.pos] == '0' && (l.peek(1) == 'x' || l.peek(1) == 'X') {
+		l.pos += 2
+		for l.pos < len(l.query) && isHexDigit(l.query[l.pos]) {
+			l.pos++
+		}
+		return
+	}
+
+	for l.pos < len(l.query) && (isDigit(l.query[l.pos]) || l.query[l.pos] == '.') {
+		l.pos++
+	}
+	if c := l.peek(0); c == 'e' || c == 'E' {
+		next := l.peek(1)
+		if isDigit(next) || (next == '+' || next == '-') && isDigit(l.peek(2)) {
+			l.pos += 2
+			for l.pos < len(l.query) && isDigit(l.query[l.pos]) {
+				l.pos++
+			}
+		}
+	}
+}
+
+// scanWord scans keywords and identifiers. String literals with a prefix
+// like X'0a', B'01', N'text' or E'text' are scanned as literals.
+func (l *lexer) scanWord() {
+	start := l.pos
+	for l.pos < len(l.query) && isWordChar(l.query[l.pos]) {
+		l.pos++
+	}
+
+	if l.pos-start == 1 && l.peek(0) == '\'' {
+		switch l.query[start] {
+		case 'x', 'X', 'b', 'B', 'n', 'N':
+			l.scanQuoted('\'', l.dialect == MySQL)
+			l.emit(tokenLiteral, start)
+			return
+		case 'e', 'E':
+			l.scanQuoted('\'', true)
+			l.emit(tokenLiteral, start)
+			return
+		}
+	}
+	l.emit(tokenWord, start)
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+func isWordStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c >= 0x80
+}
+
+func isWordChar(c byte) bool {
+	return isWordStart(c) || isDigit(c) || c == '$'
+}
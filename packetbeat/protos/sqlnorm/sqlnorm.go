@@ -0,0 +1,357 @@
+// Package sqlnorm normalizes SQL queries so that queries of the same shape
+// can be aggregated independent of the literal values used.
+//
+// Normalization replaces all literals (strings, numbers and positional
+// parameters) by a ? placeholder, collapses IN-lists and multi-row VALUES
+// lists, strips comments and white space, and lowercases keywords. A
+// fingerprint of the normalized query, the statement type and the tables
+// referenced by the query are extracted as well.
+package sqlnorm
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+
+	"github.com/elastic/beats/libbeat/common"
+)
+
+// Dialect selects the SQL dialect specific lexical rules.
+type Dialect uint8
+
+const (
+	// MySQL strings may be enclosed in single or double quotes and use
+	// backslash escapes. Identifiers are quoted with backticks.
+	MySQL Dialect = iota
+
+	// PostgreSQL identifiers are quoted with double quotes. Strings can be
+	// dollar quoted, positional parameters are written as $1.
+	PostgreSQL
+)
+
+// Placeholder replacing literal values.
+const Placeholder = "?"
+
+// Statement contains the result of normalizing a SQL query.
+type Statement struct {
+	Query       string   // Normalized query.
+	Fingerprint string   // Hash of the normalized query.
+	Type        string   // Statement type, e.g. SELECT.
+	Tables      []string // Tables referenced by the query.
+}
+
+// ToMapStr returns the statement fields to be added to an event.
+func (s *Statement) ToMapStr() common.MapStr {
+	m := common.MapStr{
+		"normalized_query": s.Query,
+		"fingerprint":      s.Fingerprint,
+		"statement":        s.Type,
+	}
+	if len(s.Tables) > 0 {
+		m["tables"] = s.Tables
+	}
+	return m
+}
+
+// Normalize normalizes the query according to the rules of the dialect.
+func Normalize(query string, dialect Dialect) *Statement {
+	tokens := tokenize(query, dialect)
+	tokens = replaceLiterals(tokens)
+	tokens = collapseLists(tokens)
+
+	normalized := render(tokens)
+
+	hash := fnv.New64a()
+	hash.Write([]byte(normalized))
+
+	return &Statement{
+		Query:       normalized,
+		Fingerprint: fmt.Sprintf("%016x", hash.Sum64()),
+		Type:        statementType(tokens),
+		Tables:      tables(tokens),
+	}
+}
+
+// replaceLiterals replaces literals by the placeholder and lowercases
+// keywords. Signs in front of numbers are folded into the placeholder.
+func replaceLiterals(tokens []token) []token {
+	out := make([]token, 0, len(tokens))
+	for _, t := range tokens {
+		switch t.kind {
+		case tokenWord:
+			lower := strings.ToLower(t.text)
+			if lower == "true" || lower == "false" {
+				t = token{kind: tokenLiteral, text: Placeholder}
+			} else if keywords[lower] {
+				t.text = lower
+			}
+		case tokenLiteral:
+			t.text = Placeholder
+			if n := len(out); n > 0 && isSign(out[n-1]) && !isOperand(out, n-2) {
+				out = out[:n-1]
+			}
+		}
+		out = append(out, t)
+	}
+	return out
+}
+
+func isSign(t token) bool {
+	return t.kind == tokenOperator && (t.text == "-" || t.text == "+")
+}
+
+// isOperand returns true if the token at index i ends an operand, in which
+// case a following sign is a binary operator.
+func isOperand(tokens []token, i int) bool {
+	if i < 0 {
+		return false
+	}
+	t := tokens[i]
+	switch t.kind {
+	case tokenLiteral, tokenIdentifier:
+		return true
+	case tokenWord:
+		return !keywords[strings.ToLower(t.text)]
+	case tokenPunct:
+		return t.text == ")" || t.text == "]"
+	}
+	return false
+}
+
+// collapseLists replaces IN-lists of placeholders by a single (?+) and
+// keeps only the first row of multi-row VALUES lists.
+func collapseLists(tokens []token) []token {
+	out := make([]token, 0, len(tokens))
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		out = append(out, t)
+
+		if t.isKeyword("in") {
+			end := placeholderList(tokens, i+1)
+			if end > 0 {
+				out = append(out,
+					token{kind: tokenPunct, text: "("},
+					token{kind: tokenLiteral, text: Placeholder + "+"},
+					token{kind: tokenPunct, text: ")"})
+				i = end
+			}
+		} else if t.isKeyword("values") {
+			end := placeholderList(tokens, i+1)
+			if end < 0 {
+				continue
+			}
+			out = append(out, tokens[i+1:end+1]...)
+			i = end
+			// skip additional rows
+			for i+2 < len(tokens) && tokens[i+1].isPunct(",") {
+				next := placeholderList(tokens, i+2)
+				if next < 0 {
+					break
+				}
+				i = next
+			}
+		}
+	}
+	return out
+}
+
+// placeholderList checks if the tokens starting at start form a list of
+// placeholders (or NULL) enclosed in parentheses. It returns the index of
+// the closing parenthesis or -1.
+func placeholderList(tokens []token, start int) int {
+	if start >= len(tokens) || !tokens[start].isPunct("(") {
+		return -1
+	}
+	expectValue := true
+	for i := start + 1; i < len(tokens); i++ {
+		t := tokens[i]
+		if expectValue {
+			if t.kind != tokenLiteral && !t.isKeyword("null") {
+				return -1
+			}
+		} else {
+			if t.isPunct(")") {
+				return i
+			}
+			if !t.isPunct(",") {
+				return -1
+			}
+		}
+		expectValue = !expectValue
+	}
+	return -1
+}
+
+// render joins the tokens into the normalized query.
+func render(tokens []token) string {
+	var b []byte
+	for i, t := range tokens {
+		if i > 0 && needsSpace(tokens[i-1], t) {
+			b = append(b, ' ')
+		}
+		b = append(b, t.text...)
+	}
+	return string(b)
+}
+
+func needsSpace(prev, t token) bool {
+	if t.kind == tokenPunct && t.text != "(" {
+		return false
+	}
+	if prev.isPunct("(") || prev.isPunct(".") || prev.isPunct("[") {
+		return false
+	}
+	if t.isPunct("(") && (prev.kind == tokenWord && !keywords[prev.text] ||
+		prev.kind == tokenIdentifier) {
+		// function call
+		return false
+	}
+	return true
+}
+
+// statementType returns the type of the statement. For common table
+// expressions (WITH ...) the type of the main statement is returned.
+func statementType(tokens []token) string {
+	if len(tokens) == 0 || !tokens[0].isKeyword("with") {
+		for _, t := range tokens {
+			if t.kind == tokenWord {
+				return strings.ToUpper(t.text)
+			}
+		}
+		return ""
+	}
+
+	depth := 0
+	for _, t := range tokens[1:] {
+		switch {
+		case t.isPunct("("):
+			depth++
+		case t.isPunct(")"):
+			depth--
+		case depth == 0 && t.kind == tokenWord:
+			switch keyword := strings.ToLower(t.text); keyword {
+			case "select", "insert", "update", "delete":
+				return strings.ToUpper(keyword)
+			}
+		}
+	}
+	return "WITH"
+}
+
+// tables returns the names of the tables following FROM, JOIN, INTO, UPDATE
+// and TABLE in order of appearance. Duplicates are removed.
+func tables(tokens []token) []string {
+	var names []string
+	seen := map[string]bool{}
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		if t.kind != tokenWord {
+			continue
+		}
+
+		switch strings.ToLower(t.text) {
+		case "from":
+			// comma separated table list
+			for {
+				name, next := tableName(tokens, i+1)
+				if name == "" {
+					break
+				}
+				add(name)
+				i = skipAlias(tokens, next)
+				if i+1 >= len(tokens) || !tokens[i+1].isPunct(",") {
+					break
+				}
+				i++
+			}
+		case "join", "into", "update", "table":
+			if name, next := tableName(tokens, i+1); name != "" {
+				add(name)
+				i = next - 1
+			}
+		}
+	}
+	return names
+}
+
+// tableName reads a possibly qualified table name starting at index start.
+// It returns the name without quotes and the index following the name.
+func tableName(tokens []token, start int) (string, int) {
+	// CREATE TABLE IF NOT EXISTS name, DROP TABLE IF EXISTS name
+	i := start
+	if i < len(tokens) && tokens[i].isKeyword("if") {
+		i++
+		for i < len(tokens) && (tokens[i].isKeyword("not") || tokens[i].isKeyword("exists")) {
+			i++
+		}
+	}
+
+	var parts []string
+	for i < len(tokens) {
+		t := tokens[i]
+		switch {
+		case t.kind == tokenIdentifier:
+			parts = append(parts, unquote(t.text))
+		case t.kind == tokenWord && !keywords[strings.ToLower(t.text)]:
+			parts = append(parts, t.text)
+		default:
+			return strings.Join(parts, "."), i
+		}
+		i++
+		if i >= len(tokens) || !tokens[i].isPunct(".") {
+			break
+		}
+		i++
+	}
+	return strings.Join(parts, "."), i
+}
+
+// skipAlias skips an optional table alias starting at index start. It
+// returns the index of the last token belonging to the table reference.
+func skipAlias(tokens []token, start int) int {
+	i := start
+	if i < len(tokens) && tokens[i].isKeyword("as") {
+		i++
+	}
+	if i < len(tokens) && (tokens[i].kind == tokenIdentifier ||
+		tokens[i].kind == tokenWord && !keywords[strings.ToLower(tokens[i].text)]) {
+		return i
+	}
+	return start - 1
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 {
+		q := s[0:1]
+		return strings.Replace(s[1:len(s)-1], q+q, q, -1)
+	}
+	return s
+}
+
+// keywords contains the SQL keywords which are lowercased by the normalizer.
+var keywords = map[string]bool{}
+
+func init() {
+	for _, keyword := range strings.Fields(`
+		add all alter analyze and any as asc begin between by call cascade
+		case check column commit constraint create cross current_date
+		current_time current_timestamp database default delete desc describe
+		distinct drop duplicate else end escape except exists explain false
+		fetch first for foreign from full grant group having if ignore ilike
+		in index inner insert intersect interval into is join key last lateral
+		left like limit lock natural next not null nulls of offset on only or
+		order outer over partition primary procedure references rename
+		replace returning revoke right rollback rows savepoint schema select
+		set share show some start table then to transaction true truncate
+		union unique update use using values view when where window with
+	`) {
+		keywords[keyword] = true
+	}
+}
@@ -0,0 +1,129 @@
+package sqlnorm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize_mysql(t *testing.T) {
+	tests := []struct {
+		query      string
+		normalized string
+	}{
+		{
+			"SELECT * FROM users WHERE id = 42",
+			"select * from users where id = ?",
+		},
+		{
+			"select name,  email from `users`\n\twhere name='O\\'Brien' and email = \"a@b.c\"",
+			"select name, email from `users` where name = ? and email = ?",
+		},
+		{
+			"SELECT COUNT(*) FROM t WHERE a IN (1, 2, 3) AND b IN ('x')",
+			"select COUNT(*) from t where a in (?+) and b in (?+)",
+		},
+		{
+			"INSERT INTO t (a, b) VALUES (1, 'x'), (2, 'y'), (3, NULL)",
+			"insert into t(a, b) values (?, ?)",
+		},
+		{
+			"UPDATE t SET a = -1.5e3, b = a - 2, c = X'0F', d = 0xff, e = TRUE WHERE id = ?",
+			"update t set a = ?, b = a - ?, c = ?, d = ?, e = ? where id = ?",
+		},
+		{
+			"/* app:42 */ SELECT 1 -- trailing\n# mysql comment\n",
+			"select ?",
+		},
+		{
+			"SELECT @@version, @name",
+			"select @@version, @name",
+		},
+	}
+
+	for _, test := range tests {
+		stmt := Normalize(test.query, MySQL)
+		assert.Equal(t, test.normalized, stmt.Query, test.query)
+	}
+}
+
+func TestNormalize_pgsql(t *testing.T) {
+	tests := []struct {
+		query      string
+		normalized string
+	}{
+		{
+			`SELECT "Name" FROM "Users" WHERE id = $1 AND note = 'it''s'`,
+			`select "Name" from "Users" where id = ? and note = ?`,
+		},
+		{
+			"SELECT $$a 'quoted' string$$, $tag$body$tag$, E'\\n'",
+			"select ?, ?, ?",
+		},
+		{
+			"select '2016-01-01'::date",
+			"select ? :: date",
+		},
+	}
+
+	for _, test := range tests {
+		stmt := Normalize(test.query, PostgreSQL)
+		assert.Equal(t, test.normalized, stmt.Query, test.query)
+	}
+}
+
+// Verify that queries differing only in literals share the fingerprint.
+func TestNormalize_fingerprint(t *testing.T) {
+	a := Normalize("SELECT * FROM t WHERE id IN (1,2) AND name = 'a'", MySQL)
+	b := Normalize("select *\nfrom t where id in (3, 4, 5) and name = \"b\"", MySQL)
+	c := Normalize("SELECT * FROM t WHERE id = 1", MySQL)
+
+	assert.Equal(t, a.Fingerprint, b.Fingerprint)
+	assert.NotEqual(t, a.Fingerprint, c.Fingerprint)
+	assert.Len(t, a.Fingerprint, 16)
+}
+
+func TestNormalize_statementType(t *testing.T) {
+	tests := map[string]string{
+		"select 1":                   "SELECT",
+		"  Insert into t values (1)": "INSERT",
+		"WITH x AS (SELECT 1) UPDATE t SET a = 1":        "UPDATE",
+		"with recursive x as (select 1) select * from x": "SELECT",
+		"BEGIN": "BEGIN",
+		"":      "",
+	}
+
+	for query, typ := range tests {
+		assert.Equal(t, typ, Normalize(query, PostgreSQL).Type, query)
+	}
+}
+
+func TestNormalize_tables(t *testing.T) {
+	tests := []struct {
+		query  string
+		tables []string
+	}{
+		{"SELECT * FROM users u, `db`.`orders` AS o WHERE u.id = o.uid",
+			[]string{"users", "db.orders"}},
+		{"SELECT * FROM a JOIN b ON a.id = b.id LEFT JOIN c USING (id)",
+			[]string{"a", "b", "c"}},
+		{"INSERT INTO logs (msg) SELECT msg FROM (SELECT msg FROM tmp) x",
+			[]string{"logs", "tmp"}},
+		{"UPDATE accounts SET balance = 0", []string{"accounts"}},
+		{"DELETE FROM sessions WHERE expired", []string{"sessions"}},
+		{"CREATE TABLE IF NOT EXISTS foo (id int)", []string{"foo"}},
+		{"SELECT 1", nil},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.tables, Normalize(test.query, MySQL).Tables, test.query)
+	}
+}
+
+func TestStatement_ToMapStr(t *testing.T) {
+	m := Normalize("SELECT 1", MySQL).ToMapStr()
+	assert.Equal(t, "select ?", m["normalized_query"])
+	assert.Equal(t, "SELECT", m["statement"])
+	assert.NotNil(t, m["fingerprint"])
+	assert.Nil(t, m["tables"])
+}
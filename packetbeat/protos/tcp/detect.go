@@ -0,0 +1,142 @@
+package tcp
+
+import (
+	"sort"
+
+	"github.com/elastic/beats/libbeat/logp"
+
+	"github.com/elastic/beats/packetbeat/protos"
+)
+
+// detectState buffers the first segments of a stream on an unknown port
+// until one of the protocol detectors claims the stream.
+type detectState struct {
+	segments []detectSegment
+	data     [2][]byte
+	packets  int
+}
+
+// detectSegment is a segment seen during protocol detection. Segments are
+// replayed to the protocol plugin once the stream has been detected.
+type detectSegment struct {
+	pkt protos.Packet
+	dir uint8
+	fin bool
+}
+
+func (tcp *Tcp) detectionEnabled() bool {
+	return tcp.detection.Enabled && len(tcp.detectors) > 0
+}
+
+// detectProtocol offers the payload buffered so far to the protocol
+// detectors. If a detector claims the stream, the stream is bound to the
+// protocol and all buffered segments are passed to the protocol plugin.
+// Detection stops without a match if no detector requires more data or if
+// the configured byte or packet limits are reached.
+func (stream *TcpStream) detectProtocol(pkt *protos.Packet, fin bool, dir uint8) {
+	state := stream.detect
+	if state == nil {
+		// detection finished without match
+		return
+	}
+
+	tcp := stream.tcp
+	if len(pkt.Payload) == 0 && !fin {
+		return
+	}
+
+	segment := detectSegment{pkt: *pkt, dir: dir, fin: fin}
+	segment.pkt.Payload = make([]byte, len(pkt.Payload))
+	copy(segment.pkt.Payload, pkt.Payload)
+	state.segments = append(state.segments, segment)
+
+	if len(pkt.Payload) == 0 {
+		return
+	}
+
+	state.packets++
+	state.data[dir] = append(state.data[dir], pkt.Payload...)
+
+	data := state.data[dir]
+	limitReached := state.packets >= tcp.detection.MaxPackets
+	if len(data) >= tcp.detection.MaxBytes {
+		data = data[:tcp.detection.MaxBytes]
+		limitReached = true
+	}
+
+	protocol, result := tcp.detect(data, dir)
+	switch result {
+	case protos.DetectMatch:
+		logp.Debug("tcp", "Detected protocol %s for stream %s", protocol, stream)
+		stream.protocol = protocol
+		stream.detect = nil
+		stream.replay(state.segments)
+	case protos.DetectNeedMoreData:
+		if limitReached {
+			logp.Debug("tcp", "Detection limit reached, ignoring stream %s", stream)
+			stream.detect = nil
+		}
+	default:
+		logp.Debug("tcp", "No protocol detected, ignoring stream %s", stream)
+		stream.detect = nil
+	}
+}
+
+// replay passes the segments buffered during detection to the protocol
+// plugin the stream has been bound to.
+func (stream *TcpStream) replay(segments []detectSegment) {
+	mod := stream.tcp.protocols.GetTcp(stream.protocol)
+	if mod == nil {
+		return
+	}
+
+	for i := range segments {
+		segment := &segments[i]
+		if len(segment.pkt.Payload) > 0 {
+			stream.data = mod.Parse(&segment.pkt, &stream.tcptuple,
+				segment.dir, stream.data)
+		}
+		if segment.fin {
+			stream.data = mod.ReceivedFin(&stream.tcptuple, segment.dir, stream.data)
+		}
+	}
+}
+
+// detect runs all protocol detectors on data. The first detector claiming
+// the data wins. DetectNeedMoreData is returned if no detector matched, but
+// at least one detector requires more data to decide.
+func (tcp *Tcp) detect(data []byte, dir uint8) (protos.Protocol, protos.DetectResult) {
+	result := protos.DetectNoMatch
+	for _, protocol := range tcp.detectors {
+		detector, ok := tcp.protocols.GetTcp(protocol).(protos.TcpProtocolDetector)
+		if !ok {
+			continue
+		}
+
+		switch detector.DetectTcp(data, dir) {
+		case protos.DetectMatch:
+			return protocol, protos.DetectMatch
+		case protos.DetectNeedMoreData:
+			result = protos.DetectNeedMoreData
+		}
+	}
+	return protos.UnknownProtocol, result
+}
+
+// buildDetectors returns the identifiers of all plugins supporting protocol
+// detection, sorted by identifier so detection order is consistent.
+func buildDetectors(plugins map[protos.Protocol]protos.TcpProtocolPlugin) []protos.Protocol {
+	var ids []int
+	for proto, plugin := range plugins {
+		if _, ok := plugin.(protos.TcpProtocolDetector); ok {
+			ids = append(ids, int(proto))
+		}
+	}
+	sort.Ints(ids)
+
+	detectors := make([]protos.Protocol, len(ids))
+	for i, id := range ids {
+		detectors[i] = protos.Protocol(id)
+	}
+	return detectors
+}
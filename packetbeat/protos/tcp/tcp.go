@@ -24,6 +24,8 @@ type Tcp struct {
 	streams   *common.Cache
 	portMap   map[uint16]protos.Protocol
 	protocols protos.Protocols
+	detection protos.DetectionConfig
+	detectors []protos.Protocol
 }
 
 type Processor interface {
@@ -66,6 +68,10 @@ type TcpStream struct {
 
 	lastSeq [2]uint32
 
+	// protocol detection state. Only set while the protocol of a stream on
+	// an unknown port is being detected.
+	detect *detectState
+
 	// protocols private data
 	data protos.ProtocolData
 }
@@ -76,6 +82,11 @@ func (stream *TcpStream) String() string {
 }
 
 func (stream *TcpStream) addPacket(pkt *protos.Packet, tcphdr *layers.TCP, original_dir uint8) {
+	if stream.protocol == protos.UnknownProtocol {
+		stream.detectProtocol(pkt, tcphdr.FIN, original_dir)
+		return
+	}
+
 	mod := stream.tcp.protocols.GetTcp(stream.protocol)
 	if mod == nil {
 		logp.Debug("tcp", "Ignoring protocol for which we have no module "+
@@ -93,6 +104,12 @@ func (stream *TcpStream) addPacket(pkt *protos.Packet, tcphdr *layers.TCP, origi
 }
 
 func (stream *TcpStream) gapInStream(original_dir uint8, nbytes int) (drop bool) {
+	if stream.protocol == protos.UnknownProtocol {
+		// can not detect the protocol with missing data, ignore the stream
+		stream.detect = nil
+		return false
+	}
+
 	mod := stream.tcp.protocols.GetTcp(stream.protocol)
 	stream.data, drop = mod.GapInStream(&stream.tcptuple, original_dir, nbytes, stream.data)
 	return drop
@@ -119,7 +136,7 @@ func (tcp *Tcp) Process(tcphdr *layers.TCP, pkt *protos.Packet) {
 		stream = tcp.getStream(pkt.Tuple.RevHashable())
 		if stream == nil {
 			protocol := tcp.decideProtocol(&pkt.Tuple)
-			if protocol == protos.UnknownProtocol {
+			if protocol == protos.UnknownProtocol && !tcp.detectionEnabled() {
 				// don't follow
 				return
 			}
@@ -135,6 +152,9 @@ func (tcp *Tcp) Process(tcphdr *layers.TCP, pkt *protos.Packet) {
 			// create
 			stream = &TcpStream{id: tcp.getId(), tuple: &pkt.Tuple, protocol: protocol, tcp: tcp}
 			stream.tcptuple = common.TcpTupleFromIpPort(stream.tuple, stream.id)
+			if protocol == protos.UnknownProtocol {
+				stream.detect = &detectState{}
+			}
 			tcp.streams.PutWithTimeout(pkt.Tuple.Hashable(), stream, timeout)
 			created = true
 		} else {
@@ -193,22 +213,35 @@ func buildPortsMap(plugins map[protos.Protocol]protos.TcpProtocolPlugin) (map[ui
 	return res, nil
 }
 
-// Creates and returns a new Tcp.
-func NewTcp(p protos.Protocols) (*Tcp, error) {
+// Creates and returns a new Tcp. If detection is enabled, streams on
+// unknown ports are offered to the plugins supporting protocol detection.
+func NewTcp(p protos.Protocols, detection protos.DetectionConfig) (*Tcp, error) {
 	portMap, err := buildPortsMap(p.GetAllTcp())
 	if err != nil {
 		return nil, err
 	}
 
+	if detection.MaxBytes <= 0 {
+		detection.MaxBytes = protos.DefaultDetectionMaxBytes
+	}
+	if detection.MaxPackets <= 0 {
+		detection.MaxPackets = protos.DefaultDetectionMaxPackets
+	}
+
 	tcp := &Tcp{
 		protocols: p,
 		portMap:   portMap,
+		detection: detection,
+		detectors: buildDetectors(p.GetAllTcp()),
 		streams: common.NewCache(
 			protos.DefaultTransactionExpiration,
 			protos.DefaultTransactionHashSize),
 	}
 	tcp.streams.StartJanitor(protos.DefaultTransactionExpiration)
 	logp.Debug("tcp", "Port map: %v", portMap)
+	if tcp.detectionEnabled() {
+		logp.Debug("tcp", "Protocol detection enabled for: %v", tcp.detectors)
+	}
 
 	return tcp, nil
 }
@@ -21,6 +21,13 @@ const (
 	ClientIp   = "10.0.0.1"
 )
 
+// Protocol IDs used by the port mapping tests.
+const (
+	httpProtocol protos.Protocol = iota + 1
+	mysqlProtocol
+	redisProtocol
+)
+
 type TestProtocol struct {
 	Ports []int
 }
@@ -64,38 +71,38 @@ func Test_configToPortsMap(t *testing.T) {
 	config_tests := []configTest{
 		{
 			Input: map[protos.Protocol]protos.TcpProtocolPlugin{
-				protos.HttpProtocol: &TestProtocol{Ports: []int{80, 8080}},
+				httpProtocol: &TestProtocol{Ports: []int{80, 8080}},
 			},
 			Output: map[uint16]protos.Protocol{
-				80:   protos.HttpProtocol,
-				8080: protos.HttpProtocol,
+				80:   httpProtocol,
+				8080: httpProtocol,
 			},
 		},
 		{
 			Input: map[protos.Protocol]protos.TcpProtocolPlugin{
-				protos.HttpProtocol:  &TestProtocol{Ports: []int{80, 8080}},
-				protos.MysqlProtocol: &TestProtocol{Ports: []int{3306}},
-				protos.RedisProtocol: &TestProtocol{Ports: []int{6379, 6380}},
+				httpProtocol:  &TestProtocol{Ports: []int{80, 8080}},
+				mysqlProtocol: &TestProtocol{Ports: []int{3306}},
+				redisProtocol: &TestProtocol{Ports: []int{6379, 6380}},
 			},
 			Output: map[uint16]protos.Protocol{
-				80:   protos.HttpProtocol,
-				8080: protos.HttpProtocol,
-				3306: protos.MysqlProtocol,
-				6379: protos.RedisProtocol,
-				6380: protos.RedisProtocol,
+				80:   httpProtocol,
+				8080: httpProtocol,
+				3306: mysqlProtocol,
+				6379: redisProtocol,
+				6380: redisProtocol,
 			},
 		},
 
 		// should ignore duplicate ports in the same protocol
 		{
 			Input: map[protos.Protocol]protos.TcpProtocolPlugin{
-				protos.HttpProtocol:  &TestProtocol{Ports: []int{80, 8080, 8080}},
-				protos.MysqlProtocol: &TestProtocol{Ports: []int{3306}},
+				httpProtocol:  &TestProtocol{Ports: []int{80, 8080, 8080}},
+				mysqlProtocol: &TestProtocol{Ports: []int{3306}},
 			},
 			Output: map[uint16]protos.Protocol{
-				80:   protos.HttpProtocol,
-				8080: protos.HttpProtocol,
-				3306: protos.MysqlProtocol,
+				80:   httpProtocol,
+				8080: httpProtocol,
+				3306: mysqlProtocol,
 			},
 		},
 	}
@@ -118,9 +125,9 @@ func Test_configToPortsMap_negative(t *testing.T) {
 		{
 			// should raise error on duplicate port
 			Input: map[protos.Protocol]protos.TcpProtocolPlugin{
-				protos.HttpProtocol:  &TestProtocol{Ports: []int{80, 8080}},
-				protos.MysqlProtocol: &TestProtocol{Ports: []int{3306}},
-				protos.RedisProtocol: &TestProtocol{Ports: []int{6379, 6380, 3306}},
+				httpProtocol:  &TestProtocol{Ports: []int{80, 8080}},
+				mysqlProtocol: &TestProtocol{Ports: []int{3306}},
+				redisProtocol: &TestProtocol{Ports: []int{6379, 6380, 3306}},
 			},
 			Err: "Duplicate port (3306) exists",
 		},
@@ -141,7 +148,7 @@ type protocols struct {
 // Verify protocols implements the protos.Protocols interface.
 var _ protos.Protocols = &protocols{}
 
-func (p protocols) BpfFilter(with_vlans bool, with_icmp bool) string             { return "" }
+func (p protocols) BpfFilter(with_vlans, with_icmp, with_detection bool) string  { return "" }
 func (p protocols) GetTcp(proto protos.Protocol) protos.TcpProtocolPlugin        { return p.tcp[proto] }
 func (p protocols) GetUdp(proto protos.Protocol) protos.UdpProtocolPlugin        { return nil }
 func (p protocols) GetAll() map[protos.Protocol]protos.ProtocolPlugin            { return nil }
@@ -157,7 +164,7 @@ func BenchmarkParallelProcess(b *testing.B) {
 	p := protocols{}
 	p.tcp = make(map[protos.Protocol]protos.TcpProtocolPlugin)
 	p.tcp[1] = &TestProtocol{Ports: []int{ServerPort}}
-	tcp, _ := NewTcp(p)
+	tcp, _ := NewTcp(p, protos.DetectionConfig{})
 
 	b.ResetTimer()
 	b.RunParallel(func(pb *testing.PB) {
@@ -173,3 +180,112 @@ func BenchmarkParallelProcess(b *testing.B) {
 		}
 	})
 }
+
+// detectProtocol is a protocol plugin claiming streams starting with its
+// magic bytes. All payloads passed to Parse are recorded.
+type detectProtocol struct {
+	TestProtocol
+	magic    []byte
+	payloads [][]byte
+	fins     int
+}
+
+func (proto *detectProtocol) DetectTcp(data []byte, dir uint8) protos.DetectResult {
+	if len(data) < len(proto.magic) {
+		if string(data) == string(proto.magic[:len(data)]) {
+			return protos.DetectNeedMoreData
+		}
+		return protos.DetectNoMatch
+	}
+	if string(data[:len(proto.magic)]) == string(proto.magic) {
+		return protos.DetectMatch
+	}
+	return protos.DetectNoMatch
+}
+
+func (proto *detectProtocol) Parse(pkt *protos.Packet, tcptuple *common.TcpTuple,
+	dir uint8, private protos.ProtocolData) protos.ProtocolData {
+	proto.payloads = append(proto.payloads, pkt.Payload)
+	return private
+}
+
+func (proto *detectProtocol) ReceivedFin(tcptuple *common.TcpTuple, dir uint8,
+	private protos.ProtocolData) protos.ProtocolData {
+	proto.fins++
+	return private
+}
+
+func newDetectTest(max_packets int) (*Tcp, *detectProtocol) {
+	plugin := &detectProtocol{
+		TestProtocol: TestProtocol{Ports: []int{ServerPort}},
+		magic:        []byte("MAGIC"),
+	}
+	p := protocols{}
+	p.tcp = map[protos.Protocol]protos.TcpProtocolPlugin{1: plugin}
+	tcp, _ := NewTcp(p, protos.DetectionConfig{
+		Enabled:    true,
+		MaxPackets: max_packets,
+	})
+	return tcp, plugin
+}
+
+func detectTestPacket(seq uint32, payload string) (*layers.TCP, *protos.Packet) {
+	pkt := &protos.Packet{
+		Ts: time.Now(),
+		Tuple: common.NewIpPortTuple(4,
+			net.ParseIP(ClientIp), 34567,
+			net.ParseIP(ServerIp), 8765),
+		Payload: []byte(payload),
+	}
+	return &layers.TCP{Seq: seq}, pkt
+}
+
+func TestProcess_detectProtocol(t *testing.T) {
+	tcp, plugin := newDetectTest(4)
+
+	hdr, pkt := detectTestPacket(1, "MA")
+	tcp.Process(hdr, pkt)
+	assert.Empty(t, plugin.payloads)
+
+	hdr, pkt = detectTestPacket(3, "GIC request")
+	tcp.Process(hdr, pkt)
+	assert.Equal(t, [][]byte{[]byte("MA"), []byte("GIC request")}, plugin.payloads)
+
+	hdr, pkt = detectTestPacket(14, "more")
+	hdr.FIN = true
+	tcp.Process(hdr, pkt)
+	assert.Len(t, plugin.payloads, 3)
+	assert.Equal(t, 1, plugin.fins)
+}
+
+func TestProcess_detectProtocolNoMatch(t *testing.T) {
+	tcp, plugin := newDetectTest(4)
+
+	hdr, pkt := detectTestPacket(1, "OTHER")
+	tcp.Process(hdr, pkt)
+	hdr, pkt = detectTestPacket(6, "MAGIC")
+	tcp.Process(hdr, pkt)
+	assert.Empty(t, plugin.payloads)
+}
+
+func TestProcess_detectProtocolPacketLimit(t *testing.T) {
+	tcp, plugin := newDetectTest(2)
+
+	for i, payload := range []string{"M", "A", "GIC"} {
+		hdr, pkt := detectTestPacket(uint32(i+1), payload)
+		tcp.Process(hdr, pkt)
+	}
+	assert.Empty(t, plugin.payloads)
+}
+
+func TestProcess_detectionDisabled(t *testing.T) {
+	p := protocols{}
+	plugin := &detectProtocol{magic: []byte("MAGIC")}
+	p.tcp = map[protos.Protocol]protos.TcpProtocolPlugin{1: plugin}
+	tcp, _ := NewTcp(p, protos.DetectionConfig{})
+
+	hdr, pkt := detectTestPacket(1, "MAGIC")
+	tcp.Process(hdr, pkt)
+	assert.Empty(t, plugin.payloads)
+	assert.Nil(t, tcp.getStream(pkt.Tuple.Hashable()))
+}
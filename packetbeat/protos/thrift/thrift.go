@@ -135,6 +135,16 @@ const (
 	ThriftTFramed = 2
 )
 
+func init() {
+	protos.Register(protos.ProtocolInfo{
+		Name:         "thrift",
+		Tcp:          true,
+		DefaultPorts: []int{9090},
+		Config:       func() interface{} { return &config.Thrift{} },
+		New:          New,
+	})
+}
+
 type Thrift struct {
 
 	// config
@@ -241,12 +251,29 @@ func (thrift *Thrift) GetPorts() []int {
 	return thrift.Ports
 }
 
+// New creates a Thrift-RPC protocol plugin from its configuration.
+func New(cfg interface{}, testMode bool, results publisher.Client) (protos.ProtocolPlugin, error) {
+	thrift := &Thrift{}
+	if err := thrift.InitWithConfig(*cfg.(*config.Thrift), testMode, results); err != nil {
+		return nil, err
+	}
+	return thrift, nil
+}
+
 func (thrift *Thrift) Init(test_mode bool, results publisher.Client) error {
+	return thrift.InitWithConfig(config.Thrift{}, test_mode, results)
+}
+
+func (thrift *Thrift) InitWithConfig(
+	config config.Thrift,
+	test_mode bool,
+	results publisher.Client,
+) error {
 
 	thrift.InitDefaults()
 
 	if !test_mode {
-		err := thrift.readConfig(config.ConfigSingleton.Protocols.Thrift)
+		err := thrift.readConfig(config)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,113 @@
+package udp
+
+import (
+	"sort"
+
+	"github.com/elastic/beats/libbeat/logp"
+
+	"github.com/elastic/beats/packetbeat/protos"
+)
+
+// udpFlow stores the protocol detection state for the packets exchanged
+// between two endpoints on an unknown port.
+type udpFlow struct {
+	protocol protos.Protocol
+	packets  int
+	done     bool
+}
+
+func (udp *Udp) detectionEnabled() bool {
+	return udp.detection.Enabled && len(udp.detectors) > 0
+}
+
+func (udp *Udp) getFlow(pkt *protos.Packet) *udpFlow {
+	if v := udp.flows.Get(pkt.Tuple.Hashable()); v != nil {
+		return v.(*udpFlow)
+	}
+	if v := udp.flows.Get(pkt.Tuple.RevHashable()); v != nil {
+		return v.(*udpFlow)
+	}
+	return nil
+}
+
+// detectProtocol offers the payload of a packet on an unknown port to the
+// protocol detectors. The result is remembered per flow, so once a flow has
+// been detected all following packets are passed to the same plugin.
+// Detection on a flow stops without a match if no detector requires more
+// data or if the configured packet limit is reached.
+func (udp *Udp) detectProtocol(pkt *protos.Packet) protos.Protocol {
+	if !udp.detectionEnabled() || len(pkt.Payload) == 0 {
+		return protos.UnknownProtocol
+	}
+
+	flow := udp.getFlow(pkt)
+	if flow == nil {
+		flow = &udpFlow{}
+		udp.flows.Put(pkt.Tuple.Hashable(), flow)
+	}
+	if flow.done {
+		return flow.protocol
+	}
+
+	data := pkt.Payload
+	if len(data) > udp.detection.MaxBytes {
+		data = data[:udp.detection.MaxBytes]
+	}
+	flow.packets++
+
+	protocol, result := udp.detect(data)
+	switch result {
+	case protos.DetectMatch:
+		logp.Debug("udp", "Detected protocol %s for %s", protocol, pkt.Tuple.String())
+		flow.protocol = protocol
+		flow.done = true
+	case protos.DetectNeedMoreData:
+		if flow.packets >= udp.detection.MaxPackets {
+			logp.Debug("udp", "Detection limit reached for %s", pkt.Tuple.String())
+			flow.done = true
+		}
+	default:
+		logp.Debug("udp", "No protocol detected for %s", pkt.Tuple.String())
+		flow.done = true
+	}
+	return flow.protocol
+}
+
+// detect runs all protocol detectors on data. The first detector claiming
+// the data wins. DetectNeedMoreData is returned if no detector matched, but
+// at least one detector requires more data to decide.
+func (udp *Udp) detect(data []byte) (protos.Protocol, protos.DetectResult) {
+	result := protos.DetectNoMatch
+	for _, protocol := range udp.detectors {
+		detector, ok := udp.protocols.GetUdp(protocol).(protos.UdpProtocolDetector)
+		if !ok {
+			continue
+		}
+
+		switch detector.DetectUdp(data) {
+		case protos.DetectMatch:
+			return protocol, protos.DetectMatch
+		case protos.DetectNeedMoreData:
+			result = protos.DetectNeedMoreData
+		}
+	}
+	return protos.UnknownProtocol, result
+}
+
+// buildDetectors returns the identifiers of all plugins supporting protocol
+// detection, sorted by identifier so detection order is consistent.
+func buildDetectors(plugins map[protos.Protocol]protos.UdpProtocolPlugin) []protos.Protocol {
+	var ids []int
+	for proto, plugin := range plugins {
+		if _, ok := plugin.(protos.UdpProtocolDetector); ok {
+			ids = append(ids, int(proto))
+		}
+	}
+	sort.Ints(ids)
+
+	detectors := make([]protos.Protocol, len(ids))
+	for i, id := range ids {
+		detectors[i] = protos.Protocol(id)
+	}
+	return detectors
+}
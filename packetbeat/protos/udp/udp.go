@@ -12,6 +12,9 @@ import (
 type Udp struct {
 	protocols protos.Protocols
 	portMap   map[uint16]protos.Protocol
+	detection protos.DetectionConfig
+	detectors []protos.Protocol
+	flows     *common.Cache
 }
 
 type Processor interface {
@@ -41,6 +44,9 @@ func (udp *Udp) decideProtocol(tuple *common.IpPortTuple) protos.Protocol {
 // or the payload is empty then the method is a noop.
 func (udp *Udp) Process(pkt *protos.Packet) {
 	protocol := udp.decideProtocol(&pkt.Tuple)
+	if protocol == protos.UnknownProtocol {
+		protocol = udp.detectProtocol(pkt)
+	}
 	if protocol == protos.UnknownProtocol {
 		logp.Debug("udp", "unknown protocol")
 		return
@@ -82,15 +88,36 @@ func buildPortsMap(plugins map[protos.Protocol]protos.UdpProtocolPlugin) (map[ui
 	return res, nil
 }
 
-// NewUdp creates and returns a new Udp.
-func NewUdp(p protos.Protocols) (*Udp, error) {
+// NewUdp creates and returns a new Udp. If detection is enabled, packets on
+// unknown ports are offered to the plugins supporting protocol detection.
+func NewUdp(p protos.Protocols, detection protos.DetectionConfig) (*Udp, error) {
 	portMap, err := buildPortsMap(p.GetAllUdp())
 	if err != nil {
 		return nil, err
 	}
 
-	udp := &Udp{protocols: p, portMap: portMap}
+	if detection.MaxBytes <= 0 {
+		detection.MaxBytes = protos.DefaultDetectionMaxBytes
+	}
+	if detection.MaxPackets <= 0 {
+		detection.MaxPackets = protos.DefaultDetectionMaxPackets
+	}
+
+	udp := &Udp{
+		protocols: p,
+		portMap:   portMap,
+		detection: detection,
+		detectors: buildDetectors(p.GetAllUdp()),
+	}
 	logp.Debug("udp", "Port map: %v", portMap)
 
+	if udp.detectionEnabled() {
+		udp.flows = common.NewCache(
+			protos.DefaultTransactionExpiration,
+			protos.DefaultTransactionHashSize)
+		udp.flows.StartJanitor(protos.DefaultTransactionExpiration)
+		logp.Debug("udp", "Protocol detection enabled for: %v", udp.detectors)
+	}
+
 	return udp, nil
 }
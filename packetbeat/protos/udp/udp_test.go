@@ -1,6 +1,7 @@
 package udp
 
 import (
+	"bytes"
 	"net"
 	"testing"
 	"time"
@@ -19,11 +20,18 @@ const (
 	PORT  = 1234
 )
 
+// Protocol IDs used by the port mapping tests.
+const (
+	httpProtocol protos.Protocol = iota + 1
+	mysqlProtocol
+	redisProtocol
+)
+
 type TestProtocols struct {
 	udp map[protos.Protocol]protos.UdpProtocolPlugin
 }
 
-func (p TestProtocols) BpfFilter(with_vlans bool, with_icmp bool) string {
+func (p TestProtocols) BpfFilter(with_vlans bool, with_icmp bool, with_detection bool) string {
 	return "mock bpf filter"
 }
 
@@ -85,7 +93,7 @@ func testSetup(t *testing.T) *TestStruct {
 	plugin := &TestProtocol{Ports: []int{PORT}}
 	protocols.udp[PROTO] = plugin
 
-	udp, err := NewUdp(protocols)
+	udp, err := NewUdp(protocols, protos.DetectionConfig{})
 	if err != nil {
 		t.Error("Error creating UDP handler: ", err)
 	}
@@ -105,38 +113,38 @@ func Test_buildPortsMap(t *testing.T) {
 	config_tests := []configTest{
 		{
 			Input: map[protos.Protocol]protos.UdpProtocolPlugin{
-				protos.HttpProtocol: &TestProtocol{Ports: []int{80, 8080}},
+				httpProtocol: &TestProtocol{Ports: []int{80, 8080}},
 			},
 			Output: map[uint16]protos.Protocol{
-				80:   protos.HttpProtocol,
-				8080: protos.HttpProtocol,
+				80:   httpProtocol,
+				8080: httpProtocol,
 			},
 		},
 		{
 			Input: map[protos.Protocol]protos.UdpProtocolPlugin{
-				protos.HttpProtocol:  &TestProtocol{Ports: []int{80, 8080}},
-				protos.MysqlProtocol: &TestProtocol{Ports: []int{3306}},
-				protos.RedisProtocol: &TestProtocol{Ports: []int{6379, 6380}},
+				httpProtocol:  &TestProtocol{Ports: []int{80, 8080}},
+				mysqlProtocol: &TestProtocol{Ports: []int{3306}},
+				redisProtocol: &TestProtocol{Ports: []int{6379, 6380}},
 			},
 			Output: map[uint16]protos.Protocol{
-				80:   protos.HttpProtocol,
-				8080: protos.HttpProtocol,
-				3306: protos.MysqlProtocol,
-				6379: protos.RedisProtocol,
-				6380: protos.RedisProtocol,
+				80:   httpProtocol,
+				8080: httpProtocol,
+				3306: mysqlProtocol,
+				6379: redisProtocol,
+				6380: redisProtocol,
 			},
 		},
 
 		// should ignore duplicate ports in the same protocol
 		{
 			Input: map[protos.Protocol]protos.UdpProtocolPlugin{
-				protos.HttpProtocol:  &TestProtocol{Ports: []int{80, 8080, 8080}},
-				protos.MysqlProtocol: &TestProtocol{Ports: []int{3306}},
+				httpProtocol:  &TestProtocol{Ports: []int{80, 8080, 8080}},
+				mysqlProtocol: &TestProtocol{Ports: []int{3306}},
 			},
 			Output: map[uint16]protos.Protocol{
-				80:   protos.HttpProtocol,
-				8080: protos.HttpProtocol,
-				3306: protos.MysqlProtocol,
+				80:   httpProtocol,
+				8080: httpProtocol,
+				3306: mysqlProtocol,
 			},
 		},
 	}
@@ -162,9 +170,9 @@ func Test_buildPortsMap_portOverlapError(t *testing.T) {
 		{
 			// Should raise error on duplicate port
 			Input: map[protos.Protocol]protos.UdpProtocolPlugin{
-				protos.HttpProtocol:  &TestProtocol{Ports: []int{80, 8080}},
-				protos.MysqlProtocol: &TestProtocol{Ports: []int{3306}},
-				protos.RedisProtocol: &TestProtocol{Ports: []int{6379, 6380, 3306}},
+				httpProtocol:  &TestProtocol{Ports: []int{80, 8080}},
+				mysqlProtocol: &TestProtocol{Ports: []int{3306}},
+				redisProtocol: &TestProtocol{Ports: []int{6379, 6380, 3306}},
 			},
 			Err: "Duplicate port (3306) exists",
 		},
@@ -230,3 +238,69 @@ func TestProcess_nonEmptyPayload(t *testing.T) {
 	test.udp.Process(pkt)
 	assert.Equal(t, pkt, test.plugin.pkt)
 }
+
+// DetectProtocol is a TestProtocol claiming all packets starting with its
+// magic bytes.
+type DetectProtocol struct {
+	TestProtocol
+	magic []byte
+}
+
+func (proto *DetectProtocol) DetectUdp(data []byte) protos.DetectResult {
+	if bytes.HasPrefix(data, proto.magic) {
+		return protos.DetectMatch
+	}
+	return protos.DetectNeedMoreData
+}
+
+// Helper method for creating a Udp instance with protocol detection enabled.
+func detectSetup(t *testing.T) (*Udp, *DetectProtocol) {
+	protocols := &TestProtocols{}
+	plugin := &DetectProtocol{
+		TestProtocol: TestProtocol{Ports: []int{PORT}},
+		magic:        []byte("MAGIC"),
+	}
+	protocols.udp = map[protos.Protocol]protos.UdpProtocolPlugin{PROTO: plugin}
+
+	udp, err := NewUdp(protocols, protos.DetectionConfig{
+		Enabled:    true,
+		MaxPackets: 2,
+	})
+	if err != nil {
+		t.Error("Error creating UDP handler: ", err)
+	}
+	return udp, plugin
+}
+
+// Verify that packets on unknown ports are passed to the plugin claiming
+// the flow, including packets sent in the reverse direction.
+func TestProcess_detectProtocol(t *testing.T) {
+	udp, plugin := detectSetup(t)
+	tuple := common.NewIpPortTuple(4,
+		net.ParseIP("192.168.0.1"), PORT+1,
+		net.ParseIP("10.0.0.1"), 34898)
+
+	pkt := &protos.Packet{Ts: time.Now(), Tuple: tuple, Payload: []byte("MAGIC")}
+	udp.Process(pkt)
+	assert.Equal(t, pkt, plugin.pkt)
+
+	reverse := common.NewIpPortTuple(4,
+		net.ParseIP("10.0.0.1"), 34898,
+		net.ParseIP("192.168.0.1"), PORT+1)
+	pkt = &protos.Packet{Ts: time.Now(), Tuple: reverse, Payload: []byte("response")}
+	udp.Process(pkt)
+	assert.Equal(t, pkt, plugin.pkt)
+}
+
+// Verify that detection on a flow stops once the packet limit is reached.
+func TestProcess_detectProtocolPacketLimit(t *testing.T) {
+	udp, plugin := detectSetup(t)
+	tuple := common.NewIpPortTuple(4,
+		net.ParseIP("192.168.0.1"), PORT+1,
+		net.ParseIP("10.0.0.1"), 34898)
+
+	for _, payload := range []string{"first", "second", "MAGIC"} {
+		udp.Process(&protos.Packet{Ts: time.Now(), Tuple: tuple, Payload: []byte(payload)})
+	}
+	assert.Nil(t, plugin.pkt)
+}
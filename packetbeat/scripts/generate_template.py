@@ -42,6 +42,13 @@ def fields_to_es_template(input, output):
                 },
                 "properties": {},
                 "dynamic_templates": [{
+                    "geoip_location": {
+                        "path_match": "*_geoip.location",
+                        "mapping": {
+                            "type": "geo_point"
+                        }
+                    }
+                }, {
                     "template1": {
                         "match": "*",
                         "mapping": {
@@ -89,8 +96,9 @@ def fill_field_properties(properties, field, defaults):
         if key not in field:
             field[key] = defaults[key]
 
+    mapping = None
     if field.get("index") == "analyzed":
-        properties[field["name"]] = {
+        mapping = {
             "type": field["type"],
             "index": "analyzed",
             "norms": {
@@ -99,22 +107,31 @@ def fill_field_properties(properties, field, defaults):
         }
 
     elif field.get("type") == "geo_point":
-        properties[field["name"]] = {
+        mapping = {
             "type": "geo_point"
         }
 
     elif field.get("type") == "date":
-        properties[field["name"]] = {
+        mapping = {
             "type": "date"
         }
 
     elif field.get("ignore_above") == 0:
-        properties[field["name"]] = {
+        mapping = {
             "type": field["type"],
             "index": field["index"],
             "doc_values": field["doc_values"]
         }
 
+    if mapping is None:
+        return
+
+    # nested fields given in dotted notation are mapped as object properties
+    path = field["name"].split(".")
+    for name in path[:-1]:
+        properties = properties.setdefault(name, {"properties": {}})["properties"]
+    properties[path[-1]] = mapping
+
 if __name__ == "__main__":
     if len(sys.argv) != 3:
         print "Usage: %s fields.yml template.json" % sys.argv[0]
@@ -1,6 +1,7 @@
 package sniffer
 
 import (
+	"expvar"
 	"fmt"
 	"io"
 	"os"
@@ -22,6 +23,18 @@ import (
 	"github.com/tsg/gopacket/pcap"
 )
 
+// Metrics that can be retrieved through the expvar web interface.
+var (
+	packetsProcessed = expvar.NewInt("packetbeatSnifferPackets")
+)
+
+// statsSniffer is the sniffer reporting capture statistics, set by Init.
+var statsSniffer *SnifferSetup
+
+func init() {
+	expvar.Publish("packetbeatSnifferCapture", expvar.Func(captureStats))
+}
+
 type SnifferSetup struct {
 	pcapHandle     *pcap.Handle
 	afpacketHandle *AfpacketHandle
@@ -244,9 +257,16 @@ func (sniffer *SnifferSetup) Init(
 	udp udp.Processor,
 ) error {
 	if config.ConfigSingleton.Interfaces.Bpf_filter == "" {
+		icmpConfig, err := config.ConfigSingleton.Protocols.Icmp()
+		if err != nil {
+			return fmt.Errorf("Invalid icmp configuration: %v", err)
+		}
+
 		with_vlans := config.ConfigSingleton.Interfaces.With_vlans
-		with_icmp := config.ConfigSingleton.Protocols.Icmp.Enabled
-		config.ConfigSingleton.Interfaces.Bpf_filter = protos.Protos.BpfFilter(with_vlans, with_icmp)
+		with_icmp := icmpConfig.Enabled
+		with_detection := config.ConfigSingleton.ProtocolDetection.Enabled
+		config.ConfigSingleton.Interfaces.Bpf_filter = protos.Protos.BpfFilter(
+			with_vlans, with_icmp, with_detection)
 	}
 	logp.Debug("sniffer", "BPF filter: %s", config.ConfigSingleton.Interfaces.Bpf_filter)
 
@@ -275,10 +295,32 @@ func (sniffer *SnifferSetup) Init(
 	}
 
 	sniffer.isAlive = true
+	statsSniffer = sniffer
 
 	return nil
 }
 
+// captureStats returns the packet counters of the live capture, including
+// the packets dropped by the kernel. Only the pcap sniffer type reports
+// these counters.
+func captureStats() interface{} {
+	sniffer := statsSniffer
+	if sniffer == nil || sniffer.pcapHandle == nil || sniffer.config.File != "" {
+		return nil
+	}
+
+	stats, err := sniffer.pcapHandle.Stats()
+	if err != nil {
+		logp.Debug("sniffer", "Failed to read capture stats: %v", err)
+		return nil
+	}
+	return map[string]int{
+		"received":   stats.PacketsReceived,
+		"dropped":    stats.PacketsDropped,
+		"if_dropped": stats.PacketsIfDropped,
+	}
+}
+
 func (sniffer *SnifferSetup) Run() error {
 	counter := 0
 	loopCount := 1
@@ -347,6 +389,7 @@ func (sniffer *SnifferSetup) Run() error {
 			}
 		}
 		counter++
+		packetsProcessed.Add(1)
 
 		if sniffer.dumper != nil {
 			sniffer.dumper.WritePacketData(data, ci)
@@ -2,6 +2,7 @@ package beat
 
 import (
 	"errors"
+	"expvar"
 	"math"
 	"regexp"
 	"strconv"
@@ -16,6 +17,14 @@ import (
 	"github.com/elastic/beats/libbeat/publisher"
 )
 
+// Metrics that can be retrieved through the expvar web interface.
+var (
+	// duration of the last collection in milliseconds by kind of stats
+	collectDurations = expvar.NewMap("topbeatCollectDurationMs")
+	// number of collections taking longer than one period
+	collectOverruns = expvar.NewInt("topbeatCollectOverruns")
+)
+
 type ProcsMap map[int]*Process
 
 type Topbeat struct {
@@ -111,21 +120,21 @@ func (t *Topbeat) Run(b *beat.Beat) error {
 		timerStart := time.Now()
 
 		if t.sysStats {
-			err = t.exportSystemStats()
+			err = timeCollect("system", t.exportSystemStats)
 			if err != nil {
 				logp.Err("Error reading system stats: %v", err)
 				break
 			}
 		}
 		if t.procStats {
-			err = t.exportProcStats()
+			err = timeCollect("proc", t.exportProcStats)
 			if err != nil {
 				logp.Err("Error reading proc stats: %v", err)
 				break
 			}
 		}
 		if t.fsStats {
-			err = t.exportFileSystemStats()
+			err = timeCollect("filesystem", t.exportFileSystemStats)
 			if err != nil {
 				logp.Err("Error reading fs stats: %v", err)
 				break
@@ -134,7 +143,9 @@ func (t *Topbeat) Run(b *beat.Beat) error {
 
 		timerEnd := time.Now()
 		duration := timerEnd.Sub(timerStart)
+		setDuration("total", duration)
 		if duration.Nanoseconds() > t.period.Nanoseconds() {
+			collectOverruns.Add(1)
 			logp.Warn("Ignoring tick(s) due to processing taking longer than one period")
 		}
 	}
@@ -142,6 +153,20 @@ func (t *Topbeat) Run(b *beat.Beat) error {
 	return err
 }
 
+// timeCollect runs collect and records its duration under name.
+func timeCollect(name string, collect func() error) error {
+	start := time.Now()
+	err := collect()
+	setDuration(name, time.Since(start))
+	return err
+}
+
+func setDuration(name string, d time.Duration) {
+	ms := new(expvar.Int)
+	ms.Set(int64(d / time.Millisecond))
+	collectDurations.Set(name, ms)
+}
+
 func (tb *Topbeat) Cleanup(b *beat.Beat) error {
 	return nil
 }
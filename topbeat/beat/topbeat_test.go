@@ -1,6 +1,8 @@
 package beat
 
 import (
+	"errors"
+	"strconv"
 	"testing"
 	"time"
 
@@ -148,3 +150,16 @@ func TestProcCpuPercentage(t *testing.T) {
 	beat.addProcCpuPercentage(&p2)
 	assert.Equal(t, p2.Cpu.UserPercent, 3.46)
 }
+
+func TestTimeCollect(t *testing.T) {
+	collectErr := errors.New("collect failed")
+	err := timeCollect("timeTest", func() error {
+		time.Sleep(2 * time.Millisecond)
+		return collectErr
+	})
+	assert.Equal(t, collectErr, err)
+
+	ms, err := strconv.Atoi(collectDurations.Get("timeTest").String())
+	assert.NoError(t, err)
+	assert.True(t, ms >= 2)
+}
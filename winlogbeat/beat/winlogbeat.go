@@ -3,8 +3,6 @@ package beat
 import (
 	"expvar"
 	"fmt"
-	"net"
-	"net/http"
 	"path/filepath"
 	"sync"
 	"time"
@@ -33,13 +31,6 @@ var (
 	memstatsf = logp.MakeDebug("memstats")
 )
 
-// Time the application was started.
-var startTime = time.Now().UTC()
-
-func init() {
-	expvar.Publish("uptime", expvar.Func(uptime))
-}
-
 type Winlogbeat struct {
 	beat       *beat.Beat                 // Common beat information.
 	config     *config.ConfigSettings     // Configuration settings.
@@ -55,17 +46,11 @@ func New() *Winlogbeat {
 }
 
 func (eb *Winlogbeat) Config(b *beat.Beat) error {
-	// Read configuration.
+	// Read and validate configuration.
 	err := cfgfile.Read(&eb.config, "")
 	if err != nil {
 		return fmt.Errorf("Error reading configuration file. %v", err)
 	}
-
-	// Validate configuration.
-	err = eb.config.Winlogbeat.Validate()
-	if err != nil {
-		return fmt.Errorf("Error validating configuration file. %v", err)
-	}
 	debugf("Configuration validated. config=%v", eb.config)
 
 	// Registry file grooming.
@@ -95,20 +80,13 @@ func (eb *Winlogbeat) Setup(b *beat.Beat) error {
 		return err
 	}
 
-	if eb.config.Winlogbeat.Metrics.BindAddress != "" {
-		bindAddress := eb.config.Winlogbeat.Metrics.BindAddress
-		sock, err := net.Listen("tcp", bindAddress)
-		if err != nil {
-			return err
+	// The metrics are served by the monitoring endpoint of libbeat, started
+	// after Setup.
+	if bindAddress := eb.config.Winlogbeat.Metrics.BindAddress; bindAddress != "" {
+		logp.Warn("winlogbeat.metrics is deprecated, use monitoring.bind_address instead")
+		if !b.Config.Monitoring.Enabled() {
+			b.Config.Monitoring.BindAddress = bindAddress
 		}
-		go func() {
-			logp.Info("Metrics hosted at http://%s/debug/vars", bindAddress)
-			err := http.Serve(sock, nil)
-			if err != nil {
-				logp.Warn("Unable to launch HTTP service for metrics. %v", err)
-				return
-			}
-		}()
 	}
 
 	return nil
@@ -238,8 +216,11 @@ loop:
 			publishedEvents.Add(api.Name(), numEvents)
 			logp.Info("EventLog[%s] Successfully published %d events", api.Name(), numEvents)
 		} else {
+			// Sync publishing only fails if the publisher has been stopped
+			// on shutdown. The events are read again on restart.
 			logp.Warn("EventLog[%s] Failed to publish %d events", api.Name(), numEvents)
 			publishedEvents.Add("failures", 1)
+			break loop
 		}
 
 		eb.checkpoint.Persist(api.Name(),
@@ -247,16 +228,3 @@ loop:
 			records[len(records)-1].TimeGenerated.UTC())
 	}
 }
-
-// uptime returns a map of uptime related metrics.
-func uptime() interface{} {
-	now := time.Now().UTC()
-	uptimeDur := now.Sub(startTime)
-
-	return map[string]interface{}{
-		"start_time":  startTime,
-		"uptime":      uptimeDur.String(),
-		"uptime_ms":   fmt.Sprintf("%d", uptimeDur.Nanoseconds()/int64(time.Microsecond)),
-		"server_time": now,
-	}
-}
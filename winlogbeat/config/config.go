@@ -3,10 +3,9 @@ package config
 
 import (
 	"fmt"
-	"net"
-	"strconv"
 	"time"
 
+	"github.com/elastic/beats/libbeat/monitoring"
 	"github.com/joeshaw/multierror"
 )
 
@@ -30,7 +29,8 @@ type WinlogbeatConfig struct {
 }
 
 // Validates the WinlogbeatConfig data and returns an error describing all
-// problems or nil if there are none.
+// problems or nil if there are none. The event logs and the metrics settings
+// are validated by their own Validate methods when reading the configuration.
 func (ebc WinlogbeatConfig) Validate() error {
 	var errs multierror.Errors
 	if _, err := IgnoreOlderDuration(ebc.IgnoreOlder); err != nil {
@@ -43,19 +43,11 @@ func (ebc WinlogbeatConfig) Validate() error {
 			"configured as part of event_logs"))
 	}
 
-	for _, eventLog := range ebc.EventLogs {
-		if err := eventLog.Validate(); err != nil {
-			errs = append(errs, err)
-		}
-	}
-
-	if err := ebc.Metrics.Validate(); err != nil {
-		errs = append(errs, err)
-	}
-
 	return errs.Err()
 }
 
+// MetricsConfig is deprecated in favor of the monitoring section common to
+// all beats.
 type MetricsConfig struct {
 	BindAddress string // Bind address for the metric service. Format is host:port.
 }
@@ -63,33 +55,7 @@ type MetricsConfig struct {
 // Validates the MetricsConfig data and returns an error describing any
 // problems or nil.
 func (mc MetricsConfig) Validate() error {
-	if mc.BindAddress == "" {
-		return nil
-	}
-
-	host, portStr, err := net.SplitHostPort(mc.BindAddress)
-	if err != nil {
-		return fmt.Errorf("bind_address must be formatted as host:port but "+
-			"was '%s' (%v)", mc.BindAddress, err)
-	}
-
-	if len(host) == 0 {
-		return fmt.Errorf("bind_address value ('%s') is missing a host",
-			mc.BindAddress)
-	}
-
-	port, err := strconv.Atoi(portStr)
-	if err != nil {
-		return fmt.Errorf("bind_address port value ('%s') must be a number "+
-			"(%v)", portStr, err)
-	}
-
-	if port < 1 || port > 65535 {
-		return fmt.Errorf("bind_address port must be within [1-65535] but "+
-			"was '%d'", port)
-	}
-
-	return nil
+	return monitoring.Config{BindAddress: mc.BindAddress}.Validate()
 }
 
 type EventLogConfig struct {
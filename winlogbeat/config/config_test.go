@@ -1,8 +1,13 @@
 package config
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/elastic/beats/libbeat/cfgfile"
+	"github.com/joeshaw/multierror"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -48,7 +53,7 @@ func TestConfigValidate(t *testing.T) {
 				},
 				Metrics: MetricsConfig{BindAddress: "example.com"},
 			},
-			"1 error: bind_address",
+			"", // validated by MetricsConfig
 		},
 		{
 			WinlogbeatConfig{
@@ -56,7 +61,7 @@ func TestConfigValidate(t *testing.T) {
 					{},
 				},
 			},
-			"1 error: event log is missing a 'name'",
+			"", // validated by EventLogConfig
 		},
 		// MetricsConfig
 		{
@@ -109,3 +114,38 @@ func TestConfigValidate(t *testing.T) {
 		test.run(t)
 	}
 }
+
+func TestReadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "winlogbeat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "winlogbeat.yml")
+	content := []byte(`
+winlogbeat:
+  event_logs:
+    - name: Application
+    - ignore_older: 1h
+  metrics:
+    bindaddress: nohostport
+`)
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the beat reads into a nil pointer to the configuration
+	var config *ConfigSettings
+	err = cfgfile.Read(&config, path)
+	if !assert.IsType(t, &multierror.MultiError{}, err) {
+		return
+	}
+
+	// each problem is reported once at the position of the setting
+	errs := err.(*multierror.MultiError).Errors
+	if assert.Len(t, errs, 2) {
+		assert.Contains(t, errs[0].Error(), "winlogbeat.yml:5: winlogbeat.event_logs.1: event log is missing a 'name'")
+		assert.Contains(t, errs[1].Error(), "winlogbeat.yml:6: winlogbeat.metrics: bind_address must be formatted as host:port")
+	}
+}